@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"polling-system/internal/config"
+	"polling-system/internal/domain/deadletter"
+	"polling-system/internal/platform/database"
+)
+
+const deadLettersUsage = "usage: server deadletters list [limit]|inspect <id>|replay <id>|all|discard <id> [flags]"
+
+// runDeadLetters implements "server deadletters", the command line
+// counterpart of the /api/v1/admin/dead-letters endpoints.
+func runDeadLetters(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, deadLettersUsage)
+		return 2
+	}
+	cmd, rest := args[0], args[1:]
+
+	var (
+		id       int64
+		limit    = deadletter.DefaultListLimit
+		all      bool
+		parseErr error
+	)
+	switch cmd {
+	case "list":
+		if len(rest) > 0 {
+			if n, err := strconv.Atoi(rest[0]); err == nil {
+				limit, rest = n, rest[1:]
+			}
+		}
+	case "inspect", "replay", "discard":
+		if len(rest) == 0 {
+			fmt.Fprintln(os.Stderr, deadLettersUsage)
+			return 2
+		}
+		if cmd == "replay" && rest[0] == "all" {
+			all = true
+		} else {
+			id, parseErr = strconv.ParseInt(rest[0], 10, 64)
+		}
+		rest = rest[1:]
+	default:
+		fmt.Fprintln(os.Stderr, deadLettersUsage)
+		return 2
+	}
+	if parseErr != nil || (cmd != "list" && !all && id <= 0) {
+		fmt.Fprintln(os.Stderr, cmd+": invalid dead letter id")
+		return 2
+	}
+
+	cfg, err := config.Load(rest)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		return 1
+	}
+	db, dialect, err := database.Open(cfg.DB)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "db connect error:", err)
+		return 1
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	_, _, voteRepo := newRepositories(db, dialect)
+	svc := newDeadLetterService(db, dialect, voteRepo, database.NewTxManager(db), cfg.Worker.DeadLetterAlertThreshold, logger)
+
+	ctx := context.Background()
+	switch cmd {
+	case "list":
+		entries, total, err := svc.List(ctx, limit, 0)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "list dead letters:", err)
+			return 1
+		}
+		for _, e := range entries {
+			fmt.Printf("%6d  poll=%d option=%d user=%d attempts=%d  %s  %s\n",
+				e.ID, e.PollID, e.OptionID, e.UserID, e.Attempts, e.LastFailedAt.Format(time.RFC3339), e.Error)
+		}
+		fmt.Printf("%d of %d dead letter(s)\n", len(entries), total)
+	case "inspect":
+		e, err := svc.Get(ctx, id)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "inspect:", err)
+			return 1
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(e)
+	case "replay":
+		if all {
+			replayed, failed, err := svc.ReplayAll(ctx)
+			fmt.Printf("replayed %d, failed %d\n", replayed, failed)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "replay:", err)
+				return 1
+			}
+			if failed > 0 {
+				return 1
+			}
+			break
+		}
+		if err := svc.Replay(ctx, id); err != nil {
+			fmt.Fprintln(os.Stderr, "replay:", err)
+			return 1
+		}
+		fmt.Printf("replayed dead letter %d\n", id)
+	case "discard":
+		if err := svc.Discard(ctx, id); err != nil {
+			fmt.Fprintln(os.Stderr, "discard:", err)
+			return 1
+		}
+		fmt.Printf("discarded dead letter %d\n", id)
+	}
+	return 0
+}
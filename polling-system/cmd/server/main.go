@@ -2,23 +2,42 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/redis/go-redis/v9"
+
 	_ "polling-system/docs"
+	"polling-system/internal/cache"
 	"polling-system/internal/config"
+	"polling-system/internal/db/migrations"
+	"polling-system/internal/domain/audit"
+	"polling-system/internal/domain/dashboard"
+	"polling-system/internal/domain/deadletter"
 	"polling-system/internal/domain/poll"
 	"polling-system/internal/domain/user"
 	"polling-system/internal/domain/vote"
+	"polling-system/internal/health"
 	api "polling-system/internal/http"
+	"polling-system/internal/lifecycle"
 	"polling-system/internal/metrics"
 	"polling-system/internal/platform/database"
 	jwtpkg "polling-system/internal/platform/jwt"
+	"polling-system/internal/platform/logging"
+	"polling-system/internal/platform/mail"
+	"polling-system/internal/platform/tracing"
+	"polling-system/internal/ratelimit"
 	"polling-system/internal/repository/postgres"
+	"polling-system/internal/repository/sqlite"
+	"polling-system/internal/retry"
 	"polling-system/internal/worker"
 )
 
@@ -30,54 +49,206 @@ import (
 // @in              header
 // @name            Authorization
 func main() {
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "config":
+			os.Exit(runConfig(args[1:]))
+		case "migrate":
+			os.Exit(runMigrate(args[1:]))
+		case "deadletters":
+			os.Exit(runDeadLetters(args[1:]))
+		}
+	}
+
+	logLevel := new(slog.LevelVar)
+	logger := slog.New(logging.NewHandler(tracing.NewLogHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}))))
 	slog.SetDefault(logger)
-	api.SetLogger(logger)
 
-	cfg := config.Load()
+	cfg, err := config.Load(args)
+	if err != nil {
+		logger.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	logLevel.Set(cfg.Log.SlogLevel())
 	metrics.Register()
 
-	db, err := database.NewPostgres(cfg.DB_DSN)
+	// Spans are written to stderr so that they do not interleave with the
+	// JSON logs on stdout.
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, os.Stderr)
+	if err != nil {
+		logger.Error("tracing setup failed", "error", err)
+		os.Exit(1)
+	}
+
+	db, dialect, err := database.Open(cfg.DB)
 	if err != nil {
 		logger.Error("db connect error", "error", err)
 		os.Exit(1)
 	}
 	defer db.Close()
 
-	userRepo := postgres.NewUserRepo(db)
-	pollRepo := postgres.NewPollRepo(db)
-	voteRepo := postgres.NewVoteRepo(db)
+	if err := checkSchema(db, dialect, cfg.DB.MigrateOnStart, logger); err != nil {
+		logger.Error("database schema check failed", "error", err)
+		os.Exit(1)
+	}
 
-	userSvc := user.NewService(userRepo)
-	pollSvc := poll.NewService(pollRepo)
-	voteSvc := vote.NewService(voteRepo)
+	userRepo, pollRepo, voteRepo := newRepositories(db, dialect)
+	logger.Info("using database", "dialect", dialect)
 
-	jwtMgr := jwtpkg.NewManager(cfg.JWTSecret, cfg.JWTIssuer)
+	passwordPolicy := user.PasswordPolicy(cfg.Auth.Password)
+	userSvc := user.NewService(userRepo, passwordPolicy)
+	var redisClient *redis.Client
+	if cfg.Redis.URL != "" {
+		redisOpts, err := redis.ParseURL(cfg.Redis.URL)
+		if err != nil {
+			logger.Error("invalid redis url", "error", err)
+			os.Exit(1)
+		}
+		redisClient = redis.NewClient(redisOpts)
+		defer redisClient.Close()
+	}
 
-	voteCh := make(chan worker.VoteEvent, 100)
-	statsWorker := worker.NewStatsWorker(voteCh, voteRepo, logger)
+	var (
+		resultsCache vote.ResultsCache = cache.NewInstrumentedResultsCache(vote.NewMemoryCache(), "memory")
+		redisCache   *cache.RedisResultsCache
+	)
+	if redisClient != nil {
+		redisCache = cache.NewRedisResultsCache(redisClient, logger)
+		listenCtx, stopListen := context.WithCancel(context.Background())
+		defer stopListen()
+		redisCache.Listen(listenCtx)
+		resultsCache = cache.NewInstrumentedResultsCache(redisCache, "redis")
+		logger.Info("using redis results cache")
+	}
+	txMgr := database.NewTxManager(db)
+	mailer, err := newMailer(cfg.Mail)
+	if err != nil {
+		logger.Error("mailer", "error", err)
+		os.Exit(1)
+	}
+	accountSvc := user.NewAccountService(userRepo, newTokenRepo(db, dialect), txMgr, mailer, user.AccountOptions{
+		Policy:           passwordPolicy,
+		VerifyEmailTTL:   cfg.Auth.VerifyEmailTTL,
+		ResetPasswordTTL: cfg.Auth.ResetPasswordTTL,
+		VerifyEmailURL:   cfg.Auth.VerifyEmailURL,
+		ResetPasswordURL: cfg.Auth.ResetPasswordURL,
+	})
+	// Roles are defined by the user package, so config.Load cannot check
+	// them.
+	for _, role := range cfg.Auth.TwoFactor.RequiredRoles {
+		if err := user.ValidateRole(role); err != nil {
+			logger.Error("invalid configuration", "error", fmt.Errorf("auth.two_factor.required_roles: %w", err))
+			os.Exit(1)
+		}
+	}
+	twoFactorSvc := user.NewTwoFactorService(userRepo, newTwoFactorRepo(db, dialect), txMgr, cfg.Auth.TwoFactor.Issuer, cfg.Auth.TwoFactor.RequiredRoles)
+	voteSvc := vote.NewServiceWithCache(voteRepo, txMgr, resultsCache, cfg.Cache.ResultsTTL)
+	// Cached results carry the poll status and, once closed, the winner.
+	pollSvc := poll.NewServiceWithHooks(pollRepo, poll.Hooks{OnChange: voteSvc.InvalidateResults})
+	deadLetterSvc := newDeadLetterService(db, dialect, voteRepo, txMgr, cfg.Worker.DeadLetterAlertThreshold, logger)
+	if n, err := deadLetterSvc.RefreshSize(context.Background()); err != nil {
+		logger.Error("count dead letters", "error", err)
+	} else if n > 0 {
+		logger.Warn("dead-letter queue is not empty", "size", n)
+	}
+
+	jwtMgr := jwtpkg.NewManager(cfg.Auth.JWTSecret, cfg.Auth.JWTIssuer)
 
-	router := api.NewRouter(userSvc, pollSvc, voteSvc, jwtMgr, voteCh, db)
+	voteCh := make(chan worker.VoteEvent, cfg.Worker.QueueSize)
+	metrics.RegisterDBStats(db, dialect)
+	metrics.RegisterVoteQueue(func() int { return len(voteCh) }, cap(voteCh))
+	metrics.RegisterPollsByStatus(pollSvc.CountByStatus)
+	statsWorker := worker.NewStatsWorker(voteCh, voteRepo, cfg.Worker.StatsWorkers, cfg.Worker.BatchWindow, cfg.Worker.BatchSize, aggregationRetryPolicy(cfg.Worker.Retry, logger), deadLetterSvc, logger)
+	rollupWorker := worker.NewRollupWorker(voteRepo, cfg.Worker.RollupInterval, logger)
+	dashboardSvc := dashboard.NewService(newDashboardRepo(db, dialect), pollSvc, deadLetterSvc, statsWorker, dashboard.DefaultCacheTTL)
+
+	// Both were validated by config.Load.
+	policies, _ := ratelimit.ParsePolicies(cfg.RateLimit.Policies)
+	ipResolver, _ := ratelimit.NewIPResolver(cfg.RateLimit.TrustedProxies)
+	memoryStore := ratelimit.NewMemoryStore()
+	var (
+		limitStore   ratelimit.Store        = memoryStore
+		failureStore ratelimit.FailureStore = memoryStore
+	)
+	if redisClient != nil {
+		redisStore := ratelimit.NewRedisStore(redisClient)
+		limitStore, failureStore = redisStore, redisStore
+	}
+	limiter := ratelimit.NewLimiter(limitStore, policies, ipResolver, logger)
+	loginGuard := ratelimit.NewLoginGuard(failureStore, ratelimit.LockoutPolicy(cfg.Auth.Lockout.Account), ratelimit.LockoutPolicy(cfg.Auth.Lockout.IP), logger)
+	auditSvc := audit.NewService(newAuditRepo(db, dialect), logger)
+	cors := api.NewCORSPolicy(cfg.CORS.AllowedOrigins)
+
+	lc := lifecycle.New(logger)
+	checks, err := newHealthChecks(cfg.Health, db, dialect, redisCache, lc, statsWorker, logger)
+	if err != nil {
+		logger.Error("health checks", "error", err)
+		os.Exit(1)
+	}
+	router := api.NewRouter(userSvc, accountSvc, twoFactorSvc, pollSvc, voteSvc, deadLetterSvc, dashboardSvc, auditSvc, jwtMgr, voteCh, checks, limiter, loginGuard, cors, cfg.Auth.TokenTTL, cfg.Auth.TwoFactor.ChallengeTTL, cfg.HTTP.MaxBodyBytes)
+
+	// Settings that are safe to change while serving; the reloader only
+	// calls this with a validated configuration.
+	reloader := config.NewReloader(cfg, args, config.DefaultWatchInterval, func(c config.Config) {
+		logLevel.Set(c.Log.SlogLevel())
+		policies, _ := ratelimit.ParsePolicies(c.RateLimit.Policies)
+		ipResolver, _ := ratelimit.NewIPResolver(c.RateLimit.TrustedProxies)
+		limiter.Update(policies, ipResolver)
+		voteSvc.SetCacheTTL(c.Cache.ResultsTTL)
+		cors.SetOrigins(c.CORS.AllowedOrigins)
+	}, logger)
 
 	srv := &http.Server{
-		Addr:    ":" + cfg.Port,
+		Addr:    ":" + cfg.HTTP.Port,
 		Handler: router,
 	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	workerCtx, workerCancel := context.WithCancel(context.Background())
-	defer workerCancel()
-	workerDone := make(chan struct{})
-
-	go func() {
-		statsWorker.Run(workerCtx)
-		close(workerDone)
-	}()
+	// Shutdown steps run in this order: stop taking requests, so that no
+	// new vote events are produced, then drain the vote queue, then stop
+	// the remaining background jobs.
+	var httpStopped atomic.Bool
+	lc.OnShutdown("http server", func(ctx context.Context) error {
+		if delay := cfg.HTTP.ShutdownDelay; delay > 0 {
+			// Give load balancers time to notice that /ready fails.
+			logger.Info("waiting before closing listeners", "delay", delay.String())
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+			}
+		}
+		err := srv.Shutdown(ctx)
+		httpStopped.Store(err == nil)
+		return err
+	})
+	// Password reset emails are sent after their request was answered.
+	lc.OnShutdown("password reset mail", accountSvc.Wait)
+	// Handlers still running after a failed server shutdown may send on
+	// voteCh, so it is only closed once they have all returned; otherwise
+	// the workers are cancelled at the shutdown deadline.
+	lc.Go("stats worker", statsWorker.Run, func() {
+		if httpStopped.Load() {
+			close(voteCh)
+		}
+	})
+	lc.OnShutdown("vote queue", func(context.Context) error {
+		// The workers have returned, so whatever is still buffered will
+		// never be aggregated.
+		if n := len(voteCh); n > 0 {
+			return fmt.Errorf("%d vote event(s) undelivered", n)
+		}
+		return nil
+	})
+	lc.Go("rollup worker", rollupWorker.Run, nil)
+	lc.Go("config reloader", reloader.Run, nil)
+	// Last, so that spans ended by the steps above are still exported.
+	lc.OnShutdown("tracing", shutdownTracing)
 
 	go func() {
-		logger.Info("server listening", "port", cfg.Port)
+		logger.Info("server listening", "port", cfg.HTTP.Port, "env", cfg.Env)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Error("listen error", "error", err)
 			os.Exit(1)
@@ -87,22 +258,171 @@ func main() {
 	<-ctx.Done()
 	logger.Info("shutting down...", "signal", ctx.Err())
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownDelay+cfg.HTTP.ShutdownTimeout)
 	defer shutdownCancel()
 
-	if err := srv.Shutdown(shutdownCtx); err != nil {
-		logger.Error("server shutdown error", "error", err)
+	if err := lc.Shutdown(shutdownCtx); err != nil {
+		logger.Error("shutdown incomplete", "error", err)
 		os.Exit(1)
 	}
+	logger.Info("server stopped")
+}
+
+// checkSchema optionally migrates the database and then refuses to start
+// unless the schema is at the version this binary was built for.
+func checkSchema(db *sql.DB, dialect string, migrate bool, logger *slog.Logger) error {
+	m, err := migrations.New(db, dialect, logger)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if migrate {
+		n, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Info("migrations applied", "count", n, "version", m.Latest())
+	}
+	return m.Check(ctx)
+}
+
+// newHealthChecks registers the probes. Liveness only covers the stats
+// workers, which a restart can unstick; everything the server depends on to
+// answer requests is a readiness check.
+func newHealthChecks(cfg config.HealthConfig, db *sql.DB, dialect string, redisCache *cache.RedisResultsCache, lc *lifecycle.Manager, workers *worker.StatsWorker, logger *slog.Logger) (*health.Registry, error) {
+	m, err := migrations.New(db, dialect, logger)
+	if err != nil {
+		return nil, err
+	}
+	r := health.NewRegistry()
+	r.AddLiveness("stats_workers", cfg.CheckTimeout, health.Heartbeat(workers.Heartbeat, cfg.WorkerStaleAfter))
 
-	close(voteCh)
-	workerCancel()
+	r.AddReadiness("lifecycle", cfg.CheckTimeout, func(context.Context) (map[string]any, error) {
+		if lc.ShuttingDown() {
+			return nil, errors.New("shutting down")
+		}
+		return nil, nil
+	})
+	r.AddReadiness("database", cfg.CheckTimeout, health.DB(db))
+	r.AddReadiness("migrations", cfg.CheckTimeout, func(ctx context.Context) (map[string]any, error) {
+		version, dirty, err := m.AppliedVersion(ctx)
+		if err != nil {
+			return nil, err
+		}
+		details := map[string]any{"version": version, "expected": m.Latest(), "dirty": dirty}
+		if dirty || version != m.Latest() {
+			return details, errors.New("schema does not match this build")
+		}
+		return details, nil
+	})
+	r.AddReadiness("vote_queue", cfg.CheckTimeout, health.Queue(workers.QueueDepth, workers.QueueCapacity(), cfg.QueueSaturation))
+	r.AddReadiness("results_cache", cfg.CheckTimeout, func(ctx context.Context) (map[string]any, error) {
+		if redisCache == nil {
+			return map[string]any{"backend": "memory"}, nil
+		}
+		// Results are served from the database while Redis is down, and
+		// without local copies while invalidations are not received.
+		if err := redisCache.Check(ctx); err != nil {
+			return map[string]any{"backend": "redis"}, health.Degraded(err)
+		}
+		return map[string]any{"backend": "redis"}, nil
+	})
+	return r, nil
+}
+
+// voteStore is what the server needs from the vote repository: the domain
+// queries plus the rollup refresh done by the background worker.
+type voteStore interface {
+	vote.Repository
+	worker.Rollup
+}
 
-	select {
-	case <-workerDone:
-	case <-shutdownCtx.Done():
-		logger.Warn("worker shutdown timed out")
+func newRepositories(db *sql.DB, dialect string) (user.Repository, poll.Repository, voteStore) {
+	if dialect == database.SQLite {
+		return sqlite.NewUserRepo(db), sqlite.NewPollRepo(db), sqlite.NewVoteRepo(db)
 	}
+	return postgres.NewUserRepo(db), postgres.NewPollRepo(db), postgres.NewVoteRepo(db)
+}
 
-	logger.Info("server stopped")
+func newTokenRepo(db *sql.DB, dialect string) user.TokenRepository {
+	if dialect == database.SQLite {
+		return sqlite.NewTokenRepo(db)
+	}
+	return postgres.NewTokenRepo(db)
+}
+
+func newTwoFactorRepo(db *sql.DB, dialect string) user.TwoFactorRepository {
+	if dialect == database.SQLite {
+		return sqlite.NewTwoFactorRepo(db)
+	}
+	return postgres.NewTwoFactorRepo(db)
+}
+
+// newMailer returns the configured mailer. The file driver keeps every
+// message in a local directory instead of sending it.
+func newMailer(cfg config.MailConfig) (mail.Mailer, error) {
+	if cfg.Driver == config.MailSMTP {
+		return mail.NewSMTP(cfg.SMTPAddr, cfg.From, cfg.SMTPUsername, cfg.SMTPPassword), nil
+	}
+	return mail.NewFileOutbox(cfg.OutboxDir, cfg.From)
+}
+
+func newAuditRepo(db *sql.DB, dialect string) audit.Repository {
+	if dialect == database.SQLite {
+		return sqlite.NewAuditRepo(db)
+	}
+	return postgres.NewAuditRepo(db)
+}
+
+func newDashboardRepo(db *sql.DB, dialect string) dashboard.Repository {
+	if dialect == database.SQLite {
+		return sqlite.NewDashboardRepo(db)
+	}
+	return postgres.NewDashboardRepo(db)
+}
+
+// newDeadLetterService builds the dead-letter queue for failed vote
+// aggregations. Its size is exported as a metric, and reaching threshold logs
+// an error for log-based alerting.
+func newDeadLetterService(db *sql.DB, dialect string, agg deadletter.Aggregator, tx deadletter.TxManager, threshold int, logger *slog.Logger) *deadletter.Service {
+	var repo deadletter.Repository = postgres.NewDeadLetterRepo(db)
+	if dialect == database.SQLite {
+		repo = sqlite.NewDeadLetterRepo(db)
+	}
+	return deadletter.NewService(repo, agg, tx, int64(threshold), deadletter.Hooks{
+		OnSize: metrics.SetDeadLetterQueueSize,
+		OnAlert: func(size int64) {
+			logger.Error("dead-letter queue reached alert threshold", "size", size, "threshold", threshold)
+		},
+	})
+}
+
+// aggregationRetryPolicy retries transient database errors while aggregating
+// votes. A shared breaker stops the workers from hammering a database that is
+// down; its state and every retry are logged and exported as metrics.
+func aggregationRetryPolicy(cfg config.RetryConfig, logger *slog.Logger) retry.Policy {
+	const operation = "aggregate_vote"
+	jitter, _ := retry.ParseJitter(cfg.Jitter) // validated by config.Load
+	breaker := retry.NewBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown, func(from, to retry.State) {
+		metrics.SetCircuitBreakerState(operation, int(to))
+		logger.Warn("circuit breaker state changed", "breaker", operation, "from", from.String(), "to", to.String())
+	})
+	return retry.Policy{
+		MaxAttempts: cfg.MaxAttempts,
+		BaseDelay:   cfg.BaseDelay,
+		MaxDelay:    cfg.MaxDelay,
+		Jitter:      jitter,
+		Budget:      cfg.Budget,
+		Retryable:   retry.IsTransient,
+		Breaker:     breaker,
+		Hooks: retry.Hooks{
+			OnRetry: func(attempt int, err error, delay time.Duration) {
+				metrics.ObserveRetry(operation, false)
+				logger.Debug("retrying", "operation", operation, "attempt", attempt, "delay", delay, "error", err)
+			},
+			OnGiveUp: func(attempts int, err error) {
+				metrics.ObserveRetry(operation, true)
+			},
+		},
+	}
 }
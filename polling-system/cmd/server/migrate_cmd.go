@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"polling-system/internal/config"
+	"polling-system/internal/db/migrations"
+	"polling-system/internal/platform/database"
+)
+
+const migrateUsage = "usage: server migrate up|down [n]|status|version [flags]"
+
+// runMigrate implements "server migrate up|down [n]|status|version". down
+// reverts one migration unless a step count is given.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	cmd, rest := args[0], args[1:]
+
+	steps := 1
+	if cmd == "down" && len(rest) > 0 {
+		if n, err := strconv.Atoi(rest[0]); err == nil {
+			if n <= 0 {
+				fmt.Fprintln(os.Stderr, "down: step count must be positive")
+				return 2
+			}
+			steps, rest = n, rest[1:]
+		}
+	}
+
+	switch cmd {
+	case "up", "down", "status", "version":
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	cfg, err := config.Load(rest)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		return 1
+	}
+	db, dialect, err := database.Open(cfg.DB)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "db connect error:", err)
+		return 1
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	m, err := migrations.New(db, dialect, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load migrations:", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	switch cmd {
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate up:", err)
+			return 1
+		}
+		fmt.Printf("applied %d migration(s), schema at version %d\n", n, m.Latest())
+	case "down":
+		n, err := m.Down(ctx, steps)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate down:", err)
+			return 1
+		}
+		fmt.Printf("reverted %d migration(s)\n", n)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate status:", err)
+			return 1
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%4d  %-24s %s\n", s.Version, s.Name, state)
+		}
+	case "version":
+		version, dirty, err := m.Version(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate version:", err)
+			return 1
+		}
+		suffix := ""
+		if dirty {
+			suffix = " (dirty)"
+		}
+		fmt.Printf("%d%s, binary expects %d\n", version, suffix, m.Latest())
+	}
+	return 0
+}
@@ -15,141 +15,123 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/auth/login": {
-            "post": {
-                "consumes": [
-                    "application/json"
+        "/api/v1/admin/audit-log": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Admin only. Security events such as failed logins, lockouts, unlocks and two-factor changes, newest first.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "Login user",
+                "summary": "List audit log",
                 "parameters": [
                     {
-                        "description": "User credentials",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.authRequest"
-                        }
+                        "enum": [
+                            "login_succeeded",
+                            "login_failed",
+                            "login_locked",
+                            "account_unlocked",
+                            "two_factor_enabled",
+                            "two_factor_disabled",
+                            "two_factor_failed",
+                            "two_factor_reset",
+                            "recovery_code_used",
+                            "recovery_codes_regenerated"
+                        ],
+                        "type": "string",
+                        "description": "Only this action",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only events about this user",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Page size (max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Events to skip",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.authResponse"
+                            "$ref": "#/definitions/api.auditLogResponse"
                         }
                     },
                     "400": {
-                        "description": "invalid body",
+                        "description": "invalid filter, limit or offset",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "401": {
-                        "description": "invalid credentials",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
-                    },
-                    "500": {
-                        "description": "server error",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
-                    }
-                }
-            }
-        },
-        "/api/v1/auth/register": {
-            "post": {
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "auth"
-                ],
-                "summary": "Register a new user",
-                "parameters": [
-                    {
-                        "description": "User credentials",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.authRequest"
-                        }
-                    }
-                ],
-                "responses": {
-                    "201": {
-                        "description": "Created",
+                        "description": "unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/api.authResponse"
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
-                    "400": {
-                        "description": "invalid body or email taken",
+                    "403": {
+                        "description": "forbidden",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "500": {
                         "description": "server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     }
                 }
             }
         },
-        "/api/v1/polls": {
+        "/api/v1/admin/dead-letters": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
+                "description": "Admin only. Vote events whose aggregation failed after all retries, oldest first.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "polls"
+                    "dead-letters"
                 ],
-                "summary": "List polls",
+                "summary": "List dead-lettered vote events",
                 "parameters": [
                     {
-                        "enum": [
-                            "draft",
-                            "active",
-                            "closed"
-                        ],
-                        "type": "string",
-                        "description": "Filter by status",
-                        "name": "status",
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Page size (max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Entries to skip",
+                        "name": "offset",
                         "in": "query"
                     }
                 ],
@@ -157,110 +139,80 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/poll.Poll"
-                            }
+                            "$ref": "#/definitions/api.deadLetterListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid limit or offset",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "401": {
                         "description": "unauthorized",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "500": {
                         "description": "server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/api/v1/admin/dead-letters/replay": {
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Admin only",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Admin only. Replays each entry once, oldest first; entries that fail again stay in the queue.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "polls"
-                ],
-                "summary": "Create poll",
-                "parameters": [
-                    {
-                        "description": "Poll payload",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.createPollRequest"
-                        }
-                    }
+                    "dead-letters"
                 ],
+                "summary": "Replay every dead-lettered vote event",
                 "responses": {
-                    "201": {
-                        "description": "Created",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "integer"
-                            }
-                        }
-                    },
-                    "400": {
-                        "description": "invalid body",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.replayAllResponse"
                         }
                     },
                     "401": {
                         "description": "unauthorized",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "403": {
                         "description": "forbidden",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "500": {
                         "description": "server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     }
                 }
             }
         },
-        "/api/v1/polls/{id}": {
+        "/api/v1/admin/dead-letters/{id}": {
             "get": {
                 "security": [
                     {
@@ -271,13 +223,13 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "polls"
+                    "dead-letters"
                 ],
-                "summary": "Get poll with options",
+                "summary": "Inspect a dead-lettered vote event",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Poll ID",
+                        "description": "Dead letter ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -287,43 +239,37 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.pollDetailsResponse"
+                            "$ref": "#/definitions/deadletter.Entry"
                         }
                     },
                     "400": {
                         "description": "invalid id",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "401": {
                         "description": "unauthorized",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "404": {
                         "description": "not found",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "500": {
                         "description": "server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     }
                 }
@@ -334,15 +280,15 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Admin only",
+                "description": "Admin only. Removes the entry without aggregating its vote.",
                 "tags": [
-                    "polls"
+                    "dead-letters"
                 ],
-                "summary": "Delete poll",
+                "summary": "Discard a dead-lettered vote event",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Poll ID",
+                        "description": "Dead letter ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -352,74 +298,58 @@ const docTemplate = `{
                     "204": {
                         "description": "No Content"
                     },
+                    "400": {
+                        "description": "invalid id",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
                     "401": {
                         "description": "unauthorized",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "403": {
                         "description": "forbidden",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "404": {
                         "description": "not found",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "500": {
                         "description": "server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     }
                 }
-            },
-            "patch": {
+            }
+        },
+        "/api/v1/admin/dead-letters/{id}/replay": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Admin only",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Admin only. Aggregates the vote again and removes the entry. On failure the entry is kept with its attempt count raised.",
                 "tags": [
-                    "polls"
+                    "dead-letters"
                 ],
-                "summary": "Update poll (partial)",
+                "summary": "Replay a dead-lettered vote event",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Poll ID",
+                        "description": "Dead letter ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "Poll fields",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.updatePollRequest"
-                        }
                     }
                 ],
                 "responses": {
@@ -427,236 +357,213 @@ const docTemplate = `{
                         "description": "No Content"
                     },
                     "400": {
-                        "description": "invalid input",
+                        "description": "invalid id",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "401": {
                         "description": "unauthorized",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "403": {
                         "description": "forbidden",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "404": {
                         "description": "not found",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
-                    "500": {
-                        "description": "server error",
+                    "409": {
+                        "description": "replay failed",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
                         }
                     }
                 }
             }
         },
-        "/api/v1/polls/{id}/results": {
+        "/api/v1/admin/stats": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
+                "description": "Admin only. User, poll and vote totals, the polls with the most votes, recent registrations and the health of the stats workers. Database figures are cached for 30 seconds; the worker queue is always current.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "polls"
-                ],
-                "summary": "Poll results",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "Poll ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
+                    "admin"
                 ],
+                "summary": "Operational overview",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.pollResultsResponse"
+                            "$ref": "#/definitions/dashboard.Stats"
                         }
                     },
-                    "400": {
-                        "description": "invalid poll id",
+                    "401": {
+                        "description": "unauthorized",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
-                    "401": {
-                        "description": "unauthorized",
+                    "403": {
+                        "description": "forbidden",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
-                    "404": {
-                        "description": "not found",
+                    "500": {
+                        "description": "server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/2fa": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Whether two-factor authentication is enabled or pending for the current user, whether their role requires it, and how many recovery codes are left.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "two-factor"
+                ],
+                "summary": "Get two-factor status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/user.TwoFactorStatus"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "500": {
                         "description": "server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     }
                 }
             }
         },
-        "/api/v1/polls/{id}/status": {
-            "patch": {
+        "/api/v1/auth/2fa/confirm": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Admin only",
+                "description": "Turns two-factor authentication on with a first code from the authenticator app. Returns the recovery codes, which are shown only this once, and an access token that counts the second factor.",
                 "consumes": [
                     "application/json"
                 ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "polls"
+                    "two-factor"
                 ],
-                "summary": "Update poll status",
+                "summary": "Confirm two-factor enrollment",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Poll ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "New status",
+                        "description": "Code from the authenticator app",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.updateStatusRequest"
+                            "$ref": "#/definitions/api.twoFactorCodeRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
-                    },
-                    "400": {
-                        "description": "invalid id or status",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.recoveryCodesResponse"
                         }
                     },
-                    "401": {
-                        "description": "unauthorized",
+                    "400": {
+                        "description": "invalid body",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
-                    "403": {
-                        "description": "forbidden",
+                    "401": {
+                        "description": "unauthorized or invalid code",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
-                    "404": {
-                        "description": "not found",
+                    "409": {
+                        "description": "not enrolled or already enabled",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "500": {
                         "description": "server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     }
                 }
             }
         },
-        "/api/v1/polls/{id}/vote": {
+        "/api/v1/auth/2fa/disable": {
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
+                "description": "Needs a current code or an unused recovery code. Refused when the user's role requires two-factor authentication. Wrong codes count as failed logins.",
                 "consumes": [
                     "application/json"
                 ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "votes"
+                    "two-factor"
                 ],
-                "summary": "Vote for an option",
+                "summary": "Turn off two-factor authentication",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Poll ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Vote payload",
+                        "description": "Code or recovery code",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.voteRequest"
+                            "$ref": "#/definitions/api.twoFactorCodeRequest"
                         }
                     }
                 ],
@@ -665,346 +572,1998 @@ const docTemplate = `{
                         "description": "No Content"
                     },
                     "400": {
-                        "description": "invalid body or already voted",
+                        "description": "invalid body",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "401": {
-                        "description": "unauthorized",
+                        "description": "unauthorized or invalid code",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
-                    "404": {
-                        "description": "not found",
+                    "403": {
+                        "description": "required for the user's role",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "409": {
-                        "description": "already voted",
+                        "description": "not enabled",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "429": {
+                        "description": "too many failed attempts",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "500": {
                         "description": "server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     }
                 }
             }
         },
-        "/api/v1/users": {
-            "get": {
+        "/api/v1/auth/2fa/enroll": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
+                "description": "Creates a TOTP secret for the current user and returns it with its otpauth:// provisioning URI, to show as a QR code. Nothing changes at login until the enrollment is confirmed; enrolling again replaces an unconfirmed secret.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "two-factor"
                 ],
-                "summary": "List users",
+                "summary": "Start two-factor enrollment",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/user.User"
-                            }
+                            "$ref": "#/definitions/user.Enrollment"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "409": {
+                        "description": "already enabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "500": {
                         "description": "server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     }
                 }
             }
         },
-        "/api/v1/users/{id}/deactivate": {
-            "patch": {
+        "/api/v1/auth/2fa/recovery-codes": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
+                "description": "Replaces all recovery codes of the current user, used or not. Needs a current code or an unused recovery code; wrong codes count as failed logins.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "users"
+                    "two-factor"
                 ],
-                "summary": "Deactivate user",
+                "summary": "Regenerate recovery codes",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "User ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Code or recovery code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.twoFactorCodeRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.recoveryCodesResponse"
+                        }
                     },
                     "400": {
-                        "description": "invalid id",
+                        "description": "invalid body",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "401": {
-                        "description": "unauthorized",
+                        "description": "unauthorized or invalid code",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
-                    "403": {
-                        "description": "forbidden",
+                    "409": {
+                        "description": "not enabled",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
-                    "404": {
-                        "description": "not found",
+                    "429": {
+                        "description": "too many failed attempts",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "500": {
                         "description": "server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     }
                 }
             }
         },
-        "/api/v1/users/{id}/role": {
-            "patch": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
+        "/api/v1/auth/forgot-password": {
+            "post": {
+                "description": "Mails a password reset link if an active account has the email. The mail is sent in the background and the response is the same either way, so it does not reveal which emails are registered.",
                 "consumes": [
                     "application/json"
                 ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "users"
+                    "auth"
                 ],
-                "summary": "Update user role",
+                "summary": "Request a password reset",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "User ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "New role",
+                        "description": "Account email",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.updateRoleRequest"
+                            "$ref": "#/definitions/api.forgotPasswordRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "202": {
+                        "description": "Accepted"
                     },
                     "400": {
-                        "description": "invalid id or body",
+                        "description": "invalid body",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
-                    "404": {
-                        "description": "not found",
+                    "429": {
+                        "description": "rate limited",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/login": {
+            "post": {
+                "description": "Repeated failures for an email or from an IP address delay further attempts, then lock them for auth.lockout.*.lock_duration; both answer 429 with Retry-After. Users with two-factor authentication get a challenge token instead of an access token, to complete at /auth/login/2fa.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Login user",
+                "parameters": [
+                    {
+                        "description": "User credentials",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.authRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "or twoFactorChallengeResponse",
+                        "schema": {
+                            "$ref": "#/definitions/api.authResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid body",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "429": {
+                        "description": "too many failed attempts",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
                         }
                     },
                     "500": {
                         "description": "server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/login/2fa": {
+            "post": {
+                "description": "Second step of a login for users with two-factor authentication: exchanges the challenge token from /auth/login and a code from the authenticator app, or an unused recovery code, for an access token. Wrong codes count as failed logins.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Complete a two-factor login",
+                "parameters": [
+                    {
+                        "description": "Challenge token and code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.twoFactorLoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.authResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid body",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "401": {
+                        "description": "invalid or expired challenge token, or invalid code",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "429": {
+                        "description": "too many failed attempts",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/register": {
+            "post": {
+                "description": "Mails a link to verify the email address.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Register a new user",
+                "parameters": [
+                    {
+                        "description": "User credentials",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.authRequest"
                         }
                     }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.authResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid body or email taken",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/reset-password": {
+            "post": {
+                "description": "Sets a new password with the token from a reset email. The token works once and expires after auth.reset_password_ttl; a password rejected by the policy leaves it usable.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Reset password",
+                "parameters": [
+                    {
+                        "description": "Token and new password",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.resetPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "invalid password or token",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "401": {
+                        "description": "inactive user",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate limited",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/verify-email": {
+            "get": {
+                "description": "Target of the link mailed on registration. The token works once and expires after auth.verify_email_ttl.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Verify email address",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token from the email",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "invalid, expired or used token",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate limited",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/verify-email/resend": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Mails a new verification link to the current user. Earlier links stop working.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Resend verification email",
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "409": {
+                        "description": "already verified",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate limited",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/polls": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "polls"
+                ],
+                "summary": "List polls",
+                "parameters": [
+                    {
+                        "enum": [
+                            "draft",
+                            "active",
+                            "closed"
+                        ],
+                        "type": "string",
+                        "description": "Filter by status",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/poll.Poll"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Admin only",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "polls"
+                ],
+                "summary": "Create poll",
+                "parameters": [
+                    {
+                        "description": "Poll payload",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.createPollRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid body or fields",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/polls/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "polls"
+                ],
+                "summary": "Get poll with options",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Poll ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.pollDetailsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid id",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Admin only",
+                "tags": [
+                    "polls"
+                ],
+                "summary": "Delete poll",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Poll ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Admin only",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "polls"
+                ],
+                "summary": "Update poll (partial)",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Poll ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Poll fields",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.updatePollRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "invalid input",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/polls/{id}/analytics": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Admins and the poll's creator. Votes per option bucketed over time with cumulative curves, peak hour and participation rate.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "polls"
+                ],
+                "summary": "Poll vote analytics",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Poll ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "minute",
+                            "hour",
+                            "day"
+                        ],
+                        "type": "string",
+                        "default": "hour",
+                        "description": "Bucket size",
+                        "name": "bucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/vote.Analytics"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid poll id or bucket",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/polls/{id}/results": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Options are ordered by votes, then position, then id; zero-vote options are included. Closed polls report a winner or a tie.\nPass confidence (e.g. 0.95) to include Wilson score intervals, the margin of error and whether the leader is statistically ahead.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "polls"
+                ],
+                "summary": "Poll results",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Poll ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "Confidence level in (0, 1)",
+                        "name": "confidence",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.pollResultsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid poll id or confidence",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/polls/{id}/status": {
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Admin only",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "polls"
+                ],
+                "summary": "Update poll status",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Poll ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New status",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.updateStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "invalid id or status",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/polls/{id}/vote": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "votes"
+                ],
+                "summary": "Vote for an option",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Poll ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Vote payload",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.voteRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "invalid body or already voted",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "409": {
+                        "description": "already voted",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "429": {
+                        "description": "rate limited",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "List users",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/user.User"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}/2fa": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "For users who lost both their authenticator and their recovery codes: removes their two-factor setup, so they log in with their password alone and can enroll again. Users whose role requires two-factor authentication must then enroll before using the API.",
+                "tags": [
+                    "users"
+                ],
+                "summary": "Reset user two-factor authentication",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "invalid id",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "409": {
+                        "description": "two-factor authentication not enabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}/deactivate": {
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Deactivate user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "invalid id",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}/role": {
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Update user role",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New role",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.updateRoleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "invalid id or body",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}/unlock": {
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Forgets the failed logins of the user's email, lifting its delay or lockout. Failures counted against IP addresses are kept.",
+                "tags": [
+                    "users"
+                ],
+                "summary": "Unlock user login",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "invalid id",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    },
+                    "500": {
+                        "description": "server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/health": {
+            "get": {
+                "description": "Checks that the process is working, such as the stats worker heartbeat. Fails only when a restart would help; dependencies are covered by /ready.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "up or degraded",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    },
+                    "503": {
+                        "description": "down",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    }
+                }
+            }
+        },
+        "/problems": {
+            "get": {
+                "description": "Documentation of every error code, the target of the type of an error response.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "problems"
+                ],
+                "summary": "List error types",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.problemDoc"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/problems/{code}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "problems"
+                ],
+                "summary": "Describe error type",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Error code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.problemDoc"
+                        }
+                    },
+                    "404": {
+                        "description": "unknown code",
+                        "schema": {
+                            "$ref": "#/definitions/api.problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/ready": {
+            "get": {
+                "description": "Checks the dependencies needed to serve traffic: database, schema version, vote queue, results cache, and whether the server is shutting down.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "up or degraded",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    },
+                    "503": {
+                        "description": "down",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.auditLogResponse": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/audit.Event"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.authRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.authResponse": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string"
+                },
+                "two_factor_setup_required": {
+                    "description": "TwoFactorSetupRequired is set when the user's role requires\ntwo-factor authentication and the user has not set it up yet.",
+                    "type": "boolean"
+                },
+                "user": {
+                    "$ref": "#/definitions/user.User"
+                }
+            }
+        },
+        "api.createPollRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "ends_at": {
+                    "type": "string"
+                },
+                "options": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "starts_at": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.deadLetterListResponse": {
+            "type": "object",
+            "properties": {
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/deadletter.Entry"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.forgotPasswordRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.pollDetailsResponse": {
+            "type": "object",
+            "properties": {
+                "options": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/poll.Option"
+                    }
+                },
+                "poll": {
+                    "$ref": "#/definitions/poll.Poll"
+                }
+            }
+        },
+        "api.pollResultsResponse": {
+            "type": "object",
+            "properties": {
+                "confidence": {
+                    "$ref": "#/definitions/vote.Confidence"
+                },
+                "options": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/vote.Result"
+                    }
+                },
+                "poll_id": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "tie": {
+                    "type": "boolean"
+                },
+                "total_votes": {
+                    "type": "integer"
+                },
+                "winner_option_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "validation_failed"
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "the request has invalid fields"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apperr.Violation"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/api/v1/polls"
+                },
+                "request_id": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 400
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Validation failed"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "/problems/validation_failed"
+                }
+            }
+        },
+        "api.problemDoc": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "validation_failed"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "One or more fields of the request are invalid. errors lists every invalid field."
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 400
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Validation failed"
+                }
+            }
+        },
+        "api.recoveryCodesResponse": {
+            "type": "object",
+            "properties": {
+                "recovery_codes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "token": {
+                    "description": "Token is an access token that counts the second factor, issued when\ntwo-factor authentication is turned on.",
+                    "type": "string"
+                }
+            }
+        },
+        "api.replayAllResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "replayed": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.resetPasswordRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.twoFactorCodeRequest": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code is a code from the authenticator app or, except when\nconfirming, an unused recovery code.",
+                    "type": "string",
+                    "example": "123456"
+                }
+            }
+        },
+        "api.twoFactorLoginRequest": {
+            "type": "object",
+            "properties": {
+                "challenge_token": {
+                    "type": "string"
+                },
+                "code": {
+                    "type": "string",
+                    "example": "123456"
+                }
+            }
+        },
+        "api.updatePollRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "ends_at": {
+                    "type": "string"
+                },
+                "starts_at": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.updateRoleRequest": {
+            "type": "object",
+            "properties": {
+                "role": {
+                    "type": "string"
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.authRequest": {
+        },
+        "api.updateStatusRequest": {
             "type": "object",
             "properties": {
-                "email": {
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.voteRequest": {
+            "type": "object",
+            "properties": {
+                "option_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "apperr.Violation": {
+            "type": "object",
+            "properties": {
+                "code": {
                     "type": "string"
                 },
-                "password": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
                     "type": "string"
                 }
             }
         },
-        "api.authResponse": {
+        "audit.Event": {
             "type": "object",
             "properties": {
-                "token": {
+                "action": {
+                    "type": "string",
+                    "example": "login_failed"
+                },
+                "actor_id": {
+                    "description": "ActorID is the user who acted on UserID, e.g. the admin who unlocked it.",
+                    "type": "integer"
+                },
+                "created_at": {
                     "type": "string"
                 },
-                "user": {
-                    "$ref": "#/definitions/user.User"
+                "detail": {
+                    "type": "string",
+                    "example": "account locked for 15m0s after 10 failures"
+                },
+                "email": {
+                    "description": "Email is the email given in the request, which may match no account.",
+                    "type": "string",
+                    "example": "john@example.com"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "ip": {
+                    "type": "string",
+                    "example": "203.0.113.7"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "description": "UserID is the account the event is about, if it exists.",
+                    "type": "integer"
                 }
             }
         },
-        "api.createPollRequest": {
+        "dashboard.PollParticipation": {
             "type": "object",
             "properties": {
-                "description": {
+                "participation_rate": {
+                    "type": "number"
+                },
+                "poll_id": {
+                    "type": "integer"
+                },
+                "status": {
                     "type": "string"
                 },
-                "ends_at": {
+                "title": {
                     "type": "string"
                 },
-                "options": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
+                "votes": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dashboard.Registration": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
                 },
-                "starts_at": {
+                "email": {
                     "type": "string"
                 },
-                "title": {
+                "role": {
                     "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
                 }
             }
         },
-        "api.pollDetailsResponse": {
+        "dashboard.Stats": {
             "type": "object",
             "properties": {
-                "options": {
+                "generated_at": {
+                    "type": "string"
+                },
+                "polls_by_status": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "recent_registrations": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/poll.Option"
+                        "$ref": "#/definitions/dashboard.Registration"
                     }
                 },
-                "poll": {
-                    "$ref": "#/definitions/poll.Poll"
+                "top_polls": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dashboard.PollParticipation"
+                    }
+                },
+                "users": {
+                    "$ref": "#/definitions/dashboard.UserTotals"
+                },
+                "votes": {
+                    "$ref": "#/definitions/dashboard.VoteTotals"
+                },
+                "workers": {
+                    "$ref": "#/definitions/dashboard.WorkerHealth"
                 }
             }
         },
-        "api.pollResultsResponse": {
+        "dashboard.UserTotals": {
             "type": "object",
             "properties": {
-                "options": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/vote.Result"
-                    }
+                "active": {
+                    "type": "integer"
                 },
-                "poll_id": {
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dashboard.VoteTotals": {
+            "type": "object",
+            "properties": {
+                "this_week": {
                     "type": "integer"
                 },
-                "total_votes": {
+                "today": {
                     "type": "integer"
                 }
             }
         },
-        "api.updatePollRequest": {
+        "dashboard.WorkerHealth": {
             "type": "object",
             "properties": {
-                "description": {
-                    "type": "string"
+                "dead_letters": {
+                    "type": "integer"
                 },
-                "ends_at": {
+                "last_aggregation_at": {
                     "type": "string"
                 },
-                "starts_at": {
-                    "type": "string"
+                "queue_capacity": {
+                    "type": "integer"
                 },
-                "title": {
-                    "type": "string"
+                "queue_depth": {
+                    "type": "integer"
                 }
             }
         },
-        "api.updateRoleRequest": {
+        "deadletter.Entry": {
             "type": "object",
             "properties": {
-                "role": {
+                "attempts": {
+                    "description": "Attempts counts every aggregation attempt, including failed replays.",
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_failed_at": {
                     "type": "string"
+                },
+                "option_id": {
+                    "type": "integer"
+                },
+                "poll_id": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "integer"
                 }
             }
         },
-        "api.updateStatusRequest": {
+        "health.Report": {
             "type": "object",
             "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/health.Result"
+                    }
+                },
                 "status": {
-                    "type": "string"
+                    "$ref": "#/definitions/health.Status"
                 }
             }
         },
-        "api.voteRequest": {
+        "health.Result": {
             "type": "object",
             "properties": {
-                "option_id": {
-                    "type": "integer"
+                "details": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "error": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "number"
+                },
+                "status": {
+                    "$ref": "#/definitions/health.Status"
                 }
             }
         },
+        "health.Status": {
+            "type": "string",
+            "enum": [
+                "up",
+                "degraded",
+                "down"
+            ],
+            "x-enum-varnames": [
+                "StatusUp",
+                "StatusDegraded",
+                "StatusDown"
+            ]
+        },
         "poll.Option": {
             "type": "object",
             "properties": {
@@ -1017,6 +2576,9 @@ const docTemplate = `{
                 "poll_id": {
                     "type": "integer"
                 },
+                "position": {
+                    "type": "integer"
+                },
                 "text": {
                     "type": "string"
                 }
@@ -1054,6 +2616,40 @@ const docTemplate = `{
                 }
             }
         },
+        "user.Enrollment": {
+            "type": "object",
+            "properties": {
+                "secret": {
+                    "type": "string"
+                },
+                "uri": {
+                    "description": "URI is the otpauth:// provisioning URI, usually shown as a QR code.",
+                    "type": "string"
+                }
+            }
+        },
+        "user.TwoFactorStatus": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "enabled_at": {
+                    "type": "string"
+                },
+                "pending": {
+                    "description": "Pending is true between enrolling and confirming the first code.",
+                    "type": "boolean"
+                },
+                "recovery_codes_left": {
+                    "type": "integer"
+                },
+                "required": {
+                    "description": "Required is true when the user's role must use two-factor\nauthentication.",
+                    "type": "boolean"
+                }
+            }
+        },
         "user.User": {
             "type": "object",
             "properties": {
@@ -1063,6 +2659,10 @@ const docTemplate = `{
                 "email": {
                     "type": "string"
                 },
+                "email_verified_at": {
+                    "description": "EmailVerifiedAt is when the user proved they own Email; nil until then.",
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
@@ -1074,15 +2674,121 @@ const docTemplate = `{
                 }
             }
         },
+        "vote.Analytics": {
+            "type": "object",
+            "properties": {
+                "bucket": {
+                    "type": "string"
+                },
+                "eligible_voters": {
+                    "type": "integer"
+                },
+                "options": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/vote.OptionSeries"
+                    }
+                },
+                "participation_rate": {
+                    "type": "number"
+                },
+                "peak_hour": {
+                    "type": "string"
+                },
+                "peak_hour_votes": {
+                    "type": "integer"
+                },
+                "poll_id": {
+                    "type": "integer"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/vote.SeriesPoint"
+                    }
+                },
+                "total_votes": {
+                    "type": "integer"
+                }
+            }
+        },
+        "vote.Confidence": {
+            "type": "object",
+            "properties": {
+                "leader_option_id": {
+                    "type": "integer"
+                },
+                "leader_significant": {
+                    "type": "boolean"
+                },
+                "level": {
+                    "type": "number"
+                },
+                "margin_of_error": {
+                    "type": "number"
+                }
+            }
+        },
+        "vote.OptionSeries": {
+            "type": "object",
+            "properties": {
+                "option_id": {
+                    "type": "integer"
+                },
+                "points": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/vote.SeriesPoint"
+                    }
+                }
+            }
+        },
+        "vote.PercentInterval": {
+            "type": "object",
+            "properties": {
+                "lower": {
+                    "type": "number"
+                },
+                "upper": {
+                    "type": "number"
+                }
+            }
+        },
         "vote.Result": {
             "type": "object",
             "properties": {
+                "confidence_interval": {
+                    "$ref": "#/definitions/vote.PercentInterval"
+                },
                 "option_id": {
                     "type": "integer"
                 },
                 "percentage": {
                     "type": "number"
                 },
+                "position": {
+                    "type": "integer"
+                },
+                "text": {
+                    "type": "string"
+                },
+                "votes": {
+                    "type": "integer"
+                }
+            }
+        },
+        "vote.SeriesPoint": {
+            "type": "object",
+            "properties": {
+                "bucket": {
+                    "type": "string"
+                },
+                "cumulative": {
+                    "type": "integer"
+                },
                 "votes": {
                     "type": "integer"
                 }
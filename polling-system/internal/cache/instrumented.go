@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"context"
+
+	"polling-system/internal/domain/vote"
+	"polling-system/internal/metrics"
+)
+
+// InstrumentedResultsCache counts the lookups of the wrapped cache as hits,
+// misses and errors, labelled with the backend name.
+type InstrumentedResultsCache struct {
+	vote.ResultsCache
+	backend string
+}
+
+func NewInstrumentedResultsCache(c vote.ResultsCache, backend string) *InstrumentedResultsCache {
+	return &InstrumentedResultsCache{ResultsCache: c, backend: backend}
+}
+
+func (c *InstrumentedResultsCache) Get(ctx context.Context, pollID int64) (*vote.PollResults, bool, error) {
+	res, ok, err := c.ResultsCache.Get(ctx, pollID)
+	switch {
+	case err != nil:
+		metrics.ObserveCacheLookup(c.backend, "error")
+	case ok:
+		metrics.ObserveCacheLookup(c.backend, "hit")
+	default:
+		metrics.ObserveCacheLookup(c.backend, "miss")
+	}
+	return res, ok, err
+}
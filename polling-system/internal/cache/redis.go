@@ -0,0 +1,262 @@
+// Package cache provides shared ResultsCache backends for running several
+// API replicas behind a load balancer.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"polling-system/internal/domain/vote"
+	"polling-system/internal/retry"
+)
+
+const (
+	keyPrefix           = "polling:results:"
+	genKeyPrefix        = "polling:results:gen:"
+	invalidationChannel = "polling:results:invalidate"
+)
+
+// generationTTL is how long a poll's generation outlives its last
+// invalidation. A generation that expired reads as 0 again, which only
+// matters to a load that started before it expired.
+const generationTTL = 24 * time.Hour
+
+// setIfCurrent stores the results only if the poll's generation is still
+// the one the caller read before computing them.
+var setIfCurrent = redis.NewScript(`
+if (redis.call('GET', KEYS[2]) or '0') ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+// healthCheckInterval is how long the invalidation subscription may stay
+// quiet before the connection is pinged.
+const healthCheckInterval = 30 * time.Second
+
+// ErrNotSubscribed is reported by Check while the cache is not receiving the
+// invalidations of other replicas.
+var ErrNotSubscribed = errors.New("not subscribed to results cache invalidations")
+
+// subscribeRetry retries subscribing to invalidations for as long as Listen
+// runs.
+var subscribeRetry = retry.Policy{
+	MaxAttempts: math.MaxInt,
+	BaseDelay:   100 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+	Jitter:      retry.FullJitter,
+}
+
+// RedisResultsCache stores results in any server speaking the Redis protocol
+// and keeps a process-local copy in front of it. Invalidations are published
+// so every replica drops its local copy, not only the one that took the vote.
+// Local copies are only used while Listen is subscribed, since without it
+// they could outlive an invalidation.
+type RedisResultsCache struct {
+	client    *redis.Client
+	local     *vote.MemoryCache
+	logger    *slog.Logger
+	subscribe retry.Policy
+	listening atomic.Bool
+}
+
+func NewRedisResultsCache(client *redis.Client, logger *slog.Logger) *RedisResultsCache {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &RedisResultsCache{
+		client:    client,
+		local:     vote.NewMemoryCache(),
+		logger:    logger,
+		subscribe: subscribeRetry,
+	}
+}
+
+func (c *RedisResultsCache) Get(ctx context.Context, pollID int64) (*vote.PollResults, bool, error) {
+	if c.listening.Load() {
+		if res, ok, _ := c.local.Get(ctx, pollID); ok {
+			return res, true, nil
+		}
+	}
+	localGen, _ := c.local.Generation(ctx, pollID)
+
+	pipe := c.client.Pipeline()
+	getCmd := pipe.Get(ctx, key(pollID))
+	ttlCmd := pipe.PTTL(ctx, key(pollID))
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, false, err
+	}
+
+	data, err := getCmd.Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var res vote.PollResults
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, false, err
+	}
+	if ttl := ttlCmd.Val(); ttl > 0 && c.listening.Load() {
+		_ = c.local.Set(ctx, pollID, localGen, &res, ttl)
+	}
+	return &res, true, nil
+}
+
+// Generation returns the poll's generation in Redis, which every replica's
+// invalidations bump.
+func (c *RedisResultsCache) Generation(ctx context.Context, pollID int64) (int64, error) {
+	gen, err := c.client.Get(ctx, genKey(pollID)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return gen, err
+}
+
+// Set stores res in Redis if gen is still current there, then keeps a local
+// copy unless an invalidation was received in the meantime.
+func (c *RedisResultsCache) Set(ctx context.Context, pollID, gen int64, res *vote.PollResults, ttl time.Duration) error {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	localGen, _ := c.local.Generation(ctx, pollID)
+	stored, err := setIfCurrent.Run(ctx, c.client, []string{key(pollID), genKey(pollID)},
+		strconv.FormatInt(gen, 10), data, ttl.Milliseconds()).Int()
+	if err != nil || stored == 0 {
+		return err
+	}
+	if !c.listening.Load() {
+		return nil
+	}
+	return c.local.Set(ctx, pollID, localGen, res, ttl)
+}
+
+func (c *RedisResultsCache) Invalidate(ctx context.Context, pollID int64) error {
+	_ = c.local.Invalidate(ctx, pollID)
+
+	pipe := c.client.TxPipeline()
+	pipe.Incr(ctx, genKey(pollID))
+	pipe.PExpire(ctx, genKey(pollID), generationTTL)
+	pipe.Del(ctx, key(pollID))
+	pipe.Publish(ctx, invalidationChannel, strconv.FormatInt(pollID, 10))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Listen drops local copies invalidated by other replicas until ctx is done.
+// When the subscription cannot be made or is lost, it is made again with
+// backoff; local copies are not used in the meantime and are dropped before
+// they are used again, since invalidations may have been missed. The returned
+// channel is closed once the first attempt to subscribe has finished, whether
+// or not it succeeded.
+func (c *RedisResultsCache) Listen(ctx context.Context) <-chan struct{} {
+	ready := make(chan struct{})
+	var once sync.Once
+	markReady := func() { once.Do(func() { close(ready) }) }
+
+	policy := c.subscribe
+	policy.Hooks.OnRetry = func(attempt int, err error, delay time.Duration) {
+		markReady()
+		c.logger.Error("results cache subscription failed", "attempt", attempt, "retry_in", delay, "error", err)
+	}
+	go func() {
+		defer markReady()
+		for ctx.Err() == nil {
+			var sub *redis.PubSub
+			err := retry.Do(ctx, policy, func(ctx context.Context) error {
+				s := c.client.Subscribe(ctx, invalidationChannel)
+				if _, err := s.Receive(ctx); err != nil {
+					_ = s.Close()
+					return err
+				}
+				sub = s
+				return nil
+			})
+			if err != nil {
+				return
+			}
+			c.local.Clear()
+			c.listening.Store(true)
+			markReady()
+
+			err = c.receive(ctx, sub)
+			c.listening.Store(false)
+			_ = sub.Close()
+			if ctx.Err() != nil {
+				return
+			}
+			c.logger.Error("results cache subscription lost", "error", err)
+		}
+	}()
+	return ready
+}
+
+// receive applies invalidations from sub until ctx is done or the
+// connection fails. A connection that stays quiet is pinged, and given up on
+// if the ping is not answered either.
+func (c *RedisResultsCache) receive(ctx context.Context, sub *redis.PubSub) error {
+	pinged := false
+	for {
+		msg, err := sub.ReceiveTimeout(ctx, healthCheckInterval)
+		if err != nil {
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Timeout() || ctx.Err() != nil {
+				return err
+			}
+			if pinged {
+				return errors.New("no answer to ping")
+			}
+			if err := sub.Ping(ctx); err != nil {
+				return err
+			}
+			pinged = true
+			continue
+		}
+		pinged = false
+
+		m, ok := msg.(*redis.Message)
+		if !ok {
+			continue
+		}
+		pollID, err := strconv.ParseInt(m.Payload, 10, 64)
+		if err != nil {
+			c.logger.Warn("invalid results cache invalidation", "payload", m.Payload)
+			continue
+		}
+		_ = c.local.Invalidate(ctx, pollID)
+	}
+}
+
+// Check reports whether Redis answers and Listen is receiving the
+// invalidations of other replicas.
+func (c *RedisResultsCache) Check(ctx context.Context) error {
+	if err := c.client.Ping(ctx).Err(); err != nil {
+		return err
+	}
+	if !c.listening.Load() {
+		return ErrNotSubscribed
+	}
+	return nil
+}
+
+func key(pollID int64) string {
+	return keyPrefix + strconv.FormatInt(pollID, 10)
+}
+
+func genKey(pollID int64) string {
+	return genKeyPrefix + strconv.FormatInt(pollID, 10)
+}
@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"polling-system/internal/domain/vote"
+)
+
+func newReplica(t *testing.T, addr string) *RedisResultsCache {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { _ = client.Close() })
+	return NewRedisResultsCache(client, nil)
+}
+
+func TestRedisResultsCacheSharedAcrossReplicas(t *testing.T) {
+	srv := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a := newReplica(t, srv.Addr())
+	b := newReplica(t, srv.Addr())
+	<-b.Listen(ctx)
+
+	res := &vote.PollResults{PollID: 1, Status: "active", TotalVotes: 3, Options: []vote.Result{{OptionID: 7, Text: "Pizza", Votes: 3, Percentage: 100}}}
+	if err := a.Set(ctx, 1, 0, res, time.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	got, ok, err := b.Get(ctx, 1)
+	if err != nil || !ok {
+		t.Fatalf("expected replica b to read shared results, ok=%v err=%v", ok, err)
+	}
+	if got.TotalVotes != 3 || got.Options[0].Text != "Pizza" {
+		t.Fatalf("unexpected results %+v", got)
+	}
+	if _, ok, _ := b.local.Get(ctx, 1); !ok {
+		t.Fatalf("expected replica b to keep a local copy")
+	}
+
+	if err := a.Invalidate(ctx, 1); err != nil {
+		t.Fatalf("invalidate: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, ok, _ := b.local.Get(ctx, 1); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("replica b did not drop its local copy")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok, _ := b.Get(ctx, 1); ok {
+		t.Fatalf("expected miss after invalidation")
+	}
+}
+
+func TestRedisResultsCacheDropsResultsOlderThanAnInvalidation(t *testing.T) {
+	srv := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a := newReplica(t, srv.Addr())
+	b := newReplica(t, srv.Addr())
+	<-a.Listen(ctx)
+
+	// Replica a computes results while replica b takes a vote.
+	gen, err := a.Generation(ctx, 4)
+	if err != nil {
+		t.Fatalf("generation: %v", err)
+	}
+	if err := b.Invalidate(ctx, 4); err != nil {
+		t.Fatalf("invalidate: %v", err)
+	}
+	if err := a.Set(ctx, 4, gen, &vote.PollResults{PollID: 4}, time.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if _, ok, _ := b.Get(ctx, 4); ok {
+		t.Fatal("expected results older than the invalidation not to be stored")
+	}
+	if _, ok, _ := a.local.Get(ctx, 4); ok {
+		t.Fatal("expected no local copy of results older than the invalidation")
+	}
+
+	gen, _ = a.Generation(ctx, 4)
+	if err := a.Set(ctx, 4, gen, &vote.PollResults{PollID: 4}, time.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if _, ok, _ := b.Get(ctx, 4); !ok {
+		t.Fatal("expected current results to be stored")
+	}
+}
+
+func TestRedisResultsCacheHonoursTTL(t *testing.T) {
+	srv := miniredis.RunT(t)
+	ctx := context.Background()
+	c := newReplica(t, srv.Addr())
+
+	if err := c.Set(ctx, 2, 0, &vote.PollResults{PollID: 2}, 5*time.Second); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	srv.FastForward(6 * time.Second)
+	_ = c.local.Invalidate(ctx, 2)
+
+	if _, ok, _ := c.Get(ctx, 2); ok {
+		t.Fatalf("expected expired entry to miss")
+	}
+}
+
+func TestRedisResultsCacheResubscribes(t *testing.T) {
+	srv := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Fail fast while Redis is down, instead of retrying inside the client.
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { _ = client.Close() })
+	c := NewRedisResultsCache(client, nil)
+	c.subscribe.MaxDelay = 50 * time.Millisecond
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	subscribed := func() bool { return c.Check(ctx) == nil }
+	if err := c.Check(ctx); !errors.Is(err, ErrNotSubscribed) {
+		t.Fatalf("expected ErrNotSubscribed before Listen, got %v", err)
+	}
+
+	// Redis is down when the replica starts.
+	srv.Close()
+	<-c.Listen(ctx)
+	if err := c.Check(ctx); err == nil {
+		t.Fatal("expected check to fail while redis is down")
+	}
+	if err := srv.Restart(); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	waitFor("subscription", subscribed)
+
+	// A lost subscription is reported and made again.
+	if err := c.Set(ctx, 3, 0, &vote.PollResults{PollID: 3}, time.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	srv.Close()
+	waitFor("subscription loss", func() bool { return !c.listening.Load() })
+	if _, ok, _ := c.Get(ctx, 3); ok {
+		t.Fatal("expected local copies to be skipped while not subscribed")
+	}
+	if err := srv.Restart(); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	waitFor("resubscription", subscribed)
+	if _, ok, _ := c.local.Get(ctx, 3); ok {
+		t.Fatal("expected local copies to be dropped on resubscription")
+	}
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"polling-system/internal/ratelimit"
+)
+
+func TestLoadLayering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "http:\n  port: \"7000\"\n  shutdown_timeout: 20s\ncache:\n  results_ttl: 30s\nworker:\n  stats_workers: 8\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	t.Setenv("APP_PORT", "7100")
+	t.Setenv("RESULTS_CACHE_TTL", "45s")
+
+	cfg, err := Load([]string{"--config", path, "--http.port", "7200"})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if cfg.HTTP.Port != "7200" {
+		t.Fatalf("flag should override env and file, got port %s", cfg.HTTP.Port)
+	}
+	if cfg.Cache.ResultsTTL != 45*time.Second {
+		t.Fatalf("env should override file, got ttl %s", cfg.Cache.ResultsTTL)
+	}
+	if cfg.HTTP.ShutdownTimeout != 20*time.Second || cfg.Worker.StatsWorkers != 8 {
+		t.Fatalf("file values not applied: %+v", cfg)
+	}
+	if cfg.Worker.QueueSize != 100 {
+		t.Fatalf("defaults should fill unset values, got queue size %d", cfg.Worker.QueueSize)
+	}
+}
+
+func TestLoadRejectsUnknownFileKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("http:\n  prot: \"7000\"\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := Load([]string{"--config", path}); err == nil {
+		t.Fatalf("expected error for unknown key")
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	cfg := Default()
+	cfg.HTTP.Port = "http"
+	cfg.Worker.StatsWorkers = 0
+	cfg.RateLimit.Policies = "vote=ten/1m"
+	cfg.Auth.Lockout.IP.LockAfter = cfg.Auth.Lockout.IP.FreeAttempts
+	cfg.Auth.TwoFactor.ChallengeTTL = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, want := range []string{"http.port", "worker.stats_workers", "rate_limit.policies", "auth.lockout.ip", "auth.two_factor.challenge_ttl"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestProductionRefusesDefaultSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Env = EnvProduction
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "auth.jwt_secret") || !strings.Contains(err.Error(), "mail.driver") {
+		t.Fatalf("expected default secret and mail driver to be rejected, got %v", err)
+	}
+
+	cfg.Auth.JWTSecret = strings.Repeat("s", 48)
+	cfg.DB.DSN = "postgres://polling_user:strong@db:5432/polling_db"
+	cfg.Mail.Driver = MailSMTP
+	cfg.Mail.SMTPAddr = "smtp.internal:587"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected production config to be valid, got %v", err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Redis.URL = "redis://:hunter2@cache:6379/0"
+	cfg.Mail.SMTPPassword = "mail-pass-123"
+	var out strings.Builder
+	if err := cfg.Print(&out); err != nil {
+		t.Fatalf("print: %v", err)
+	}
+	for _, secret := range []string{"dev-secret-change-me", "polling_pass", "hunter2", "mail-pass-123"} {
+		if strings.Contains(out.String(), secret) {
+			t.Fatalf("secret %q leaked in:\n%s", secret, out.String())
+		}
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	for _, tc := range []struct {
+		dsn, secret string
+	}{
+		{"postgres://polling_user:hunter2@db:5432/polling_db", "hunter2"},
+		{"postgres://db:5432/polling_db?user=u&password=hunter2", "hunter2"},
+		{"host=db user=u password=hunter2 dbname=x", "hunter2"},
+		{"host=db password='hunter 2' dbname=x", "hunter 2"},
+		{"postgres://u:hunter2@db:bad port/x", "hunter2"},
+	} {
+		got := redactURL(tc.dsn)
+		if strings.Contains(got, tc.secret) {
+			t.Fatalf("redactURL(%q) leaked the password: %q", tc.dsn, got)
+		}
+	}
+	if got := redactURL("host=db user=u password=hunter2 dbname=x"); got != "host=db user=u password=[REDACTED] dbname=x" {
+		t.Fatalf("unexpected key/value redaction %q", got)
+	}
+	if got := redactURL("sqlite:///var/lib/polls.db"); got != "sqlite:///var/lib/polls.db" {
+		t.Fatalf("DSN without a password should be unchanged, got %q", got)
+	}
+}
+
+func TestDefaultRateLimitPolicies(t *testing.T) {
+	got, err := ratelimit.ParsePolicies(Default().RateLimit.Policies)
+	if err != nil {
+		t.Fatalf("parse default policies: %v", err)
+	}
+	if want := ratelimit.DefaultPolicies(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("default policies %v do not match ratelimit.DefaultPolicies %v", got, want)
+	}
+}
+
+func TestLoadBoolFlagAlias(t *testing.T) {
+	cfg, err := Load([]string{"--migrate-on-start"})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.DB.MigrateOnStart {
+		t.Fatalf("expected --migrate-on-start to enable db.migrate_on_start")
+	}
+
+	t.Setenv("MIGRATE_ON_START", "yes")
+	if _, err := Load(nil); err == nil {
+		t.Fatalf("expected invalid boolean to be rejected")
+	}
+}
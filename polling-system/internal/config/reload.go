@@ -0,0 +1,182 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"polling-system/internal/metrics"
+)
+
+// DefaultWatchInterval is how often the config file is checked for changes.
+const DefaultWatchInterval = 5 * time.Second
+
+// hotReloadable lists the settings that are applied to a running server.
+// Changes to any other setting are reported and take effect on restart.
+var hotReloadable = map[string]bool{
+	"log.level":                  true,
+	"cors.allowed_origins":       true,
+	"cache.results_ttl":          true,
+	"rate_limit.policies":        true,
+	"rate_limit.trusted_proxies": true,
+}
+
+// Diff returns the names of the settings that differ between old and next,
+// split into those that can be applied live and those that need a restart.
+func Diff(old, next Config) (reloadable, restart []string) {
+	oldSettings, nextSettings := old.settings(), next.settings()
+	for i, s := range oldSettings {
+		if reflect.DeepEqual(reflect.ValueOf(s.ptr).Elem().Interface(), reflect.ValueOf(nextSettings[i].ptr).Elem().Interface()) {
+			continue
+		}
+		if hotReloadable[s.flag] {
+			reloadable = append(reloadable, s.flag)
+		} else {
+			restart = append(restart, s.flag)
+		}
+	}
+	return reloadable, restart
+}
+
+// ReloadResult describes a successful reload.
+type ReloadResult struct {
+	Applied         []string
+	RestartRequired []string
+}
+
+// Reloader re-reads the configuration on SIGHUP or when the config file
+// changes and hands the hot-reloadable settings to apply. Invalid
+// configurations are rejected as a whole and the running one is kept.
+type Reloader struct {
+	args     []string
+	interval time.Duration
+	apply    func(Config)
+	logger   *slog.Logger
+
+	mu      sync.Mutex
+	current Config
+	modTime time.Time
+}
+
+// NewReloader watches the configuration cfg was loaded from. args must be the
+// arguments cfg was loaded with so that flags keep their precedence.
+func NewReloader(cfg Config, args []string, interval time.Duration, apply func(Config), logger *slog.Logger) *Reloader {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Reloader{
+		args:     args,
+		interval: interval,
+		apply:    apply,
+		logger:   logger,
+		current:  cfg,
+		modTime:  fileModTime(cfg.File),
+	}
+}
+
+// Current returns the configuration the server is running with.
+func (r *Reloader) Current() Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload loads the configuration again and applies the settings that can
+// change at runtime. Other changed settings keep their running values and
+// are reported in RestartRequired.
+func (r *Reloader) Reload(trigger string) (ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Taken before loading, so that a file written meanwhile is loaded again.
+	modTime := fileModTime(r.current.File)
+	next, err := Load(r.args)
+	if err != nil {
+		// A broken file is not loaded again until it changes.
+		r.modTime = modTime
+		metrics.ObserveConfigReload(false)
+		r.logger.Error("config reload failed, keeping current configuration", "trigger", trigger, "error", err)
+		return ReloadResult{}, err
+	}
+	r.modTime = fileModTime(next.File)
+
+	reloadable, restart := Diff(r.current, next)
+	applied := r.current
+	copyReloadable(&applied, next)
+	r.current = applied
+	if len(reloadable) > 0 && r.apply != nil {
+		r.apply(applied)
+	}
+
+	_, pending := Diff(applied, next)
+	metrics.ObserveConfigReload(true)
+	metrics.SetConfigRestartRequired(len(pending))
+	r.logger.Info("config reloaded", "trigger", trigger, "applied", reloadable, "restart_required", restart)
+	if len(pending) > 0 {
+		r.logger.Warn("config changes pending restart", "settings", pending)
+	}
+	return ReloadResult{Applied: reloadable, RestartRequired: pending}, nil
+}
+
+// Run reloads on SIGHUP and whenever the config file's modification time
+// changes, until ctx is cancelled.
+func (r *Reloader) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			_, _ = r.Reload("signal")
+		case <-ticker.C:
+			if r.fileChanged() {
+				_, _ = r.Reload("file")
+			}
+		}
+	}
+}
+
+func (r *Reloader) fileChanged() bool {
+	r.mu.Lock()
+	path, last := r.current.File, r.modTime
+	r.mu.Unlock()
+	if path == "" {
+		return false
+	}
+	mt := fileModTime(path)
+	return !mt.IsZero() && !mt.Equal(last)
+}
+
+func copyReloadable(dst *Config, src Config) {
+	dstSettings, srcSettings := dst.settings(), src.settings()
+	for i, s := range dstSettings {
+		if hotReloadable[s.flag] {
+			reflect.ValueOf(s.ptr).Elem().Set(reflect.ValueOf(srcSettings[i].ptr).Elem())
+		}
+	}
+}
+
+func fileModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
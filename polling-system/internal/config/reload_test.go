@@ -0,0 +1,130 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := Default()
+	next := Default()
+	next.Log.Level = "debug"
+	next.RateLimit.Policies = "vote=5/1m"
+	next.HTTP.Port = "9090"
+
+	reloadable, restart := Diff(old, next)
+	if !reflect.DeepEqual(reloadable, []string{"rate_limit.policies", "log.level"}) {
+		t.Fatalf("unexpected reloadable settings %v", reloadable)
+	}
+	if !reflect.DeepEqual(restart, []string{"http.port"}) {
+		t.Fatalf("unexpected restart settings %v", restart)
+	}
+}
+
+func TestReloaderAppliesSafeSettingsOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "cache:\n  results_ttl: 10s\n")
+	args := []string{"--config", path}
+
+	cfg, err := Load(args)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	var applied []Config
+	r := NewReloader(cfg, args, time.Hour, func(c Config) { applied = append(applied, c) }, nil)
+
+	writeConfig(t, path, "cache:\n  results_ttl: 1m\nhttp:\n  port: \"9090\"\nlog:\n  level: debug\n")
+	res, err := r.Reload("test")
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(applied) != 1 {
+		t.Fatalf("expected apply to be called once, got %d", len(applied))
+	}
+	if applied[0].Cache.ResultsTTL != time.Minute || applied[0].Log.Level != "debug" {
+		t.Fatalf("hot settings not applied: %+v", applied[0])
+	}
+	if applied[0].HTTP.Port != cfg.HTTP.Port || r.Current().HTTP.Port != cfg.HTTP.Port {
+		t.Fatalf("restart-only setting must keep its running value")
+	}
+	if !reflect.DeepEqual(res.RestartRequired, []string{"http.port"}) {
+		t.Fatalf("expected http.port to require restart, got %v", res.RestartRequired)
+	}
+
+	writeConfig(t, path, "cache:\n  results_ttl: -1s\n")
+	if _, err := r.Reload("test"); err == nil {
+		t.Fatalf("expected invalid config to be rejected")
+	}
+	if len(applied) != 1 || r.Current().Cache.ResultsTTL != time.Minute {
+		t.Fatalf("invalid config must not be applied")
+	}
+}
+
+func TestReloaderDetectsFileChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "log:\n  level: info\n")
+	cfg, err := Load([]string{"--config", path})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	r := NewReloader(cfg, []string{"--config", path}, time.Hour, nil, nil)
+	if r.fileChanged() {
+		t.Fatalf("unchanged file reported as changed")
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if !r.fileChanged() {
+		t.Fatalf("expected modified file to be detected")
+	}
+}
+
+func TestReloaderSkipsBrokenFileUntilItChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "log:\n  level: info\n")
+	cfg, err := Load([]string{"--config", path})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	r := NewReloader(cfg, []string{"--config", path}, time.Hour, nil, nil)
+
+	writeConfig(t, path, "log:\n  level: loud\n")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if !r.fileChanged() {
+		t.Fatalf("expected modified file to be detected")
+	}
+	if _, err := r.Reload("file"); err == nil {
+		t.Fatalf("expected invalid file to be rejected")
+	}
+	if r.fileChanged() {
+		t.Fatalf("rejected file reported as changed again")
+	}
+
+	writeConfig(t, path, "log:\n  level: debug\n")
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if !r.fileChanged() {
+		t.Fatalf("expected fixed file to be detected")
+	}
+	if _, err := r.Reload("file"); err != nil || r.Current().Log.Level != "debug" {
+		t.Fatalf("expected fixed file to be applied, level %q, err %v", r.Current().Log.Level, err)
+	}
+}
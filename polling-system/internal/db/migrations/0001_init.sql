@@ -1,48 +0,0 @@
-CREATE TABLE users (
-    id SERIAL PRIMARY KEY,
-    email TEXT UNIQUE NOT NULL,
-    password_hash TEXT NOT NULL,
-    role TEXT NOT NULL CHECK (role IN ('admin', 'user')),
-    created_at TIMESTAMP NOT NULL DEFAULT now()
-);
-
-CREATE TABLE polls (
-    id SERIAL PRIMARY KEY,
-    title TEXT NOT NULL,
-    description TEXT,
-    status TEXT NOT NULL CHECK (status IN ('draft','active','closed')),
-    starts_at TIMESTAMP,
-    ends_at TIMESTAMP,
-    creator_id INT NOT NULL REFERENCES users(id),
-    created_at TIMESTAMP NOT NULL DEFAULT now(),
-    updated_at TIMESTAMP NOT NULL DEFAULT now()
-);
-
-CREATE TABLE options (
-    id SERIAL PRIMARY KEY,
-    poll_id INT NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
-    text TEXT NOT NULL,
-    created_at TIMESTAMP NOT NULL DEFAULT now()
-);
-        
-CREATE TABLE votes (
-    id SERIAL PRIMARY KEY,
-    poll_id INT NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
-    option_id INT NOT NULL REFERENCES options(id) ON DELETE CASCADE,
-    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-    created_at TIMESTAMP NOT NULL DEFAULT now(),
-    UNIQUE (poll_id, user_id)
-);
-
-CREATE TABLE aggregated_results (
-    id SERIAL PRIMARY KEY,
-    poll_id INT NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
-    option_id INT NOT NULL REFERENCES options(id) ON DELETE CASCADE,
-    votes_count INT NOT NULL,
-    updated_at TIMESTAMP NOT NULL DEFAULT now(),
-    UNIQUE (poll_id, option_id)
-);
-
-CREATE INDEX idx_votes_poll_id ON votes(poll_id);
-CREATE INDEX idx_votes_user_id ON votes(user_id);
-CREATE INDEX idx_votes_option_id ON votes(option_id);
@@ -0,0 +1,16 @@
+-- Security events. There is no foreign key to users: entries must outlive
+-- the accounts they mention, and failed logins name emails with no account.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    action TEXT NOT NULL,
+    user_id INT,
+    actor_id INT,
+    email TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_audit_log_user ON audit_log(user_id, id);
+CREATE INDEX idx_audit_log_action ON audit_log(action, id);
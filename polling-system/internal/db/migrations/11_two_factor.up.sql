@@ -0,0 +1,18 @@
+-- TOTP enrollments. A row is pending until enabled_at is set; last_step is
+-- the time step of the last accepted code, so codes cannot be replayed.
+CREATE TABLE user_totp (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    enabled_at TIMESTAMP,
+    last_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- One-time recovery codes. Only the SHA-256 of a code is stored.
+CREATE TABLE user_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP,
+    UNIQUE (user_id, code_hash)
+);
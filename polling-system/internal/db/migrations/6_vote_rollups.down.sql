@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_votes_created_at;
+DROP INDEX IF EXISTS idx_vote_rollups_bucket_start;
+
+DROP TABLE IF EXISTS vote_rollups;
@@ -0,0 +1,13 @@
+CREATE TABLE vote_rollups (
+    poll_id INT NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+    option_id INT NOT NULL,
+    bucket_start TIMESTAMP NOT NULL,
+    votes_count INT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (poll_id, option_id, bucket_start),
+    CONSTRAINT vote_rollups_option_poll_fkey
+        FOREIGN KEY (option_id, poll_id) REFERENCES options (id, poll_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_vote_rollups_bucket_start ON vote_rollups(bucket_start);
+CREATE INDEX IF NOT EXISTS idx_votes_created_at ON votes(created_at);
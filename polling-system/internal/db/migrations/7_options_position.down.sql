@@ -0,0 +1,2 @@
+ALTER TABLE options
+    DROP COLUMN IF EXISTS position;
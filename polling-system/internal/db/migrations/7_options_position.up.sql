@@ -0,0 +1,10 @@
+ALTER TABLE options
+    ADD COLUMN position INT NOT NULL DEFAULT 0;
+
+UPDATE options o
+SET position = ranked.rn - 1
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY poll_id ORDER BY id) AS rn
+    FROM options
+) ranked
+WHERE o.id = ranked.id;
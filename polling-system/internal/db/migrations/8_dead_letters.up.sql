@@ -0,0 +1,12 @@
+-- Vote events the stats workers gave up on. There are no foreign keys: an
+-- entry must outlive its poll so that an admin can still see and discard it.
+CREATE TABLE dead_letters (
+    id BIGSERIAL PRIMARY KEY,
+    poll_id BIGINT NOT NULL,
+    option_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    error TEXT NOT NULL,
+    attempts INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    last_failed_at TIMESTAMP NOT NULL DEFAULT now()
+);
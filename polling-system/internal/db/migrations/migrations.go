@@ -0,0 +1,366 @@
+// Package migrations embeds the SQL schema migrations and applies them.
+//
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+// Postgres migrations live at the top level and SQLite migrations in the
+// sqlite directory. The applied version is tracked in a golang-migrate
+// compatible schema_migrations table, so databases migrated with the migrate
+// CLI are picked up as is.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"polling-system/internal/platform/database"
+)
+
+//go:embed *.sql sqlite/*.sql
+var files embed.FS
+
+// lockID is the Postgres advisory lock key held while migrating so that
+// replicas starting together do not race.
+const lockID int64 = 7_381_402_116
+
+var (
+	ErrDirty          = errors.New("database schema is dirty")
+	ErrSchemaOutdated = errors.New("database schema is older than this binary expects")
+	ErrSchemaTooNew   = errors.New("database schema is newer than this binary expects")
+)
+
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied.
+type Status struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+// Migrator applies the migrations of one dialect, Postgres or SQLite, to a
+// database.
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+	logger     *slog.Logger
+}
+
+// New returns a Migrator for the embedded migrations of dialect.
+func New(db *sql.DB, dialect string, logger *slog.Logger) (*Migrator, error) {
+	var fsys fs.FS = files
+	switch dialect {
+	case database.Postgres:
+	case database.SQLite:
+		sub, err := fs.Sub(files, "sqlite")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	default:
+		return nil, fmt.Errorf("migrations: unsupported dialect %q", dialect)
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations, logger: logger}, nil
+}
+
+// Load reads and orders the migrations in fsys. Every version needs an up
+// file; down files are optional.
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, file := range names {
+		base, direction, ok := cutDirection(file)
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name>.up.sql or .down.sql", file)
+		}
+		versionStr, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: missing name", file)
+		}
+		version, err := strconv.ParseUint(versionStr, 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", file, versionStr)
+		}
+
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[uint(version)]
+		if !ok {
+			m = &Migration{Version: uint(version), Name: name}
+			byVersion[uint(version)] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d: conflicting names %q and %q", version, m.Name, name)
+		}
+		switch direction {
+		case "up":
+			m.Up = string(body)
+		case "down":
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s: missing up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func cutDirection(file string) (base, direction string, ok bool) {
+	for _, d := range []string{"up", "down"} {
+		if b, found := strings.CutSuffix(file, "."+d+".sql"); found {
+			return b, d, true
+		}
+	}
+	return "", "", false
+}
+
+// Latest returns the schema version this binary expects.
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the current schema version; 0 means nothing is applied.
+func (m *Migrator) Version(ctx context.Context) (uint, bool, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer conn.Close()
+	if err := ensureVersionTable(ctx, conn); err != nil {
+		return 0, false, err
+	}
+	return currentVersion(ctx, conn)
+}
+
+// AppliedVersion is Version without creating the schema_migrations table, so
+// that it can be polled by health checks. A database without the table is at
+// version 0.
+func (m *Migrator) AppliedVersion(ctx context.Context) (uint, bool, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer conn.Close()
+	exists, err := m.versionTableExists(ctx, conn)
+	if err != nil || !exists {
+		return 0, false, err
+	}
+	return currentVersion(ctx, conn)
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	version, _, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		out = append(out, Status{Version: mig.Version, Name: mig.Name, Applied: mig.Version <= version})
+	}
+	return out, nil
+}
+
+// Check returns an error unless the schema is clean and at Latest.
+func (m *Migrator) Check(ctx context.Context) error {
+	version, dirty, err := m.AppliedVersion(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case dirty:
+		return fmt.Errorf("%w at version %d", ErrDirty, version)
+	case version < m.Latest():
+		return fmt.Errorf("%w: at version %d, want %d; run \"server migrate up\"", ErrSchemaOutdated, version, m.Latest())
+	case version > m.Latest():
+		return fmt.Errorf("%w: at version %d, want %d", ErrSchemaTooNew, version, m.Latest())
+	}
+	return nil
+}
+
+// Up applies all pending migrations and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		version, dirty, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("%w at version %d", ErrDirty, version)
+		}
+		for _, mig := range m.migrations {
+			if mig.Version <= version {
+				continue
+			}
+			if err := m.apply(ctx, conn, mig.Up, mig.Version); err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+			}
+			m.logger.Info("applied migration", "version", mig.Version, "name", mig.Name)
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts up to steps applied migrations and returns how many were
+// reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		version, dirty, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("%w at version %d", ErrDirty, version)
+		}
+		for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+			mig := m.migrations[i]
+			if mig.Version > version {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+			}
+			var prev uint
+			if i > 0 {
+				prev = m.migrations[i-1].Version
+			}
+			if err := m.apply(ctx, conn, mig.Down, prev); err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+			}
+			m.logger.Info("reverted migration", "version", mig.Version, "name", mig.Name)
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// apply runs one migration and records the resulting version in the same
+// transaction, so a failed migration leaves the schema untouched.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, stmt string, version uint) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, stmt); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+	if version > 0 {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, int64(version)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// withLock runs fn on a single connection holding the migration advisory
+// lock. Other replicas block until it is released. SQLite has no advisory
+// locks; it is meant for a single process, and each migration still runs in
+// its own write transaction.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.dialect != database.Postgres {
+		if err := ensureVersionTable(ctx, conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	}
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+			m.logger.Warn("failed to release migration lock", "error", err)
+		}
+	}()
+
+	if err := ensureVersionTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func ensureVersionTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT NOT NULL PRIMARY KEY,
+			dirty BOOLEAN NOT NULL
+		)`)
+	return err
+}
+
+func (m *Migrator) versionTableExists(ctx context.Context, conn *sql.Conn) (bool, error) {
+	query := `SELECT to_regclass('schema_migrations') IS NOT NULL`
+	if m.dialect == database.SQLite {
+		query = `SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`
+	}
+	var exists bool
+	err := conn.QueryRowContext(ctx, query).Scan(&exists)
+	return exists, err
+}
+
+func currentVersion(ctx context.Context, conn *sql.Conn) (uint, bool, error) {
+	var (
+		version int64
+		dirty   bool
+	)
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return uint(version), dirty, nil
+}
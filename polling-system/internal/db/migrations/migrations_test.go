@@ -0,0 +1,109 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"polling-system/internal/config"
+	"polling-system/internal/platform/database"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	for _, dialect := range []string{database.Postgres, database.SQLite} {
+		m, err := New(nil, dialect, nil)
+		if err != nil {
+			t.Fatalf("load embedded %s migrations: %v", dialect, err)
+		}
+		for i, mig := range m.migrations {
+			if mig.Version != uint(i+1) {
+				t.Fatalf("%s: expected contiguous versions, got %d at position %d", dialect, mig.Version, i)
+			}
+			if mig.Down == "" {
+				t.Fatalf("%s: migration %d_%s has no down file", dialect, mig.Version, mig.Name)
+			}
+		}
+		if m.Latest() != uint(len(m.migrations)) {
+			t.Fatalf("%s: unexpected latest version %d", dialect, m.Latest())
+		}
+	}
+}
+
+func TestSQLiteUpDown(t *testing.T) {
+	db, err := database.NewSQLite(config.DBConfig{DSN: "sqlite::memory:"})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	m, err := New(db, database.SQLite, nil)
+	if err != nil {
+		t.Fatalf("new migrator: %v", err)
+	}
+	if version, dirty, err := m.AppliedVersion(ctx); err != nil || dirty || version != 0 {
+		t.Fatalf("expected an empty database at version 0, got %d dirty=%v err=%v", version, dirty, err)
+	}
+	if err := m.Check(ctx); !errors.Is(err, ErrSchemaOutdated) {
+		t.Fatalf("expected outdated schema, got %v", err)
+	}
+	var tables int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE name = 'schema_migrations'`).Scan(&tables); err != nil || tables != 0 {
+		t.Fatalf("expected reading the version not to create schema_migrations, got %d tables (%v)", tables, err)
+	}
+
+	if n, err := m.Up(ctx); err != nil || n != int(m.Latest()) {
+		t.Fatalf("up: applied %d, err %v", n, err)
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Fatalf("expected schema at latest, got %v", err)
+	}
+	if n, err := m.Up(ctx); err != nil || n != 0 {
+		t.Fatalf("second up should be a no-op: applied %d, err %v", n, err)
+	}
+
+	if n, err := m.Down(ctx, 1); err != nil || n != 1 {
+		t.Fatalf("down: reverted %d, err %v", n, err)
+	}
+	version, dirty, err := m.AppliedVersion(ctx)
+	if err != nil || dirty || version != m.Latest()-1 {
+		t.Fatalf("unexpected version %d dirty=%v err=%v", version, dirty, err)
+	}
+}
+
+func TestLoadOrdersByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"10_ten.up.sql":  {Data: []byte("SELECT 10")},
+		"2_two.up.sql":   {Data: []byte("SELECT 2")},
+		"2_two.down.sql": {Data: []byte("SELECT -2")},
+	}
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 2 || migrations[1].Version != 10 {
+		t.Fatalf("unexpected order: %+v", migrations)
+	}
+	if migrations[0].Down != "SELECT -2" || migrations[1].Down != "" {
+		t.Fatalf("unexpected down files: %+v", migrations)
+	}
+}
+
+func TestLoadRejectsMalformedFiles(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"no direction":  {"0001_init.sql": {Data: []byte("x")}},
+		"no name":       {"1.up.sql": {Data: []byte("x")}},
+		"bad version":   {"one_init.up.sql": {Data: []byte("x")}},
+		"missing up":    {"1_init.down.sql": {Data: []byte("x")}},
+		"name conflict": {"1_init.up.sql": {Data: []byte("x")}, "1_other.down.sql": {Data: []byte("x")}},
+	}
+	for name, fsys := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(fsys); err == nil || !strings.Contains(err.Error(), "migration") {
+				t.Fatalf("expected migration error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS vote_rollups;
+DROP TABLE IF EXISTS aggregated_results;
+DROP TABLE IF EXISTS votes;
+DROP TABLE IF EXISTS options;
+DROP TABLE IF EXISTS polls;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,72 @@
+-- SQLite schema equivalent to Postgres migrations 1-7. Timestamps are UTC
+-- text in "YYYY-MM-DD HH:MM:SS.SSS" form so they sort and compare lexically.
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT UNIQUE NOT NULL,
+    password_hash TEXT NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('admin', 'user')),
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE polls (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    description TEXT,
+    status TEXT NOT NULL CHECK (status IN ('draft','active','closed')),
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    creator_id INTEGER NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE options (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    poll_id INTEGER NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+    text TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    CONSTRAINT options_id_poll_id_unique UNIQUE (id, poll_id)
+);
+
+CREATE TABLE votes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    poll_id INTEGER NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+    option_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    UNIQUE (poll_id, user_id),
+    CONSTRAINT votes_option_poll_fkey
+        FOREIGN KEY (option_id, poll_id) REFERENCES options (id, poll_id) ON DELETE CASCADE
+);
+
+CREATE TABLE aggregated_results (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    poll_id INTEGER NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+    option_id INTEGER NOT NULL,
+    votes_count INTEGER NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    UNIQUE (poll_id, option_id),
+    CONSTRAINT aggregated_results_option_poll_fkey
+        FOREIGN KEY (option_id, poll_id) REFERENCES options (id, poll_id) ON DELETE CASCADE
+);
+
+CREATE TABLE vote_rollups (
+    poll_id INTEGER NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+    option_id INTEGER NOT NULL,
+    bucket_start TIMESTAMP NOT NULL,
+    votes_count INTEGER NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    PRIMARY KEY (poll_id, option_id, bucket_start),
+    CONSTRAINT vote_rollups_option_poll_fkey
+        FOREIGN KEY (option_id, poll_id) REFERENCES options (id, poll_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_votes_poll_id ON votes(poll_id);
+CREATE INDEX idx_votes_user_id ON votes(user_id);
+CREATE INDEX idx_votes_option_id ON votes(option_id);
+CREATE INDEX idx_votes_created_at ON votes(created_at);
+CREATE INDEX idx_polls_status ON polls(status);
+CREATE UNIQUE INDEX idx_options_poll_text ON options(poll_id, text);
+CREATE INDEX idx_vote_rollups_bucket_start ON vote_rollups(bucket_start);
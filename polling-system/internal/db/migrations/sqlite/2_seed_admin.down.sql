@@ -0,0 +1,3 @@
+DELETE FROM users
+WHERE email = 'admin@example.com';
+    
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- Security events; see Postgres migration 10.
+CREATE TABLE audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    action TEXT NOT NULL,
+    user_id INTEGER,
+    actor_id INTEGER,
+    email TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX idx_audit_log_user ON audit_log(user_id, id);
+CREATE INDEX idx_audit_log_action ON audit_log(action, id);
@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionLoginSucceeded  = "login_succeeded"
+	ActionLoginFailed     = "login_failed"
+	ActionLoginLocked     = "login_locked"
+	ActionAccountUnlocked = "account_unlocked"
+
+	ActionTwoFactorEnabled     = "two_factor_enabled"
+	ActionTwoFactorDisabled    = "two_factor_disabled"
+	ActionTwoFactorFailed      = "two_factor_failed"
+	ActionTwoFactorReset       = "two_factor_reset"
+	ActionRecoveryCodeUsed     = "recovery_code_used"
+	ActionRecoveryCodesRenewed = "recovery_codes_regenerated"
+)
+
+// Event is a security-relevant action.
+type Event struct {
+	ID     int64  `json:"id"`
+	Action string `json:"action" example:"login_failed"`
+	// UserID is the account the event is about, if it exists.
+	UserID *int64 `json:"user_id,omitempty"`
+	// ActorID is the user who acted on UserID, e.g. the admin who unlocked it.
+	ActorID *int64 `json:"actor_id,omitempty"`
+	// Email is the email given in the request, which may match no account.
+	Email     string    `json:"email,omitempty" example:"john@example.com"`
+	IP        string    `json:"ip,omitempty" example:"203.0.113.7"`
+	RequestID string    `json:"request_id,omitempty"`
+	Detail    string    `json:"detail,omitempty" example:"account locked for 15m0s after 10 failures"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Filter narrows a listing; zero fields match everything.
+type Filter struct {
+	Action string
+	UserID int64
+}
+
+type Repository interface {
+	Create(ctx context.Context, e *Event) error
+	// List returns events newest first.
+	List(ctx context.Context, f Filter, limit, offset int) ([]Event, error)
+}
@@ -0,0 +1,43 @@
+// Package audit keeps a log of security events, such as failed logins and
+// account lockouts, for admins to review.
+package audit
+
+import (
+	"context"
+	"log/slog"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+type Service struct {
+	repo   Repository
+	logger *slog.Logger
+}
+
+func NewService(repo Repository, logger *slog.Logger) *Service {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Service{repo: repo, logger: logger}
+}
+
+// Record stores e. Losing an entry must not fail the action it records, so
+// a store failure is logged instead of returned.
+func (s *Service) Record(ctx context.Context, e Event) {
+	if err := s.repo.Create(ctx, &e); err != nil {
+		s.logger.ErrorContext(ctx, "record audit event", "action", e.Action, "error", err)
+	}
+}
+
+// List returns a page of events matching f, newest first.
+func (s *Service) List(ctx context.Context, f Filter, limit, offset int) ([]Event, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	limit = min(limit, MaxListLimit)
+	offset = max(offset, 0)
+	return s.repo.List(ctx, f, limit, offset)
+}
@@ -0,0 +1,91 @@
+package dashboard
+
+import (
+	"context"
+	"time"
+)
+
+// Stats is the operational overview served to admins.
+type Stats struct {
+	GeneratedAt         time.Time           `json:"generated_at"`
+	Users               UserTotals          `json:"users"`
+	PollsByStatus       map[string]int64    `json:"polls_by_status"`
+	Votes               VoteTotals          `json:"votes"`
+	TopPolls            []PollParticipation `json:"top_polls"`
+	RecentRegistrations []Registration      `json:"recent_registrations"`
+	Workers             WorkerHealth        `json:"workers"`
+}
+
+type UserTotals struct {
+	Total  int64 `json:"total"`
+	Active int64 `json:"active"`
+}
+
+// VoteTotals counts votes cast since midnight UTC and since Monday midnight
+// UTC.
+type VoteTotals struct {
+	Today    int64 `json:"today"`
+	ThisWeek int64 `json:"this_week"`
+}
+
+// Totals are the counters computed by the repository in one round trip.
+type Totals struct {
+	Users UserTotals
+	Votes VoteTotals
+}
+
+// PollParticipation is a poll ranked by its aggregated vote count.
+// ParticipationRate is the share of active users that voted, in percent.
+type PollParticipation struct {
+	PollID            int64   `json:"poll_id"`
+	Title             string  `json:"title"`
+	Status            string  `json:"status"`
+	Votes             int64   `json:"votes"`
+	ParticipationRate float64 `json:"participation_rate"`
+}
+
+type Registration struct {
+	UserID    int64     `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WorkerHealth describes the stats worker pool. LastAggregationAt is unset
+// until the workers have aggregated a vote since the server started.
+type WorkerHealth struct {
+	QueueDepth        int        `json:"queue_depth"`
+	QueueCapacity     int        `json:"queue_capacity"`
+	DeadLetters       int64      `json:"dead_letters"`
+	LastAggregationAt *time.Time `json:"last_aggregation_at,omitempty"`
+}
+
+type Repository interface {
+	// Totals counts users, active users and the votes cast since today
+	// and since weekStart.
+	Totals(ctx context.Context, today, weekStart time.Time) (Totals, error)
+	// TopPolls returns the polls with the most aggregated votes, most
+	// first, then by id. ParticipationRate is left zero.
+	TopPolls(ctx context.Context, limit int) ([]PollParticipation, error)
+	// RecentRegistrations returns the newest users first.
+	RecentRegistrations(ctx context.Context, limit int) ([]Registration, error)
+}
+
+// PollCounter counts polls per status; see poll.Service.CountByStatus.
+type PollCounter interface {
+	CountByStatus(ctx context.Context) (map[string]int64, error)
+}
+
+// DeadLetterCounter reports the size of the dead-letter queue.
+type DeadLetterCounter interface {
+	RefreshSize(ctx context.Context) (int64, error)
+}
+
+// Workers reports the state of the stats worker pool.
+type Workers interface {
+	QueueDepth() int
+	QueueCapacity() int
+	// LastAggregation is when a vote was last aggregated, or the zero
+	// time if none was.
+	LastAggregation() time.Time
+}
@@ -0,0 +1,144 @@
+// Package dashboard builds the operational overview shown to admins.
+package dashboard
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"polling-system/internal/platform/tracing"
+)
+
+const (
+	// DefaultCacheTTL is how long a computed overview is served before it
+	// is recomputed.
+	DefaultCacheTTL = 30 * time.Second
+
+	TopPollsLimit            = 5
+	RecentRegistrationsLimit = 10
+)
+
+type Service struct {
+	repo        Repository
+	polls       PollCounter
+	deadLetters DeadLetterCounter
+	workers     Workers
+	ttl         time.Duration
+	now         func() time.Time
+
+	// mu is held while the overview is computed, so concurrent requests
+	// on an expired cache run the queries once.
+	mu        sync.Mutex
+	cached    *Stats
+	expiresAt time.Time
+}
+
+// NewService builds a Service that caches the overview for ttl. workers may
+// be nil when no stats workers run in the process.
+func NewService(repo Repository, polls PollCounter, deadLetters DeadLetterCounter, workers Workers, ttl time.Duration) *Service {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &Service{
+		repo:        repo,
+		polls:       polls,
+		deadLetters: deadLetters,
+		workers:     workers,
+		ttl:         ttl,
+		now:         time.Now,
+	}
+}
+
+// Stats returns the overview. The database figures are computed again once
+// the cached ones are older than the cache TTL; the worker queue and last
+// aggregation are always current.
+func (s *Service) Stats(ctx context.Context) (_ *Stats, err error) {
+	ctx, span := tracing.Start(ctx, "dashboard.Service.Stats")
+	defer func() { tracing.End(span, err) }()
+
+	cached, err := s.cachedStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	st := *cached
+	if s.workers != nil {
+		st.Workers.QueueDepth = s.workers.QueueDepth()
+		st.Workers.QueueCapacity = s.workers.QueueCapacity()
+		if last := s.workers.LastAggregation(); !last.IsZero() {
+			last = last.UTC()
+			st.Workers.LastAggregationAt = &last
+		}
+	}
+	return &st, nil
+}
+
+func (s *Service) cachedStats(ctx context.Context) (*Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.cached != nil && now.Before(s.expiresAt) {
+		return s.cached, nil
+	}
+	st, err := s.load(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	s.cached, s.expiresAt = st, now.Add(s.ttl)
+	return st, nil
+}
+
+func (s *Service) load(ctx context.Context, now time.Time) (*Stats, error) {
+	today, weekStart := periodStarts(now)
+	totals, err := s.repo.Totals(ctx, today, weekStart)
+	if err != nil {
+		return nil, err
+	}
+	byStatus, err := s.polls.CountByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	top, err := s.repo.TopPolls(ctx, TopPollsLimit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range top {
+		if totals.Users.Active > 0 {
+			top[i].ParticipationRate = float64(top[i].Votes) / float64(totals.Users.Active) * 100
+		}
+	}
+	recent, err := s.repo.RecentRegistrations(ctx, RecentRegistrationsLimit)
+	if err != nil {
+		return nil, err
+	}
+	dlq, err := s.deadLetters.RefreshSize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Stats{
+		GeneratedAt:         now.UTC(),
+		Users:               totals.Users,
+		PollsByStatus:       byStatus,
+		Votes:               totals.Votes,
+		TopPolls:            top,
+		RecentRegistrations: recent,
+		Workers:             WorkerHealth{DeadLetters: dlq},
+	}
+	if st.TopPolls == nil {
+		st.TopPolls = []PollParticipation{}
+	}
+	if st.RecentRegistrations == nil {
+		st.RecentRegistrations = []Registration{}
+	}
+	return st, nil
+}
+
+// periodStarts returns midnight UTC of the day of now and of the Monday of
+// its week.
+func periodStarts(now time.Time) (today, weekStart time.Time) {
+	now = now.UTC()
+	today = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	sinceMonday := (int(today.Weekday()) + 6) % 7
+	return today, today.AddDate(0, 0, -sinceMonday)
+}
@@ -0,0 +1,91 @@
+package dashboard
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type fakeRepo struct {
+	totals      Totals
+	top         []PollParticipation
+	calls       int
+	today, week time.Time
+}
+
+func (r *fakeRepo) Totals(ctx context.Context, today, weekStart time.Time) (Totals, error) {
+	r.calls++
+	r.today, r.week = today, weekStart
+	return r.totals, nil
+}
+
+func (r *fakeRepo) TopPolls(ctx context.Context, limit int) ([]PollParticipation, error) {
+	return append([]PollParticipation(nil), r.top...), nil
+}
+
+func (r *fakeRepo) RecentRegistrations(ctx context.Context, limit int) ([]Registration, error) {
+	return nil, nil
+}
+
+type fakeCounts struct{}
+
+func (fakeCounts) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	return map[string]int64{"draft": 1, "active": 2, "closed": 0}, nil
+}
+
+func (fakeCounts) RefreshSize(ctx context.Context) (int64, error) {
+	return 3, nil
+}
+
+type fakeWorkers struct {
+	depth int
+	last  time.Time
+}
+
+func (w *fakeWorkers) QueueDepth() int            { return w.depth }
+func (w *fakeWorkers) QueueCapacity() int         { return 100 }
+func (w *fakeWorkers) LastAggregation() time.Time { return w.last }
+
+func TestStatsCachesDatabaseFigures(t *testing.T) {
+	repo := &fakeRepo{
+		totals: Totals{Users: UserTotals{Total: 5, Active: 4}, Votes: VoteTotals{Today: 1, ThisWeek: 3}},
+		top:    []PollParticipation{{PollID: 1, Votes: 3}},
+	}
+	workers := &fakeWorkers{depth: 7}
+	svc := NewService(repo, fakeCounts{}, fakeCounts{}, workers, time.Minute)
+	clock := time.Date(2026, 10, 15, 13, 30, 0, 0, time.UTC) // a Thursday
+	svc.now = func() time.Time { return clock }
+	ctx := context.Background()
+
+	st, err := svc.Stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if !repo.today.Equal(time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)) || !repo.week.Equal(time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected midnight and Monday midnight UTC, got %v and %v", repo.today, repo.week)
+	}
+	if st.TopPolls[0].ParticipationRate != 75 {
+		t.Fatalf("expected 3 of 4 active users to have voted, got %v%%", st.TopPolls[0].ParticipationRate)
+	}
+	if st.PollsByStatus["active"] != 2 || st.Workers.DeadLetters != 3 || st.Workers.QueueDepth != 7 || st.Workers.LastAggregationAt != nil {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+	if st.RecentRegistrations == nil {
+		t.Fatalf("recent registrations must be an empty list, not null")
+	}
+
+	clock = clock.Add(30 * time.Second)
+	workers.depth, workers.last = 2, clock
+	st, _ = svc.Stats(ctx)
+	if repo.calls != 1 {
+		t.Fatalf("expected the cached figures to be reused, got %d loads", repo.calls)
+	}
+	if st.Workers.QueueDepth != 2 || st.Workers.LastAggregationAt == nil {
+		t.Fatalf("worker health must be current, got %+v", st.Workers)
+	}
+
+	clock = clock.Add(time.Minute)
+	if _, err := svc.Stats(ctx); err != nil || repo.calls != 2 {
+		t.Fatalf("expected a reload after the TTL, got %d loads (%v)", repo.calls, err)
+	}
+}
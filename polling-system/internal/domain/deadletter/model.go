@@ -0,0 +1,40 @@
+package deadletter
+
+import (
+	"context"
+	"time"
+)
+
+// Entry is a vote event whose aggregation failed after all retries.
+type Entry struct {
+	ID       int64  `json:"id"`
+	PollID   int64  `json:"poll_id"`
+	OptionID int64  `json:"option_id"`
+	UserID   int64  `json:"user_id"`
+	Error    string `json:"error"`
+	// Attempts counts every aggregation attempt, including failed replays.
+	Attempts     int       `json:"attempts"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastFailedAt time.Time `json:"last_failed_at"`
+}
+
+type Repository interface {
+	Create(ctx context.Context, e *Entry) error
+	GetByID(ctx context.Context, id int64) (*Entry, error)
+	// List returns entries oldest first.
+	List(ctx context.Context, limit, offset int) ([]Entry, error)
+	Count(ctx context.Context) (int64, error)
+	// RecordFailure adds attempts to an entry and replaces its error.
+	RecordFailure(ctx context.Context, id int64, attempts int, errMsg string) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// Aggregator applies a vote event to the aggregated results.
+type Aggregator interface {
+	IncrementAggregated(ctx context.Context, pollID, optionID int64) error
+}
+
+// TxManager runs fn as one unit of work; see vote.TxManager.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,194 @@
+// Package deadletter keeps vote events that the stats workers could not
+// aggregate, so that an admin can inspect, replay or discard them.
+package deadletter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+)
+
+var (
+	ErrNotFound     = errors.New("dead letter not found")
+	ErrReplayFailed = errors.New("dead letter replay failed")
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// Hooks observe the queue size. Either field may be nil.
+type Hooks struct {
+	// OnSize is called with the queue size after every change.
+	OnSize func(size int64)
+	// OnAlert is called when the size reaches the alert threshold from below.
+	OnAlert func(size int64)
+}
+
+type Service struct {
+	repo           Repository
+	agg            Aggregator
+	tx             TxManager
+	alertThreshold int64
+	hooks          Hooks
+	size           atomic.Int64
+}
+
+// NewService builds a Service that replays entries through agg inside tx.
+// An alertThreshold of zero disables the alert hook.
+func NewService(repo Repository, agg Aggregator, tx TxManager, alertThreshold int64, hooks Hooks) *Service {
+	return &Service{
+		repo:           repo,
+		agg:            agg,
+		tx:             tx,
+		alertThreshold: alertThreshold,
+		hooks:          hooks,
+	}
+}
+
+// Record stores a vote event that failed after attempts tries. Once the entry
+// is stored the event is safe, so failing to refresh the queue size is only
+// logged.
+func (s *Service) Record(ctx context.Context, pollID, optionID, userID int64, attempts int, cause error) error {
+	e := &Entry{
+		PollID:   pollID,
+		OptionID: optionID,
+		UserID:   userID,
+		Error:    errorText(cause),
+		Attempts: attempts,
+	}
+	if err := s.repo.Create(ctx, e); err != nil {
+		return err
+	}
+	if _, err := s.RefreshSize(ctx); err != nil {
+		slog.WarnContext(ctx, "dead letter queue size refresh failed", "dead_letter_id", e.ID, "error", err)
+	}
+	return nil
+}
+
+// List returns a page of entries, oldest first, and the total queue size.
+func (s *Service) List(ctx context.Context, limit, offset int) ([]Entry, int64, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	limit = min(limit, MaxListLimit)
+	offset = max(offset, 0)
+
+	entries, err := s.repo.List(ctx, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.repo.Count(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+func (s *Service) Get(ctx context.Context, id int64) (*Entry, error) {
+	e, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return e, err
+}
+
+// Replay aggregates the entry's vote again and removes it from the queue in
+// the same transaction, so concurrent replays count the vote once. If the
+// aggregation fails the entry is kept with its attempt count raised.
+func (s *Service) Replay(ctx context.Context, id int64) error {
+	var cause error
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		e, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if cause = s.agg.IncrementAggregated(ctx, e.PollID, e.OptionID); cause != nil {
+			return cause
+		}
+		// A concurrent replay that got here first has already deleted the
+		// entry; failing rolls back this replay's increment.
+		return s.repo.Delete(ctx, id)
+	})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case err != nil && cause != nil:
+		if rerr := s.repo.RecordFailure(ctx, id, 1, errorText(cause)); rerr != nil {
+			return errors.Join(fmt.Errorf("%w: %v", ErrReplayFailed, cause), rerr)
+		}
+		return fmt.Errorf("%w: %v", ErrReplayFailed, cause)
+	case err != nil:
+		return err
+	}
+	_, err = s.RefreshSize(ctx)
+	return err
+}
+
+// ReplayAll replays every entry once, oldest first. Entries that fail again
+// stay in the queue; their count is returned as failed.
+func (s *Service) ReplayAll(ctx context.Context) (replayed, failed int, err error) {
+	for {
+		// Replayed entries leave the queue, so the failed ones are exactly
+		// the entries in front of the next page.
+		page, err := s.repo.List(ctx, MaxListLimit, failed)
+		if err != nil {
+			return replayed, failed, err
+		}
+		if len(page) == 0 {
+			return replayed, failed, nil
+		}
+		for _, e := range page {
+			switch err := s.Replay(ctx, e.ID); {
+			case err == nil:
+				replayed++
+			case errors.Is(err, ErrReplayFailed):
+				failed++
+			case errors.Is(err, ErrNotFound):
+				// Replayed or discarded concurrently.
+			default:
+				return replayed, failed, err
+			}
+		}
+	}
+}
+
+// Discard removes an entry without replaying it.
+func (s *Service) Discard(ctx context.Context, id int64) error {
+	err := s.repo.Delete(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.RefreshSize(ctx)
+	return err
+}
+
+// RefreshSize counts the queue and reports the size to the hooks.
+func (s *Service) RefreshSize(ctx context.Context) (int64, error) {
+	n, err := s.repo.Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+	prev := s.size.Swap(n)
+	if s.hooks.OnSize != nil {
+		s.hooks.OnSize(n)
+	}
+	if s.alertThreshold > 0 && prev < s.alertThreshold && n >= s.alertThreshold && s.hooks.OnAlert != nil {
+		s.hooks.OnAlert(n)
+	}
+	return n, nil
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return "unknown error"
+	}
+	return err.Error()
+}
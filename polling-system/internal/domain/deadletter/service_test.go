@@ -0,0 +1,225 @@
+package deadletter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+)
+
+type memoryRepo struct {
+	mu       sync.Mutex
+	entries  map[int64]Entry
+	nextID   int64
+	countErr error
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{entries: make(map[int64]Entry)}
+}
+
+func (r *memoryRepo) Create(ctx context.Context, e *Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	e.ID = r.nextID
+	r.entries[e.ID] = *e
+	return nil
+}
+
+func (r *memoryRepo) GetByID(ctx context.Context, id int64) (*Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &e, nil
+}
+
+func (r *memoryRepo) List(ctx context.Context, limit, offset int) ([]Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var res []Entry
+	for _, e := range r.entries {
+		res = append(res, e)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	if offset >= len(res) {
+		return nil, nil
+	}
+	res = res[offset:]
+	return res[:min(limit, len(res))], nil
+}
+
+func (r *memoryRepo) Count(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.countErr != nil {
+		return 0, r.countErr
+	}
+	return int64(len(r.entries)), nil
+}
+
+func (r *memoryRepo) RecordFailure(ctx context.Context, id int64, attempts int, errMsg string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	e.Attempts += attempts
+	e.Error = errMsg
+	r.entries[id] = e
+	return nil
+}
+
+func (r *memoryRepo) Delete(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.entries, id)
+	return nil
+}
+
+// fakeAggregator fails for polls listed in failing.
+type fakeAggregator struct {
+	mu      sync.Mutex
+	counts  map[int64]int64
+	failing map[int64]bool
+}
+
+func (a *fakeAggregator) IncrementAggregated(ctx context.Context, pollID, optionID int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.failing[pollID] {
+		return errors.New("connection refused")
+	}
+	a.counts[optionID]++
+	return nil
+}
+
+type directTx struct{}
+
+func (directTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func newTestService(threshold int64, hooks Hooks) (*Service, *memoryRepo, *fakeAggregator) {
+	repo := newMemoryRepo()
+	agg := &fakeAggregator{counts: make(map[int64]int64), failing: make(map[int64]bool)}
+	return NewService(repo, agg, directTx{}, threshold, hooks), repo, agg
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, agg := newTestService(0, Hooks{})
+
+	if err := svc.Record(ctx, 1, 10, 7, 4, errors.New("timeout")); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if err := svc.Record(ctx, 2, 20, 7, 4, errors.New("timeout")); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	agg.failing[2] = true
+
+	if err := svc.Replay(ctx, 1); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if agg.counts[10] != 1 {
+		t.Fatalf("expected the vote to be aggregated once, got %d", agg.counts[10])
+	}
+	if _, err := svc.Get(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("replayed entry must be removed, got %v", err)
+	}
+	if err := svc.Replay(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := svc.Replay(ctx, 2); !errors.Is(err, ErrReplayFailed) {
+		t.Fatalf("expected ErrReplayFailed, got %v", err)
+	}
+	e := repo.entries[2]
+	if e.Attempts != 5 || e.Error != "connection refused" {
+		t.Fatalf("failed replay must be recorded on the entry: %+v", e)
+	}
+}
+
+func TestReplayAll(t *testing.T) {
+	ctx := context.Background()
+	svc, _, agg := newTestService(0, Hooks{})
+
+	for i := int64(1); i <= 5; i++ {
+		if err := svc.Record(ctx, i, i*10, 1, 4, nil); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	agg.failing[2] = true
+	agg.failing[4] = true
+
+	replayed, failed, err := svc.ReplayAll(ctx)
+	if err != nil {
+		t.Fatalf("replay all: %v", err)
+	}
+	if replayed != 3 || failed != 2 {
+		t.Fatalf("expected 3 replayed and 2 failed, got %d and %d", replayed, failed)
+	}
+	if _, total, _ := svc.List(ctx, 0, 0); total != 2 {
+		t.Fatalf("expected the failed entries to stay queued, got %d", total)
+	}
+}
+
+func TestAlertFiresWhenThresholdIsCrossed(t *testing.T) {
+	ctx := context.Background()
+	var sizes []int64
+	alerts := 0
+	svc, _, _ := newTestService(2, Hooks{
+		OnSize:  func(n int64) { sizes = append(sizes, n) },
+		OnAlert: func(int64) { alerts++ },
+	})
+
+	for i := int64(1); i <= 3; i++ {
+		if err := svc.Record(ctx, i, i, 1, 4, nil); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	if alerts != 1 {
+		t.Fatalf("expected one alert while above the threshold, got %d", alerts)
+	}
+
+	for _, id := range []int64{1, 2} {
+		if err := svc.Discard(ctx, id); err != nil {
+			t.Fatalf("discard: %v", err)
+		}
+	}
+	if err := svc.Record(ctx, 9, 9, 1, 4, nil); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if alerts != 2 {
+		t.Fatalf("expected the alert to fire again after dropping below, got %d", alerts)
+	}
+	if want := []int64{1, 2, 3, 2, 1, 2}; !slices.Equal(sizes, want) {
+		t.Fatalf("unexpected size reports %v", sizes)
+	}
+	if err := svc.Discard(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestRecordSucceedsOnceStored(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, _ := newTestService(0, Hooks{})
+	repo.countErr = errors.New("connection reset")
+
+	if err := svc.Record(ctx, 1, 2, 3, 4, errors.New("timeout")); err != nil {
+		t.Fatalf("expected a stored entry to be recorded despite the size refresh failing, got %v", err)
+	}
+	if e, err := repo.GetByID(ctx, 1); err != nil || e.PollID != 1 {
+		t.Fatalf("expected the entry to be stored, got %+v (%v)", e, err)
+	}
+}
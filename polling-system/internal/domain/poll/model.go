@@ -21,6 +21,7 @@ type Option struct {
 	ID        int64     `json:"id"`
 	PollID    int64     `json:"poll_id"`
 	Text      string    `json:"text"`
+	Position  int       `json:"position"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -35,6 +36,9 @@ type Repository interface {
 	Create(ctx context.Context, p *Poll, options []Option) (int64, error)
 	GetByID(ctx context.Context, id int64) (*Poll, []Option, error)
 	List(ctx context.Context, status *string) ([]Poll, error)
+	// CountByStatus returns the number of polls per status; statuses
+	// without polls may be missing.
+	CountByStatus(ctx context.Context) (map[string]int64, error)
 	UpdateStatus(ctx context.Context, id int64, status string) error
 	Update(ctx context.Context, id int64, input UpdateInput) error
 	Delete(ctx context.Context, id int64) error
@@ -4,34 +4,55 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"polling-system/internal/platform/tracing"
 )
 
 var (
-	ErrInvalidStatus = errors.New("invalid poll status")
-	ErrInvalidDates  = errors.New("ends_at must be after starts_at")
-	ErrPollNotFound  = errors.New("poll not found")
+	ErrInvalidStatus   = errors.New("invalid poll status")
+	ErrPollNotFound    = errors.New("poll not found")
+	ErrDuplicateOption = errors.New("poll options must be unique")
+	ErrNothingToUpdate = errors.New("no fields to update")
 )
 
+// Hooks observe changes to polls. OnChange may be nil.
+type Hooks struct {
+	// OnChange is called after the status of a poll changed or the poll
+	// was deleted, for example to drop results cached for it.
+	OnChange func(ctx context.Context, id int64)
+}
+
 type Service struct {
-	repo Repository
+	repo  Repository
+	hooks Hooks
 }
 
 func NewService(repo Repository) *Service {
-	return &Service{repo: repo}
+	return NewServiceWithHooks(repo, Hooks{})
 }
 
-func (s *Service) Create(ctx context.Context, p *Poll, options []Option) (int64, error) {
-	if p.Title == "" {
-		return 0, errors.New("title required")
-	}
-	if len(options) < 2 {
-		return 0, errors.New("poll must have at least 2 options")
+// NewServiceWithHooks builds a Service that reports changes to hooks.
+func NewServiceWithHooks(repo Repository, hooks Hooks) *Service {
+	return &Service{repo: repo, hooks: hooks}
+}
+
+func (s *Service) Create(ctx context.Context, p *Poll, options []Option) (_ int64, err error) {
+	ctx, span := tracing.Start(ctx, "poll.Service.Create")
+	defer func() { tracing.End(span, err) }()
+
+	if err := Validate(p, options); err != nil {
+		return 0, err
 	}
 	p.Status = "draft"
 	return s.repo.Create(ctx, p, options)
 }
 
-func (s *Service) Get(ctx context.Context, id int64) (*Poll, []Option, error) {
+func (s *Service) Get(ctx context.Context, id int64) (_ *Poll, _ []Option, err error) {
+	ctx, span := tracing.Start(ctx, "poll.Service.Get", attribute.Int64("poll.id", id))
+	defer func() { tracing.End(span, err) }()
+
 	p, opts, err := s.repo.GetByID(ctx, id)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil, ErrPollNotFound
@@ -39,43 +60,77 @@ func (s *Service) Get(ctx context.Context, id int64) (*Poll, []Option, error) {
 	return p, opts, err
 }
 
-func (s *Service) List(ctx context.Context, status *string) ([]Poll, error) {
+func (s *Service) List(ctx context.Context, status *string) (_ []Poll, err error) {
+	ctx, span := tracing.Start(ctx, "poll.Service.List")
+	defer func() { tracing.End(span, err) }()
+
 	return s.repo.List(ctx, status)
 }
 
-func (s *Service) UpdateStatus(ctx context.Context, id int64, status string) error {
+// CountByStatus returns the number of polls for every status, including
+// statuses without polls.
+func (s *Service) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	counts, err := s.repo.CountByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]int64{"draft": 0, "active": 0, "closed": 0}
+	for status, n := range counts {
+		res[status] = n
+	}
+	return res, nil
+}
+
+func (s *Service) UpdateStatus(ctx context.Context, id int64, status string) (err error) {
+	ctx, span := tracing.Start(ctx, "poll.Service.UpdateStatus", attribute.Int64("poll.id", id), attribute.String("poll.status", status))
+	defer func() { tracing.End(span, err) }()
+
 	if status != "draft" && status != "active" && status != "closed" {
 		return ErrInvalidStatus
 	}
-	err := s.repo.UpdateStatus(ctx, id, status)
+	err = s.repo.UpdateStatus(ctx, id, status)
 	if errors.Is(err, sql.ErrNoRows) {
 		return ErrPollNotFound
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	s.changed(ctx, id)
+	return nil
 }
 
-func (s *Service) Update(ctx context.Context, id int64, input UpdateInput) error {
-	if input.Title != nil && *input.Title == "" {
-		return errors.New("title required")
-	}
-	if input.StartsAt != nil && input.EndsAt != nil && input.EndsAt.Before(*input.StartsAt) {
-		return ErrInvalidDates
-	}
-	if input.Title == nil && input.Description == nil && input.StartsAt == nil && input.EndsAt == nil {
-		return errors.New("no fields to update")
+func (s *Service) Update(ctx context.Context, id int64, input UpdateInput) (err error) {
+	ctx, span := tracing.Start(ctx, "poll.Service.Update", attribute.Int64("poll.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	if err := input.Validate(); err != nil {
+		return err
 	}
 
-	err := s.repo.Update(ctx, id, input)
+	err = s.repo.Update(ctx, id, input)
 	if errors.Is(err, sql.ErrNoRows) {
 		return ErrPollNotFound
 	}
 	return err
 }
 
-func (s *Service) Delete(ctx context.Context, id int64) error {
-	err := s.repo.Delete(ctx, id)
+func (s *Service) Delete(ctx context.Context, id int64) (err error) {
+	ctx, span := tracing.Start(ctx, "poll.Service.Delete", attribute.Int64("poll.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	err = s.repo.Delete(ctx, id)
 	if errors.Is(err, sql.ErrNoRows) {
 		return ErrPollNotFound
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	s.changed(ctx, id)
+	return nil
+}
+
+func (s *Service) changed(ctx context.Context, id int64) {
+	if s.hooks.OnChange != nil {
+		s.hooks.OnChange(ctx, id)
+	}
 }
@@ -7,6 +7,8 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"polling-system/internal/platform/validate"
 )
 
 type memoryPollRepo struct {
@@ -38,6 +40,7 @@ func (r *memoryPollRepo) Create(ctx context.Context, p *Poll, options []Option)
 	cloned := make([]Option, len(options))
 	for i, opt := range options {
 		opt.ID = int64(i + 1)
+		opt.Position = i
 		opt.PollID = p.ID
 		opt.CreatedAt = time.Now()
 		cloned[i] = opt
@@ -73,6 +76,16 @@ func (r *memoryPollRepo) List(ctx context.Context, status *string) ([]Poll, erro
 	return res, nil
 }
 
+func (r *memoryPollRepo) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make(map[string]int64)
+	for _, p := range r.polls {
+		res[p.Status]++
+	}
+	return res, nil
+}
+
 func (r *memoryPollRepo) UpdateStatus(ctx context.Context, id int64, status string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -142,4 +155,66 @@ func TestPollValidationAndStatus(t *testing.T) {
 	if err := svc.UpdateStatus(ctx, id, "active"); err != nil {
 		t.Fatalf("expected status update success: %v", err)
 	}
+
+	counts, err := svc.CountByStatus(ctx)
+	if err != nil {
+		t.Fatalf("count by status: %v", err)
+	}
+	if counts["active"] != 1 || counts["draft"] != 0 || counts["closed"] != 0 || len(counts) != 3 {
+		t.Fatalf("expected every status to be reported, got %v", counts)
+	}
+}
+
+func TestHooksSeeStatusChangesAndDeletes(t *testing.T) {
+	var changed []int64
+	svc := NewServiceWithHooks(newMemoryPollRepo(), Hooks{OnChange: func(ctx context.Context, id int64) {
+		changed = append(changed, id)
+	}})
+	ctx := context.Background()
+
+	id, err := svc.Create(ctx, &Poll{Title: "Ready"}, []Option{{Text: "A"}, {Text: "B"}})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := svc.UpdateStatus(ctx, id, "closed"); err != nil {
+		t.Fatalf("update status: %v", err)
+	}
+	if err := svc.Delete(ctx, id); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := svc.Delete(ctx, id); !errors.Is(err, ErrPollNotFound) {
+		t.Fatalf("expected ErrPollNotFound, got %v", err)
+	}
+	if len(changed) != 2 || changed[0] != id || changed[1] != id {
+		t.Fatalf("expected two changes of poll %d, got %v", id, changed)
+	}
+}
+
+func TestCreateReportsEveryViolation(t *testing.T) {
+	svc := NewService(newMemoryPollRepo())
+	start := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	end := start.Add(-time.Hour)
+
+	_, err := svc.Create(context.Background(), &Poll{Title: " ", StartsAt: &start, EndsAt: &end}, []Option{{Text: "A"}, {Text: "A"}, {Text: ""}})
+	var invalid validate.Errors
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+	got := make(map[string]string, len(invalid))
+	for _, fe := range invalid {
+		got[fe.Field] = fe.Code
+	}
+	want := map[string]string{"title": "required", "options[1]": "duplicate", "options[2]": "required", "ends_at": "before_start"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for field, code := range want {
+		if got[field] != code {
+			t.Fatalf("expected %s to be %s, got %v", field, code, got)
+		}
+	}
+
+	if err := svc.Update(context.Background(), 1, UpdateInput{}); !errors.Is(err, ErrNothingToUpdate) {
+		t.Fatalf("expected ErrNothingToUpdate, got %v", err)
+	}
 }
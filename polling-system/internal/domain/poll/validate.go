@@ -0,0 +1,50 @@
+package poll
+
+import (
+	"time"
+
+	"polling-system/internal/platform/validate"
+)
+
+const (
+	TitleMaxLen       = 200
+	DescriptionMaxLen = 2000
+	OptionMaxLen      = 200
+	MinOptions        = 2
+	MaxOptions        = 20
+)
+
+// Validate checks a new poll and its options.
+func Validate(p *Poll, options []Option) error {
+	texts := make([]string, len(options))
+	for i, o := range options {
+		texts[i] = o.Text
+	}
+
+	v := validate.New()
+	v.String("title", p.Title, validate.Required, validate.MaxLen(TitleMaxLen))
+	v.OptionalString("description", p.Description, validate.MaxLen(DescriptionMaxLen))
+	v.List("options", texts, validate.MinItems(MinOptions), validate.MaxItems(MaxOptions), validate.Unique)
+	v.Each("options", texts, validate.Required, validate.MaxLen(OptionMaxLen))
+	checkDates(v, p.StartsAt, p.EndsAt)
+	return v.Err()
+}
+
+// Validate checks the fields set in the input. An input without fields is
+// rejected with ErrNothingToUpdate.
+func (in UpdateInput) Validate() error {
+	if in.Title == nil && in.Description == nil && in.StartsAt == nil && in.EndsAt == nil {
+		return ErrNothingToUpdate
+	}
+	v := validate.New()
+	v.OptionalString("title", in.Title, validate.Required, validate.MaxLen(TitleMaxLen))
+	v.OptionalString("description", in.Description, validate.MaxLen(DescriptionMaxLen))
+	checkDates(v, in.StartsAt, in.EndsAt)
+	return v.Err()
+}
+
+func checkDates(v *validate.Validator, startsAt, endsAt *time.Time) {
+	if startsAt != nil && endsAt != nil {
+		v.Check(!endsAt.Before(*startsAt), "ends_at", "before_start", "ends_at must be after starts_at")
+	}
+}
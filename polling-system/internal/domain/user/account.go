@@ -0,0 +1,273 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/crypto/bcrypt"
+
+	"polling-system/internal/platform/mail"
+	"polling-system/internal/platform/tracing"
+)
+
+// backgroundMailTimeout bounds a password reset request that runs after
+// its HTTP request has been answered.
+const backgroundMailTimeout = time.Minute
+
+var (
+	ErrInvalidToken    = errors.New("token is invalid, expired or already used")
+	ErrAlreadyVerified = errors.New("email already verified")
+)
+
+// AccountOptions configures an AccountService.
+type AccountOptions struct {
+	Policy           PasswordPolicy
+	VerifyEmailTTL   time.Duration
+	ResetPasswordTTL time.Duration
+	// VerifyEmailURL and ResetPasswordURL are the links mailed to users,
+	// with the token added as the token query parameter.
+	VerifyEmailURL   string
+	ResetPasswordURL string
+}
+
+// AccountService runs the flows that prove a user owns their email address:
+// email verification and password reset. Both mail a single-use token that
+// expires, and only the latest token mailed for a purpose works.
+type AccountService struct {
+	users  Repository
+	tokens TokenRepository
+	tx     TxManager
+	mailer mail.Mailer
+	opts   AccountOptions
+	now    func() time.Time
+	// pending tracks password reset requests running in the background.
+	pending sync.WaitGroup
+}
+
+func NewAccountService(users Repository, tokens TokenRepository, tx TxManager, mailer mail.Mailer, opts AccountOptions) *AccountService {
+	return &AccountService{
+		users:  users,
+		tokens: tokens,
+		tx:     tx,
+		mailer: mailer,
+		opts:   opts,
+		now:    time.Now,
+	}
+}
+
+// SendVerification mails the user a link to verify their email address.
+func (s *AccountService) SendVerification(ctx context.Context, userID int64) (err error) {
+	ctx, span := tracing.Start(ctx, "user.AccountService.SendVerification", attribute.Int64("user.id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u.EmailVerifiedAt != nil {
+		return ErrAlreadyVerified
+	}
+	token, err := s.issue(ctx, u.ID, PurposeVerifyEmail, s.opts.VerifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Confirm that %s is your email address by opening this link:\n\n%s\n\n"+
+			"The link expires in %s. If you did not create an account, ignore this email.\n",
+			u.Email, link(s.opts.VerifyEmailURL, token), describe(s.opts.VerifyEmailTTL)),
+	})
+}
+
+// VerifyEmail marks the email of the token's user as verified.
+func (s *AccountService) VerifyEmail(ctx context.Context, token string) (err error) {
+	ctx, span := tracing.Start(ctx, "user.AccountService.VerifyEmail")
+	defer func() { tracing.End(span, err) }()
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		t, err := s.consume(ctx, PurposeVerifyEmail, token)
+		if err != nil {
+			return err
+		}
+		return s.users.MarkEmailVerified(ctx, t.UserID, s.now().UTC())
+	})
+}
+
+// RequestPasswordReset mails a password reset link to the account with
+// email. It succeeds without sending anything if there is no active
+// account, so callers cannot find out which emails are registered.
+func (s *AccountService) RequestPasswordReset(ctx context.Context, email string) (err error) {
+	ctx, span := tracing.Start(ctx, "user.AccountService.RequestPasswordReset")
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.users.GetByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !u.IsActive {
+		return nil
+	}
+	token, err := s.issue(ctx, u.ID, PurposeResetPassword, s.opts.ResetPasswordTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your account. To choose a new password, open this link:\n\n%s\n\n"+
+			"The link expires in %s and works once. If you did not ask for this, ignore this email; your password is unchanged.\n",
+			link(s.opts.ResetPasswordURL, token), describe(s.opts.ResetPasswordTTL)),
+	})
+}
+
+// RequestPasswordResetAsync runs RequestPasswordReset in the background and
+// returns at once, so that neither the response time nor a failure to send
+// the mail tells the caller whether email is registered. Errors are logged.
+func (s *AccountService) RequestPasswordResetAsync(ctx context.Context, email string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), backgroundMailTimeout)
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		defer cancel()
+		if err := s.RequestPasswordReset(ctx, email); err != nil {
+			slog.ErrorContext(ctx, "request password reset", "error", err)
+		}
+	}()
+}
+
+// Wait blocks until the password reset requests running in the background
+// have finished, or ctx is done.
+func (s *AccountService) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("password reset requests still running: %w", ctx.Err())
+	}
+}
+
+// ResetPassword sets a new password for the token's user. The password is
+// checked before the token is used, so a rejected password does not burn
+// the link. Resetting also verifies the email, since the link was mailed to
+// it.
+func (s *AccountService) ResetPassword(ctx context.Context, token, password string) (err error) {
+	ctx, span := tracing.Start(ctx, "user.AccountService.ResetPassword")
+	defer func() { tracing.End(span, err) }()
+
+	if err := ValidatePassword(password, s.opts.Policy); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		t, err := s.consume(ctx, PurposeResetPassword, token)
+		if err != nil {
+			return err
+		}
+		u, err := s.users.GetByID(ctx, t.UserID)
+		if err != nil {
+			return err
+		}
+		if !u.IsActive {
+			return ErrInactiveUser
+		}
+		if err := s.users.SetPassword(ctx, u.ID, string(hash)); err != nil {
+			return err
+		}
+		if err := s.users.MarkEmailVerified(ctx, u.ID, s.now().UTC()); err != nil {
+			return err
+		}
+		return s.tokens.DeleteUnused(ctx, u.ID, PurposeResetPassword)
+	})
+}
+
+// issue stores a new token for the user, replacing their unused ones for
+// purpose, and returns its secret.
+func (s *AccountService) issue(ctx context.Context, userID int64, purpose string, ttl time.Duration) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.tokens.DeleteUnused(ctx, userID, purpose); err != nil {
+			return err
+		}
+		return s.tokens.Create(ctx, &Token{
+			UserID:    userID,
+			Purpose:   purpose,
+			Hash:      hashToken(token),
+			ExpiresAt: s.now().UTC().Add(ttl),
+		})
+	})
+	return token, err
+}
+
+func (s *AccountService) consume(ctx context.Context, purpose, token string) (*Token, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+	t, err := s.tokens.Consume(ctx, purpose, hashToken(token), s.now().UTC())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	return t, err
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// link adds token to base as the token query parameter.
+func link(base, token string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base + "?token=" + url.QueryEscape(token)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// describe formats a token lifetime for an email, e.g. "2 days".
+func describe(d time.Duration) string {
+	unit := func(n int64, name string) string {
+		if n == 1 {
+			return "1 " + name
+		}
+		return fmt.Sprintf("%d %ss", n, name)
+	}
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		return unit(int64(d/(24*time.Hour)), "day")
+	case d >= time.Hour && d%time.Hour == 0:
+		return unit(int64(d/time.Hour), "hour")
+	case d >= time.Minute && d%time.Minute == 0:
+		return unit(int64(d/time.Minute), "minute")
+	}
+	return d.String()
+}
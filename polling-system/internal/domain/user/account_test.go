@@ -0,0 +1,227 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"polling-system/internal/platform/mail"
+	"polling-system/internal/platform/validate"
+)
+
+type memoryTokenRepo struct {
+	mu     sync.Mutex
+	tokens map[int64]Token
+	nextID int64
+}
+
+func (r *memoryTokenRepo) Create(ctx context.Context, t *Token) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	t.ID = r.nextID
+	t.CreatedAt = time.Now()
+	r.tokens[t.ID] = *t
+	return nil
+}
+
+func (r *memoryTokenRepo) Consume(ctx context.Context, purpose, hash string, at time.Time) (*Token, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, t := range r.tokens {
+		if t.Hash == hash && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.After(at) {
+			t.UsedAt = &at
+			r.tokens[id] = t
+			return &t, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *memoryTokenRepo) DeleteUnused(ctx context.Context, userID int64, purpose string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, t := range r.tokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			delete(r.tokens, id)
+		}
+	}
+	return nil
+}
+
+type directTx struct{}
+
+func (directTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func newTestAccountService(t *testing.T) (*AccountService, *memoryUserRepo, *mail.Outbox, *User) {
+	t.Helper()
+	users := newMemoryUserRepo()
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cretpass"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	u := &User{Email: "john@example.com", PasswordHash: string(hash), Role: "user", IsActive: true}
+	if err := users.Create(context.Background(), u); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	outbox := mail.NewOutbox()
+	svc := NewAccountService(users, &memoryTokenRepo{tokens: make(map[int64]Token)}, directTx{}, outbox, AccountOptions{
+		Policy:           DefaultPasswordPolicy(),
+		VerifyEmailTTL:   48 * time.Hour,
+		ResetPasswordTTL: time.Hour,
+		VerifyEmailURL:   "https://polls.example.com/verify?src=mail",
+		ResetPasswordURL: "https://polls.example.com/reset",
+	})
+	return svc, users, outbox, u
+}
+
+var linkPattern = regexp.MustCompile(`https://\S+`)
+
+// mailedToken returns the token of the link in the last message sent.
+func mailedToken(t *testing.T, outbox *mail.Outbox) string {
+	t.Helper()
+	msgs := outbox.Messages()
+	if len(msgs) == 0 {
+		t.Fatal("no message sent")
+	}
+	u, err := url.Parse(linkPattern.FindString(msgs[len(msgs)-1].Body))
+	if err != nil {
+		t.Fatalf("parse link: %v", err)
+	}
+	token := u.Query().Get("token")
+	if token == "" {
+		t.Fatalf("no token in %q", msgs[len(msgs)-1].Body)
+	}
+	return token
+}
+
+func TestVerifyEmail(t *testing.T) {
+	svc, users, outbox, u := newTestAccountService(t)
+	ctx := context.Background()
+
+	if err := svc.SendVerification(ctx, u.ID); err != nil {
+		t.Fatalf("send verification: %v", err)
+	}
+	stale := mailedToken(t, outbox)
+	if err := svc.SendVerification(ctx, u.ID); err != nil {
+		t.Fatalf("resend verification: %v", err)
+	}
+	msgs := outbox.Messages()
+	if msgs[1].To != u.Email || !regexp.MustCompile(`src=mail`).MatchString(msgs[1].Body) {
+		t.Fatalf("unexpected message: %+v", msgs[1])
+	}
+	token := mailedToken(t, outbox)
+
+	if err := svc.VerifyEmail(ctx, stale); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected replaced token to be invalid, got %v", err)
+	}
+	if err := svc.VerifyEmail(ctx, token); err != nil {
+		t.Fatalf("verify email: %v", err)
+	}
+	got, _ := users.GetByID(ctx, u.ID)
+	if got.EmailVerifiedAt == nil {
+		t.Fatal("expected email to be verified")
+	}
+	if err := svc.VerifyEmail(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected used token to be invalid, got %v", err)
+	}
+	if err := svc.SendVerification(ctx, u.ID); !errors.Is(err, ErrAlreadyVerified) {
+		t.Fatalf("expected already verified, got %v", err)
+	}
+}
+
+func TestVerifyEmailExpires(t *testing.T) {
+	svc, _, outbox, u := newTestAccountService(t)
+	ctx := context.Background()
+
+	if err := svc.SendVerification(ctx, u.ID); err != nil {
+		t.Fatalf("send verification: %v", err)
+	}
+	svc.now = func() time.Time { return time.Now().Add(49 * time.Hour) }
+	if err := svc.VerifyEmail(ctx, mailedToken(t, outbox)); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected expired token to be invalid, got %v", err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	svc, users, outbox, u := newTestAccountService(t)
+	ctx := context.Background()
+
+	if err := svc.RequestPasswordReset(ctx, u.Email); err != nil {
+		t.Fatalf("request reset: %v", err)
+	}
+	token := mailedToken(t, outbox)
+
+	var invalid validate.Errors
+	if err := svc.ResetPassword(ctx, token, "weak"); !errors.As(err, &invalid) {
+		t.Fatalf("expected policy violation, got %v", err)
+	}
+	if err := svc.ResetPassword(ctx, token, "n3wpassword"); err != nil {
+		t.Fatalf("reset password after rejected attempt: %v", err)
+	}
+	got, _ := users.GetByID(ctx, u.ID)
+	if bcrypt.CompareHashAndPassword([]byte(got.PasswordHash), []byte("n3wpassword")) != nil {
+		t.Fatal("expected new password to be set")
+	}
+	if got.EmailVerifiedAt == nil {
+		t.Fatal("expected reset to verify the email")
+	}
+	if err := svc.ResetPassword(ctx, token, "an0therpassword"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected used token to be invalid, got %v", err)
+	}
+}
+
+func TestRequestPasswordResetDoesNotRevealAccounts(t *testing.T) {
+	svc, users, outbox, u := newTestAccountService(t)
+	ctx := context.Background()
+
+	if err := svc.RequestPasswordReset(ctx, "nobody@example.com"); err != nil {
+		t.Fatalf("unknown email: %v", err)
+	}
+	if err := users.Deactivate(ctx, u.ID); err != nil {
+		t.Fatalf("deactivate: %v", err)
+	}
+	if err := svc.RequestPasswordReset(ctx, u.Email); err != nil {
+		t.Fatalf("inactive user: %v", err)
+	}
+	if n := len(outbox.Messages()); n != 0 {
+		t.Fatalf("expected no mail, got %d", n)
+	}
+}
+
+type failingMailer struct{}
+
+func (failingMailer) Send(context.Context, mail.Message) error {
+	return errors.New("smtp unavailable")
+}
+
+func TestRequestPasswordResetAsync(t *testing.T) {
+	svc, _, outbox, u := newTestAccountService(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The request outlives the context of the caller.
+	svc.RequestPasswordResetAsync(ctx, u.Email)
+	cancel()
+	if err := svc.Wait(context.Background()); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if n := len(outbox.Messages()); n != 1 {
+		t.Fatalf("expected one reset email, got %d", n)
+	}
+
+	// A failure to send is only logged.
+	svc.mailer = failingMailer{}
+	svc.RequestPasswordResetAsync(context.Background(), u.Email)
+	if err := svc.Wait(context.Background()); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+}
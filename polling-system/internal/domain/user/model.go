@@ -12,6 +12,8 @@ type User struct {
 	Role         string    `json:"role"`
 	CreatedAt    time.Time `json:"created_at"`
 	IsActive     bool      `json:"is_active"`
+	// EmailVerifiedAt is when the user proved they own Email; nil until then.
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
 }
 
 type Repository interface {
@@ -21,4 +23,84 @@ type Repository interface {
 	List(ctx context.Context) ([]User, error)
 	UpdateRole(ctx context.Context, id int64, role string) error
 	Deactivate(ctx context.Context, id int64) error
+	SetPassword(ctx context.Context, id int64, passwordHash string) error
+	// MarkEmailVerified records at as the verification time unless the
+	// email is already verified.
+	MarkEmailVerified(ctx context.Context, id int64, at time.Time) error
+}
+
+// Token purposes.
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+)
+
+// Token is a single-use secret mailed to a user. Only its hash is stored.
+type Token struct {
+	ID        int64
+	UserID    int64
+	Purpose   string
+	Hash      string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type TokenRepository interface {
+	Create(ctx context.Context, t *Token) error
+	// Consume marks the unused, unexpired token with hash and purpose as
+	// used at at and returns it, or returns sql.ErrNoRows if there is none.
+	// A token can be consumed once even under concurrent calls.
+	Consume(ctx context.Context, purpose, hash string, at time.Time) (*Token, error)
+	// DeleteUnused deletes the unused tokens of a user for purpose, so that
+	// only the latest link mailed works.
+	DeleteUnused(ctx context.Context, userID int64, purpose string) error
+}
+
+// TxManager runs fn as one unit of work. Repository calls made with the ctx
+// passed to fn commit or roll back together; fn may be run more than once if
+// the transaction has to be retried.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TwoFactor is a user's TOTP enrollment. It is pending until the user
+// confirms a first code, which sets EnabledAt.
+type TwoFactor struct {
+	UserID int64
+	// Secret is the base32 TOTP key. Unlike passwords it must be stored in
+	// a recoverable form to compute codes.
+	Secret    string
+	EnabledAt *time.Time
+	// LastStep is the TOTP time step of the last accepted code. Codes from
+	// that step or earlier are refused, so each code works once.
+	LastStep  int64
+	CreatedAt time.Time
+}
+
+type TwoFactorRepository interface {
+	// Get returns the enrollment of a user, or sql.ErrNoRows.
+	Get(ctx context.Context, userID int64) (*TwoFactor, error)
+	// SavePending stores a pending enrollment, replacing an earlier pending
+	// one. It returns ErrTwoFactorEnabled if the user has 2FA enabled.
+	SavePending(ctx context.Context, t *TwoFactor) error
+	// Enable enables the pending enrollment of a user with step as its last
+	// used step, or returns sql.ErrNoRows if nothing is pending.
+	Enable(ctx context.Context, userID, step int64, at time.Time) error
+	// UseStep records step as used if it is later than the last one used,
+	// or returns sql.ErrNoRows. It is atomic, so a code is accepted once
+	// even under concurrent calls.
+	UseStep(ctx context.Context, userID, step int64) error
+	// Delete removes the enrollment of a user and their recovery codes, or
+	// returns sql.ErrNoRows if there is none.
+	Delete(ctx context.Context, userID int64) error
+	// ReplaceRecoveryCodes replaces all recovery codes of a user with the
+	// given SHA-256 hashes.
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error
+	// UseRecoveryCode marks the unused recovery code with hash as used at
+	// at, or returns sql.ErrNoRows if there is none.
+	UseRecoveryCode(ctx context.Context, userID int64, hash string, at time.Time) error
+	// CountRecoveryCodes returns how many recovery codes of a user are
+	// still unused.
+	CountRecoveryCodes(ctx context.Context, userID int64) (int, error)
 }
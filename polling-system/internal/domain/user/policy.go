@@ -0,0 +1,43 @@
+package user
+
+import (
+	"unicode"
+
+	"polling-system/internal/platform/validate"
+)
+
+// PasswordPolicy is the strength a new password must have. Passwords are
+// never longer than PasswordMaxBytes, which bcrypt would truncate.
+type PasswordPolicy struct {
+	MinLength        int
+	RequireLetter    bool
+	RequireDigit     bool
+	RequireMixedCase bool
+	RequireSymbol    bool
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{MinLength: 8, RequireLetter: true, RequireDigit: true}
+}
+
+// Rules returns the validation rules of the policy.
+func (p PasswordPolicy) Rules() []validate.Rule {
+	rules := []validate.Rule{validate.Required, validate.MinLen(p.MinLength), validate.MaxBytes(PasswordMaxBytes)}
+	if p.RequireLetter {
+		rules = append(rules, validate.Contains("a letter", unicode.IsLetter))
+	}
+	if p.RequireDigit {
+		rules = append(rules, validate.Contains("a digit", unicode.IsDigit))
+	}
+	if p.RequireMixedCase {
+		rules = append(rules, validate.Contains("an uppercase letter", unicode.IsUpper), validate.Contains("a lowercase letter", unicode.IsLower))
+	}
+	if p.RequireSymbol {
+		rules = append(rules, validate.Contains("a symbol", isSymbol))
+	}
+	return rules
+}
+
+func isSymbol(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+}
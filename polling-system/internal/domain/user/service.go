@@ -2,9 +2,13 @@ package user
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/crypto/bcrypt"
+
+	"polling-system/internal/platform/tracing"
 )
 
 var (
@@ -13,17 +17,26 @@ var (
 	ErrInactiveUser       = errors.New("inactive user")
 )
 
+// dummyHash is compared against when the email is unknown, so that a login
+// takes as long whether or not the account exists. Its cost matches the
+// hashes of registered passwords.
+const dummyHash = "$2a$10$x4L2pMWlCX11AvS2dtcZYeW3IfTlymmZIuLZz0zZLozY22MhPMI2i"
+
 type Service struct {
-	repo Repository
+	repo   Repository
+	policy PasswordPolicy
 }
 
-func NewService(repo Repository) *Service {
-	return &Service{repo: repo}
+func NewService(repo Repository, policy PasswordPolicy) *Service {
+	return &Service{repo: repo, policy: policy}
 }
 
-func (s *Service) Register(ctx context.Context, email, password string) (*User, error) {
-	if email == "" || password == "" {
-		return nil, errors.New("email and password required")
+func (s *Service) Register(ctx context.Context, email, password string) (_ *User, err error) {
+	ctx, span := tracing.Start(ctx, "user.Service.Register")
+	defer func() { tracing.End(span, err) }()
+
+	if err := ValidateRegistration(email, password, s.policy); err != nil {
+		return nil, err
 	}
 
 	if _, err := s.repo.GetByEmail(ctx, email); err == nil {
@@ -49,38 +62,65 @@ func (s *Service) Register(ctx context.Context, email, password string) (*User,
 	return u, nil
 }
 
-func (s *Service) Login(ctx context.Context, email, password string) (*User, error) {
+func (s *Service) Login(ctx context.Context, email, password string) (_ *User, err error) {
+	ctx, span := tracing.Start(ctx, "user.Service.Login")
+	defer func() { tracing.End(span, err) }()
+
 	u, err := s.repo.GetByEmail(ctx, email)
-	if err != nil {
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte(password))
 		return nil, ErrInvalidCredentials
 	}
-
-	if !u.IsActive {
-		return nil, ErrInactiveUser
+	if err != nil {
+		return nil, err
 	}
 
+	// The password is checked first so that only its owner learns that an
+	// account is inactive.
 	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
 		return nil, ErrInvalidCredentials
 	}
+	if !u.IsActive {
+		return nil, ErrInactiveUser
+	}
 
 	return u, nil
 }
 
-func (s *Service) List(ctx context.Context) ([]User, error) {
+func (s *Service) List(ctx context.Context) (_ []User, err error) {
+	ctx, span := tracing.Start(ctx, "user.Service.List")
+	defer func() { tracing.End(span, err) }()
+
 	return s.repo.List(ctx)
 }
 
-func (s *Service) UpdateRole(ctx context.Context, id int64, role string) error {
-	if role != "admin" && role != "user" {
-		return errors.New("invalid role")
+func (s *Service) UpdateRole(ctx context.Context, id int64, role string) (err error) {
+	ctx, span := tracing.Start(ctx, "user.Service.UpdateRole", attribute.Int64("user.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	if err := ValidateRole(role); err != nil {
+		return err
 	}
 	return s.repo.UpdateRole(ctx, id, role)
 }
 
-func (s *Service) GetByID(ctx context.Context, id int64) (*User, error) {
+func (s *Service) GetByID(ctx context.Context, id int64) (_ *User, err error) {
+	ctx, span := tracing.Start(ctx, "user.Service.GetByID", attribute.Int64("user.id", id))
+	defer func() { tracing.End(span, err) }()
+
 	return s.repo.GetByID(ctx, id)
 }
 
-func (s *Service) Deactivate(ctx context.Context, id int64) error {
+func (s *Service) GetByEmail(ctx context.Context, email string) (_ *User, err error) {
+	ctx, span := tracing.Start(ctx, "user.Service.GetByEmail")
+	defer func() { tracing.End(span, err) }()
+
+	return s.repo.GetByEmail(ctx, email)
+}
+
+func (s *Service) Deactivate(ctx context.Context, id int64) (err error) {
+	ctx, span := tracing.Start(ctx, "user.Service.Deactivate", attribute.Int64("user.id", id))
+	defer func() { tracing.End(span, err) }()
+
 	return s.repo.Deactivate(ctx, id)
 }
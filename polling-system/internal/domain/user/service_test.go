@@ -7,6 +7,10 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"polling-system/internal/platform/validate"
 )
 
 type memoryUserRepo struct {
@@ -90,26 +94,50 @@ func (r *memoryUserRepo) Deactivate(ctx context.Context, id int64) error {
 	return nil
 }
 
+func (r *memoryUserRepo) SetPassword(ctx context.Context, id int64, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	u.PasswordHash = hash
+	return nil
+}
+
+func (r *memoryUserRepo) MarkEmailVerified(ctx context.Context, id int64, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if u.EmailVerifiedAt == nil {
+		u.EmailVerifiedAt = &at
+	}
+	return nil
+}
+
 func TestRegisterAndLogin(t *testing.T) {
 	repo := newMemoryUserRepo()
-	svc := NewService(repo)
+	svc := NewService(repo, DefaultPasswordPolicy())
 	ctx := context.Background()
 
-	u, err := svc.Register(ctx, "john@example.com", "s3cret")
+	u, err := svc.Register(ctx, "john@example.com", "s3cretpass")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	if u.Role != "user" {
 		t.Fatalf("expected role user, got %s", u.Role)
 	}
-	if u.PasswordHash == "s3cret" || u.PasswordHash == "" {
+	if u.PasswordHash == "s3cretpass" || u.PasswordHash == "" {
 		t.Fatalf("password should be hashed")
 	}
 
-	if _, err := svc.Login(ctx, "john@example.com", "s3cret"); err != nil {
+	if _, err := svc.Login(ctx, "john@example.com", "s3cretpass"); err != nil {
 		t.Fatalf("login failed: %v", err)
 	}
-	if _, err := svc.Register(ctx, "john@example.com", "another"); !errors.Is(err, ErrEmailTaken) {
+	if _, err := svc.Register(ctx, "john@example.com", "another1pass"); !errors.Is(err, ErrEmailTaken) {
 		t.Fatalf("expected email taken error")
 	}
 	if _, err := svc.Login(ctx, "john@example.com", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
@@ -119,7 +147,54 @@ func TestRegisterAndLogin(t *testing.T) {
 	if err := svc.Deactivate(ctx, u.ID); err != nil {
 		t.Fatalf("deactivate: %v", err)
 	}
-	if _, err := svc.Login(ctx, "john@example.com", "s3cret"); !errors.Is(err, ErrInactiveUser) {
+	if _, err := svc.Login(ctx, "john@example.com", "s3cretpass"); !errors.Is(err, ErrInactiveUser) {
 		t.Fatalf("expected inactive user error")
 	}
 }
+
+func TestRegisterValidatesEmailAndPassword(t *testing.T) {
+	svc := NewService(newMemoryUserRepo(), DefaultPasswordPolicy())
+
+	_, err := svc.Register(context.Background(), "john@", "short")
+	var invalid validate.Errors
+	if !errors.As(err, &invalid) || len(invalid) != 2 {
+		t.Fatalf("expected email and password violations, got %v", err)
+	}
+	if invalid[0].Field != "email" || invalid[1].Field != "password" || invalid[1].Code != "too_short" {
+		t.Fatalf("unexpected violations: %+v", invalid)
+	}
+
+	if _, err := svc.Register(context.Background(), "john@example.com", "lettersonly"); !errors.As(err, &invalid) || invalid[0].Code != "too_weak" {
+		t.Fatalf("expected weak password violation, got %v", err)
+	}
+	if err := svc.UpdateRole(context.Background(), 1, "owner"); !errors.As(err, &invalid) || invalid[0].Field != "role" {
+		t.Fatalf("expected role violation, got %v", err)
+	}
+}
+
+func TestLoginDoesNotRevealAccounts(t *testing.T) {
+	if cost, err := bcrypt.Cost([]byte(dummyHash)); err != nil || cost != bcrypt.DefaultCost {
+		t.Fatalf("dummy hash must use the default cost, got %d (%v)", cost, err)
+	}
+
+	repo := newMemoryUserRepo()
+	svc := NewService(repo, DefaultPasswordPolicy())
+	ctx := context.Background()
+	u, err := svc.Register(ctx, "john@example.com", "s3cretpass")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := svc.Deactivate(ctx, u.ID); err != nil {
+		t.Fatalf("deactivate: %v", err)
+	}
+
+	if _, err := svc.Login(ctx, "nobody@example.com", "s3cretpass"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("unknown email: expected invalid credentials, got %v", err)
+	}
+	if _, err := svc.Login(ctx, "john@example.com", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("inactive account with a wrong password: expected invalid credentials, got %v", err)
+	}
+	if _, err := svc.Login(ctx, "john@example.com", "s3cretpass"); !errors.Is(err, ErrInactiveUser) {
+		t.Fatalf("inactive account: expected inactive user, got %v", err)
+	}
+}
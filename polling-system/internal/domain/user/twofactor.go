@@ -0,0 +1,330 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"polling-system/internal/platform/totp"
+	"polling-system/internal/platform/tracing"
+)
+
+var (
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled  = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotEnrolled = errors.New("no two-factor enrollment to confirm")
+	ErrTwoFactorRequired    = errors.New("two-factor authentication is required for this role")
+	ErrInvalidCode          = errors.New("invalid or already used code")
+)
+
+const (
+	// RecoveryCodeCount is how many recovery codes a user gets at a time.
+	RecoveryCodeCount = 10
+	// codeSkew is how many 30 second steps a TOTP code may be off by, to
+	// allow for clock drift and slow typing.
+	codeSkew = 1
+	// recoveryAlphabet is Crockford's base32, which leaves out letters
+	// easily confused with digits.
+	recoveryAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+)
+
+// Enrollment is what an authenticator app needs to start generating codes.
+type Enrollment struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// provisioning URI, usually shown as a QR code.
+	URI string `json:"uri"`
+}
+
+// TwoFactorStatus describes the two-factor setup of a user.
+type TwoFactorStatus struct {
+	Enabled   bool       `json:"enabled"`
+	EnabledAt *time.Time `json:"enabled_at,omitempty"`
+	// Pending is true between enrolling and confirming the first code.
+	Pending bool `json:"pending"`
+	// Required is true when the user's role must use two-factor
+	// authentication.
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TwoFactorService manages TOTP two-factor authentication: enrolling an
+// authenticator app, checking codes, and the one-time recovery codes that
+// stand in for a lost device.
+type TwoFactorService struct {
+	users         Repository
+	repo          TwoFactorRepository
+	tx            TxManager
+	issuer        string
+	requiredRoles []string
+	now           func() time.Time
+}
+
+func NewTwoFactorService(users Repository, repo TwoFactorRepository, tx TxManager, issuer string, requiredRoles []string) *TwoFactorService {
+	return &TwoFactorService{
+		users:         users,
+		repo:          repo,
+		tx:            tx,
+		issuer:        issuer,
+		requiredRoles: requiredRoles,
+		now:           time.Now,
+	}
+}
+
+// Required reports whether users with role must use two-factor
+// authentication.
+func (s *TwoFactorService) Required(role string) bool {
+	return slices.Contains(s.requiredRoles, role)
+}
+
+// Enabled reports whether the user has confirmed a two-factor enrollment.
+func (s *TwoFactorService) Enabled(ctx context.Context, userID int64) (bool, error) {
+	t, err := s.repo.Get(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return t.EnabledAt != nil, nil
+}
+
+func (s *TwoFactorService) Status(ctx context.Context, userID int64) (st *TwoFactorStatus, err error) {
+	ctx, span := tracing.Start(ctx, "user.TwoFactorService.Status", attribute.Int64("user.id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	st = &TwoFactorStatus{Required: s.Required(u.Role)}
+	t, err := s.repo.Get(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	st.Enabled, st.EnabledAt, st.Pending = t.EnabledAt != nil, t.EnabledAt, t.EnabledAt == nil
+	if st.Enabled {
+		if st.RecoveryCodesLeft, err = s.repo.CountRecoveryCodes(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// Enroll creates a new TOTP secret for the user. It stays pending, and
+// codes are not asked for at login, until Confirm is called with a code
+// generated from it. Enrolling again replaces a pending secret.
+func (s *TwoFactorService) Enroll(ctx context.Context, userID int64) (e *Enrollment, err error) {
+	ctx, span := tracing.Start(ctx, "user.TwoFactorService.Enroll", attribute.Int64("user.id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SavePending(ctx, &TwoFactor{UserID: userID, Secret: secret}); err != nil {
+		return nil, err
+	}
+	return &Enrollment{Secret: secret, URI: totp.URI(s.issuer, u.Email, secret)}, nil
+}
+
+// Confirm enables the pending enrollment of the user if code matches its
+// secret, and returns a fresh set of recovery codes. The codes are not
+// stored in plain text, so this is the only time they can be shown.
+func (s *TwoFactorService) Confirm(ctx context.Context, userID int64, code string) (codes []string, err error) {
+	ctx, span := tracing.Start(ctx, "user.TwoFactorService.Confirm", attribute.Int64("user.id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		t, err := s.repo.Get(ctx, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTwoFactorNotEnrolled
+		}
+		if err != nil {
+			return err
+		}
+		if t.EnabledAt != nil {
+			return ErrTwoFactorEnabled
+		}
+		now := s.now().UTC()
+		digits, _ := totpCode(code)
+		step, ok := totp.Match(t.Secret, digits, now, codeSkew)
+		if !ok {
+			return ErrInvalidCode
+		}
+		if err := s.repo.Enable(ctx, userID, step, now); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrTwoFactorNotEnrolled
+			}
+			return err
+		}
+		codes, err = s.replaceRecoveryCodes(ctx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// Verify checks a TOTP code or an unused recovery code for the user and
+// uses it up. It reports whether a recovery code was used.
+func (s *TwoFactorService) Verify(ctx context.Context, userID int64, code string) (recovery bool, err error) {
+	ctx, span := tracing.Start(ctx, "user.TwoFactorService.Verify", attribute.Int64("user.id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	t, err := s.repo.Get(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return false, err
+	}
+	if t.EnabledAt == nil {
+		return false, ErrTwoFactorNotEnabled
+	}
+
+	now := s.now().UTC()
+	if digits, ok := totpCode(code); ok {
+		step, ok := totp.Match(t.Secret, digits, now, codeSkew)
+		if !ok {
+			return false, ErrInvalidCode
+		}
+		if err := s.repo.UseStep(ctx, userID, step); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return false, ErrInvalidCode
+			}
+			return false, err
+		}
+		return false, nil
+	}
+
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return false, ErrInvalidCode
+	}
+	if err := s.repo.UseRecoveryCode(ctx, userID, hashToken(normalized), now); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrInvalidCode
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Disable turns two-factor authentication off after checking a code. Users
+// whose role requires it cannot turn it off.
+func (s *TwoFactorService) Disable(ctx context.Context, userID int64, code string) (err error) {
+	ctx, span := tracing.Start(ctx, "user.TwoFactorService.Disable", attribute.Int64("user.id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if s.Required(u.Role) {
+		return ErrTwoFactorRequired
+	}
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := s.Verify(ctx, userID, code); err != nil {
+			return err
+		}
+		return s.repo.Delete(ctx, userID)
+	})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user after
+// checking a code, and returns the new ones.
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) (codes []string, err error) {
+	ctx, span := tracing.Start(ctx, "user.TwoFactorService.RegenerateRecoveryCodes", attribute.Int64("user.id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := s.Verify(ctx, userID, code); err != nil {
+			return err
+		}
+		codes, err = s.replaceRecoveryCodes(ctx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// Reset removes the two-factor setup of a user who lost both their device
+// and their recovery codes. It is meant for admins and asks for no code.
+func (s *TwoFactorService) Reset(ctx context.Context, userID int64) (err error) {
+	ctx, span := tracing.Start(ctx, "user.TwoFactorService.Reset", attribute.Int64("user.id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.users.GetByID(ctx, userID); err != nil {
+		return err
+	}
+	err = s.repo.Delete(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTwoFactorNotEnabled
+	}
+	return err
+}
+
+func (s *TwoFactorService) replaceRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i], hashes[i] = code, hashToken(normalizeRecoveryCode(code))
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// newRecoveryCode returns a code like "7kq2m-xh3ad": ten characters, 50
+// bits, split in two for reading aloud.
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = recoveryAlphabet[b[i]%32]
+	}
+	return string(b[:5]) + "-" + string(b[5:]), nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes, so a code typed
+// slightly differently from how it was shown still matches.
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+}
+
+// totpCode returns code without spaces, and whether it has the form of a
+// TOTP code rather than a recovery code.
+func totpCode(code string) (string, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totp.Digits {
+		return code, false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return code, false
+		}
+	}
+	return code, true
+}
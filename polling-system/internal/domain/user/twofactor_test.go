@@ -0,0 +1,273 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"polling-system/internal/platform/totp"
+)
+
+type memoryTwoFactorRepo struct {
+	mu      sync.Mutex
+	entries map[int64]TwoFactor
+	// codes maps a user to their recovery code hashes and whether each is
+	// used.
+	codes map[int64]map[string]bool
+}
+
+func newMemoryTwoFactorRepo() *memoryTwoFactorRepo {
+	return &memoryTwoFactorRepo{entries: make(map[int64]TwoFactor), codes: make(map[int64]map[string]bool)}
+}
+
+func (r *memoryTwoFactorRepo) Get(ctx context.Context, userID int64) (*TwoFactor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.entries[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &t, nil
+}
+
+func (r *memoryTwoFactorRepo) SavePending(ctx context.Context, t *TwoFactor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.entries[t.UserID]; ok && existing.EnabledAt != nil {
+		return ErrTwoFactorEnabled
+	}
+	t.EnabledAt, t.LastStep, t.CreatedAt = nil, 0, time.Now()
+	r.entries[t.UserID] = *t
+	return nil
+}
+
+func (r *memoryTwoFactorRepo) Enable(ctx context.Context, userID, step int64, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.entries[userID]
+	if !ok || t.EnabledAt != nil {
+		return sql.ErrNoRows
+	}
+	t.EnabledAt, t.LastStep = &at, step
+	r.entries[userID] = t
+	return nil
+}
+
+func (r *memoryTwoFactorRepo) UseStep(ctx context.Context, userID, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.entries[userID]
+	if !ok || t.EnabledAt == nil || t.LastStep >= step {
+		return sql.ErrNoRows
+	}
+	t.LastStep = step
+	r.entries[userID] = t
+	return nil
+}
+
+func (r *memoryTwoFactorRepo) Delete(ctx context.Context, userID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[userID]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.entries, userID)
+	delete(r.codes, userID)
+	return nil
+}
+
+func (r *memoryTwoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codes[userID] = make(map[string]bool)
+	for _, h := range hashes {
+		r.codes[userID][h] = false
+	}
+	return nil
+}
+
+func (r *memoryTwoFactorRepo) UseRecoveryCode(ctx context.Context, userID int64, hash string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	used, ok := r.codes[userID][hash]
+	if !ok || used {
+		return sql.ErrNoRows
+	}
+	r.codes[userID][hash] = true
+	return nil
+}
+
+func (r *memoryTwoFactorRepo) CountRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, used := range r.codes[userID] {
+		if !used {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func newTestTwoFactorService(t *testing.T, role string, requiredRoles ...string) (*TwoFactorService, *User, *time.Time) {
+	t.Helper()
+	users := newMemoryUserRepo()
+	u := &User{Email: "john@example.com", Role: role, IsActive: true}
+	if err := users.Create(context.Background(), u); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	clock := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewTwoFactorService(users, newMemoryTwoFactorRepo(), directTx{}, "Polls", requiredRoles)
+	svc.now = func() time.Time { return clock }
+	return svc, u, &clock
+}
+
+func codeAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	code, err := totp.Code(secret, totp.Step(at))
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+	return code
+}
+
+func TestTwoFactorEnrollment(t *testing.T) {
+	svc, u, clock := newTestTwoFactorService(t, "user")
+	ctx := context.Background()
+
+	e, err := svc.Enroll(ctx, u.ID)
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	if !strings.HasPrefix(e.URI, "otpauth://totp/Polls:john@example.com?") || !strings.Contains(e.URI, "secret="+e.Secret) {
+		t.Fatalf("unexpected provisioning URI %s", e.URI)
+	}
+	if _, err := svc.Verify(ctx, u.ID, codeAt(t, e.Secret, *clock)); !errors.Is(err, ErrTwoFactorNotEnabled) {
+		t.Fatalf("pending enrollment should not verify codes, got %v", err)
+	}
+	if _, err := svc.Confirm(ctx, u.ID, "000000"); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected wrong code to be rejected, got %v", err)
+	}
+
+	codes, err := svc.Confirm(ctx, u.ID, codeAt(t, e.Secret, *clock))
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("expected %d recovery codes, got %d", RecoveryCodeCount, len(codes))
+	}
+	if _, err := svc.Enroll(ctx, u.ID); !errors.Is(err, ErrTwoFactorEnabled) {
+		t.Fatalf("expected enrolling twice to fail, got %v", err)
+	}
+
+	st, err := svc.Status(ctx, u.ID)
+	if err != nil || !st.Enabled || st.Pending || st.RecoveryCodesLeft != RecoveryCodeCount {
+		t.Fatalf("unexpected status %+v, err %v", st, err)
+	}
+}
+
+func TestTwoFactorVerify(t *testing.T) {
+	svc, u, clock := newTestTwoFactorService(t, "user")
+	ctx := context.Background()
+	e, err := svc.Enroll(ctx, u.ID)
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	codes, err := svc.Confirm(ctx, u.ID, codeAt(t, e.Secret, *clock))
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+
+	// The code used to confirm cannot be replayed.
+	if _, err := svc.Verify(ctx, u.ID, codeAt(t, e.Secret, *clock)); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected replayed code to be rejected, got %v", err)
+	}
+
+	*clock = clock.Add(totp.Period)
+	if recovery, err := svc.Verify(ctx, u.ID, codeAt(t, e.Secret, *clock)); err != nil || recovery {
+		t.Fatalf("verify next code: recovery %v, err %v", recovery, err)
+	}
+	// One step of skew is allowed, but only for steps not used yet.
+	*clock = clock.Add(totp.Period)
+	if _, err := svc.Verify(ctx, u.ID, codeAt(t, e.Secret, clock.Add(-totp.Period))); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected used step to be rejected, got %v", err)
+	}
+	if _, err := svc.Verify(ctx, u.ID, codeAt(t, e.Secret, clock.Add(totp.Period))); err != nil {
+		t.Fatalf("expected code one step ahead to verify, got %v", err)
+	}
+	if _, err := svc.Verify(ctx, u.ID, codeAt(t, e.Secret, clock.Add(3*totp.Period))); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected code far ahead to be rejected, got %v", err)
+	}
+
+	// Recovery codes work once, however they are typed.
+	typed := " " + strings.ToUpper(strings.ReplaceAll(codes[0], "-", " ")) + " "
+	if recovery, err := svc.Verify(ctx, u.ID, typed); err != nil || !recovery {
+		t.Fatalf("verify recovery code: recovery %v, err %v", recovery, err)
+	}
+	if _, err := svc.Verify(ctx, u.ID, codes[0]); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected used recovery code to be rejected, got %v", err)
+	}
+	st, err := svc.Status(ctx, u.ID)
+	if err != nil || st.RecoveryCodesLeft != RecoveryCodeCount-1 {
+		t.Fatalf("expected one recovery code used, got %+v, err %v", st, err)
+	}
+
+	*clock = clock.Add(5 * totp.Period)
+	fresh, err := svc.RegenerateRecoveryCodes(ctx, u.ID, codeAt(t, e.Secret, *clock))
+	if err != nil {
+		t.Fatalf("regenerate: %v", err)
+	}
+	if _, err := svc.Verify(ctx, u.ID, codes[1]); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected old recovery codes to be replaced, got %v", err)
+	}
+	if _, err := svc.Verify(ctx, u.ID, fresh[0]); err != nil {
+		t.Fatalf("verify new recovery code: %v", err)
+	}
+}
+
+func TestTwoFactorDisable(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		role     string
+		required []string
+		wantErr  error
+	}{
+		{"optional", "user", []string{"admin"}, nil},
+		{"required", "admin", []string{"admin"}, ErrTwoFactorRequired},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc, u, clock := newTestTwoFactorService(t, tc.role, tc.required...)
+			ctx := context.Background()
+			e, err := svc.Enroll(ctx, u.ID)
+			if err != nil {
+				t.Fatalf("enroll: %v", err)
+			}
+			codes, err := svc.Confirm(ctx, u.ID, codeAt(t, e.Secret, *clock))
+			if err != nil {
+				t.Fatalf("confirm: %v", err)
+			}
+
+			if err := svc.Disable(ctx, u.ID, codes[0]); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("disable: expected %v, got %v", tc.wantErr, err)
+			}
+			enabled, err := svc.Enabled(ctx, u.ID)
+			if err != nil || enabled != (tc.wantErr != nil) {
+				t.Fatalf("unexpected enabled %v after disable, err %v", enabled, err)
+			}
+
+			// An admin can always reset it.
+			if tc.wantErr != nil {
+				if err := svc.Reset(ctx, u.ID); err != nil {
+					t.Fatalf("reset: %v", err)
+				}
+			}
+			if err := svc.Reset(ctx, u.ID); !errors.Is(err, ErrTwoFactorNotEnabled) {
+				t.Fatalf("expected reset without 2FA to fail, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package user
+
+import "polling-system/internal/platform/validate"
+
+const (
+	EmailMaxLen = 254
+	// PasswordMaxBytes bounds new passwords; bcrypt ignores everything past
+	// 72 bytes.
+	PasswordMaxBytes = 72
+)
+
+// Roles are the roles a user can have.
+var Roles = []string{"admin", "user"}
+
+// ValidateRegistration checks the email and password of a new account.
+func ValidateRegistration(email, password string, policy PasswordPolicy) error {
+	v := validate.New()
+	v.String("email", email, validate.Required, validate.MaxLen(EmailMaxLen), validate.Email)
+	v.String("password", password, policy.Rules()...)
+	return v.Err()
+}
+
+// ValidatePassword checks a new password against policy.
+func ValidatePassword(password string, policy PasswordPolicy) error {
+	v := validate.New()
+	v.String("password", password, policy.Rules()...)
+	return v.Err()
+}
+
+// ValidateRole checks that role is one of Roles.
+func ValidateRole(role string) error {
+	v := validate.New()
+	v.String("role", role, validate.Required, validate.OneOf(Roles...))
+	return v.Err()
+}
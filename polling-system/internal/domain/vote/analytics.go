@@ -0,0 +1,162 @@
+package vote
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"polling-system/internal/platform/tracing"
+)
+
+var ErrInvalidBucket = errors.New("invalid analytics bucket")
+
+const (
+	BucketMinute = "minute"
+	BucketHour   = "hour"
+	BucketDay    = "day"
+)
+
+// heavyPollThreshold is the vote count above which analytics are served
+// from the pre-aggregated rollup table instead of scanning votes.
+const heavyPollThreshold = 5000
+
+type SeriesPoint struct {
+	Bucket     time.Time `json:"bucket"`
+	Votes      int64     `json:"votes"`
+	Cumulative int64     `json:"cumulative"`
+}
+
+type OptionSeries struct {
+	OptionID int64         `json:"option_id"`
+	Points   []SeriesPoint `json:"points"`
+}
+
+type Analytics struct {
+	PollID            int64          `json:"poll_id"`
+	Bucket            string         `json:"bucket"`
+	Source            string         `json:"source"`
+	TotalVotes        int64          `json:"total_votes"`
+	EligibleVoters    int64          `json:"eligible_voters"`
+	ParticipationRate float64        `json:"participation_rate"`
+	PeakHour          *time.Time     `json:"peak_hour,omitempty"`
+	PeakHourVotes     int64          `json:"peak_hour_votes"`
+	Total             []SeriesPoint  `json:"total"`
+	Options           []OptionSeries `json:"options"`
+}
+
+func ValidBucket(bucket string) bool {
+	return bucket == BucketMinute || bucket == BucketHour || bucket == BucketDay
+}
+
+// Analytics returns the votes of a poll bucketed over time together with
+// cumulative curves, the busiest hour and the participation rate.
+func (s *Service) Analytics(ctx context.Context, pollID int64, bucket string) (_ *Analytics, err error) {
+	ctx, span := tracing.Start(ctx, "vote.Service.Analytics", attribute.Int64("poll.id", pollID), attribute.String("analytics.bucket", bucket))
+	defer func() { tracing.End(span, err) }()
+
+	if !ValidBucket(bucket) {
+		return nil, ErrInvalidBucket
+	}
+
+	if _, err := s.repo.GetPollStatus(ctx, pollID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPollNotFound
+		}
+		return nil, err
+	}
+
+	_, aggregated, err := s.repo.AggregatedByPoll(ctx, pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	source := "votes"
+	series := s.repo.VotesTimeSeries
+	if aggregated >= heavyPollThreshold {
+		source = "rollup"
+		series = s.repo.RollupTimeSeries
+	}
+
+	counts, err := series(ctx, pollID, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	hourly := counts
+	if bucket != BucketHour {
+		hourly, err = series(ctx, pollID, BucketHour)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	eligible, err := s.repo.CountEligibleVoters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Analytics{
+		PollID:         pollID,
+		Bucket:         bucket,
+		Source:         source,
+		EligibleVoters: eligible,
+		Total:          totalSeries(counts),
+		Options:        optionSeries(counts),
+	}
+	if n := len(a.Total); n > 0 {
+		a.TotalVotes = a.Total[n-1].Cumulative
+	}
+	if eligible > 0 {
+		a.ParticipationRate = float64(a.TotalVotes) * 100.0 / float64(eligible)
+	}
+	for _, p := range totalSeries(hourly) {
+		if p.Votes > a.PeakHourVotes {
+			peak := p.Bucket
+			a.PeakHour = &peak
+			a.PeakHourVotes = p.Votes
+		}
+	}
+
+	return a, nil
+}
+
+func totalSeries(counts []BucketCount) []SeriesPoint {
+	byBucket := make(map[time.Time]int64)
+	for _, c := range counts {
+		byBucket[c.Bucket] += c.Votes
+	}
+
+	points := make([]SeriesPoint, 0, len(byBucket))
+	for b, v := range byBucket {
+		points = append(points, SeriesPoint{Bucket: b, Votes: v})
+	}
+	return accumulate(points)
+}
+
+func optionSeries(counts []BucketCount) []OptionSeries {
+	byOption := make(map[int64][]SeriesPoint)
+	for _, c := range counts {
+		byOption[c.OptionID] = append(byOption[c.OptionID], SeriesPoint{Bucket: c.Bucket, Votes: c.Votes})
+	}
+
+	res := make([]OptionSeries, 0, len(byOption))
+	for optionID, points := range byOption {
+		res = append(res, OptionSeries{OptionID: optionID, Points: accumulate(points)})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].OptionID < res[j].OptionID })
+	return res
+}
+
+func accumulate(points []SeriesPoint) []SeriesPoint {
+	sort.Slice(points, func(i, j int) bool { return points[i].Bucket.Before(points[j].Bucket) })
+	var running int64
+	for i := range points {
+		running += points[i].Votes
+		points[i].Cumulative = running
+	}
+	return points
+}
@@ -0,0 +1,92 @@
+package vote
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ResultsCache stores computed poll results. Implementations must be safe for
+// concurrent use and treat cached values as read-only.
+//
+// Results computed from a read that raced with an invalidation must not be
+// stored, so callers read the poll's generation before computing results and
+// pass it to Set, which drops the results if the poll has been invalidated
+// since.
+type ResultsCache interface {
+	Get(ctx context.Context, pollID int64) (*PollResults, bool, error)
+	// Generation returns a value that changes whenever the results of pollID
+	// are invalidated.
+	Generation(ctx context.Context, pollID int64) (int64, error)
+	// Set stores res unless the results of pollID were invalidated after
+	// Generation returned gen.
+	Set(ctx context.Context, pollID, gen int64, res *PollResults, ttl time.Duration) error
+	Invalidate(ctx context.Context, pollID int64) error
+}
+
+// MemoryCache is a process-local ResultsCache.
+type MemoryCache struct {
+	mu      sync.RWMutex
+	entries map[int64]cachedResult
+	// A poll's generation is its invalidation count plus the number of
+	// times the whole cache was cleared, so that either makes it change.
+	gens    map[int64]int64
+	cleared int64
+}
+
+type cachedResult struct {
+	results   *PollResults
+	expiresAt time.Time
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		entries: make(map[int64]cachedResult),
+		gens:    make(map[int64]int64),
+	}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, pollID int64) (*PollResults, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res, ok := c.entries[pollID]
+	if !ok || time.Now().After(res.expiresAt) {
+		return nil, false, nil
+	}
+	return res.results, true, nil
+}
+
+func (c *MemoryCache) Generation(ctx context.Context, pollID int64) (int64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cleared + c.gens[pollID], nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, pollID, gen int64, res *PollResults, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cleared+c.gens[pollID] != gen {
+		return nil
+	}
+	c.entries[pollID] = cachedResult{
+		results:   res,
+		expiresAt: time.Now().Add(ttl),
+	}
+	return nil
+}
+
+func (c *MemoryCache) Invalidate(ctx context.Context, pollID int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, pollID)
+	c.gens[pollID]++
+	return nil
+}
+
+// Clear drops every entry.
+func (c *MemoryCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+	c.cleared++
+}
@@ -13,10 +13,49 @@ type Vote struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// OptionInfo is the option metadata joined into poll results.
+type OptionInfo struct {
+	ID       int64
+	Text     string
+	Position int
+}
+
+// BucketCount is the number of votes an option received within one time bucket.
+type BucketCount struct {
+	Bucket   time.Time
+	OptionID int64
+	Votes    int64
+}
+
+// AggregateDelta is a number of votes to add to one option's aggregated
+// count.
+type AggregateDelta struct {
+	PollID   int64
+	OptionID int64
+	Votes    int64
+}
+
+// TxManager runs fn as one unit of work. Repository calls made with the ctx
+// passed to fn commit or roll back together; fn may be run more than once if
+// the transaction has to be retried.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
 type Repository interface {
 	Create(ctx context.Context, v *Vote) error
 	CountByPoll(ctx context.Context, pollID int64) (map[int64]int64, int64, error)
 	AggregatedByPoll(ctx context.Context, pollID int64) (map[int64]int64, int64, error)
 	IncrementAggregated(ctx context.Context, pollID, optionID int64) error
+	// AddAggregated applies all deltas in one statement; either every delta
+	// is added or none is. Each (poll, option) pair may appear only once.
+	AddAggregated(ctx context.Context, deltas []AggregateDelta) error
 	GetPollStatus(ctx context.Context, pollID int64) (string, error)
+	// LockPollStatus is GetPollStatus that also locks the poll row until the
+	// surrounding transaction ends, so the status cannot change under it.
+	LockPollStatus(ctx context.Context, pollID int64) (string, error)
+	ListOptions(ctx context.Context, pollID int64) ([]OptionInfo, error)
+	VotesTimeSeries(ctx context.Context, pollID int64, bucket string) ([]BucketCount, error)
+	RollupTimeSeries(ctx context.Context, pollID int64, bucket string) ([]BucketCount, error)
+	CountEligibleVoters(ctx context.Context) (int64, error)
 }
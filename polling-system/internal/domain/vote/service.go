@@ -4,8 +4,17 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"sync"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
+
+	"polling-system/internal/platform/tracing"
+	"polling-system/internal/stats"
 )
 
 var (
@@ -15,46 +24,75 @@ var (
 	ErrPollNotFound    = errors.New("poll not found")
 )
 
+const DefaultCacheTTL = 10 * time.Second
+
 type Service struct {
 	repo     Repository
-	cacheTTL time.Duration
-	cache    map[int64]cachedResult
-	mu       sync.RWMutex
+	tx       TxManager
+	cache    ResultsCache
+	cacheTTL atomic.Int64
+	loads    singleflight.Group
 }
 
-type cachedResult struct {
-	results   []Result
-	total     int64
-	expiresAt time.Time
+// NewService builds a Service without a transaction manager: each repository
+// call runs on its own.
+func NewService(repo Repository) *Service {
+	return NewServiceWithCache(repo, noTx{}, NewMemoryCache(), DefaultCacheTTL)
 }
 
-func NewService(repo Repository) *Service {
-	return &Service{
-		repo:     repo,
-		cacheTTL: 10 * time.Second,
-		cache:    make(map[int64]cachedResult),
+// NewServiceWithCache builds a Service that runs votes through tx and stores
+// results in cache for ttl. Concurrent cache misses for the same poll share a
+// single database load.
+func NewServiceWithCache(repo Repository, tx TxManager, cache ResultsCache, ttl time.Duration) *Service {
+	s := &Service{
+		repo:  repo,
+		tx:    tx,
+		cache: cache,
 	}
+	s.SetCacheTTL(ttl)
+	return s
 }
 
-func (s *Service) Vote(ctx context.Context, pollID, optionID, userID int64) error {
-	status, err := s.repo.GetPollStatus(ctx, pollID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return ErrPollNotFound
-		}
-		return err
-	}
-	if status != "active" {
-		return ErrPollNotActive
-	}
+type noTx struct{}
+
+func (noTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
 
-	v := &Vote{
-		PollID:   pollID,
-		OptionID: optionID,
-		UserID:   userID,
+// SetCacheTTL changes how long newly computed results are cached. Entries
+// already cached keep their original expiry.
+func (s *Service) SetCacheTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
 	}
+	s.cacheTTL.Store(int64(ttl))
+}
+
+// Vote records a vote. The poll row is locked while the vote is inserted, so
+// a poll that closes concurrently either sees the vote or rejects it.
+func (s *Service) Vote(ctx context.Context, pollID, optionID, userID int64) (err error) {
+	ctx, span := tracing.Start(ctx, "vote.Service.Vote", attribute.Int64("poll.id", pollID), attribute.Int64("option.id", optionID))
+	defer func() { tracing.End(span, err) }()
 
-	err = s.repo.Create(ctx, v)
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		status, err := s.repo.LockPollStatus(ctx, pollID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrPollNotFound
+			}
+			return err
+		}
+		if status != "active" {
+			return ErrPollNotActive
+		}
+
+		v := &Vote{
+			PollID:   pollID,
+			OptionID: optionID,
+			UserID:   userID,
+		}
+		return s.repo.Create(ctx, v)
+	})
 	if err != nil {
 		if errors.Is(err, ErrAlreadyVoted) {
 			return ErrAlreadyVoted
@@ -68,72 +106,235 @@ func (s *Service) Vote(ctx context.Context, pollID, optionID, userID int64) erro
 		return err
 	}
 
-	s.invalidateCache(pollID)
+	s.InvalidateResults(ctx, pollID)
 	return nil
 }
 
 type Result struct {
-	OptionID   int64   `json:"option_id"`
-	Votes      int64   `json:"votes"`
-	Percentage float64 `json:"percentage"`
+	OptionID           int64            `json:"option_id"`
+	Text               string           `json:"text"`
+	Position           int              `json:"position"`
+	Votes              int64            `json:"votes"`
+	Percentage         float64          `json:"percentage"`
+	ConfidenceInterval *PercentInterval `json:"confidence_interval,omitempty"`
+}
+
+// PercentInterval is a confidence interval in percentage points, on the same
+// scale as Result.Percentage, unlike stats.Interval which is a proportion.
+type PercentInterval struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// PollResults holds every option of a poll ordered by votes, then position,
+// then id. Tie and WinnerOptionID are only computed once the poll is closed.
+type PollResults struct {
+	PollID         int64    `json:"poll_id"`
+	Status         string   `json:"status"`
+	TotalVotes     int64    `json:"total_votes"`
+	Options        []Result `json:"options"`
+	Tie            bool     `json:"tie"`
+	WinnerOptionID *int64   `json:"winner_option_id,omitempty"`
+}
+
+// Confidence qualifies sample-based results at a chosen confidence level.
+// Intervals and the margin of error are expressed in percentage points.
+type Confidence struct {
+	Level             float64 `json:"level"`
+	MarginOfError     float64 `json:"margin_of_error"`
+	LeaderOptionID    int64   `json:"leader_option_id,omitempty"`
+	LeaderSignificant bool    `json:"leader_significant"`
 }
 
-func (s *Service) Results(ctx context.Context, pollID int64) ([]Result, int64, error) {
-	if cached, ok := s.getCached(pollID); ok {
-		return cached.results, cached.total, nil
+func (s *Service) Results(ctx context.Context, pollID int64) (_ *PollResults, err error) {
+	ctx, span := tracing.Start(ctx, "vote.Service.Results", attribute.Int64("poll.id", pollID))
+	defer func() { tracing.End(span, err) }()
+
+	if cached, ok := s.getCached(ctx, pollID); ok {
+		return cached, nil
+	}
+
+	// The load is detached from the caller's cancellation because its result
+	// is shared with every other caller waiting on the same poll.
+	loadCtx := context.WithoutCancel(ctx)
+	v, err, _ := s.loads.Do(strconv.FormatInt(pollID, 10), func() (any, error) {
+		if cached, ok := s.getCached(loadCtx, pollID); ok {
+			return cached, nil
+		}
+		return s.loadResults(loadCtx, pollID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*PollResults), nil
+}
+
+func (s *Service) loadResults(ctx context.Context, pollID int64) (*PollResults, error) {
+	// The generation is read before the database so that a vote committed
+	// while the results are computed keeps them out of the cache.
+	gen, genErr := s.cache.Generation(ctx, pollID)
+	if genErr != nil {
+		slog.WarnContext(ctx, "results cache generation failed", "poll_id", pollID, "error", genErr)
+	}
+
+	status, err := s.repo.GetPollStatus(ctx, pollID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPollNotFound
+		}
+		return nil, err
 	}
 
 	counts, total, err := s.repo.AggregatedByPoll(ctx, pollID)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
 	if len(counts) == 0 && total == 0 {
 		counts, total, err = s.repo.CountByPoll(ctx, pollID)
 		if err != nil {
-			return nil, 0, err
+			return nil, err
 		}
 	}
 
-	results := make([]Result, 0, len(counts))
+	options, err := s.repo.ListOptions(ctx, pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(options))
+	seen := make(map[int64]bool, len(options))
+	for _, o := range options {
+		seen[o.ID] = true
+		results = append(results, newResult(o, counts[o.ID], total))
+	}
 	for optionID, c := range counts {
-		var p float64
-		if total > 0 {
-			p = float64(c) * 100.0 / float64(total)
+		if !seen[optionID] {
+			results = append(results, newResult(OptionInfo{ID: optionID}, c, total))
 		}
-		results = append(results, Result{
-			OptionID:   optionID,
-			Votes:      c,
-			Percentage: p,
-		})
+	}
+	sortResults(results)
+
+	res := &PollResults{
+		PollID:     pollID,
+		Status:     status,
+		TotalVotes: total,
+		Options:    results,
+	}
+	if status == "closed" {
+		res.Tie, res.WinnerOptionID = decideWinner(results)
 	}
 
-	s.setCached(pollID, results, total)
-	return results, total, nil
+	if genErr == nil {
+		s.setCached(ctx, pollID, gen, res)
+	}
+	return res, nil
 }
 
-func (s *Service) getCached(pollID int64) (cachedResult, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	res, ok := s.cache[pollID]
-	if !ok || time.Now().After(res.expiresAt) {
-		return cachedResult{}, false
+func newResult(o OptionInfo, votes, total int64) Result {
+	var p float64
+	if total > 0 {
+		p = float64(votes) * 100.0 / float64(total)
+	}
+	return Result{
+		OptionID:   o.ID,
+		Text:       o.Text,
+		Position:   o.Position,
+		Votes:      votes,
+		Percentage: p,
 	}
-	return res, true
 }
 
-func (s *Service) setCached(pollID int64, results []Result, total int64) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.cache[pollID] = cachedResult{
-		results:   results,
-		total:     total,
-		expiresAt: time.Now().Add(s.cacheTTL),
+func sortResults(results []Result) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Votes != b.Votes {
+			return a.Votes > b.Votes
+		}
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		return a.OptionID < b.OptionID
+	})
+}
+
+// decideWinner expects results sorted by votes. A poll without votes has
+// neither a winner nor a tie.
+func decideWinner(results []Result) (bool, *int64) {
+	if len(results) == 0 || results[0].Votes == 0 {
+		return false, nil
+	}
+	if len(results) > 1 && results[1].Votes == results[0].Votes {
+		return true, nil
 	}
+	winner := results[0].OptionID
+	return false, &winner
 }
 
-func (s *Service) invalidateCache(pollID int64) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	delete(s.cache, pollID)
+// WithConfidence returns a copy of results annotated with Wilson score
+// intervals, together with the margin of error and whether the leading option
+// is statistically ahead of the runner-up.
+func WithConfidence(results []Result, total int64, level float64) ([]Result, *Confidence, error) {
+	z, err := stats.ZScore(level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	annotated := make([]Result, len(results))
+	var leader, runnerUp *Result
+	for i, r := range results {
+		ci := stats.Wilson(r.Votes, total, z)
+		r.ConfidenceInterval = &PercentInterval{Lower: ci.Lower * 100, Upper: ci.Upper * 100}
+		annotated[i] = r
+
+		switch {
+		case leader == nil || r.Votes > leader.Votes:
+			runnerUp = leader
+			leader = &annotated[i]
+		case runnerUp == nil || r.Votes > runnerUp.Votes:
+			runnerUp = &annotated[i]
+		}
+	}
+
+	c := &Confidence{
+		Level:         level,
+		MarginOfError: stats.MarginOfError(total, z) * 100,
+	}
+	if leader != nil && leader.Votes > 0 {
+		var second int64
+		if runnerUp != nil {
+			second = runnerUp.Votes
+		}
+		if leader.Votes > second {
+			c.LeaderOptionID = leader.OptionID
+		}
+		c.LeaderSignificant = stats.LeadIsSignificant(leader.Votes, second, total, z)
+	}
+
+	return annotated, c, nil
+}
+
+func (s *Service) getCached(ctx context.Context, pollID int64) (*PollResults, bool) {
+	res, ok, err := s.cache.Get(ctx, pollID)
+	if err != nil {
+		slog.WarnContext(ctx, "results cache get failed", "poll_id", pollID, "error", err)
+		return nil, false
+	}
+	return res, ok
+}
+
+func (s *Service) setCached(ctx context.Context, pollID, gen int64, res *PollResults) {
+	if err := s.cache.Set(ctx, pollID, gen, res, time.Duration(s.cacheTTL.Load())); err != nil {
+		slog.WarnContext(ctx, "results cache set failed", "poll_id", pollID, "error", err)
+	}
+}
+
+// InvalidateResults drops the cached results of a poll. Votes do this
+// themselves; it is for changes made elsewhere that cached results reflect,
+// such as the poll closing or being deleted.
+func (s *Service) InvalidateResults(ctx context.Context, pollID int64) {
+	s.loads.Forget(strconv.FormatInt(pollID, 10))
+	if err := s.cache.Invalidate(ctx, pollID); err != nil {
+		slog.WarnContext(ctx, "results cache invalidation failed", "poll_id", pollID, "error", err)
+	}
 }
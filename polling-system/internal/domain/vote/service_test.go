@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -14,8 +15,12 @@ type memoryVoteRepo struct {
 	userVotes     map[int64]map[int64]bool
 	aggregated    map[int64]map[int64]int64
 	pollStatus    map[int64]string
+	options       map[int64][]OptionInfo
+	timeline      map[int64][]Vote
+	eligible      int64
 	countCalls    int
 	aggregatedHit int
+	rollupCalls   int
 }
 
 func newMemoryVoteRepo() *memoryVoteRepo {
@@ -24,6 +29,8 @@ func newMemoryVoteRepo() *memoryVoteRepo {
 		userVotes:  make(map[int64]map[int64]bool),
 		aggregated: make(map[int64]map[int64]int64),
 		pollStatus: make(map[int64]string),
+		options:    make(map[int64][]OptionInfo),
+		timeline:   make(map[int64][]Vote),
 	}
 }
 
@@ -41,6 +48,10 @@ func (r *memoryVoteRepo) Create(ctx context.Context, v *Vote) error {
 		r.votes[v.PollID] = make(map[int64]int64)
 	}
 	r.votes[v.PollID][v.OptionID]++
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now()
+	}
+	r.timeline[v.PollID] = append(r.timeline[v.PollID], *v)
 	return nil
 }
 
@@ -80,6 +91,18 @@ func (r *memoryVoteRepo) IncrementAggregated(ctx context.Context, pollID, option
 	return nil
 }
 
+func (r *memoryVoteRepo) AddAggregated(ctx context.Context, deltas []AggregateDelta) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range deltas {
+		if r.aggregated[d.PollID] == nil {
+			r.aggregated[d.PollID] = make(map[int64]int64)
+		}
+		r.aggregated[d.PollID][d.OptionID] += d.Votes
+	}
+	return nil
+}
+
 func (r *memoryVoteRepo) GetPollStatus(ctx context.Context, pollID int64) (string, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -90,10 +113,62 @@ func (r *memoryVoteRepo) GetPollStatus(ctx context.Context, pollID int64) (strin
 	return "active", nil
 }
 
+func (r *memoryVoteRepo) LockPollStatus(ctx context.Context, pollID int64) (string, error) {
+	return r.GetPollStatus(ctx, pollID)
+}
+
+func (r *memoryVoteRepo) ListOptions(ctx context.Context, pollID int64) ([]OptionInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]OptionInfo(nil), r.options[pollID]...), nil
+}
+
+func (r *memoryVoteRepo) VotesTimeSeries(ctx context.Context, pollID int64, bucket string) ([]BucketCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	type key struct {
+		bucket time.Time
+		option int64
+	}
+	counts := make(map[key]int64)
+	for _, v := range r.timeline[pollID] {
+		counts[key{truncateBucket(v.CreatedAt, bucket), v.OptionID}]++
+	}
+	res := make([]BucketCount, 0, len(counts))
+	for k, c := range counts {
+		res = append(res, BucketCount{Bucket: k.bucket, OptionID: k.option, Votes: c})
+	}
+	return res, nil
+}
+
+func (r *memoryVoteRepo) RollupTimeSeries(ctx context.Context, pollID int64, bucket string) ([]BucketCount, error) {
+	r.mu.Lock()
+	r.rollupCalls++
+	r.mu.Unlock()
+	return r.VotesTimeSeries(ctx, pollID, bucket)
+}
+
+func (r *memoryVoteRepo) CountEligibleVoters(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.eligible, nil
+}
+
+func truncateBucket(t time.Time, bucket string) time.Time {
+	switch bucket {
+	case BucketMinute:
+		return t.Truncate(time.Minute)
+	case BucketHour:
+		return t.Truncate(time.Hour)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+}
+
 func TestVoteIdempotencyAndCache(t *testing.T) {
 	repo := newMemoryVoteRepo()
 	svc := NewService(repo)
-	svc.cacheTTL = time.Hour
+	svc.SetCacheTTL(time.Hour)
 	ctx := context.Background()
 
 	if err := svc.Vote(ctx, 1, 10, 42); err != nil {
@@ -103,21 +178,21 @@ func TestVoteIdempotencyAndCache(t *testing.T) {
 		t.Fatalf("expected duplicate vote error")
 	}
 
-	results, total, err := svc.Results(ctx, 1)
+	res, err := svc.Results(ctx, 1)
 	if err != nil {
 		t.Fatalf("results error: %v", err)
 	}
-	if total != 1 {
-		t.Fatalf("expected total 1, got %d", total)
+	if res.TotalVotes != 1 {
+		t.Fatalf("expected total 1, got %d", res.TotalVotes)
 	}
-	if len(results) != 1 || results[0].Percentage != 100 {
-		t.Fatalf("unexpected results %+v", results)
+	if len(res.Options) != 1 || res.Options[0].Percentage != 100 {
+		t.Fatalf("unexpected results %+v", res.Options)
 	}
 	if repo.countCalls != 1 {
 		t.Fatalf("expected one count call, got %d", repo.countCalls)
 	}
 
-	if _, _, err := svc.Results(ctx, 1); err != nil {
+	if _, err := svc.Results(ctx, 1); err != nil {
 		t.Fatalf("cache lookup failed: %v", err)
 	}
 	if repo.countCalls != 1 {
@@ -135,3 +210,289 @@ func TestVoteRejectsWhenPollNotActive(t *testing.T) {
 		t.Fatalf("expected poll not active error, got %v", err)
 	}
 }
+
+type countingTx struct {
+	runs int
+}
+
+func (tx *countingTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx.runs++
+	return fn(ctx)
+}
+
+func TestVoteRunsInsideUnitOfWork(t *testing.T) {
+	repo := newMemoryVoteRepo()
+	repo.pollStatus[2] = "closed"
+	tx := &countingTx{}
+	svc := NewServiceWithCache(repo, tx, NewMemoryCache(), time.Minute)
+	ctx := context.Background()
+
+	if err := svc.Vote(ctx, 1, 10, 1); err != nil {
+		t.Fatalf("vote: %v", err)
+	}
+	if err := svc.Vote(ctx, 2, 20, 1); !errors.Is(err, ErrPollNotActive) {
+		t.Fatalf("expected poll not active error, got %v", err)
+	}
+	if tx.runs != 2 {
+		t.Fatalf("expected each vote to run in one unit of work, got %d", tx.runs)
+	}
+}
+
+func TestAnalyticsBucketsAndPeakHour(t *testing.T) {
+	repo := newMemoryVoteRepo()
+	repo.eligible = 10
+	svc := NewService(repo)
+	ctx := context.Background()
+
+	base := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	seed := []Vote{
+		{UserID: 1, OptionID: 1, CreatedAt: base.Add(5 * time.Minute)},
+		{UserID: 2, OptionID: 2, CreatedAt: base.Add(70 * time.Minute)},
+		{UserID: 3, OptionID: 1, CreatedAt: base.Add(75 * time.Minute)},
+		{UserID: 4, OptionID: 1, CreatedAt: base.Add(80 * time.Minute)},
+	}
+	for i := range seed {
+		seed[i].PollID = 1
+		if err := repo.Create(ctx, &seed[i]); err != nil {
+			t.Fatalf("seed vote: %v", err)
+		}
+	}
+
+	if _, err := svc.Analytics(ctx, 1, "week"); !errors.Is(err, ErrInvalidBucket) {
+		t.Fatalf("expected invalid bucket error, got %v", err)
+	}
+
+	a, err := svc.Analytics(ctx, 1, BucketDay)
+	if err != nil {
+		t.Fatalf("analytics error: %v", err)
+	}
+	if a.Source != "votes" || repo.rollupCalls != 0 {
+		t.Fatalf("expected raw votes source for small poll, got %s", a.Source)
+	}
+	if a.TotalVotes != 4 || a.ParticipationRate != 40 {
+		t.Fatalf("unexpected totals %d / %.1f", a.TotalVotes, a.ParticipationRate)
+	}
+	if len(a.Total) != 1 || a.Total[0].Cumulative != 4 {
+		t.Fatalf("unexpected daily series %+v", a.Total)
+	}
+	if a.PeakHour == nil || !a.PeakHour.Equal(base.Add(time.Hour)) || a.PeakHourVotes != 3 {
+		t.Fatalf("unexpected peak hour %v (%d votes)", a.PeakHour, a.PeakHourVotes)
+	}
+	if len(a.Options) != 2 || a.Options[0].OptionID != 1 || a.Options[1].OptionID != 2 {
+		t.Fatalf("expected options ordered by id, got %+v", a.Options)
+	}
+
+	hourly, err := svc.Analytics(ctx, 1, BucketHour)
+	if err != nil {
+		t.Fatalf("hourly analytics error: %v", err)
+	}
+	opt1 := hourly.Options[0].Points
+	if len(opt1) != 2 || opt1[0].Votes != 1 || opt1[1].Votes != 2 || opt1[1].Cumulative != 3 {
+		t.Fatalf("unexpected cumulative curve %+v", opt1)
+	}
+}
+
+func TestAnalyticsUsesRollupForHeavyPolls(t *testing.T) {
+	repo := newMemoryVoteRepo()
+	repo.aggregated[1] = map[int64]int64{1: heavyPollThreshold}
+	svc := NewService(repo)
+
+	a, err := svc.Analytics(context.Background(), 1, BucketHour)
+	if err != nil {
+		t.Fatalf("analytics error: %v", err)
+	}
+	if a.Source != "rollup" || repo.rollupCalls == 0 {
+		t.Fatalf("expected rollup source for heavy poll, got %s", a.Source)
+	}
+}
+
+func TestResultsOrderingAndMetadata(t *testing.T) {
+	repo := newMemoryVoteRepo()
+	repo.options[1] = []OptionInfo{
+		{ID: 10, Text: "Pizza", Position: 0},
+		{ID: 11, Text: "Salad", Position: 1},
+		{ID: 12, Text: "Soup", Position: 2},
+	}
+	svc := NewService(repo)
+	ctx := context.Background()
+
+	for userID, optionID := range []int64{11, 12, 11} {
+		if err := svc.Vote(ctx, 1, optionID, int64(userID+1)); err != nil {
+			t.Fatalf("vote: %v", err)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		svc.InvalidateResults(ctx, 1)
+		res, err := svc.Results(ctx, 1)
+		if err != nil {
+			t.Fatalf("results error: %v", err)
+		}
+		got := make([]int64, 0, len(res.Options))
+		for _, r := range res.Options {
+			got = append(got, r.OptionID)
+		}
+		if len(got) != 3 || got[0] != 11 || got[1] != 12 || got[2] != 10 {
+			t.Fatalf("unexpected order %v", got)
+		}
+		if res.Options[0].Text != "Salad" || res.Options[2].Votes != 0 {
+			t.Fatalf("expected option metadata and zero-vote options, got %+v", res.Options)
+		}
+		if res.WinnerOptionID != nil || res.Tie {
+			t.Fatalf("winner must not be declared for an active poll")
+		}
+	}
+}
+
+func TestResultsWinnerAndTieForClosedPolls(t *testing.T) {
+	repo := newMemoryVoteRepo()
+	repo.options[1] = []OptionInfo{{ID: 1, Position: 0}, {ID: 2, Position: 1}}
+	repo.options[2] = []OptionInfo{{ID: 3, Position: 0}, {ID: 4, Position: 1}}
+	repo.votes[1] = map[int64]int64{1: 2, 2: 3}
+	repo.votes[2] = map[int64]int64{3: 2, 4: 2}
+	repo.pollStatus[1] = "closed"
+	repo.pollStatus[2] = "closed"
+	svc := NewService(repo)
+	ctx := context.Background()
+
+	res, err := svc.Results(ctx, 1)
+	if err != nil {
+		t.Fatalf("results error: %v", err)
+	}
+	if res.Tie || res.WinnerOptionID == nil || *res.WinnerOptionID != 2 {
+		t.Fatalf("expected option 2 to win, got tie=%v winner=%v", res.Tie, res.WinnerOptionID)
+	}
+
+	res, err = svc.Results(ctx, 2)
+	if err != nil {
+		t.Fatalf("results error: %v", err)
+	}
+	if !res.Tie || res.WinnerOptionID != nil {
+		t.Fatalf("expected a tie, got tie=%v winner=%v", res.Tie, res.WinnerOptionID)
+	}
+	if res.Options[0].OptionID != 3 {
+		t.Fatalf("expected tied options ordered by position, got %+v", res.Options)
+	}
+}
+
+func TestWithConfidence(t *testing.T) {
+	results := []Result{
+		{OptionID: 1, Votes: 80, Percentage: 80},
+		{OptionID: 2, Votes: 20, Percentage: 20},
+	}
+
+	annotated, c, err := WithConfidence(results, 100, 0.95)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].ConfidenceInterval != nil {
+		t.Fatalf("input results must not be modified")
+	}
+	for _, r := range annotated {
+		ci := r.ConfidenceInterval
+		if ci == nil || ci.Lower > r.Percentage || ci.Upper < r.Percentage {
+			t.Fatalf("interval %+v does not contain %.1f", ci, r.Percentage)
+		}
+	}
+	if c.LeaderOptionID != 1 || !c.LeaderSignificant {
+		t.Fatalf("expected option 1 to lead significantly, got %+v", c)
+	}
+	if c.MarginOfError < 9.7 || c.MarginOfError > 9.9 {
+		t.Fatalf("unexpected margin of error %.2f", c.MarginOfError)
+	}
+
+	_, c, err = WithConfidence([]Result{{OptionID: 1, Votes: 5}, {OptionID: 2, Votes: 5}}, 10, 0.95)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.LeaderOptionID != 0 || c.LeaderSignificant {
+		t.Fatalf("expected no leader on a tie, got %+v", c)
+	}
+
+	if _, _, err := WithConfidence(results, 100, 95); err == nil {
+		t.Fatalf("expected invalid confidence error")
+	}
+}
+
+type slowOptionsRepo struct {
+	*memoryVoteRepo
+	release chan struct{}
+	loads   atomic.Int32
+}
+
+func (r *slowOptionsRepo) ListOptions(ctx context.Context, pollID int64) ([]OptionInfo, error) {
+	r.loads.Add(1)
+	<-r.release
+	return r.memoryVoteRepo.ListOptions(ctx, pollID)
+}
+
+func TestResultsCoalescesConcurrentMisses(t *testing.T) {
+	repo := &slowOptionsRepo{memoryVoteRepo: newMemoryVoteRepo(), release: make(chan struct{})}
+	svc := NewServiceWithCache(repo, noTx{}, NewMemoryCache(), time.Minute)
+	ctx := context.Background()
+
+	const callers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.Results(ctx, 1)
+			errs <- err
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("results error: %v", err)
+		}
+	}
+	if n := repo.loads.Load(); n != 1 {
+		t.Fatalf("expected a single database load, got %d", n)
+	}
+}
+
+func TestResultsLoadRacingAVoteIsNotCached(t *testing.T) {
+	repo := &slowOptionsRepo{memoryVoteRepo: newMemoryVoteRepo(), release: make(chan struct{})}
+	cache := NewMemoryCache()
+	svc := NewServiceWithCache(repo, noTx{}, cache, time.Hour)
+	ctx := context.Background()
+
+	// The load counts the votes, then waits for the options while a vote
+	// commits.
+	stale := make(chan *PollResults, 1)
+	go func() {
+		res, err := svc.Results(ctx, 1)
+		if err != nil {
+			t.Errorf("results error: %v", err)
+		}
+		stale <- res
+	}()
+	for repo.loads.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := svc.Vote(ctx, 1, 7, 1); err != nil {
+		t.Fatalf("vote: %v", err)
+	}
+	close(repo.release)
+
+	if res := <-stale; res == nil || res.TotalVotes != 0 {
+		t.Fatalf("expected the racing load to miss the vote, got %+v", res)
+	}
+	if _, ok, _ := cache.Get(ctx, 1); ok {
+		t.Fatal("expected results computed before the vote not to be cached")
+	}
+	res, err := svc.Results(ctx, 1)
+	if err != nil {
+		t.Fatalf("results error: %v", err)
+	}
+	if res.TotalVotes != 1 {
+		t.Fatalf("expected the vote to be counted, got %d votes", res.TotalVotes)
+	}
+}
@@ -0,0 +1,59 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DB pings db and reports its connection pool.
+func DB(db *sql.DB) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		if db == nil {
+			return nil, errors.New("database not configured")
+		}
+		err := db.PingContext(ctx)
+		st := db.Stats()
+		return map[string]any{
+			"open_connections": st.OpenConnections,
+			"in_use":           st.InUse,
+			"idle":             st.Idle,
+		}, err
+	}
+}
+
+// Queue reports the fill level of a queue and is degraded once it is at
+// least saturatedAt (between 0 and 1) full.
+func Queue(depth func() int, capacity int, saturatedAt float64) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		d := depth()
+		saturation := 1.0
+		if capacity > 0 {
+			saturation = float64(d) / float64(capacity)
+		}
+		details := map[string]any{"depth": d, "capacity": capacity, "saturation": saturation}
+		if saturation >= saturatedAt {
+			return details, Degraded(fmt.Errorf("queue %.0f%% full", saturation*100))
+		}
+		return details, nil
+	}
+}
+
+// Heartbeat fails once last has not been updated for staleAfter. A zero
+// time means the component has not started.
+func Heartbeat(last func() time.Time, staleAfter time.Duration) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		beat := last()
+		if beat.IsZero() {
+			return nil, errors.New("not running")
+		}
+		age := time.Since(beat)
+		details := map[string]any{"last_beat": beat.UTC(), "age_seconds": age.Seconds()}
+		if age > staleAfter {
+			return details, fmt.Errorf("no heartbeat for %s", age.Truncate(time.Second))
+		}
+		return details, nil
+	}
+}
@@ -0,0 +1,179 @@
+// Package health runs the checks behind the liveness and readiness probes.
+//
+// Liveness checks answer "is the process working at all" and should only
+// fail when a restart would help, such as a stuck worker. Readiness checks
+// cover the dependencies needed to serve traffic; a failing readiness check
+// takes the instance out of the load balancer without restarting it.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusUp Status = "up"
+	// StatusDegraded reports a problem that does not stop the instance
+	// from serving, such as a saturated queue or a failing cache.
+	StatusDegraded Status = "degraded"
+	StatusDown     Status = "down"
+)
+
+// DefaultTimeout bounds a check registered without a timeout.
+const DefaultTimeout = 2 * time.Second
+
+// CheckFunc checks one component and returns details worth reporting, which
+// may be nil. A non-nil error marks the component down, or degraded if the
+// error was wrapped with Degraded.
+type CheckFunc func(ctx context.Context) (map[string]any, error)
+
+type degradedError struct{ err error }
+
+func (e degradedError) Error() string { return e.err.Error() }
+func (e degradedError) Unwrap() error { return e.err }
+
+// Degraded marks err as a degradation rather than a failure.
+func Degraded(err error) error {
+	return degradedError{err: err}
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Status    Status         `json:"status"`
+	LatencyMS float64        `json:"latency_ms"`
+	Details   map[string]any `json:"details,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// Report is the outcome of a set of checks. Its status is the worst status
+// of its checks.
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      CheckFunc
+}
+
+// Registry holds the liveness and readiness checks of the process.
+type Registry struct {
+	mu        sync.RWMutex
+	liveness  []check
+	readiness []check
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// AddLiveness registers a liveness check bounded by timeout.
+func (r *Registry) AddLiveness(name string, timeout time.Duration, fn CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.liveness = append(r.liveness, newCheck(name, timeout, fn))
+}
+
+// AddReadiness registers a readiness check bounded by timeout.
+func (r *Registry) AddReadiness(name string, timeout time.Duration, fn CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.readiness = append(r.readiness, newCheck(name, timeout, fn))
+}
+
+func newCheck(name string, timeout time.Duration, fn CheckFunc) check {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return check{name: name, timeout: timeout, fn: fn}
+}
+
+// Liveness runs the liveness checks concurrently.
+func (r *Registry) Liveness(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := r.liveness
+	r.mu.RUnlock()
+	return run(ctx, checks)
+}
+
+// Readiness runs the readiness checks concurrently.
+func (r *Registry) Readiness(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := r.readiness
+	r.mu.RUnlock()
+	return run(ctx, checks)
+}
+
+func run(ctx context.Context, checks []check) Report {
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(checks))}
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		report.Status = worst(report.Status, results[i].Status)
+	}
+	return report
+}
+
+type outcome struct {
+	details map[string]any
+	err     error
+}
+
+// run calls the check with its timeout. A check that ignores its context is
+// reported down once the timeout expires and left to finish on its own.
+func (c check) run(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan outcome, 1)
+	go func() {
+		details, err := c.fn(ctx)
+		done <- outcome{details: details, err: err}
+	}()
+
+	var o outcome
+	select {
+	case o = <-done:
+	case <-ctx.Done():
+		o.err = fmt.Errorf("timed out after %s", c.timeout)
+	}
+	res := Result{
+		Status:    StatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   o.details,
+	}
+	if o.err != nil {
+		res.Status, res.Error = StatusDown, o.err.Error()
+		var degraded degradedError
+		if errors.As(o.err, &degraded) {
+			res.Status = StatusDegraded
+		}
+	}
+	return res
+}
+
+func worst(a, b Status) Status {
+	rank := map[Status]int{StatusUp: 0, StatusDegraded: 1, StatusDown: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,83 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReportTakesWorstStatus(t *testing.T) {
+	r := NewRegistry()
+	r.AddReadiness("up", time.Second, func(context.Context) (map[string]any, error) {
+		return map[string]any{"n": 1}, nil
+	})
+	r.AddReadiness("degraded", time.Second, func(context.Context) (map[string]any, error) {
+		return nil, Degraded(errors.New("slow"))
+	})
+
+	report := r.Readiness(context.Background())
+	if report.Status != StatusDegraded {
+		t.Fatalf("expected degraded, got %+v", report)
+	}
+	if c := report.Checks["up"]; c.Status != StatusUp || c.Details["n"] != 1 || c.Error != "" {
+		t.Fatalf("unexpected up check %+v", c)
+	}
+
+	r.AddReadiness("down", time.Second, func(context.Context) (map[string]any, error) {
+		return nil, errors.New("boom")
+	})
+	if report := r.Readiness(context.Background()); report.Status != StatusDown || report.Checks["down"].Error != "boom" {
+		t.Fatalf("expected down, got %+v", report)
+	}
+	if report := r.Liveness(context.Background()); report.Status != StatusUp || len(report.Checks) != 0 {
+		t.Fatalf("readiness checks must not affect liveness, got %+v", report)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	r := NewRegistry()
+	block := make(chan struct{})
+	defer close(block)
+	r.AddLiveness("stuck", 20*time.Millisecond, func(context.Context) (map[string]any, error) {
+		<-block // ignores its context
+		return nil, nil
+	})
+
+	start := time.Now()
+	report := r.Liveness(context.Background())
+	if time.Since(start) > time.Second {
+		t.Fatalf("a stuck check must not block the probe")
+	}
+	if c := report.Checks["stuck"]; c.Status != StatusDown || c.Error != "timed out after 20ms" {
+		t.Fatalf("expected a timeout, got %+v", c)
+	}
+}
+
+func TestQueueAndHeartbeatChecks(t *testing.T) {
+	ctx := context.Background()
+	depth := 5
+	queue := Queue(func() int { return depth }, 10, 0.8)
+	if details, err := queue(ctx); err != nil || details["saturation"] != 0.5 {
+		t.Fatalf("half-full queue: %v %v", details, err)
+	}
+	depth = 9
+	var degraded degradedError
+	if _, err := queue(ctx); !errors.As(err, &degraded) {
+		t.Fatalf("expected a saturated queue to be degraded, got %v", err)
+	}
+
+	var last time.Time
+	beat := Heartbeat(func() time.Time { return last }, time.Minute)
+	if _, err := beat(ctx); err == nil {
+		t.Fatalf("a component that never beat must be down")
+	}
+	last = time.Now()
+	if _, err := beat(ctx); err != nil {
+		t.Fatalf("fresh heartbeat: %v", err)
+	}
+	last = time.Now().Add(-2 * time.Minute)
+	if _, err := beat(ctx); err == nil {
+		t.Fatalf("a stale heartbeat must be down")
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"net/http"
+
+	"polling-system/internal/platform/validate"
+)
+
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// @Summary     Verify email address
+// @Description Target of the link mailed on registration. The token works once and expires after auth.verify_email_ttl.
+// @Tags        auth
+// @Produce     json
+// @Param       token  query  string  true  "Token from the email"
+// @Success     204
+// @Failure     400    {object}  problem            "invalid, expired or used token"
+// @Failure     429    {object}  problem            "rate limited"
+// @Failure     500    {object}  problem            "server error"
+// @Router      /api/v1/auth/verify-email [get]
+func (h *Handler) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if err := h.accountSvc.VerifyEmail(r.Context(), r.URL.Query().Get("token")); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary     Resend verification email
+// @Description Mails a new verification link to the current user. Earlier links stop working.
+// @Tags        auth
+// @Security    BearerAuth
+// @Produce     json
+// @Success     202
+// @Failure     401  {object}  problem            "unauthorized"
+// @Failure     409  {object}  problem            "already verified"
+// @Failure     429  {object}  problem            "rate limited"
+// @Failure     500  {object}  problem            "server error"
+// @Router      /api/v1/auth/verify-email/resend [post]
+func (h *Handler) handleResendVerification(w http.ResponseWriter, r *http.Request) {
+	if err := h.accountSvc.SendVerification(r.Context(), userIDFromCtx(r)); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// @Summary     Request a password reset
+// @Description Mails a password reset link if an active account has the email. The mail is sent in the background and the response is the same either way, so it does not reveal which emails are registered.
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       request  body  forgotPasswordRequest  true  "Account email"
+// @Success     202
+// @Failure     400      {object}  problem            "invalid body"
+// @Failure     429      {object}  problem            "rate limited"
+// @Router      /api/v1/auth/forgot-password [post]
+func (h *Handler) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req forgotPasswordRequest
+	if err := h.decodeJSON(w, r, &req); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	v := validate.New()
+	v.String("email", req.Email, validate.Required, validate.Email)
+	if err := v.Err(); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	h.accountSvc.RequestPasswordResetAsync(r.Context(), req.Email)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// @Summary     Reset password
+// @Description Sets a new password with the token from a reset email. The token works once and expires after auth.reset_password_ttl; a password rejected by the policy leaves it usable.
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       request  body  resetPasswordRequest  true  "Token and new password"
+// @Success     204
+// @Failure     400      {object}  problem            "invalid password or token"
+// @Failure     401      {object}  problem            "inactive user"
+// @Failure     429      {object}  problem            "rate limited"
+// @Failure     500      {object}  problem            "server error"
+// @Router      /api/v1/auth/reset-password [post]
+func (h *Handler) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+	if err := h.decodeJSON(w, r, &req); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	if err := h.accountSvc.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,22 @@
+package api
+
+import "net/http"
+
+// @Summary     Operational overview
+// @Description Admin only. User, poll and vote totals, the polls with the most votes, recent registrations and the health of the stats workers. Database figures are cached for 30 seconds; the worker queue is always current.
+// @Tags        admin
+// @Security    BearerAuth
+// @Produce     json
+// @Success     200  {object}  dashboard.Stats
+// @Failure     401  {object}  problem            "unauthorized"
+// @Failure     403  {object}  problem            "forbidden"
+// @Failure     500  {object}  problem            "server error"
+// @Router      /api/v1/admin/stats [get]
+func (h *Handler) handleAdminStats(w http.ResponseWriter, r *http.Request) {
+	st, err := h.dashboardSvc.Stats(r.Context())
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+
+	chimw "github.com/go-chi/chi/v5/middleware"
+
+	"polling-system/internal/domain/audit"
+	"polling-system/internal/platform/apperr"
+)
+
+type auditLogResponse struct {
+	Events []audit.Event `json:"events"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
+
+// auditEvent describes an action of the request, with its client IP and
+// request ID.
+func (h *Handler) auditEvent(r *http.Request, action string, userID *int64, email, detail string) audit.Event {
+	return audit.Event{
+		Action:    action,
+		UserID:    userID,
+		Email:     email,
+		IP:        h.limiter.ClientIP(r),
+		RequestID: chimw.GetReqID(r.Context()),
+		Detail:    detail,
+	}
+}
+
+// @Summary     List audit log
+// @Description Admin only. Security events such as failed logins, lockouts, unlocks and two-factor changes, newest first.
+// @Tags        admin
+// @Security    BearerAuth
+// @Produce     json
+// @Param       action   query     string  false  "Only this action"  Enums(login_succeeded, login_failed, login_locked, account_unlocked, two_factor_enabled, two_factor_disabled, two_factor_failed, two_factor_reset, recovery_code_used, recovery_codes_regenerated)
+// @Param       user_id  query     int64   false  "Only events about this user"
+// @Param       limit    query     int     false  "Page size (max 500)"  default(50)
+// @Param       offset   query     int     false  "Events to skip"       default(0)
+// @Success     200      {object}  auditLogResponse
+// @Failure     400      {object}  problem            "invalid filter, limit or offset"
+// @Failure     401      {object}  problem            "unauthorized"
+// @Failure     403      {object}  problem            "forbidden"
+// @Failure     500      {object}  problem            "server error"
+// @Router      /api/v1/admin/audit-log [get]
+func (h *Handler) handleListAuditLog(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", audit.DefaultListLimit)
+	if err != nil || limit <= 0 {
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid limit", err))
+		return
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid offset", err))
+		return
+	}
+	userID, err := queryInt(r, "user_id", 0)
+	if err != nil || userID < 0 {
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid user_id", err))
+		return
+	}
+	limit = min(limit, audit.MaxListLimit)
+
+	f := audit.Filter{Action: r.URL.Query().Get("action"), UserID: int64(userID)}
+	events, err := h.auditSvc.List(r.Context(), f, limit, offset)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	if events == nil {
+		events = []audit.Event{}
+	}
+	writeJSON(w, http.StatusOK, auditLogResponse{Events: events, Limit: limit, Offset: offset})
+}
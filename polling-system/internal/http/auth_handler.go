@@ -1,12 +1,19 @@
 package api
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
+	"polling-system/internal/domain/audit"
 	"polling-system/internal/domain/user"
 	"polling-system/internal/platform/apperr"
+	jwtpkg "polling-system/internal/platform/jwt"
+	"polling-system/internal/platform/validate"
+	"polling-system/internal/ratelimit"
 )
 
 type authRequest struct {
@@ -17,37 +24,56 @@ type authRequest struct {
 type authResponse struct {
 	User  *user.User `json:"user"`
 	Token string     `json:"token"`
+	// TwoFactorSetupRequired is set when the user's role requires
+	// two-factor authentication and the user has not set it up yet.
+	TwoFactorSetupRequired bool `json:"two_factor_setup_required,omitempty"`
+}
+
+// twoFactorChallengeResponse answers the password step of a login for
+// users with two-factor authentication.
+type twoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required" example:"true"`
+	ChallengeToken    string `json:"challenge_token"`
+	// ExpiresIn is the lifetime of ChallengeToken in seconds.
+	ExpiresIn int `json:"expires_in" example:"300"`
+}
+
+type twoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code" example:"123456"`
 }
 
 // @Summary     Register a new user
+// @Description Mails a link to verify the email address.
 // @Tags        auth
 // @Accept      json
 // @Produce     json
 // @Param       request  body      authRequest  true  "User credentials"
 // @Success     201      {object}  authResponse
-// @Failure     400      {object}  map[string]string  "invalid body or email taken"
-// @Failure     500      {object}  map[string]string  "server error"
+// @Failure     400      {object}  problem            "invalid body or email taken"
+// @Failure     500      {object}  problem            "server error"
 // @Router      /api/v1/auth/register [post]
 func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
 	var req authRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid body", err))
-		return
-	}
-	if req.Email == "" || req.Password == "" {
-		errorResponse(w, apperr.BadRequest("invalid_input", "email and password required", nil))
+	if err := h.decodeJSON(w, r, &req); err != nil {
+		errorResponse(w, r, err)
 		return
 	}
 
 	u, err := h.userSvc.Register(r.Context(), req.Email, req.Password)
 	if err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
 		return
 	}
+	// The account works without a verified email, and the user can ask for
+	// another link, so a mail failure does not fail the registration.
+	if err := h.accountSvc.SendVerification(r.Context(), u.ID); err != nil {
+		slog.ErrorContext(r.Context(), "send verification email", "user_id", u.ID, "error", err)
+	}
 
-	token, err := h.jwtMgr.Generate(u.ID, u.Role, 24*time.Hour)
+	token, err := h.jwtMgr.Generate(u.ID, u.Role, h.tokenTTL, jwtpkg.MethodPassword)
 	if err != nil {
-		errorResponse(w, apperr.Internal("internal_error", "failed to generate token", err))
+		errorResponse(w, r, apperr.Internal("internal_error", "failed to generate token", err))
 		return
 	}
 
@@ -58,35 +84,145 @@ func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary     Login user
+// @Description Repeated failures for an email or from an IP address delay further attempts, then lock them for auth.lockout.*.lock_duration; both answer 429 with Retry-After. Users with two-factor authentication get a challenge token instead of an access token, to complete at /auth/login/2fa.
 // @Tags        auth
 // @Accept      json
 // @Produce     json
 // @Param       request  body      authRequest  true  "User credentials"
-// @Success     200      {object}  authResponse
-// @Failure     400      {object}  map[string]string  "invalid body"
-// @Failure     401      {object}  map[string]string  "invalid credentials"
-// @Failure     500      {object}  map[string]string  "server error"
+// @Success     200      {object}  authResponse  "or twoFactorChallengeResponse"
+// @Failure     400      {object}  problem            "invalid body"
+// @Failure     401      {object}  problem            "invalid credentials"
+// @Failure     429      {object}  problem            "too many failed attempts"
+// @Failure     500      {object}  problem            "server error"
 // @Router      /api/v1/auth/login [post]
 func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 	var req authRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid body", err))
+	if err := h.decodeJSON(w, r, &req); err != nil {
+		errorResponse(w, r, err)
 		return
 	}
-	if req.Email == "" || req.Password == "" {
-		errorResponse(w, apperr.BadRequest("invalid_input", "email and password required", nil))
+	v := validate.New()
+	v.String("email", req.Email, validate.Required)
+	v.String("password", req.Password, validate.Required)
+	if err := v.Err(); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	ip := h.limiter.ClientIP(r)
+	if block, blocked := h.loginGuard.Check(r.Context(), req.Email, ip); blocked {
+		loginBlockedResponse(w, r, block)
 		return
 	}
 
 	u, err := h.userSvc.Login(r.Context(), req.Email, req.Password)
+	if errors.Is(err, user.ErrInvalidCredentials) {
+		h.recordLoginFailure(r, req.Email, ip)
+	}
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	enabled, err := h.twoFactorSvc.Enabled(r.Context(), u.ID)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	if enabled {
+		// Failures are not reset until the second step succeeds, so the
+		// lockout also covers guessing codes.
+		challenge, err := h.jwtMgr.GenerateChallenge(u.ID, h.challengeTTL)
+		if err != nil {
+			errorResponse(w, r, apperr.Internal("internal_error", "failed to generate token", err))
+			return
+		}
+		writeJSON(w, http.StatusOK, twoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresIn:         int(h.challengeTTL / time.Second),
+		})
+		return
+	}
+
+	h.loginGuard.Succeed(r.Context(), req.Email)
+	h.auditSvc.Record(r.Context(), h.auditEvent(r, audit.ActionLoginSucceeded, &u.ID, u.Email, ""))
+
+	token, err := h.jwtMgr.Generate(u.ID, u.Role, h.tokenTTL, jwtpkg.MethodPassword)
+	if err != nil {
+		errorResponse(w, r, apperr.Internal("internal_error", "failed to generate token", err))
+		return
+	}
+
+	resp := map[string]any{
+		"user":  u,
+		"token": token,
+	}
+	if h.twoFactorSvc.Required(u.Role) {
+		// The token only reaches the two-factor setup endpoints until the
+		// user enrolls.
+		resp["two_factor_setup_required"] = true
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// @Summary     Complete a two-factor login
+// @Description Second step of a login for users with two-factor authentication: exchanges the challenge token from /auth/login and a code from the authenticator app, or an unused recovery code, for an access token. Wrong codes count as failed logins.
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       request  body      twoFactorLoginRequest  true  "Challenge token and code"
+// @Success     200      {object}  authResponse
+// @Failure     400      {object}  problem            "invalid body"
+// @Failure     401      {object}  problem            "invalid or expired challenge token, or invalid code"
+// @Failure     429      {object}  problem            "too many failed attempts"
+// @Failure     500      {object}  problem            "server error"
+// @Router      /api/v1/auth/login/2fa [post]
+func (h *Handler) handleLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req twoFactorLoginRequest
+	if err := h.decodeJSON(w, r, &req); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	v := validate.New()
+	v.String("challenge_token", req.ChallengeToken, validate.Required)
+	v.String("code", req.Code, validate.Required)
+	if err := v.Err(); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	claims, err := h.jwtMgr.ParseChallenge(req.ChallengeToken)
+	if err != nil {
+		errorResponse(w, r, apperr.Unauthorized("invalid_token", "invalid or expired challenge token", err))
+		return
+	}
+	u, err := h.userSvc.GetByID(r.Context(), claims.UserID)
 	if err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
+		return
+	}
+	if !u.IsActive {
+		errorResponse(w, r, user.ErrInactiveUser)
 		return
 	}
 
-	token, err := h.jwtMgr.Generate(u.ID, u.Role, 24*time.Hour)
+	var recovery bool
+	if !h.guardTwoFactorCode(w, r, u, func() (err error) {
+		recovery, err = h.twoFactorSvc.Verify(r.Context(), u.ID, req.Code)
+		return err
+	}) {
+		return
+	}
+	h.loginGuard.Succeed(r.Context(), u.Email)
+	if recovery {
+		h.auditSvc.Record(r.Context(), h.auditEvent(r, audit.ActionRecoveryCodeUsed, &u.ID, u.Email, ""))
+	}
+	h.auditSvc.Record(r.Context(), h.auditEvent(r, audit.ActionLoginSucceeded, &u.ID, u.Email, "two-factor"))
+
+	token, err := h.jwtMgr.Generate(u.ID, u.Role, h.tokenTTL, jwtpkg.MethodPassword, jwtpkg.MethodOTP)
 	if err != nil {
-		errorResponse(w, apperr.Internal("internal_error", "failed to generate token", err))
+		errorResponse(w, r, apperr.Internal("internal_error", "failed to generate token", err))
 		return
 	}
 
@@ -95,3 +231,35 @@ func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 		"token": token,
 	})
 }
+
+// recordLoginFailure counts a failed login and audits it, along with the
+// lockout it causes, if any.
+func (h *Handler) recordLoginFailure(r *http.Request, email, ip string) {
+	var userID *int64
+	if u, err := h.userSvc.GetByEmail(r.Context(), email); err == nil {
+		userID = &u.ID
+	}
+	h.recordFailure(r, audit.ActionLoginFailed, userID, email, ip)
+}
+
+// recordFailure audits a failed authentication as action and counts it
+// against the email and ip, auditing the lockout it causes, if any.
+func (h *Handler) recordFailure(r *http.Request, action string, userID *int64, email, ip string) {
+	ctx := r.Context()
+	h.auditSvc.Record(ctx, h.auditEvent(r, action, userID, email, ""))
+
+	block, blocked := h.loginGuard.Fail(ctx, email, ip)
+	if blocked && block.Locked {
+		detail := fmt.Sprintf("%s locked for %s after %d failures", block.Scope, block.RetryAfter.Round(time.Second), block.Failures)
+		h.auditSvc.Record(ctx, h.auditEvent(r, audit.ActionLoginLocked, userID, email, detail))
+	}
+}
+
+func loginBlockedResponse(w http.ResponseWriter, r *http.Request, block ratelimit.LoginBlock) {
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(block.RetryAfter)))
+	if block.Locked {
+		errorResponse(w, r, apperr.TooManyRequests("login_locked", "too many failed logins; try again later", nil))
+		return
+	}
+	errorResponse(w, r, apperr.TooManyRequests("login_delayed", "too many failed logins; wait before trying again", nil))
+}
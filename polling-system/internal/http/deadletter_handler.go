@@ -0,0 +1,166 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"polling-system/internal/domain/deadletter"
+	"polling-system/internal/platform/apperr"
+)
+
+type deadLetterListResponse struct {
+	Entries []deadletter.Entry `json:"entries"`
+	Total   int64              `json:"total"`
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
+}
+
+type replayAllResponse struct {
+	Replayed int `json:"replayed"`
+	Failed   int `json:"failed"`
+}
+
+// @Summary     List dead-lettered vote events
+// @Description Admin only. Vote events whose aggregation failed after all retries, oldest first.
+// @Tags        dead-letters
+// @Security    BearerAuth
+// @Produce     json
+// @Param       limit   query     int  false  "Page size (max 500)"  default(50)
+// @Param       offset  query     int  false  "Entries to skip"      default(0)
+// @Success     200     {object}  deadLetterListResponse
+// @Failure     400     {object}  problem            "invalid limit or offset"
+// @Failure     401     {object}  problem            "unauthorized"
+// @Failure     403     {object}  problem            "forbidden"
+// @Failure     500     {object}  problem            "server error"
+// @Router      /api/v1/admin/dead-letters [get]
+func (h *Handler) handleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", deadletter.DefaultListLimit)
+	if err != nil || limit <= 0 {
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid limit", err))
+		return
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid offset", err))
+		return
+	}
+	limit = min(limit, deadletter.MaxListLimit)
+
+	entries, total, err := h.deadLetterSvc.List(r.Context(), limit, offset)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	if entries == nil {
+		entries = []deadletter.Entry{}
+	}
+	writeJSON(w, http.StatusOK, deadLetterListResponse{Entries: entries, Total: total, Limit: limit, Offset: offset})
+}
+
+// @Summary     Inspect a dead-lettered vote event
+// @Tags        dead-letters
+// @Security    BearerAuth
+// @Produce     json
+// @Param       id   path      int64  true  "Dead letter ID"
+// @Success     200  {object}  deadletter.Entry
+// @Failure     400  {object}  problem            "invalid id"
+// @Failure     401  {object}  problem            "unauthorized"
+// @Failure     403  {object}  problem            "forbidden"
+// @Failure     404  {object}  problem            "not found"
+// @Failure     500  {object}  problem            "server error"
+// @Router      /api/v1/admin/dead-letters/{id} [get]
+func (h *Handler) handleGetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(r, "id")
+	if err != nil {
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid id", err))
+		return
+	}
+
+	e, err := h.deadLetterSvc.Get(r.Context(), id)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, e)
+}
+
+// @Summary     Replay a dead-lettered vote event
+// @Description Admin only. Aggregates the vote again and removes the entry. On failure the entry is kept with its attempt count raised.
+// @Tags        dead-letters
+// @Security    BearerAuth
+// @Param       id   path  int64  true  "Dead letter ID"
+// @Success     204
+// @Failure     400  {object}  problem            "invalid id"
+// @Failure     401  {object}  problem            "unauthorized"
+// @Failure     403  {object}  problem            "forbidden"
+// @Failure     404  {object}  problem            "not found"
+// @Failure     409  {object}  problem            "replay failed"
+// @Failure     500  {object}  problem            "server error"
+// @Router      /api/v1/admin/dead-letters/{id}/replay [post]
+func (h *Handler) handleReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(r, "id")
+	if err != nil {
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid id", err))
+		return
+	}
+
+	if err := h.deadLetterSvc.Replay(r.Context(), id); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary     Replay every dead-lettered vote event
+// @Description Admin only. Replays each entry once, oldest first; entries that fail again stay in the queue.
+// @Tags        dead-letters
+// @Security    BearerAuth
+// @Produce     json
+// @Success     200  {object}  replayAllResponse
+// @Failure     401  {object}  problem            "unauthorized"
+// @Failure     403  {object}  problem            "forbidden"
+// @Failure     500  {object}  problem            "server error"
+// @Router      /api/v1/admin/dead-letters/replay [post]
+func (h *Handler) handleReplayAllDeadLetters(w http.ResponseWriter, r *http.Request) {
+	replayed, failed, err := h.deadLetterSvc.ReplayAll(r.Context())
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, replayAllResponse{Replayed: replayed, Failed: failed})
+}
+
+// @Summary     Discard a dead-lettered vote event
+// @Description Admin only. Removes the entry without aggregating its vote.
+// @Tags        dead-letters
+// @Security    BearerAuth
+// @Param       id   path  int64  true  "Dead letter ID"
+// @Success     204
+// @Failure     400  {object}  problem            "invalid id"
+// @Failure     401  {object}  problem            "unauthorized"
+// @Failure     403  {object}  problem            "forbidden"
+// @Failure     404  {object}  problem            "not found"
+// @Failure     500  {object}  problem            "server error"
+// @Router      /api/v1/admin/dead-letters/{id} [delete]
+func (h *Handler) handleDiscardDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(r, "id")
+	if err != nil {
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid id", err))
+		return
+	}
+
+	if err := h.deadLetterSvc.Discard(r.Context(), id); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// queryInt parses an optional integer query parameter.
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	return strconv.Atoi(raw)
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"polling-system/internal/platform/apperr"
+)
+
+// decodeJSON decodes the body of r into dst. The body must be a single JSON
+// value of at most h.maxBodyBytes without fields unknown to dst.
+func (h *Handler) decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return bodyError(err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return bodyError(err)
+		}
+		return apperr.BadRequest("invalid_input", "body must contain a single JSON value", err)
+	}
+	return nil
+}
+
+func bodyError(err error) error {
+	var (
+		tooLarge  *http.MaxBytesError
+		typeErr   *json.UnmarshalTypeError
+		syntaxErr *json.SyntaxError
+	)
+	switch {
+	case errors.As(err, &tooLarge):
+		return apperr.RequestTooLarge("body_too_large", "request body is too large", err)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return apperr.Validation(apperr.Violation{
+			Field: typeErr.Field, Code: "invalid_type",
+			Message: typeErr.Field + " must be " + jsonKind(typeErr.Type.Kind().String()),
+		})
+	case errors.Is(err, io.EOF):
+		return apperr.BadRequest("invalid_input", "request body is required", err)
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return apperr.BadRequest("invalid_input", "request body is not valid JSON", err)
+	}
+	// encoding/json has no error type for unknown fields.
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field = strings.Trim(field, `"`)
+		return apperr.Validation(apperr.Violation{Field: field, Code: "unknown_field", Message: field + " is not a known field"})
+	}
+	return apperr.BadRequest("invalid_input", "invalid body", err)
+}
+
+// jsonKind names a Go kind the way a JSON client would.
+func jsonKind(kind string) string {
+	switch {
+	case kind == "string":
+		return "a string"
+	case kind == "bool":
+		return "a boolean"
+	case kind == "slice" || kind == "array":
+		return "an array"
+	case kind == "struct" || kind == "map":
+		return "an object"
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"):
+		return "an integer"
+	case strings.HasPrefix(kind, "float"):
+		return "a number"
+	}
+	return "of another type"
+}
@@ -2,21 +2,57 @@ package api
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"net/http"
 
+	chimw "github.com/go-chi/chi/v5/middleware"
+
+	"polling-system/internal/domain/deadletter"
 	"polling-system/internal/domain/poll"
 	"polling-system/internal/domain/user"
 	"polling-system/internal/domain/vote"
 	"polling-system/internal/platform/apperr"
+	"polling-system/internal/platform/validate"
+	"polling-system/internal/stats"
 )
 
-func errorResponse(w http.ResponseWriter, err error) {
+// problemContentType is the media type of RFC 7807 problem details.
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 problem details document. Type links to the
+// documentation of Code, served under /problems.
+type problem struct {
+	Type      string             `json:"type" example:"/problems/validation_failed"`
+	Title     string             `json:"title" example:"Validation failed"`
+	Status    int                `json:"status" example:"400"`
+	Detail    string             `json:"detail,omitempty" example:"the request has invalid fields"`
+	Instance  string             `json:"instance,omitempty" example:"/api/v1/polls"`
+	Code      string             `json:"code" example:"validation_failed"`
+	RequestID string             `json:"request_id,omitempty" example:"9f86d081884c7d659a2feaa0c55ad015"`
+	Errors    []apperr.Violation `json:"errors,omitempty"`
+}
+
+// errorResponse writes err as a problem document.
+func errorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	appErr := mapError(err)
-	writeJSON(w, appErr.StatusCode(), map[string]string{
-		"error":   appErr.Code,
-		"message": appErr.Message,
-	})
+	status := appErr.StatusCode()
+	p := problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    appErr.Message,
+		Instance:  r.URL.Path,
+		Code:      appErr.Code,
+		RequestID: chimw.GetReqID(r.Context()),
+		Errors:    appErr.Violations,
+	}
+	if doc, ok := problemDocs[appErr.Code]; ok {
+		p.Type, p.Title = problemPath+appErr.Code, doc.Title
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(p)
 }
 
 func mapError(err error) *apperr.AppError {
@@ -28,6 +64,14 @@ func mapError(err error) *apperr.AppError {
 	if errors.As(err, &appErr) {
 		return appErr
 	}
+	var invalid validate.Errors
+	if errors.As(err, &invalid) {
+		violations := make([]apperr.Violation, len(invalid))
+		for i, fe := range invalid {
+			violations[i] = apperr.Violation{Field: fe.Field, Code: fe.Code, Message: fe.Message}
+		}
+		return apperr.Validation(violations...)
+	}
 
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
@@ -36,14 +80,31 @@ func mapError(err error) *apperr.AppError {
 		return apperr.Unauthorized("invalid_credentials", "invalid credentials", err)
 	case errors.Is(err, user.ErrInactiveUser):
 		return apperr.Unauthorized("inactive_user", "user is inactive", err)
+	case errors.Is(err, user.ErrInvalidToken):
+		return apperr.BadRequest("expired_link", "the link is invalid, expired or already used", err)
+	case errors.Is(err, user.ErrAlreadyVerified):
+		return apperr.Conflict("already_verified", "email already verified", err)
+	case errors.Is(err, user.ErrInvalidCode):
+		return apperr.Unauthorized("invalid_code", "invalid or already used code", err)
+	case errors.Is(err, user.ErrTwoFactorRequired):
+		return apperr.Forbidden("two_factor_required", "two-factor authentication is required for this role", err)
+	case errors.Is(err, user.ErrTwoFactorEnabled):
+		return apperr.Conflict("two_factor_enabled", "two-factor authentication is already enabled", err)
+	case errors.Is(err, user.ErrTwoFactorNotEnabled):
+		return apperr.Conflict("two_factor_not_enabled", "two-factor authentication is not enabled", err)
+	case errors.Is(err, user.ErrTwoFactorNotEnrolled):
+		return apperr.Conflict("two_factor_not_enrolled", "no two-factor enrollment to confirm; enroll first", err)
 	case errors.Is(err, user.ErrEmailTaken):
 		return apperr.BadRequest("email_taken", "email already taken", err)
 	case errors.Is(err, poll.ErrPollNotFound):
 		return apperr.NotFound("poll_not_found", "poll not found", err)
 	case errors.Is(err, poll.ErrInvalidStatus):
 		return apperr.BadRequest("invalid_status", "invalid poll status", err)
-	case errors.Is(err, poll.ErrInvalidDates):
-		return apperr.BadRequest("invalid_dates", "ends_at must be after starts_at", err)
+	case errors.Is(err, poll.ErrNothingToUpdate):
+		return apperr.BadRequest("invalid_input", "no fields to update", err)
+	case errors.Is(err, poll.ErrDuplicateOption):
+		// The unique index caught what validation should have.
+		return apperr.Validation(apperr.Violation{Field: "options", Code: "duplicate", Message: "options must be unique"})
 	case errors.Is(err, vote.ErrAlreadyVoted):
 		return apperr.Conflict("already_voted", "user already voted in this poll", err)
 	case errors.Is(err, vote.ErrPollNotActive):
@@ -52,6 +113,14 @@ func mapError(err error) *apperr.AppError {
 		return apperr.BadRequest("invalid_option", "option does not belong to poll", err)
 	case errors.Is(err, vote.ErrPollNotFound):
 		return apperr.NotFound("poll_not_found", "poll not found", err)
+	case errors.Is(err, stats.ErrInvalidConfidence):
+		return apperr.BadRequest("invalid_confidence", "confidence must be between 0 and 1", err)
+	case errors.Is(err, deadletter.ErrNotFound):
+		return apperr.NotFound("dead_letter_not_found", "dead letter not found", err)
+	case errors.Is(err, deadletter.ErrReplayFailed):
+		return apperr.Conflict("replay_failed", "replay failed; the entry was kept", err)
+	case errors.Is(err, vote.ErrInvalidBucket):
+		return apperr.BadRequest("invalid_bucket", "bucket must be one of minute, hour, day", err)
 	default:
 		return apperr.Internal("internal_error", http.StatusText(http.StatusInternalServerError), err)
 	}
@@ -2,20 +2,31 @@ package api
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"log/slog"
-	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	chimw "github.com/go-chi/chi/v5/middleware"
-	"golang.org/x/time/rate"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 
+	"polling-system/internal/domain/user"
 	"polling-system/internal/metrics"
 	"polling-system/internal/platform/apperr"
 	jwtpkg "polling-system/internal/platform/jwt"
+	"polling-system/internal/platform/logging"
+	"polling-system/internal/platform/tracing"
+	"polling-system/internal/ratelimit"
 )
 
 type ctxKey string
@@ -23,39 +34,38 @@ type ctxKey string
 const (
 	ctxKeyUserID ctxKey = "user_id"
 	ctxKeyRole   ctxKey = "role"
-)
-
-var slogLogger = slog.Default()
+	ctxKeyAMR    ctxKey = "amr"
 
-func SetLogger(l *slog.Logger) {
-	if l != nil {
-		slogLogger = l
-	}
-}
+	ctxKeyRequestInfo ctxKey = "request_info"
+)
 
 func AuthMiddleware(jm *jwtpkg.Manager) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			h := r.Header.Get("Authorization")
 			if h == "" {
-				errorResponse(w, apperr.Unauthorized("missing_token", "missing authorization header", nil))
+				errorResponse(w, r, apperr.Unauthorized("missing_token", "missing authorization header", nil))
 				return
 			}
 
 			parts := strings.SplitN(h, " ", 2)
 			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				errorResponse(w, apperr.Unauthorized("invalid_token", "invalid authorization header", nil))
+				errorResponse(w, r, apperr.Unauthorized("invalid_token", "invalid authorization header", nil))
 				return
 			}
 
 			claims, err := jm.Parse(parts[1])
 			if err != nil {
-				errorResponse(w, apperr.Unauthorized("invalid_token", "invalid token", err))
+				errorResponse(w, r, apperr.Unauthorized("invalid_token", "invalid token", err))
 				return
 			}
 
 			ctx := context.WithValue(r.Context(), ctxKeyUserID, claims.UserID)
 			ctx = context.WithValue(ctx, ctxKeyRole, claims.Role)
+			ctx = context.WithValue(ctx, ctxKeyAMR, claims.AMR)
+			if info, ok := ctx.Value(ctxKeyRequestInfo).(*requestInfo); ok {
+				info.userID.Store(claims.UserID)
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -66,7 +76,23 @@ func RequireRole(role string) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctxRole, ok := r.Context().Value(ctxKeyRole).(string)
 			if !ok || ctxRole != role {
-				errorResponse(w, apperr.Forbidden("forbidden", "insufficient permissions", nil))
+				errorResponse(w, r, apperr.Forbidden("forbidden", "insufficient permissions", nil))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireTwoFactor refuses tokens of roles that must use two-factor
+// authentication unless they were issued after a second factor.
+func RequireTwoFactor(svc *user.TwoFactorService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value(ctxKeyRole).(string)
+			amr, _ := r.Context().Value(ctxKeyAMR).([]string)
+			if svc.Required(role) && !slices.Contains(amr, jwtpkg.MethodOTP) {
+				errorResponse(w, r, apperr.Forbidden("two_factor_required", "your role requires two-factor authentication; set it up under /api/v1/auth/2fa and log in again", nil))
 				return
 			}
 			next.ServeHTTP(w, r)
@@ -83,29 +109,48 @@ func userIDFromCtx(r *http.Request) int64 {
 	return 0
 }
 
-func CORSMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type")
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PATCH,DELETE,OPTIONS")
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
+// CORSPolicy holds the allowed CORS origins. Origins can be replaced at
+// runtime with SetOrigins.
+type CORSPolicy struct {
+	origins atomic.Pointer[[]string]
+}
+
+func NewCORSPolicy(origins []string) *CORSPolicy {
+	p := &CORSPolicy{}
+	p.SetOrigins(origins)
+	return p
+}
+
+func (p *CORSPolicy) SetOrigins(origins []string) {
+	origins = append([]string(nil), origins...)
+	p.origins.Store(&origins)
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, or
+// "" if it is not allowed.
+func (p *CORSPolicy) allowOrigin(origin string) string {
+	for _, o := range *p.origins.Load() {
+		if o == "*" {
+			return "*"
 		}
-		next.ServeHTTP(w, r)
-	})
+		if origin != "" && strings.EqualFold(o, origin) {
+			return origin
+		}
+	}
+	return ""
 }
 
-func RateLimitVotes(r rate.Limit, burst int) func(http.Handler) http.Handler {
-	limiter := newIPRateLimiter(r, burst, 10*time.Minute)
+func CORS(p *CORSPolicy) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := clientIP(r)
-			if !limiter.allow(ip) {
-				writeJSON(w, http.StatusTooManyRequests, map[string]string{
-					"error":   "rate_limited",
-					"message": "too many requests",
-				})
+			w.Header().Add("Vary", "Origin")
+			if allowed := p.allowOrigin(r.Header.Get("Origin")); allowed != "" {
+				w.Header().Set("Access-Control-Allow-Origin", allowed)
+				w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type")
+				w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PATCH,DELETE,OPTIONS")
+			}
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusNoContent)
 				return
 			}
 			next.ServeHTTP(w, r)
@@ -113,86 +158,194 @@ func RateLimitVotes(r rate.Limit, burst int) func(http.Handler) http.Handler {
 	}
 }
 
-func RequestLogger(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		rw := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
-		next.ServeHTTP(rw, r)
+// RateLimit enforces the limiter policy registered for route. User-keyed
+// policies fall back to the client IP for anonymous requests.
+func RateLimit(l *ratelimit.Limiter, route string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := l.Policy(route)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-		status := rw.Status()
-		if status == 0 {
-			status = http.StatusOK
-		}
-		route := r.URL.Path
-		if rc := chi.RouteContext(r.Context()); rc != nil && rc.RoutePattern() != "" {
-			route = rc.RoutePattern()
-		}
+			subject := "ip:" + l.ClientIP(r)
+			if p.Key == ratelimit.KeyUser {
+				if id := userIDFromCtx(r); id != 0 {
+					subject = "user:" + strconv.FormatInt(id, 10)
+				}
+			}
 
-		metrics.IncRequest(r.Method, route, status)
+			d, _ := l.Allow(r.Context(), route, subject)
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.ResetAfter)))
+			if !d.Allowed {
+				if route == "vote" {
+					metrics.ObserveVote("rate_limited")
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(d.RetryAfter)))
+				errorResponse(w, r, apperr.TooManyRequests("rate_limited", "too many requests", nil))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-		slogLogger.Info("request",
-			"method", r.Method,
-			"path", route,
-			"status", status,
-			"duration_ms", time.Since(start).Milliseconds(),
-		)
-	})
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
 }
 
-type ipRateLimiter struct {
-	mu       sync.Mutex
-	limiters map[string]*rate.Limiter
-	lastSeen map[string]time.Time
-	limit    rate.Limit
-	burst    int
-	entryTTL time.Duration
+// maxRequestIDLen bounds the X-Request-ID values accepted from clients.
+const maxRequestIDLen = 128
+
+// RequestID takes the request ID from the X-Request-ID header, or generates
+// one if it is missing or malformed, and echoes it in the response. The ID
+// is stored where chi's middleware.GetReqID finds it.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(chimw.RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(chimw.RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), chimw.RequestIDKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
 
-func newIPRateLimiter(limit rate.Limit, burst int, entryTTL time.Duration) *ipRateLimiter {
-	return &ipRateLimiter{
-		limiters: make(map[string]*rate.Limiter),
-		lastSeen: make(map[string]time.Time),
-		limit:    limit,
-		burst:    burst,
-		entryTTL: entryTTL,
+// validRequestID accepts IDs that are safe to echo and to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
 	}
+	return true
 }
 
-func (l *ipRateLimiter) getLimiter(ip string) *rate.Limiter {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
 
-	now := time.Now()
-	for key, ts := range l.lastSeen {
-		if now.Sub(ts) > l.entryTTL {
-			delete(l.limiters, key)
-			delete(l.lastSeen, key)
-		}
-	}
+// requestInfo holds request fields that are only known after the logger
+// fields were added to the context: the user, set by AuthMiddleware, and the
+// route, filled in by chi while routing. Both are resolved when a record is
+// logged and left out while unknown.
+type requestInfo struct {
+	userID atomic.Int64
+	route  *chi.Context
+}
+
+type requestUser struct{ *requestInfo }
 
-	if limiter, ok := l.limiters[ip]; ok {
-		l.lastSeen[ip] = now
-		return limiter
+func (u requestUser) LogValue() slog.Value {
+	if id := u.userID.Load(); id != 0 {
+		return slog.Int64Value(id)
 	}
-	limiter := rate.NewLimiter(l.limit, l.burst)
-	l.limiters[ip] = limiter
-	l.lastSeen[ip] = now
-	return limiter
+	return slog.GroupValue()
 }
 
-func (l *ipRateLimiter) allow(ip string) bool {
-	limiter := l.getLimiter(ip)
-	return limiter.Allow()
+type requestRoute struct{ *requestInfo }
+
+func (rt requestRoute) LogValue() slog.Value {
+	if rt.route != nil && rt.route.RoutePattern() != "" {
+		return slog.StringValue(rt.route.RoutePattern())
+	}
+	return slog.GroupValue()
 }
 
-func clientIP(r *http.Request) string {
-	if xfwd := r.Header.Get("X-Forwarded-For"); xfwd != "" {
-		parts := strings.Split(xfwd, ",")
-		return strings.TrimSpace(parts[0])
+// RequestLogger adds the request ID, client IP, user ID and route to the
+// log fields of the request context, so that every layer logging with that
+// context carries them, and logs one record per request.
+func RequestLogger(clientIP func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			done := metrics.TrackInFlight()
+			defer done()
+
+			info := &requestInfo{route: chi.RouteContext(r.Context())}
+			ctx := context.WithValue(r.Context(), ctxKeyRequestInfo, info)
+			ctx = logging.With(ctx,
+				"request_id", chimw.GetReqID(ctx),
+				"client_ip", clientIP(r),
+				"user_id", requestUser{info},
+				"route", requestRoute{info},
+			)
+			r = r.WithContext(ctx)
+
+			rw := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(rw, r)
+			took := time.Since(start)
+
+			status := rw.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := routePattern(r)
+
+			metrics.ObserveRequest(r.Method, route, status, took)
+
+			slog.InfoContext(ctx, "request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", status,
+				"duration_ms", took.Milliseconds(),
+			)
+		})
 	}
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+}
+
+// unmatchedRoute stands in for the route pattern of requests that matched no
+// route, so that arbitrary paths do not become metric labels or span names.
+const unmatchedRoute = "unmatched"
+
+// routePattern returns the chi route pattern that matched r, or
+// unmatchedRoute before routing or when nothing matched.
+func routePattern(r *http.Request) string {
+	if rc := chi.RouteContext(r.Context()); rc != nil && rc.RoutePattern() != "" {
+		return rc.RoutePattern()
 	}
-	return host
+	return unmatchedRoute
+}
+
+// Tracing starts a server span for every request, continuing the trace of
+// an incoming traceparent header. The span is named after the route pattern
+// once routing is done.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		rw := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		status := rw.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := routePattern(r)
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", status),
+		)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
 }
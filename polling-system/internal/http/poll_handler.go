@@ -1,11 +1,12 @@
 package api
 
 import (
-	"encoding/json"
 	"net/http"
+	"time"
 
 	"polling-system/internal/domain/poll"
 	"polling-system/internal/platform/apperr"
+	"polling-system/internal/platform/validate"
 )
 
 type createPollRequest struct {
@@ -40,61 +41,42 @@ type pollDetailsResponse struct {
 // @Produce     json
 // @Param       request  body      createPollRequest  true  "Poll payload"
 // @Success     201      {object}  map[string]int64
-// @Failure     400      {object}  map[string]string  "invalid body"
-// @Failure     401      {object}  map[string]string  "unauthorized"
-// @Failure     403      {object}  map[string]string  "forbidden"
-// @Failure     500      {object}  map[string]string  "server error"
+// @Failure     400      {object}  problem            "invalid body or fields"
+// @Failure     401      {object}  problem            "unauthorized"
+// @Failure     403      {object}  problem            "forbidden"
+// @Failure     500      {object}  problem            "server error"
 // @Router      /api/v1/polls [post]
 func (h *Handler) handleCreatePoll(w http.ResponseWriter, r *http.Request) {
 	var req createPollRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid body", err))
-		return
-	}
-
-	if req.Title == "" {
-		errorResponse(w, apperr.BadRequest("invalid_input", "title is required", nil))
-		return
-	}
-
-	if len(req.Options) < 2 {
-		errorResponse(w, apperr.BadRequest("invalid_input", "at least 2 options are required", nil))
-		return
-	}
-
-	userID := userIDFromCtx(r)
-
-	startsAt := parseTimePtr(req.StartsAt)
-	if req.StartsAt != nil && *req.StartsAt != "" && startsAt == nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid starts_at format", nil))
-		return
-	}
-	endsAt := parseTimePtr(req.EndsAt)
-	if req.EndsAt != nil && *req.EndsAt != "" && endsAt == nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid ends_at format", nil))
-		return
-	}
-	if startsAt != nil && endsAt != nil && endsAt.Before(*startsAt) {
-		errorResponse(w, apperr.BadRequest("invalid_dates", "ends_at must be after starts_at", nil))
+	if err := h.decodeJSON(w, r, &req); err != nil {
+		errorResponse(w, r, err)
 		return
 	}
 
+	v := validate.New()
+	startsAt, endsAt := parsePollDates(v, req.StartsAt, req.EndsAt)
 	p := &poll.Poll{
 		Title:       req.Title,
 		Description: req.Description,
 		StartsAt:    startsAt,
 		EndsAt:      endsAt,
-		CreatorID:   userID,
+		CreatorID:   userIDFromCtx(r),
 	}
-
 	opts := make([]poll.Option, 0, len(req.Options))
 	for _, text := range req.Options {
 		opts = append(opts, poll.Option{Text: text})
 	}
+	// The service checks the poll again; checking here as well reports
+	// malformed dates together with the poll's own violations.
+	v.Merge(poll.Validate(p, opts))
+	if err := v.Err(); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
 
 	id, err := h.pollSvc.Create(r.Context(), p, opts)
 	if err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
 		return
 	}
 
@@ -107,8 +89,8 @@ func (h *Handler) handleCreatePoll(w http.ResponseWriter, r *http.Request) {
 // @Produce     json
 // @Param       status  query     string  false  "Filter by status"  Enums(draft,active,closed)
 // @Success     200     {array}   poll.Poll
-// @Failure     401     {object}  map[string]string  "unauthorized"
-// @Failure     500     {object}  map[string]string  "server error"
+// @Failure     401     {object}  problem            "unauthorized"
+// @Failure     500     {object}  problem            "server error"
 // @Router      /api/v1/polls [get]
 func (h *Handler) handleListPolls(w http.ResponseWriter, r *http.Request) {
 	statusParam := r.URL.Query().Get("status")
@@ -118,7 +100,7 @@ func (h *Handler) handleListPolls(w http.ResponseWriter, r *http.Request) {
 	}
 	polls, err := h.pollSvc.List(r.Context(), status)
 	if err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, polls)
@@ -130,21 +112,21 @@ func (h *Handler) handleListPolls(w http.ResponseWriter, r *http.Request) {
 // @Produce     json
 // @Param       id   path     int64  true  "Poll ID"
 // @Success     200  {object} pollDetailsResponse
-// @Failure     400  {object}  map[string]string  "invalid id"
-// @Failure     401  {object}  map[string]string  "unauthorized"
-// @Failure     404  {object}  map[string]string  "not found"
-// @Failure     500  {object}  map[string]string  "server error"
+// @Failure     400  {object}  problem            "invalid id"
+// @Failure     401  {object}  problem            "unauthorized"
+// @Failure     404  {object}  problem            "not found"
+// @Failure     500  {object}  problem            "server error"
 // @Router      /api/v1/polls/{id} [get]
 func (h *Handler) handleGetPoll(w http.ResponseWriter, r *http.Request) {
 	id, err := parseIDParam(r, "id")
 	if err != nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid id", err))
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid id", err))
 		return
 	}
 
 	p, opts, err := h.pollSvc.Get(r.Context(), id)
 	if err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
 		return
 	}
 
@@ -162,27 +144,27 @@ func (h *Handler) handleGetPoll(w http.ResponseWriter, r *http.Request) {
 // @Param       id       path      int64                true  "Poll ID"
 // @Param       request  body      updateStatusRequest  true  "New status"
 // @Success     204
-// @Failure     400      {object}  map[string]string  "invalid id or status"
-// @Failure     401      {object}  map[string]string  "unauthorized"
-// @Failure     403      {object}  map[string]string  "forbidden"
-// @Failure     404      {object}  map[string]string  "not found"
-// @Failure     500      {object}  map[string]string  "server error"
+// @Failure     400      {object}  problem            "invalid id or status"
+// @Failure     401      {object}  problem            "unauthorized"
+// @Failure     403      {object}  problem            "forbidden"
+// @Failure     404      {object}  problem            "not found"
+// @Failure     500      {object}  problem            "server error"
 // @Router      /api/v1/polls/{id}/status [patch]
 func (h *Handler) handleUpdatePollStatus(w http.ResponseWriter, r *http.Request) {
 	id, err := parseIDParam(r, "id")
 	if err != nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid id", err))
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid id", err))
 		return
 	}
 
 	var req updateStatusRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid body", err))
+	if err := h.decodeJSON(w, r, &req); err != nil {
+		errorResponse(w, r, err)
 		return
 	}
 
 	if err := h.pollSvc.UpdateStatus(r.Context(), id, req.Status); err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
 		return
 	}
 
@@ -197,59 +179,41 @@ func (h *Handler) handleUpdatePollStatus(w http.ResponseWriter, r *http.Request)
 // @Param       id       path      int64               true  "Poll ID"
 // @Param       request  body      updatePollRequest   true  "Poll fields"
 // @Success     204
-// @Failure     400      {object}  map[string]string  "invalid input"
-// @Failure     401      {object}  map[string]string  "unauthorized"
-// @Failure     403      {object}  map[string]string  "forbidden"
-// @Failure     404      {object}  map[string]string  "not found"
-// @Failure     500      {object}  map[string]string  "server error"
+// @Failure     400      {object}  problem            "invalid input"
+// @Failure     401      {object}  problem            "unauthorized"
+// @Failure     403      {object}  problem            "forbidden"
+// @Failure     404      {object}  problem            "not found"
+// @Failure     500      {object}  problem            "server error"
 // @Router      /api/v1/polls/{id} [patch]
 func (h *Handler) handleUpdatePoll(w http.ResponseWriter, r *http.Request) {
 	id, err := parseIDParam(r, "id")
 	if err != nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid id", err))
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid id", err))
 		return
 	}
 
 	var req updatePollRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid body", err))
-		return
-	}
-
-	if req.Title != nil && *req.Title == "" {
-		errorResponse(w, apperr.BadRequest("invalid_input", "title is required", nil))
-		return
-	}
-
-	startsAt := parseTimePtr(req.StartsAt)
-	if req.StartsAt != nil && *req.StartsAt != "" && startsAt == nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid starts_at format", nil))
-		return
-	}
-	endsAt := parseTimePtr(req.EndsAt)
-	if req.EndsAt != nil && *req.EndsAt != "" && endsAt == nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid ends_at format", nil))
-		return
-	}
-	if startsAt != nil && endsAt != nil && endsAt.Before(*startsAt) {
-		errorResponse(w, apperr.BadRequest("invalid_dates", "ends_at must be after starts_at", nil))
-		return
-	}
-
-	if req.Title == nil && req.Description == nil && startsAt == nil && endsAt == nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "no fields to update", nil))
+	if err := h.decodeJSON(w, r, &req); err != nil {
+		errorResponse(w, r, err)
 		return
 	}
 
+	v := validate.New()
+	startsAt, endsAt := parsePollDates(v, req.StartsAt, req.EndsAt)
 	input := poll.UpdateInput{
 		Title:       req.Title,
 		Description: req.Description,
 		StartsAt:    startsAt,
 		EndsAt:      endsAt,
 	}
+	v.Merge(input.Validate())
+	if err := v.Err(); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
 
 	if err := h.pollSvc.Update(r.Context(), id, input); err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
 		return
 	}
 
@@ -262,22 +226,34 @@ func (h *Handler) handleUpdatePoll(w http.ResponseWriter, r *http.Request) {
 // @Security    BearerAuth
 // @Param       id   path  int64  true  "Poll ID"
 // @Success     204
-// @Failure     401  {object}  map[string]string  "unauthorized"
-// @Failure     403  {object}  map[string]string  "forbidden"
-// @Failure     404  {object}  map[string]string  "not found"
-// @Failure     500  {object}  map[string]string  "server error"
+// @Failure     401  {object}  problem            "unauthorized"
+// @Failure     403  {object}  problem            "forbidden"
+// @Failure     404  {object}  problem            "not found"
+// @Failure     500  {object}  problem            "server error"
 // @Router      /api/v1/polls/{id} [delete]
 func (h *Handler) handleDeletePoll(w http.ResponseWriter, r *http.Request) {
 	id, err := parseIDParam(r, "id")
 	if err != nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid id", err))
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid id", err))
 		return
 	}
 
 	if err := h.pollSvc.Delete(r.Context(), id); err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// parsePollDates parses the optional dates of a poll, recording malformed
+// ones in v. An empty string leaves a date unset.
+func parsePollDates(v *validate.Validator, rawStartsAt, rawEndsAt *string) (startsAt, endsAt *time.Time) {
+	if rawStartsAt != nil && *rawStartsAt != "" {
+		v.String("starts_at", *rawStartsAt, validate.RFC3339)
+	}
+	if rawEndsAt != nil && *rawEndsAt != "" {
+		v.String("ends_at", *rawEndsAt, validate.RFC3339)
+	}
+	return parseTimePtr(rawStartsAt), parseTimePtr(rawEndsAt)
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/chi/v5"
+
+	"polling-system/internal/platform/apperr"
+)
+
+// problemPath prefixes the type of every documented problem, so clients can
+// follow the type of an error response to its documentation.
+const problemPath = "/problems/"
+
+type problemDoc struct {
+	Code        string `json:"code" example:"validation_failed"`
+	Title       string `json:"title" example:"Validation failed"`
+	Status      int    `json:"status" example:"400"`
+	Description string `json:"description" example:"One or more fields of the request are invalid. errors lists every invalid field."`
+}
+
+// problemDocs documents every error code the API returns. A code missing
+// here is still returned, with the type about:blank.
+var problemDocs = map[string]problemDoc{
+	"validation_failed": {
+		Title: "Validation failed", Status: http.StatusBadRequest,
+		Description: "One or more fields of the request are invalid. errors lists every invalid field with its own code and message; unknown fields and values of the wrong JSON type are reported here too.",
+	},
+	"invalid_input": {
+		Title: "Invalid input", Status: http.StatusBadRequest,
+		Description: "The request body is missing or not a single JSON value, or a path or query parameter is malformed.",
+	},
+	"body_too_large": {
+		Title: "Body too large", Status: http.StatusRequestEntityTooLarge,
+		Description: "The request body is larger than the configured http.max_body_bytes.",
+	},
+	"invalid_status": {
+		Title: "Invalid poll status", Status: http.StatusBadRequest,
+		Description: "The status is not one of draft, active or closed.",
+	},
+	"email_taken": {
+		Title: "Email taken", Status: http.StatusBadRequest,
+		Description: "An account with this email already exists.",
+	},
+	"expired_link": {
+		Title: "Expired link", Status: http.StatusBadRequest,
+		Description: "The email verification or password reset token is unknown, expired, already used or replaced by a newer link. Ask for a new email.",
+	},
+	"poll_not_active": {
+		Title: "Poll not active", Status: http.StatusBadRequest,
+		Description: "Votes are only accepted while the poll is active and within its dates.",
+	},
+	"invalid_option": {
+		Title: "Invalid option", Status: http.StatusBadRequest,
+		Description: "The option does not belong to the poll.",
+	},
+	"invalid_confidence": {
+		Title: "Invalid confidence", Status: http.StatusBadRequest,
+		Description: "The confidence level must be between 0 and 1, exclusive.",
+	},
+	"invalid_bucket": {
+		Title: "Invalid bucket", Status: http.StatusBadRequest,
+		Description: "The analytics bucket must be one of minute, hour or day.",
+	},
+	"missing_token": {
+		Title: "Missing token", Status: http.StatusUnauthorized,
+		Description: "The request has no bearer token.",
+	},
+	"invalid_token": {
+		Title: "Invalid token", Status: http.StatusUnauthorized,
+		Description: "The bearer token is malformed, expired or signed with another key.",
+	},
+	"invalid_credentials": {
+		Title: "Invalid credentials", Status: http.StatusUnauthorized,
+		Description: "The email or password is wrong.",
+	},
+	"invalid_code": {
+		Title: "Invalid code", Status: http.StatusUnauthorized,
+		Description: "The two-factor code is wrong, too old or already used, or the recovery code is unknown or already used. Wrong codes count as failed logins.",
+	},
+	"inactive_user": {
+		Title: "Inactive user", Status: http.StatusUnauthorized,
+		Description: "The account has been deactivated.",
+	},
+	"forbidden": {
+		Title: "Forbidden", Status: http.StatusForbidden,
+		Description: "The account's role does not allow this request.",
+	},
+	"two_factor_required": {
+		Title: "Two-factor authentication required", Status: http.StatusForbidden,
+		Description: "The account's role requires two-factor authentication. Set it up under /api/v1/auth/2fa and use the token returned on confirmation or log in again; it cannot be turned off.",
+	},
+	"not_found": {
+		Title: "Not found", Status: http.StatusNotFound,
+		Description: "No resource exists at this path.",
+	},
+	"poll_not_found": {
+		Title: "Poll not found", Status: http.StatusNotFound,
+		Description: "No poll has this ID.",
+	},
+	"dead_letter_not_found": {
+		Title: "Dead letter not found", Status: http.StatusNotFound,
+		Description: "No dead-letter entry has this ID.",
+	},
+	"already_voted": {
+		Title: "Already voted", Status: http.StatusConflict,
+		Description: "The user has already voted in this poll.",
+	},
+	"already_verified": {
+		Title: "Already verified", Status: http.StatusConflict,
+		Description: "The email address of the account is already verified.",
+	},
+	"two_factor_enabled": {
+		Title: "Two-factor authentication enabled", Status: http.StatusConflict,
+		Description: "Two-factor authentication is already on. Turn it off before enrolling a new authenticator.",
+	},
+	"two_factor_not_enabled": {
+		Title: "Two-factor authentication not enabled", Status: http.StatusConflict,
+		Description: "Two-factor authentication is not on for the account.",
+	},
+	"two_factor_not_enrolled": {
+		Title: "Two-factor authentication not enrolled", Status: http.StatusConflict,
+		Description: "There is no enrollment to confirm. Enroll first, then confirm with a code from the authenticator app.",
+	},
+	"replay_failed": {
+		Title: "Replay failed", Status: http.StatusConflict,
+		Description: "The dead-letter entry could not be replayed and was kept.",
+	},
+	"login_delayed": {
+		Title: "Login delayed", Status: http.StatusTooManyRequests,
+		Description: "Recent failed logins for this email or from this IP address delay the next attempt. Retry after the number of seconds in the Retry-After header.",
+	},
+	"login_locked": {
+		Title: "Login locked", Status: http.StatusTooManyRequests,
+		Description: "Too many failed logins locked this email or IP address for a while. Retry after the number of seconds in the Retry-After header, or ask an admin to unlock the account.",
+	},
+	"rate_limited": {
+		Title: "Rate limited", Status: http.StatusTooManyRequests,
+		Description: "Too many requests. Retry after the number of seconds in the Retry-After header.",
+	},
+	"internal_error": {
+		Title: "Internal error", Status: http.StatusInternalServerError,
+		Description: "The server failed to handle the request. Quote the request_id when reporting it.",
+	},
+}
+
+// @Summary     List error types
+// @Description Documentation of every error code, the target of the type of an error response.
+// @Tags        problems
+// @Produce     json
+// @Success     200  {array}   problemDoc
+// @Router      /problems [get]
+func (h *Handler) handleListProblems(w http.ResponseWriter, r *http.Request) {
+	docs := make([]problemDoc, 0, len(problemDocs))
+	for code, doc := range problemDocs {
+		doc.Code = code
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Code < docs[j].Code })
+	writeJSON(w, http.StatusOK, docs)
+}
+
+// @Summary     Describe error type
+// @Tags        problems
+// @Produce     json
+// @Param       code  path      string  true  "Error code"
+// @Success     200   {object}  problemDoc
+// @Failure     404   {object}  problem  "unknown code"
+// @Router      /problems/{code} [get]
+func (h *Handler) handleGetProblem(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	doc, ok := problemDocs[code]
+	if !ok {
+		errorResponse(w, r, apperr.NotFound("not_found", "unknown error code", nil))
+		return
+	}
+	doc.Code = code
+	writeJSON(w, http.StatusOK, doc)
+}
+
+func handleNotFound(w http.ResponseWriter, r *http.Request) {
+	errorResponse(w, r, apperr.NotFound("not_found", "resource not found", nil))
+}
@@ -1,89 +1,150 @@
 package api
 
 import (
-	"context"
-	"database/sql"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	httpSwagger "github.com/swaggo/http-swagger"
 
 	"github.com/go-chi/chi/v5"
 	chimw "github.com/go-chi/chi/v5/middleware"
-	"golang.org/x/time/rate"
 
+	"polling-system/internal/domain/audit"
+	"polling-system/internal/domain/dashboard"
+	"polling-system/internal/domain/deadletter"
 	"polling-system/internal/domain/poll"
 	"polling-system/internal/domain/user"
 	"polling-system/internal/domain/vote"
+	"polling-system/internal/health"
+	"polling-system/internal/metrics"
 	jwtpkg "polling-system/internal/platform/jwt"
+	"polling-system/internal/ratelimit"
 	"polling-system/internal/worker"
 )
 
 type Handler struct {
-	userSvc *user.Service
-	pollSvc *poll.Service
-	voteSvc *vote.Service
-	jwtMgr  *jwtpkg.Manager
-	voteCh  chan<- worker.VoteEvent
-	db      *sql.DB
+	userSvc       *user.Service
+	accountSvc    *user.AccountService
+	twoFactorSvc  *user.TwoFactorService
+	pollSvc       *poll.Service
+	voteSvc       *vote.Service
+	deadLetterSvc *deadletter.Service
+	dashboardSvc  *dashboard.Service
+	auditSvc      *audit.Service
+	jwtMgr        *jwtpkg.Manager
+	voteCh        chan<- worker.VoteEvent
+	health        *health.Registry
+	limiter       *ratelimit.Limiter
+	loginGuard    *ratelimit.LoginGuard
+	tokenTTL      time.Duration
+	challengeTTL  time.Duration
+	maxBodyBytes  int64
 }
 
 func NewRouter(
 	userSvc *user.Service,
+	accountSvc *user.AccountService,
+	twoFactorSvc *user.TwoFactorService,
 	pollSvc *poll.Service,
 	voteSvc *vote.Service,
+	deadLetterSvc *deadletter.Service,
+	dashboardSvc *dashboard.Service,
+	auditSvc *audit.Service,
 	jwtMgr *jwtpkg.Manager,
 	voteCh chan<- worker.VoteEvent,
-	db *sql.DB,
+	healthChecks *health.Registry,
+	limiter *ratelimit.Limiter,
+	loginGuard *ratelimit.LoginGuard,
+	cors *CORSPolicy,
+	tokenTTL time.Duration,
+	challengeTTL time.Duration,
+	maxBodyBytes int,
 ) http.Handler {
 	h := &Handler{
-		userSvc: userSvc,
-		pollSvc: pollSvc,
-		voteSvc: voteSvc,
-		jwtMgr:  jwtMgr,
-		voteCh:  voteCh,
-		db:      db,
+		userSvc:       userSvc,
+		accountSvc:    accountSvc,
+		twoFactorSvc:  twoFactorSvc,
+		pollSvc:       pollSvc,
+		voteSvc:       voteSvc,
+		deadLetterSvc: deadLetterSvc,
+		dashboardSvc:  dashboardSvc,
+		auditSvc:      auditSvc,
+		jwtMgr:        jwtMgr,
+		voteCh:        voteCh,
+		health:        healthChecks,
+		limiter:       limiter,
+		loginGuard:    loginGuard,
+		tokenTTL:      tokenTTL,
+		challengeTTL:  challengeTTL,
+		maxBodyBytes:  int64(maxBodyBytes),
 	}
 
 	r := chi.NewRouter()
-	r.Use(chimw.RequestID)
-	r.Use(chimw.RealIP)
+	r.Use(RequestID)
+	r.Use(Tracing)
 	r.Use(chimw.Recoverer)
 	r.Use(chimw.Timeout(60 * time.Second))
-	r.Use(RequestLogger)
-	r.Use(CORSMiddleware)
+	r.Use(RequestLogger(limiter.ClientIP))
+	r.Use(CORS(cors))
+	r.NotFound(handleNotFound)
 
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
-	})
+	r.Get("/health", h.handleHealth)
 	r.Get("/ready", h.handleReady)
 	r.Get("/swagger/*", httpSwagger.WrapHandler)
-	r.Get("/metrics", promhttp.Handler().ServeHTTP)
+	r.Get("/metrics", metrics.Handler().ServeHTTP)
+	r.Get("/problems", h.handleListProblems)
+	r.Get("/problems/{code}", h.handleGetProblem)
 
 	r.Route("/api/v1", func(r chi.Router) {
-		r.Post("/auth/register", h.handleRegister)
-		r.Post("/auth/login", h.handleLogin)
+		r.With(RateLimit(limiter, "register")).Post("/auth/register", h.handleRegister)
+		r.With(RateLimit(limiter, "login")).Post("/auth/login", h.handleLogin)
+		r.With(RateLimit(limiter, "login")).Post("/auth/login/2fa", h.handleLoginTwoFactor)
+		r.With(RateLimit(limiter, "account_token")).Get("/auth/verify-email", h.handleVerifyEmail)
+		r.With(RateLimit(limiter, "password_reset")).Post("/auth/forgot-password", h.handleForgotPassword)
+		r.With(RateLimit(limiter, "account_token")).Post("/auth/reset-password", h.handleResetPassword)
 
 		r.Group(func(r chi.Router) {
 			r.Use(AuthMiddleware(jwtMgr))
 
-			r.Get("/polls", h.handleListPolls)
-			r.Get("/polls/{id}", h.handleGetPoll)
-			r.With(RateLimitVotes(rate.Every(time.Minute/10), 3)).Post("/polls/{id}/vote", h.handleVote)
-			r.Get("/polls/{id}/results", h.handlePollResults)
+			// Users whose role requires two-factor authentication reach
+			// only these routes until they set it up.
+			r.Get("/auth/2fa", h.handleTwoFactorStatus)
+			r.Post("/auth/2fa/enroll", h.handleEnrollTwoFactor)
+			r.Post("/auth/2fa/confirm", h.handleConfirmTwoFactor)
+			r.Post("/auth/2fa/disable", h.handleDisableTwoFactor)
+			r.Post("/auth/2fa/recovery-codes", h.handleRegenerateRecoveryCodes)
 
 			r.Group(func(r chi.Router) {
-				r.Use(RequireRole("admin"))
-				r.Post("/polls", h.handleCreatePoll)
-				r.Patch("/polls/{id}", h.handleUpdatePoll)
-				r.Patch("/polls/{id}/status", h.handleUpdatePollStatus)
-				r.Delete("/polls/{id}", h.handleDeletePoll)
-				r.Get("/users", h.handleListUsers)
-				r.Patch("/users/{id}/role", h.handleUpdateUserRole)
-				r.Patch("/users/{id}/deactivate", h.handleDeactivateUser)
+				r.Use(RequireTwoFactor(twoFactorSvc))
+
+				r.With(RateLimit(limiter, "verify_email")).Post("/auth/verify-email/resend", h.handleResendVerification)
+				r.Get("/polls", h.handleListPolls)
+				r.Get("/polls/{id}", h.handleGetPoll)
+				r.With(RateLimit(limiter, "vote")).Post("/polls/{id}/vote", h.handleVote)
+				r.Get("/polls/{id}/results", h.handlePollResults)
+				r.Get("/polls/{id}/analytics", h.handlePollAnalytics)
+
+				r.Group(func(r chi.Router) {
+					r.Use(RequireRole("admin"))
+					r.Post("/polls", h.handleCreatePoll)
+					r.Patch("/polls/{id}", h.handleUpdatePoll)
+					r.Patch("/polls/{id}/status", h.handleUpdatePollStatus)
+					r.Delete("/polls/{id}", h.handleDeletePoll)
+					r.Get("/users", h.handleListUsers)
+					r.Patch("/users/{id}/role", h.handleUpdateUserRole)
+					r.Patch("/users/{id}/deactivate", h.handleDeactivateUser)
+					r.Patch("/users/{id}/unlock", h.handleUnlockUser)
+					r.Delete("/users/{id}/2fa", h.handleResetTwoFactor)
+					r.Get("/admin/stats", h.handleAdminStats)
+					r.Get("/admin/audit-log", h.handleListAuditLog)
+					r.Get("/admin/dead-letters", h.handleListDeadLetters)
+					r.Post("/admin/dead-letters/replay", h.handleReplayAllDeadLetters)
+					r.Get("/admin/dead-letters/{id}", h.handleGetDeadLetter)
+					r.Post("/admin/dead-letters/{id}/replay", h.handleReplayDeadLetter)
+					r.Delete("/admin/dead-letters/{id}", h.handleDiscardDeadLetter)
+				})
 			})
 		})
 	})
@@ -113,25 +174,34 @@ func parseTimePtr(s *string) *time.Time {
 	return &t
 }
 
-func (h *Handler) handleReady(w http.ResponseWriter, r *http.Request) {
-	if h.db == nil {
-		writeJSON(w, http.StatusServiceUnavailable, map[string]string{
-			"error":   "db_unavailable",
-			"message": "database not configured",
-		})
-		return
-	}
+// @Summary     Liveness probe
+// @Description Checks that the process is working, such as the stats worker heartbeat. Fails only when a restart would help; dependencies are covered by /ready.
+// @Tags        health
+// @Produce     json
+// @Success     200  {object}  health.Report  "up or degraded"
+// @Failure     503  {object}  health.Report  "down"
+// @Router      /health [get]
+func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, h.health.Liveness(r.Context()))
+}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+// @Summary     Readiness probe
+// @Description Checks the dependencies needed to serve traffic: database, schema version, vote queue, results cache, and whether the server is shutting down.
+// @Tags        health
+// @Produce     json
+// @Success     200  {object}  health.Report  "up or degraded"
+// @Failure     503  {object}  health.Report  "down"
+// @Router      /ready [get]
+func (h *Handler) handleReady(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, h.health.Readiness(r.Context()))
+}
 
-	if err := h.db.PingContext(ctx); err != nil {
-		writeJSON(w, http.StatusServiceUnavailable, map[string]string{
-			"error":   "db_unavailable",
-			"message": "database not ready",
-		})
-		return
+// writeReport answers 503 when a check is down; degraded checks do not fail
+// the probe.
+func writeReport(w http.ResponseWriter, report health.Report) {
+	status := http.StatusOK
+	if report.Status == health.StatusDown {
+		status = http.StatusServiceUnavailable
 	}
-
-	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+	writeJSON(w, status, report)
 }
@@ -3,351 +3,111 @@ package api
 import (
 	"bytes"
 	"context"
-	"database/sql"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"golang.org/x/crypto/bcrypt"
 
+	"polling-system/internal/domain/audit"
+	"polling-system/internal/domain/dashboard"
+	"polling-system/internal/domain/deadletter"
 	"polling-system/internal/domain/poll"
 	"polling-system/internal/domain/user"
 	"polling-system/internal/domain/vote"
+	"polling-system/internal/health"
 	jwtpkg "polling-system/internal/platform/jwt"
+	"polling-system/internal/platform/logging"
+	"polling-system/internal/platform/mail"
+	"polling-system/internal/platform/totp"
+	"polling-system/internal/ratelimit"
+	"polling-system/internal/repository/memory"
 	"polling-system/internal/worker"
 )
 
-type testUserRepo struct {
-	mu     sync.Mutex
-	users  map[int64]*user.User
-	byMail map[string]int64
-	nextID int64
-}
-
-func newTestUserRepo() *testUserRepo {
-	return &testUserRepo{
-		users:  make(map[int64]*user.User),
-		byMail: make(map[string]int64),
-		nextID: 1,
-	}
-}
-
-func (r *testUserRepo) seed(u *user.User) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if u.ID == 0 {
-		u.ID = r.nextID
-		r.nextID++
-	}
-	if u.CreatedAt.IsZero() {
-		u.CreatedAt = time.Now()
-	}
-	if !u.IsActive {
-		u.IsActive = true
-	}
-	copyUser := *u
-	r.users[u.ID] = &copyUser
-	r.byMail[u.Email] = u.ID
-}
-
-func (r *testUserRepo) Create(ctx context.Context, u *user.User) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	u.ID = r.nextID
-	r.nextID++
-	u.CreatedAt = time.Now()
-	if !u.IsActive {
-		u.IsActive = true
-	}
-	copyUser := *u
-	r.users[u.ID] = &copyUser
-	r.byMail[u.Email] = u.ID
-	return nil
-}
-
-func (r *testUserRepo) GetByEmail(ctx context.Context, email string) (*user.User, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	id, ok := r.byMail[email]
-	if !ok {
-		return nil, sql.ErrNoRows
-	}
-	copyUser := *r.users[id]
-	return &copyUser, nil
-}
-
-func (r *testUserRepo) GetByID(ctx context.Context, id int64) (*user.User, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	u, ok := r.users[id]
-	if !ok {
-		return nil, sql.ErrNoRows
-	}
-	copyUser := *u
-	return &copyUser, nil
-}
-
-func (r *testUserRepo) List(ctx context.Context) ([]user.User, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	res := make([]user.User, 0, len(r.users))
-	for _, u := range r.users {
-		res = append(res, *u)
-	}
-	return res, nil
-}
-
-func (r *testUserRepo) UpdateRole(ctx context.Context, id int64, role string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	u, ok := r.users[id]
-	if !ok {
-		return sql.ErrNoRows
-	}
-	u.Role = role
-	return nil
-}
-
-func (r *testUserRepo) Deactivate(ctx context.Context, id int64) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	u, ok := r.users[id]
-	if !ok {
-		return sql.ErrNoRows
-	}
-	u.IsActive = false
-	return nil
-}
-
-type testPollRepo struct {
-	mu           sync.Mutex
-	polls        map[int64]*poll.Poll
-	opts         map[int64][]poll.Option
-	nextPollID   int64
-	nextOptionID int64
-}
-
-func newTestPollRepo() *testPollRepo {
-	return &testPollRepo{
-		polls:        make(map[int64]*poll.Poll),
-		opts:         make(map[int64][]poll.Option),
-		nextPollID:   1,
-		nextOptionID: 1,
-	}
-}
-
-func (r *testPollRepo) Create(ctx context.Context, p *poll.Poll, options []poll.Option) (int64, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	p.ID = r.nextPollID
-	r.nextPollID++
-	now := time.Now()
-	p.CreatedAt = now
-	p.UpdatedAt = now
-	copyPoll := *p
-	r.polls[p.ID] = &copyPoll
-
-	cloned := make([]poll.Option, len(options))
-	for i := range options {
-		options[i].ID = r.nextOptionID
-		r.nextOptionID++
-		options[i].PollID = p.ID
-		options[i].CreatedAt = now
-		cloned[i] = options[i]
-	}
-	r.opts[p.ID] = cloned
-	return p.ID, nil
-}
-
-func (r *testPollRepo) GetByID(ctx context.Context, id int64) (*poll.Poll, []poll.Option, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	p, ok := r.polls[id]
-	if !ok {
-		return nil, nil, sql.ErrNoRows
-	}
-	opts := r.opts[id]
-	copyPoll := *p
-	copiedOpts := make([]poll.Option, len(opts))
-	copy(copiedOpts, opts)
-	return &copyPoll, copiedOpts, nil
-}
-
-func (r *testPollRepo) List(ctx context.Context, status *string) ([]poll.Poll, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	res := []poll.Poll{}
-	for _, p := range r.polls {
-		if status != nil && p.Status != *status {
-			continue
-		}
-		res = append(res, *p)
-	}
-	return res, nil
-}
-
-func (r *testPollRepo) UpdateStatus(ctx context.Context, id int64, status string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	p, ok := r.polls[id]
-	if !ok {
-		return sql.ErrNoRows
-	}
-	p.Status = status
-	p.UpdatedAt = time.Now()
-	return nil
-}
-
-func (r *testPollRepo) Update(ctx context.Context, id int64, input poll.UpdateInput) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	p, ok := r.polls[id]
-	if !ok {
-		return sql.ErrNoRows
-	}
-	if input.Title != nil {
-		p.Title = *input.Title
-	}
-	if input.Description != nil {
-		p.Description = input.Description
-	}
-	if input.StartsAt != nil {
-		p.StartsAt = input.StartsAt
-	}
-	if input.EndsAt != nil {
-		p.EndsAt = input.EndsAt
-	}
-	p.UpdatedAt = time.Now()
-	return nil
-}
-
-func (r *testPollRepo) Delete(ctx context.Context, id int64) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if _, ok := r.polls[id]; !ok {
-		return sql.ErrNoRows
-	}
-	delete(r.polls, id)
-	delete(r.opts, id)
-	return nil
-}
-
-func (r *testPollRepo) optionBelongs(pollID, optionID int64) bool {
-	opts := r.opts[pollID]
-	for _, o := range opts {
-		if o.ID == optionID {
-			return true
-		}
-	}
-	return false
-}
-
-type testVoteRepo struct {
-	mu       sync.Mutex
-	votes    map[int64]map[int64]int64
-	agg      map[int64]map[int64]int64
-	pollRepo *testPollRepo
-}
-
-func newTestVoteRepo(pollRepo *testPollRepo) *testVoteRepo {
-	return &testVoteRepo{
-		votes:    make(map[int64]map[int64]int64),
-		agg:      make(map[int64]map[int64]int64),
-		pollRepo: pollRepo,
-	}
-}
-
-func (r *testVoteRepo) Create(ctx context.Context, v *vote.Vote) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	p, ok := r.pollRepo.polls[v.PollID]
-	if !ok {
-		return vote.ErrPollNotFound
-	}
-	if p.Status != "active" {
-		return vote.ErrPollNotActive
-	}
-	if !r.pollRepo.optionBelongs(v.PollID, v.OptionID) {
-		return vote.ErrOptionNotInPoll
-	}
-	if _, ok := r.votes[v.PollID]; !ok {
-		r.votes[v.PollID] = make(map[int64]int64)
+// testAccountLockout delays logins to an account briefly after 3 failures
+// and locks them after 5. IPs are only locked after many more.
+var (
+	testAccountLockout = ratelimit.LockoutPolicy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		LockAfter:    5,
+		LockDuration: time.Hour,
+		Window:       time.Hour,
 	}
-	if _, exists := r.votes[v.PollID][v.UserID]; exists {
-		return vote.ErrAlreadyVoted
+	testIPLockout = ratelimit.LockoutPolicy{
+		FreeAttempts: 50,
+		BaseDelay:    time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		LockAfter:    100,
+		LockDuration: time.Hour,
+		Window:       time.Hour,
 	}
-	r.votes[v.PollID][v.UserID] = v.OptionID
-	v.ID = int64(len(r.votes[v.PollID]))
-	v.CreatedAt = time.Now()
-	if p.Status != "active" {
-		return vote.ErrPollNotActive
-	}
-	return nil
-}
-
-func (r *testVoteRepo) CountByPoll(ctx context.Context, pollID int64) (map[int64]int64, int64, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	m := make(map[int64]int64)
-	var total int64
-	for _, optID := range r.votes[pollID] {
-		m[optID]++
-		total++
-	}
-	return m, total, nil
-}
+)
 
-func (r *testVoteRepo) AggregatedByPoll(ctx context.Context, pollID int64) (map[int64]int64, int64, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	agg := r.agg[pollID]
-	res := make(map[int64]int64)
-	var total int64
-	for opt, c := range agg {
-		res[opt] = c
-		total += c
-	}
-	return res, total, nil
+func setupServer(t *testing.T) (*httptest.Server, *memory.UserRepo, *memory.PollRepo, *memory.VoteRepo, func()) {
+	t.Helper()
+	return setupServerWithStore(t, memory.NewStore())
 }
 
-func (r *testVoteRepo) IncrementAggregated(ctx context.Context, pollID, optionID int64) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if _, ok := r.agg[pollID]; !ok {
-		r.agg[pollID] = make(map[int64]int64)
-	}
-	r.agg[pollID][optionID]++
-	return nil
+func setupServerWithStore(t *testing.T, store *memory.Store) (*httptest.Server, *memory.UserRepo, *memory.PollRepo, *memory.VoteRepo, func()) {
+	t.Helper()
+	return setupServerWithMailer(t, store, mail.NewOutbox())
 }
 
-func (r *testVoteRepo) GetPollStatus(ctx context.Context, pollID int64) (string, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	p, ok := r.pollRepo.polls[pollID]
-	if !ok {
-		return "", sql.ErrNoRows
-	}
-	return p.Status, nil
+// setupServerWithMailer sends mail to mailer and requires two-factor
+// authentication for twoFactorRoles.
+func setupServerWithMailer(t *testing.T, store *memory.Store, mailer mail.Mailer, twoFactorRoles ...string) (*httptest.Server, *memory.UserRepo, *memory.PollRepo, *memory.VoteRepo, func()) {
+	t.Helper()
+	return setupServerWithClock(t, store, mailer, time.Now, twoFactorRoles...)
 }
 
-func setupServer(t *testing.T) (*httptest.Server, *testUserRepo, *testPollRepo, *testVoteRepo, func()) {
+// setupServerWithClock is setupServerWithMailer with rate limits and login
+// lockouts measured by now.
+func setupServerWithClock(t *testing.T, store *memory.Store, mailer mail.Mailer, now func() time.Time, twoFactorRoles ...string) (*httptest.Server, *memory.UserRepo, *memory.PollRepo, *memory.VoteRepo, func()) {
 	t.Helper()
-	userRepo := newTestUserRepo()
-	pollRepo := newTestPollRepo()
-	voteRepo := newTestVoteRepo(pollRepo)
-
-	userSvc := user.NewService(userRepo)
-	pollSvc := poll.NewService(pollRepo)
-	voteSvc := vote.NewService(voteRepo)
+	userRepo := memory.NewUserRepo(store)
+	pollRepo := memory.NewPollRepo(store)
+	voteRepo := memory.NewVoteRepo(store)
+
+	userSvc := user.NewService(userRepo, user.DefaultPasswordPolicy())
+	txMgr := memory.NewTxManager(store)
+	accountSvc := user.NewAccountService(userRepo, memory.NewTokenRepo(store), txMgr, mailer, user.AccountOptions{
+		Policy:           user.DefaultPasswordPolicy(),
+		VerifyEmailTTL:   time.Hour,
+		ResetPasswordTTL: time.Hour,
+		VerifyEmailURL:   "http://example.com/api/v1/auth/verify-email",
+		ResetPasswordURL: "http://example.com/reset-password",
+	})
+	twoFactorSvc := user.NewTwoFactorService(userRepo, memory.NewTwoFactorRepo(store), txMgr, "Polls", twoFactorRoles)
+	voteSvc := vote.NewServiceWithCache(voteRepo, txMgr, vote.NewMemoryCache(), vote.DefaultCacheTTL)
+	pollSvc := poll.NewServiceWithHooks(pollRepo, poll.Hooks{OnChange: voteSvc.InvalidateResults})
+	deadLetterSvc := deadletter.NewService(memory.NewDeadLetterRepo(store), voteRepo, txMgr, 0, deadletter.Hooks{})
+	dashboardSvc := dashboard.NewService(memory.NewDashboardRepo(store), pollSvc, deadLetterSvc, nil, dashboard.DefaultCacheTTL)
 	jwtMgr := jwtpkg.NewManager("secret", "test-issuer")
 	voteCh := make(chan worker.VoteEvent, 100)
 
-	server := httptest.NewServer(NewRouter(userSvc, pollSvc, voteSvc, jwtMgr, voteCh, &sql.DB{}))
+	limitStore := ratelimit.NewMemoryStoreWithClock(now)
+	// Most tests log in many times from the same address, so logins are
+	// left to the login guard here.
+	policies := ratelimit.DefaultPolicies()
+	delete(policies, "login")
+	limiter := ratelimit.NewLimiter(limitStore, policies, nil, nil)
+	loginGuard := ratelimit.NewLoginGuardWithClock(limitStore, testAccountLockout, testIPLockout, nil, now)
+	auditSvc := audit.NewService(memory.NewAuditRepo(store), nil)
+
+	server := httptest.NewServer(NewRouter(userSvc, accountSvc, twoFactorSvc, pollSvc, voteSvc, deadLetterSvc, dashboardSvc, auditSvc, jwtMgr, voteCh, health.NewRegistry(), limiter, loginGuard, NewCORSPolicy([]string{"*"}), time.Hour, time.Minute, 1<<20))
 	cleanup := func() {
 		server.Close()
 		close(voteCh)
@@ -355,19 +115,26 @@ func setupServer(t *testing.T) (*httptest.Server, *testUserRepo, *testPollRepo,
 	return server, userRepo, pollRepo, voteRepo, cleanup
 }
 
-func seedUserWithPassword(t *testing.T, repo *testUserRepo, email, role, password string) int64 {
+func seedUserWithPassword(t *testing.T, repo *memory.UserRepo, email, role, password string) int64 {
 	t.Helper()
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
 	if err != nil {
 		t.Fatalf("hash password: %v", err)
 	}
-	repo.seed(&user.User{
-		Email:        email,
-		PasswordHash: string(hash),
-		Role:         role,
-		IsActive:     true,
-	})
-	return repo.byMail[email]
+	u := &user.User{Email: email, PasswordHash: string(hash), Role: role}
+	if err := repo.Create(context.Background(), u); err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	return u.ID
+}
+
+func pollOptions(t *testing.T, repo *memory.PollRepo, pollID int64) []poll.Option {
+	t.Helper()
+	_, opts, err := repo.GetByID(context.Background(), pollID)
+	if err != nil {
+		t.Fatalf("load poll options: %v", err)
+	}
+	return opts
 }
 
 func loginAndToken(t *testing.T, serverURL, email, password string) string {
@@ -446,9 +213,12 @@ func itoa(v int64) string {
 	return strconv.FormatInt(v, 10)
 }
 
-func decodeError(t *testing.T, resp *http.Response) map[string]string {
+func decodeError(t *testing.T, resp *http.Response) problem {
 	t.Helper()
-	var payload map[string]string
+	if ct := resp.Header.Get("Content-Type"); ct != problemContentType {
+		t.Fatalf("expected %s, got %q", problemContentType, ct)
+	}
+	var payload problem
 	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
 		t.Fatalf("decode error response: %v", err)
 	}
@@ -502,6 +272,38 @@ func TestRBACForUserRole(t *testing.T) {
 	}
 }
 
+func TestAnalyticsForAdminsAndPollCreator(t *testing.T) {
+	server, userRepo, pollRepo, _, cleanup := setupServer(t)
+	defer cleanup()
+
+	seedUserWithPassword(t, userRepo, "admin@test.com", "admin", "pass123")
+	creatorID := seedUserWithPassword(t, userRepo, "creator@test.com", "user", "pass123")
+	seedUserWithPassword(t, userRepo, "other@test.com", "user", "pass123")
+
+	// Polls are created by admins; the creator has since become a user.
+	pollID, err := pollRepo.Create(context.Background(), &poll.Poll{Title: "Lunch", Status: "active", CreatorID: creatorID}, []poll.Option{{Text: "Pizza"}, {Text: "Salad"}})
+	if err != nil {
+		t.Fatalf("seed poll: %v", err)
+	}
+
+	url := server.URL + "/api/v1/polls/" + itoa(pollID) + "/analytics"
+	for _, tc := range []struct {
+		email string
+		want  int
+	}{
+		{"admin@test.com", http.StatusOK},
+		{"creator@test.com", http.StatusOK},
+		{"other@test.com", http.StatusForbidden},
+	} {
+		token := loginAndToken(t, server.URL, tc.email, "pass123")
+		resp := sendJSON(t, http.MethodGet, url, token, nil)
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.email, tc.want, resp.StatusCode)
+		}
+	}
+}
+
 func TestVoteIdempotencyAndConflicts(t *testing.T) {
 	server, userRepo, pollRepo, _, cleanup := setupServer(t)
 	defer cleanup()
@@ -518,7 +320,7 @@ func TestVoteIdempotencyAndConflicts(t *testing.T) {
 	})
 	updatePollStatus(t, server.URL, adminToken, pollID, "active")
 
-	opts := pollRepo.opts[pollID]
+	opts := pollOptions(t, pollRepo, pollID)
 	firstResp := votePoll(t, server.URL, userToken, pollID, opts[0].ID)
 	defer firstResp.Body.Close()
 	if firstResp.StatusCode != http.StatusNoContent {
@@ -546,7 +348,7 @@ func TestPollStatusGating(t *testing.T) {
 		Title:   "Draft poll",
 		Options: []string{"opt1", "opt2"},
 	})
-	opts := pollRepo.opts[pollID]
+	opts := pollOptions(t, pollRepo, pollID)
 
 	resp := votePoll(t, server.URL, userToken, pollID, opts[0].ID)
 	defer resp.Body.Close()
@@ -562,6 +364,46 @@ func TestPollStatusGating(t *testing.T) {
 	}
 }
 
+func TestResultsFollowPollStatus(t *testing.T) {
+	server, userRepo, pollRepo, _, cleanup := setupServer(t)
+	defer cleanup()
+
+	seedUserWithPassword(t, userRepo, "admin@test.com", "admin", "pass123")
+	adminToken := loginAndToken(t, server.URL, "admin@test.com", "pass123")
+	pollID := createPollViaAPI(t, server.URL, adminToken, createPollRequest{
+		Title:   "Cached poll",
+		Options: []string{"opt1", "opt2"},
+	})
+	opts := pollOptions(t, pollRepo, pollID)
+	updatePollStatus(t, server.URL, adminToken, pollID, "active")
+	resp := votePoll(t, server.URL, adminToken, pollID, opts[1].ID)
+	resp.Body.Close()
+
+	results := func() vote.PollResults {
+		t.Helper()
+		resp := sendJSON(t, http.MethodGet, server.URL+"/api/v1/polls/"+itoa(pollID)+"/results", adminToken, nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 results, got %d", resp.StatusCode)
+		}
+		var res vote.PollResults
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			t.Fatalf("decode results: %v", err)
+		}
+		return res
+	}
+
+	// The first read caches the results of the active poll.
+	if res := results(); res.Status != "active" || res.WinnerOptionID != nil {
+		t.Fatalf("unexpected results of active poll: %+v", res)
+	}
+	updatePollStatus(t, server.URL, adminToken, pollID, "closed")
+	res := results()
+	if res.Status != "closed" || res.WinnerOptionID == nil || *res.WinnerOptionID != opts[1].ID {
+		t.Fatalf("expected closing to refresh cached results, got %+v", res)
+	}
+}
+
 func TestOptionMustBelongToPoll(t *testing.T) {
 	server, userRepo, pollRepo, _, cleanup := setupServer(t)
 	defer cleanup()
@@ -583,15 +425,15 @@ func TestOptionMustBelongToPoll(t *testing.T) {
 	updatePollStatus(t, server.URL, adminToken, pollA, "active")
 	updatePollStatus(t, server.URL, adminToken, pollB, "active")
 
-	optionFromB := pollRepo.opts[pollB][0].ID
+	optionFromB := pollOptions(t, pollRepo, pollB)[0].ID
 	resp := votePoll(t, server.URL, userToken, pollA, optionFromB)
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusBadRequest {
 		t.Fatalf("expected 400 for option not in poll, got %d", resp.StatusCode)
 	}
 	errPayload := decodeError(t, resp)
-	if errPayload["error"] == "" || errPayload["message"] == "" {
-		t.Fatalf("expected structured error payload")
+	if errPayload.Code != "invalid_option" || errPayload.Detail == "" || errPayload.Status != http.StatusBadRequest {
+		t.Fatalf("expected structured error payload, got %+v", errPayload)
 	}
 }
 
@@ -626,11 +468,881 @@ func TestPollNotFoundPatchAndDelete(t *testing.T) {
 		t.Fatalf("expected 404 for delete not found, got %d", delResp.StatusCode)
 	}
 	errPayload := decodeError(t, delResp)
-	if errPayload["error"] == "" {
-		t.Fatalf("expected error code in response")
+	if errPayload.Code != "poll_not_found" || errPayload.Type != "/problems/poll_not_found" {
+		t.Fatalf("expected documented error code in response, got %+v", errPayload)
 	}
 }
 
 func strPtr(s string) *string {
 	return &s
 }
+
+func TestCreatePollReportsAllViolations(t *testing.T) {
+	server, userRepo, _, _, cleanup := setupServer(t)
+	defer cleanup()
+
+	seedUserWithPassword(t, userRepo, "admin@test.com", "admin", "pass123")
+	adminToken := loginAndToken(t, server.URL, "admin@test.com", "pass123")
+
+	body, _ := json.Marshal(createPollRequest{
+		Title:    " ",
+		Options:  []string{"A", "A"},
+		StartsAt: strPtr("2030-01-02T00:00:00Z"),
+		EndsAt:   strPtr("2030-01-01T00:00:00Z"),
+	})
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/v1/polls", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	req.Header.Set("X-Request-ID", "create-poll-1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("create poll: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+
+	p := decodeError(t, resp)
+	if p.Code != "validation_failed" || p.Type != "/problems/validation_failed" || p.Instance != "/api/v1/polls" {
+		t.Fatalf("unexpected problem: %+v", p)
+	}
+	if p.RequestID != "create-poll-1" {
+		t.Fatalf("expected request id in problem, got %q", p.RequestID)
+	}
+	got := make(map[string]string, len(p.Errors))
+	for _, v := range p.Errors {
+		got[v.Field] = v.Code
+	}
+	want := map[string]string{"title": "required", "options[1]": "duplicate", "ends_at": "before_start"}
+	if len(got) != len(want) {
+		t.Fatalf("expected violations %v, got %+v", want, p.Errors)
+	}
+	for field, code := range want {
+		if got[field] != code {
+			t.Fatalf("expected %s to be %s, got %+v", field, code, p.Errors)
+		}
+	}
+
+	docResp, err := http.Get(server.URL + p.Type)
+	if err != nil {
+		t.Fatalf("get problem doc: %v", err)
+	}
+	defer docResp.Body.Close()
+	var doc problemDoc
+	if err := json.NewDecoder(docResp.Body).Decode(&doc); err != nil {
+		t.Fatalf("decode problem doc: %v", err)
+	}
+	if docResp.StatusCode != http.StatusOK || doc.Code != "validation_failed" || doc.Title != p.Title {
+		t.Fatalf("unexpected problem doc %d: %+v", docResp.StatusCode, doc)
+	}
+}
+
+func TestStrictJSONDecoding(t *testing.T) {
+	server, userRepo, _, _, cleanup := setupServer(t)
+	defer cleanup()
+
+	seedUserWithPassword(t, userRepo, "admin@test.com", "admin", "pass123")
+	adminToken := loginAndToken(t, server.URL, "admin@test.com", "pass123")
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		code   string
+		field  string
+	}{
+		{"unknown field", "/api/v1/polls", `{"title":"T","options":["A","B"],"colour":"red"}`, http.StatusBadRequest, "validation_failed", "colour"},
+		{"wrong type", "/api/v1/polls", `{"title":7,"options":["A","B"]}`, http.StatusBadRequest, "validation_failed", "title"},
+		{"trailing data", "/api/v1/polls", `{"title":"T","options":["A","B"]} {}`, http.StatusBadRequest, "invalid_input", ""},
+		{"empty body", "/api/v1/polls", ``, http.StatusBadRequest, "invalid_input", ""},
+		{"too large", "/api/v1/polls", `{"title":"` + strings.Repeat("x", 1<<20) + `"}`, http.StatusRequestEntityTooLarge, "body_too_large", ""},
+		{"weak password", "/api/v1/auth/register", `{"email":"new@test.com","password":"short"}`, http.StatusBadRequest, "validation_failed", "password"},
+		{"bad email", "/api/v1/auth/register", `{"email":"new","password":"longenough1"}`, http.StatusBadRequest, "validation_failed", "email"},
+		{"bad role", "/api/v1/users/1/role", `{"role":"owner"}`, http.StatusBadRequest, "validation_failed", "role"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := http.MethodPost
+			if strings.HasSuffix(tt.path, "/role") {
+				method = http.MethodPatch
+			}
+			req, _ := http.NewRequest(method, server.URL+tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, resp.StatusCode)
+			}
+			p := decodeError(t, resp)
+			if p.Code != tt.code {
+				t.Fatalf("expected code %s, got %+v", tt.code, p)
+			}
+			if tt.field != "" && (len(p.Errors) != 1 || p.Errors[0].Field != tt.field) {
+				t.Fatalf("expected a violation of %s, got %+v", tt.field, p.Errors)
+			}
+		})
+	}
+}
+
+// mailedLink returns the path and query of the link in the last message sent.
+func mailedLink(t *testing.T, outbox *mail.Outbox) string {
+	t.Helper()
+	msgs := outbox.Messages()
+	if len(msgs) == 0 {
+		t.Fatal("no message sent")
+	}
+	body := msgs[len(msgs)-1].Body
+	start := strings.Index(body, "http://example.com")
+	if start < 0 {
+		t.Fatalf("no link in %q", body)
+	}
+	link, _, _ := strings.Cut(body[start+len("http://example.com"):], "\n")
+	return link
+}
+
+func TestAccountEmailFlows(t *testing.T) {
+	outbox := mail.NewOutbox()
+	server, userRepo, _, _, cleanup := setupServerWithMailer(t, memory.NewStore(), outbox)
+	defer cleanup()
+
+	body := `{"email":"new@test.com","password":"longenough1"}`
+	resp, err := http.Post(server.URL+"/api/v1/auth/register", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	verify := mailedLink(t, outbox)
+	if !strings.HasPrefix(verify, "/api/v1/auth/verify-email?token=") {
+		t.Fatalf("unexpected verification link %q", verify)
+	}
+	for i, want := range []int{http.StatusNoContent, http.StatusBadRequest} {
+		resp, err := http.Get(server.URL + verify)
+		if err != nil {
+			t.Fatalf("verify email: %v", err)
+		}
+		if resp.StatusCode != want {
+			t.Fatalf("verify attempt %d: expected %d, got %d", i+1, want, resp.StatusCode)
+		}
+		if want == http.StatusBadRequest {
+			if p := decodeError(t, resp); p.Code != "expired_link" {
+				t.Fatalf("expected expired_link, got %+v", p)
+			}
+		}
+		resp.Body.Close()
+	}
+	u, _ := userRepo.GetByEmail(context.Background(), "new@test.com")
+	if u.EmailVerifiedAt == nil {
+		t.Fatal("expected email to be verified")
+	}
+
+	sent := len(outbox.Messages())
+	for _, email := range []string{"new@test.com", "nobody@test.com"} {
+		resp, err := http.Post(server.URL+"/api/v1/auth/forgot-password", "application/json", strings.NewReader(`{"email":"`+email+`"}`))
+		if err != nil {
+			t.Fatalf("forgot password: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("forgot password for %s: expected 202, got %d", email, resp.StatusCode)
+		}
+	}
+	// The reset email is sent in the background.
+	deadline := time.Now().Add(2 * time.Second)
+	for len(outbox.Messages()) == sent && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(outbox.Messages()); n != sent+1 {
+		t.Fatalf("expected one reset email, got %d", n-sent)
+	}
+	reset := mailedLink(t, outbox)
+	token := strings.TrimPrefix(reset, "/reset-password?token=")
+
+	for i, tc := range []struct {
+		password string
+		status   int
+		code     string
+	}{
+		{"short", http.StatusBadRequest, "validation_failed"},
+		{"changed1pass", http.StatusNoContent, ""},
+		{"changed2pass", http.StatusBadRequest, "expired_link"},
+	} {
+		data, _ := json.Marshal(resetPasswordRequest{Token: token, Password: tc.password})
+		resp, err := http.Post(server.URL+"/api/v1/auth/reset-password", "application/json", bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("reset password: %v", err)
+		}
+		if resp.StatusCode != tc.status {
+			t.Fatalf("reset attempt %d: expected %d, got %d", i+1, tc.status, resp.StatusCode)
+		}
+		if tc.code != "" {
+			if p := decodeError(t, resp); p.Code != tc.code {
+				t.Fatalf("reset attempt %d: expected %s, got %+v", i+1, tc.code, p)
+			}
+		}
+		resp.Body.Close()
+	}
+	loginAndToken(t, server.URL, "new@test.com", "changed1pass")
+}
+
+func TestAccountTokenRoutesAreRateLimited(t *testing.T) {
+	server, _, _, _, cleanup := setupServer(t)
+	defer cleanup()
+
+	burst := ratelimit.DefaultPolicies()["account_token"].Burst
+	var statuses []int
+	for range burst {
+		resp, err := http.Get(server.URL + "/api/v1/auth/verify-email?token=guess")
+		if err != nil {
+			t.Fatalf("verify email: %v", err)
+		}
+		resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+	}
+	// Both routes share the limit of the client address.
+	resp, err := http.Post(server.URL+"/api/v1/auth/reset-password", "application/json", strings.NewReader(`{"token":"guess","password":"longenough1"}`))
+	if err != nil {
+		t.Fatalf("reset password: %v", err)
+	}
+	resp.Body.Close()
+	statuses = append(statuses, resp.StatusCode)
+
+	for i, status := range statuses {
+		want := http.StatusBadRequest
+		if i == burst {
+			want = http.StatusTooManyRequests
+		}
+		if status != want {
+			t.Fatalf("request %d: expected %d, got %d (all: %v)", i+1, want, status, statuses)
+		}
+	}
+}
+
+func TestResendVerificationIsRateLimited(t *testing.T) {
+	outbox := mail.NewOutbox()
+	server, userRepo, _, _, cleanup := setupServerWithMailer(t, memory.NewStore(), outbox)
+	defer cleanup()
+
+	seedUserWithPassword(t, userRepo, "user@test.com", "user", "pass123")
+	token := loginAndToken(t, server.URL, "user@test.com", "pass123")
+
+	burst := ratelimit.DefaultPolicies()["verify_email"].Burst
+	for i := 1; i <= burst+1; i++ {
+		resp := sendJSON(t, http.MethodPost, server.URL+"/api/v1/auth/verify-email/resend", token, nil)
+		resp.Body.Close()
+		want := http.StatusAccepted
+		if i > burst {
+			want = http.StatusTooManyRequests
+		}
+		if resp.StatusCode != want {
+			t.Fatalf("resend %d: expected %d, got %d", i, want, resp.StatusCode)
+		}
+	}
+	if n := len(outbox.Messages()); n != burst {
+		t.Fatalf("expected %d verification emails, got %d", burst, n)
+	}
+}
+
+func postLogin(t *testing.T, serverURL, email, password string) *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(authRequest{Email: email, Password: password})
+	resp, err := http.Post(serverURL+"/api/v1/auth/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("login request: %v", err)
+	}
+	return resp
+}
+
+// testClock is a clock that only moves when told to.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestLoginLockoutAndAudit(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	server, userRepo, _, _, cleanup := setupServerWithClock(t, memory.NewStore(), mail.NewOutbox(), clock.Now)
+	defer cleanup()
+
+	seedUserWithPassword(t, userRepo, "admin@test.com", "admin", "pass123")
+	victimID := seedUserWithPassword(t, userRepo, "victim@test.com", "user", "pass123")
+
+	for i := 1; i <= 5; i++ {
+		if i == 5 {
+			// Let the delay after the fourth failure pass.
+			clock.Advance(testAccountLockout.MaxDelay)
+		}
+		resp := postLogin(t, server.URL, "victim@test.com", "wrong")
+		p := decodeError(t, resp)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || p.Code != "invalid_credentials" {
+			t.Fatalf("failure %d: expected 401 invalid_credentials, got %d %+v", i, resp.StatusCode, p)
+		}
+	}
+
+	resp := postLogin(t, server.URL, "victim@test.com", "pass123")
+	p := decodeError(t, resp)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || p.Code != "login_locked" || resp.Header.Get("Retry-After") != "3600" {
+		t.Fatalf("expected a locked account, got %d %+v (Retry-After %q)", resp.StatusCode, p, resp.Header.Get("Retry-After"))
+	}
+
+	// Unknown emails are tracked like accounts and get the same answer.
+	for i := 1; i <= 4; i++ {
+		resp := postLogin(t, server.URL, "nobody@test.com", "wrong")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("unknown email failure %d: expected 401, got %d", i, resp.StatusCode)
+		}
+	}
+	resp = postLogin(t, server.URL, "nobody@test.com", "wrong")
+	if p := decodeError(t, resp); resp.StatusCode != http.StatusTooManyRequests || p.Code != "login_delayed" {
+		t.Fatalf("expected the unknown email to be delayed, got %d %+v", resp.StatusCode, p)
+	}
+	resp.Body.Close()
+
+	adminToken := loginAndToken(t, server.URL, "admin@test.com", "pass123")
+	req, _ := http.NewRequest(http.MethodPatch, server.URL+"/api/v1/users/"+itoa(victimID)+"/unlock", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 unlock, got %d", resp.StatusCode)
+	}
+	loginAndToken(t, server.URL, "victim@test.com", "pass123")
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/api/v1/admin/audit-log?user_id="+itoa(victimID), nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("audit log: %v", err)
+	}
+	defer resp.Body.Close()
+	var page auditLogResponse
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatalf("decode audit log: %v", err)
+	}
+	var actions []string
+	for _, e := range page.Events {
+		actions = append(actions, e.Action)
+	}
+	want := []string{"login_succeeded", "account_unlocked", "login_locked", "login_failed", "login_failed", "login_failed", "login_failed", "login_failed"}
+	if strings.Join(actions, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected audit log %v", actions)
+	}
+	if unlock := page.Events[1]; unlock.ActorID == nil || unlock.IP == "" || unlock.RequestID == "" {
+		t.Fatalf("expected the unlock to name the admin, IP and request, got %+v", unlock)
+	}
+	if locked := page.Events[2]; locked.Detail != "account locked for 1h0m0s after 5 failures" {
+		t.Fatalf("unexpected lockout detail %q", locked.Detail)
+	}
+}
+
+// sendJSON sends body as JSON with token as bearer token, if any.
+func sendJSON(t *testing.T, method, url, token string, body any) *http.Response {
+	t.Helper()
+	r := bytes.NewReader(nil)
+	if body != nil {
+		data, _ := json.Marshal(body)
+		r = bytes.NewReader(data)
+	}
+	req, _ := http.NewRequest(method, url, r)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	return resp
+}
+
+func TestTwoFactorFlow(t *testing.T) {
+	server, userRepo, _, _, cleanup := setupServerWithMailer(t, memory.NewStore(), mail.NewOutbox(), "admin")
+	defer cleanup()
+	api := server.URL + "/api/v1"
+
+	adminID := seedUserWithPassword(t, userRepo, "admin@test.com", "admin", "pass123")
+	seedUserWithPassword(t, userRepo, "user@test.com", "user", "pass123")
+
+	// Until they enroll, admins only reach the two-factor endpoints.
+	resp := postLogin(t, server.URL, "admin@test.com", "pass123")
+	var setup map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&setup)
+	resp.Body.Close()
+	pwdToken, _ := setup["token"].(string)
+	if pwdToken == "" || setup["two_factor_setup_required"] != true {
+		t.Fatalf("expected a token that asks for setup, got %v", setup)
+	}
+	resp = sendJSON(t, http.MethodGet, api+"/polls", pwdToken, nil)
+	if p := decodeError(t, resp); resp.StatusCode != http.StatusForbidden || p.Code != "two_factor_required" {
+		t.Fatalf("expected 403 two_factor_required, got %d %+v", resp.StatusCode, p)
+	}
+	resp.Body.Close()
+
+	resp = sendJSON(t, http.MethodPost, api+"/auth/2fa/enroll", pwdToken, nil)
+	var enrollment user.Enrollment
+	_ = json.NewDecoder(resp.Body).Decode(&enrollment)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(enrollment.URI, "otpauth://totp/") {
+		t.Fatalf("enroll: %d %+v", resp.StatusCode, enrollment)
+	}
+	step := totp.Step(time.Now())
+	code, _ := totp.Code(enrollment.Secret, step)
+	resp = sendJSON(t, http.MethodPost, api+"/auth/2fa/confirm", pwdToken, twoFactorCodeRequest{Code: code})
+	var confirmed recoveryCodesResponse
+	_ = json.NewDecoder(resp.Body).Decode(&confirmed)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(confirmed.RecoveryCodes) != user.RecoveryCodeCount || confirmed.Token == "" {
+		t.Fatalf("confirm: %d %+v", resp.StatusCode, confirmed)
+	}
+	resp = sendJSON(t, http.MethodGet, api+"/polls", confirmed.Token, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the confirmation token to pass, got %d", resp.StatusCode)
+	}
+
+	// Logging in now takes a second step.
+	challenge := func() string {
+		t.Helper()
+		resp := postLogin(t, server.URL, "admin@test.com", "pass123")
+		defer resp.Body.Close()
+		var c twoFactorChallengeResponse
+		_ = json.NewDecoder(resp.Body).Decode(&c)
+		if resp.StatusCode != http.StatusOK || !c.TwoFactorRequired || c.ChallengeToken == "" || c.ExpiresIn != 60 {
+			t.Fatalf("expected a challenge, got %d %+v", resp.StatusCode, c)
+		}
+		return c.ChallengeToken
+	}
+	ch := challenge()
+	resp = sendJSON(t, http.MethodGet, api+"/polls", ch, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("challenge token must not be an access token, got %d", resp.StatusCode)
+	}
+	wrong, _ := totp.Code(enrollment.Secret, step+100)
+	for _, tc := range []struct {
+		code   string
+		status int
+	}{
+		{wrong, http.StatusUnauthorized},
+		{confirmed.RecoveryCodes[0], http.StatusOK},
+		{confirmed.RecoveryCodes[0], http.StatusUnauthorized},
+	} {
+		resp := sendJSON(t, http.MethodPost, api+"/auth/login/2fa", "", twoFactorLoginRequest{ChallengeToken: ch, Code: tc.code})
+		if resp.StatusCode != tc.status {
+			t.Fatalf("code %s: expected %d, got %d", tc.code, tc.status, resp.StatusCode)
+		}
+		if tc.status == http.StatusUnauthorized {
+			if p := decodeError(t, resp); p.Code != "invalid_code" {
+				t.Fatalf("expected invalid_code, got %+v", p)
+			}
+		}
+		resp.Body.Close()
+	}
+	userToken := loginAndToken(t, server.URL, "user@test.com", "pass123")
+	resp = sendJSON(t, http.MethodGet, api+"/polls", userToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("users without required 2FA log in with a password, got %d", resp.StatusCode)
+	}
+
+	resp = sendJSON(t, http.MethodPost, api+"/auth/2fa/disable", confirmed.Token, twoFactorCodeRequest{Code: confirmed.RecoveryCodes[1]})
+	if p := decodeError(t, resp); resp.StatusCode != http.StatusForbidden || p.Code != "two_factor_required" {
+		t.Fatalf("expected admins to keep 2FA, got %d %+v", resp.StatusCode, p)
+	}
+	resp.Body.Close()
+
+	// An admin resets the setup of a user who lost their device.
+	for _, want := range []int{http.StatusNoContent, http.StatusConflict} {
+		resp = sendJSON(t, http.MethodDelete, api+"/users/"+itoa(adminID)+"/2fa", confirmed.Token, nil)
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("reset: expected %d, got %d", want, resp.StatusCode)
+		}
+	}
+	resp = postLogin(t, server.URL, "admin@test.com", "pass123")
+	setup = nil
+	_ = json.NewDecoder(resp.Body).Decode(&setup)
+	resp.Body.Close()
+	if setup["two_factor_setup_required"] != true {
+		t.Fatalf("expected setup to be required again after a reset, got %v", setup)
+	}
+
+	resp = sendJSON(t, http.MethodGet, api+"/admin/audit-log?user_id="+itoa(adminID), confirmed.Token, nil)
+	defer resp.Body.Close()
+	var page auditLogResponse
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatalf("decode audit log: %v", err)
+	}
+	var actions []string
+	for _, e := range page.Events {
+		actions = append(actions, e.Action)
+	}
+	want := []string{"login_succeeded", "two_factor_reset", "two_factor_failed", "login_succeeded", "recovery_code_used", "two_factor_failed", "two_factor_enabled", "login_succeeded"}
+	if strings.Join(actions, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected audit log %v", actions)
+	}
+}
+
+func TestVoteRateLimitHeaders(t *testing.T) {
+	server, userRepo, pollRepo, _, cleanup := setupServer(t)
+	defer cleanup()
+
+	seedUserWithPassword(t, userRepo, "admin@test.com", "admin", "pass123")
+	seedUserWithPassword(t, userRepo, "user@test.com", "user", "pass123")
+
+	adminToken := loginAndToken(t, server.URL, "admin@test.com", "pass123")
+	userToken := loginAndToken(t, server.URL, "user@test.com", "pass123")
+
+	pollID := createPollViaAPI(t, server.URL, adminToken, createPollRequest{
+		Title:   "Limited",
+		Options: []string{"yes", "no"},
+	})
+	updatePollStatus(t, server.URL, adminToken, pollID, "active")
+	optionID := pollOptions(t, pollRepo, pollID)[0].ID
+
+	for i := 0; i < 3; i++ {
+		resp := votePoll(t, server.URL, userToken, pollID, optionID)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusTooManyRequests {
+			t.Fatalf("request %d limited too early", i+1)
+		}
+		if resp.Header.Get("RateLimit-Remaining") != strconv.Itoa(2-i) {
+			t.Fatalf("request %d: unexpected RateLimit-Remaining %q", i+1, resp.Header.Get("RateLimit-Remaining"))
+		}
+	}
+
+	resp := votePoll(t, server.URL, userToken, pollID, optionID)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" || resp.Header.Get("RateLimit-Limit") != "3" {
+		t.Fatalf("expected rate limit headers, got %v", resp.Header)
+	}
+
+	// A spoofed X-Forwarded-For must not reset a user-keyed limit.
+	body, _ := json.Marshal(voteRequest{OptionID: optionID})
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/v1/polls/"+itoa(pollID)+"/vote", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+userToken)
+	req.Header.Set("X-Forwarded-For", "203.0.113.99")
+	spoofed, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("vote request: %v", err)
+	}
+	defer spoofed.Body.Close()
+	if spoofed.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected spoofed request to stay limited, got %d", spoofed.StatusCode)
+	}
+}
+
+func TestCORSOriginsCanBeSwapped(t *testing.T) {
+	policy := NewCORSPolicy([]string{"https://a.example"})
+	handler := CORS(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	allowOrigin := func(origin string) string {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	if got := allowOrigin("https://a.example"); got != "https://a.example" {
+		t.Fatalf("expected configured origin to be allowed, got %q", got)
+	}
+	if got := allowOrigin("https://b.example"); got != "" {
+		t.Fatalf("expected other origin to be rejected, got %q", got)
+	}
+
+	policy.SetOrigins([]string{"https://b.example"})
+	if got := allowOrigin("https://b.example"); got != "https://b.example" {
+		t.Fatalf("expected swapped origin to be allowed, got %q", got)
+	}
+	if got := allowOrigin("https://a.example"); got != "" {
+		t.Fatalf("expected old origin to be rejected after swap, got %q", got)
+	}
+}
+
+func TestDeadLetterAdminEndpoints(t *testing.T) {
+	store := memory.NewStore()
+	server, userRepo, pollRepo, voteRepo, cleanup := setupServerWithStore(t, store)
+	defer cleanup()
+
+	seedUserWithPassword(t, userRepo, "admin@test.com", "admin", "pass123")
+	seedUserWithPassword(t, userRepo, "user@test.com", "user", "pass123")
+	adminToken := loginAndToken(t, server.URL, "admin@test.com", "pass123")
+	userToken := loginAndToken(t, server.URL, "user@test.com", "pass123")
+
+	pollID := createPollViaAPI(t, server.URL, adminToken, createPollRequest{
+		Title:   "Dead letters",
+		Options: []string{"yes", "no"},
+	})
+	optionID := pollOptions(t, pollRepo, pollID)[0].ID
+
+	ctx := context.Background()
+	dlq := memory.NewDeadLetterRepo(store)
+	good := &deadletter.Entry{PollID: pollID, OptionID: optionID, UserID: 1, Error: "connection reset", Attempts: 4}
+	bad := &deadletter.Entry{PollID: pollID + 100, OptionID: optionID, UserID: 1, Error: "connection reset", Attempts: 4}
+	for _, e := range []*deadletter.Entry{good, bad} {
+		if err := dlq.Create(ctx, e); err != nil {
+			t.Fatalf("seed dead letter: %v", err)
+		}
+	}
+
+	do := func(method, path, token string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+"/api/v1/admin/dead-letters"+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return resp
+	}
+	expect := func(resp *http.Response, status int) {
+		t.Helper()
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Fatalf("%s %s: expected %d, got %d", resp.Request.Method, resp.Request.URL.Path, status, resp.StatusCode)
+		}
+	}
+
+	expect(do(http.MethodGet, "", userToken), http.StatusForbidden)
+
+	resp := do(http.MethodGet, "?limit=1", adminToken)
+	var list deadLetterListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	resp.Body.Close()
+	if list.Total != 2 || len(list.Entries) != 1 || list.Entries[0].ID != good.ID || list.Limit != 1 {
+		t.Fatalf("unexpected list %+v", list)
+	}
+	expect(do(http.MethodGet, "?limit=abc", adminToken), http.StatusBadRequest)
+
+	expect(do(http.MethodPost, "/"+itoa(good.ID)+"/replay", adminToken), http.StatusNoContent)
+	expect(do(http.MethodGet, "/"+itoa(good.ID), adminToken), http.StatusNotFound)
+	if counts, _, _ := voteRepo.AggregatedByPoll(ctx, pollID); counts[optionID] != 1 {
+		t.Fatalf("replay must aggregate the vote, got %v", counts)
+	}
+
+	expect(do(http.MethodPost, "/"+itoa(bad.ID)+"/replay", adminToken), http.StatusConflict)
+	resp = do(http.MethodGet, "/"+itoa(bad.ID), adminToken)
+	var kept deadletter.Entry
+	if err := json.NewDecoder(resp.Body).Decode(&kept); err != nil {
+		t.Fatalf("decode entry: %v", err)
+	}
+	resp.Body.Close()
+	if kept.Attempts != 5 || kept.Error == "connection reset" {
+		t.Fatalf("failed replay must keep the entry with the new error: %+v", kept)
+	}
+
+	expect(do(http.MethodDelete, "/"+itoa(bad.ID), adminToken), http.StatusNoContent)
+	expect(do(http.MethodDelete, "/"+itoa(bad.ID), adminToken), http.StatusNotFound)
+}
+
+func TestAdminStats(t *testing.T) {
+	server, userRepo, pollRepo, _, cleanup := setupServer(t)
+	defer cleanup()
+
+	seedUserWithPassword(t, userRepo, "admin@test.com", "admin", "pass123")
+	seedUserWithPassword(t, userRepo, "user@test.com", "user", "pass123")
+	adminToken := loginAndToken(t, server.URL, "admin@test.com", "pass123")
+	userToken := loginAndToken(t, server.URL, "user@test.com", "pass123")
+
+	pollID := createPollViaAPI(t, server.URL, adminToken, createPollRequest{
+		Title:   "Stats",
+		Options: []string{"yes", "no"},
+	})
+	updatePollStatus(t, server.URL, adminToken, pollID, "active")
+	resp := votePoll(t, server.URL, userToken, pollID, pollOptions(t, pollRepo, pollID)[0].ID)
+	resp.Body.Close()
+
+	get := func(token string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/admin/stats", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("get stats: %v", err)
+		}
+		return resp
+	}
+
+	resp = get(userToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for a user, got %d", resp.StatusCode)
+	}
+
+	resp = get(adminToken)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var st dashboard.Stats
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		t.Fatalf("decode stats: %v", err)
+	}
+	if st.Users.Total != 2 || st.Users.Active != 2 || st.Votes.Today != 1 || st.Votes.ThisWeek != 1 {
+		t.Fatalf("unexpected totals %+v %+v", st.Users, st.Votes)
+	}
+	if st.PollsByStatus["active"] != 1 || st.PollsByStatus["draft"] != 0 {
+		t.Fatalf("unexpected polls by status %v", st.PollsByStatus)
+	}
+	if len(st.RecentRegistrations) != 2 || st.RecentRegistrations[0].Email != "user@test.com" {
+		t.Fatalf("unexpected recent registrations %+v", st.RecentRegistrations)
+	}
+	if st.TopPolls == nil {
+		t.Fatalf("top polls must be an empty list while nothing is aggregated")
+	}
+}
+
+func TestHealthProbesAreSeparate(t *testing.T) {
+	var dbDown atomic.Bool
+	checks := health.NewRegistry()
+	checks.AddLiveness("stats_workers", time.Second, func(context.Context) (map[string]any, error) {
+		return nil, nil
+	})
+	checks.AddReadiness("database", time.Second, func(context.Context) (map[string]any, error) {
+		if dbDown.Load() {
+			return nil, errors.New("connection refused")
+		}
+		return map[string]any{"open_connections": 1}, nil
+	})
+	checks.AddReadiness("results_cache", time.Second, func(context.Context) (map[string]any, error) {
+		return nil, health.Degraded(errors.New("redis unreachable"))
+	})
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), nil, nil, nil)
+	server := httptest.NewServer(NewRouter(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, checks, limiter, nil, NewCORSPolicy([]string{"*"}), time.Hour, time.Minute, 1<<20))
+	defer server.Close()
+
+	probe := func(path string, wantStatus int) health.Report {
+		t.Helper()
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("%s: expected %d, got %d", path, wantStatus, resp.StatusCode)
+		}
+		var report health.Report
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+			t.Fatalf("%s: decode: %v", path, err)
+		}
+		return report
+	}
+
+	ready := probe("/ready", http.StatusOK)
+	if ready.Status != health.StatusDegraded || ready.Checks["results_cache"].Error != "redis unreachable" || ready.Checks["database"].Status != health.StatusUp {
+		t.Fatalf("a degraded cache must not fail readiness: %+v", ready)
+	}
+
+	dbDown.Store(true)
+	ready = probe("/ready", http.StatusServiceUnavailable)
+	if ready.Status != health.StatusDown || ready.Checks["database"].Error != "connection refused" {
+		t.Fatalf("expected the database check to fail readiness: %+v", ready)
+	}
+	live := probe("/health", http.StatusOK)
+	if live.Status != health.StatusUp || len(live.Checks) != 1 {
+		t.Fatalf("liveness must not depend on the database: %+v", live)
+	}
+}
+
+func TestRequestLogCarriesRequestFields(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(logging.NewHandler(slog.NewJSONHandler(&buf, nil))))
+	defer slog.SetDefault(prev)
+
+	server, userRepo, _, _, cleanup := setupServer(t)
+	userID := seedUserWithPassword(t, userRepo, "user@example.com", "user", "secret123")
+	token := loginAndToken(t, server.URL, "user@example.com", "secret123")
+
+	get := func(requestID string) string {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/polls/999", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-Request-ID", requestID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("get poll: %v", err)
+		}
+		resp.Body.Close()
+		return resp.Header.Get("X-Request-ID")
+	}
+	if got := get("abc-123"); got != "abc-123" {
+		t.Fatalf("expected the request ID to be echoed, got %q", got)
+	}
+	if got := get("bad id"); got == "" || got == "bad id" {
+		t.Fatalf("expected a generated request ID, got %q", got)
+	}
+	// Close waits for the handlers, and so for their log records.
+	cleanup()
+
+	var found bool
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var rec map[string]any
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatalf("decode log: %v", err)
+		}
+		if rec["msg"] != "request" || rec["request_id"] != "abc-123" {
+			continue
+		}
+		found = true
+		if rec["user_id"] != float64(userID) || rec["route"] != "/api/v1/polls/{id}" || rec["client_ip"] == nil {
+			t.Fatalf("request log misses request fields: %v", rec)
+		}
+	}
+	if !found {
+		t.Fatalf("no request log for abc-123 in %s", buf.String())
+	}
+}
+
+func TestRoutePatternOfUnmatchedRequests(t *testing.T) {
+	var got []string
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, req)
+			got = append(got, routePattern(req))
+		})
+	})
+	r.NotFound(handleNotFound)
+	r.Get("/polls/{id}", func(http.ResponseWriter, *http.Request) {})
+
+	for _, path := range []string{"/polls/7", "/wp-login.php", "/polls/7/unknown"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	want := []string{"/polls/{id}", unmatchedRoute, unmatchedRoute}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected route patterns %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"polling-system/internal/domain/audit"
+	"polling-system/internal/domain/user"
+	"polling-system/internal/platform/apperr"
+	jwtpkg "polling-system/internal/platform/jwt"
+	"polling-system/internal/platform/validate"
+)
+
+type twoFactorCodeRequest struct {
+	// Code is a code from the authenticator app or, except when
+	// confirming, an unused recovery code.
+	Code string `json:"code" example:"123456"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	// Token is an access token that counts the second factor, issued when
+	// two-factor authentication is turned on.
+	Token string `json:"token,omitempty"`
+}
+
+// @Summary     Get two-factor status
+// @Description Whether two-factor authentication is enabled or pending for the current user, whether their role requires it, and how many recovery codes are left.
+// @Tags        two-factor
+// @Security    BearerAuth
+// @Produce     json
+// @Success     200  {object}  user.TwoFactorStatus
+// @Failure     401  {object}  problem            "unauthorized"
+// @Failure     500  {object}  problem            "server error"
+// @Router      /api/v1/auth/2fa [get]
+func (h *Handler) handleTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	st, err := h.twoFactorSvc.Status(r.Context(), userIDFromCtx(r))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+// @Summary     Start two-factor enrollment
+// @Description Creates a TOTP secret for the current user and returns it with its otpauth:// provisioning URI, to show as a QR code. Nothing changes at login until the enrollment is confirmed; enrolling again replaces an unconfirmed secret.
+// @Tags        two-factor
+// @Security    BearerAuth
+// @Produce     json
+// @Success     200  {object}  user.Enrollment
+// @Failure     401  {object}  problem            "unauthorized"
+// @Failure     409  {object}  problem            "already enabled"
+// @Failure     500  {object}  problem            "server error"
+// @Router      /api/v1/auth/2fa/enroll [post]
+func (h *Handler) handleEnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	e, err := h.twoFactorSvc.Enroll(r.Context(), userIDFromCtx(r))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, e)
+}
+
+// @Summary     Confirm two-factor enrollment
+// @Description Turns two-factor authentication on with a first code from the authenticator app. Returns the recovery codes, which are shown only this once, and an access token that counts the second factor.
+// @Tags        two-factor
+// @Security    BearerAuth
+// @Accept      json
+// @Produce     json
+// @Param       request  body      twoFactorCodeRequest  true  "Code from the authenticator app"
+// @Success     200      {object}  recoveryCodesResponse
+// @Failure     400      {object}  problem            "invalid body"
+// @Failure     401      {object}  problem            "unauthorized or invalid code"
+// @Failure     409      {object}  problem            "not enrolled or already enabled"
+// @Failure     500      {object}  problem            "server error"
+// @Router      /api/v1/auth/2fa/confirm [post]
+func (h *Handler) handleConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req twoFactorCodeRequest
+	if !h.decodeCode(w, r, &req) {
+		return
+	}
+	u, err := h.userSvc.GetByID(r.Context(), userIDFromCtx(r))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	codes, err := h.twoFactorSvc.Confirm(r.Context(), u.ID, req.Code)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	h.auditSvc.Record(r.Context(), h.auditEvent(r, audit.ActionTwoFactorEnabled, &u.ID, u.Email, ""))
+
+	token, err := h.jwtMgr.Generate(u.ID, u.Role, h.tokenTTL, jwtpkg.MethodPassword, jwtpkg.MethodOTP)
+	if err != nil {
+		errorResponse(w, r, apperr.Internal("internal_error", "failed to generate token", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes, Token: token})
+}
+
+// @Summary     Turn off two-factor authentication
+// @Description Needs a current code or an unused recovery code. Refused when the user's role requires two-factor authentication. Wrong codes count as failed logins.
+// @Tags        two-factor
+// @Security    BearerAuth
+// @Accept      json
+// @Produce     json
+// @Param       request  body  twoFactorCodeRequest  true  "Code or recovery code"
+// @Success     204
+// @Failure     400      {object}  problem            "invalid body"
+// @Failure     401      {object}  problem            "unauthorized or invalid code"
+// @Failure     403      {object}  problem            "required for the user's role"
+// @Failure     409      {object}  problem            "not enabled"
+// @Failure     429      {object}  problem            "too many failed attempts"
+// @Failure     500      {object}  problem            "server error"
+// @Router      /api/v1/auth/2fa/disable [post]
+func (h *Handler) handleDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req twoFactorCodeRequest
+	if !h.decodeCode(w, r, &req) {
+		return
+	}
+	u, err := h.userSvc.GetByID(r.Context(), userIDFromCtx(r))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	if !h.guardTwoFactorCode(w, r, u, func() error {
+		return h.twoFactorSvc.Disable(r.Context(), u.ID, req.Code)
+	}) {
+		return
+	}
+	h.auditSvc.Record(r.Context(), h.auditEvent(r, audit.ActionTwoFactorDisabled, &u.ID, u.Email, ""))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary     Regenerate recovery codes
+// @Description Replaces all recovery codes of the current user, used or not. Needs a current code or an unused recovery code; wrong codes count as failed logins.
+// @Tags        two-factor
+// @Security    BearerAuth
+// @Accept      json
+// @Produce     json
+// @Param       request  body      twoFactorCodeRequest  true  "Code or recovery code"
+// @Success     200      {object}  recoveryCodesResponse
+// @Failure     400      {object}  problem            "invalid body"
+// @Failure     401      {object}  problem            "unauthorized or invalid code"
+// @Failure     409      {object}  problem            "not enabled"
+// @Failure     429      {object}  problem            "too many failed attempts"
+// @Failure     500      {object}  problem            "server error"
+// @Router      /api/v1/auth/2fa/recovery-codes [post]
+func (h *Handler) handleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var req twoFactorCodeRequest
+	if !h.decodeCode(w, r, &req) {
+		return
+	}
+	u, err := h.userSvc.GetByID(r.Context(), userIDFromCtx(r))
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+
+	var codes []string
+	if !h.guardTwoFactorCode(w, r, u, func() (err error) {
+		codes, err = h.twoFactorSvc.RegenerateRecoveryCodes(r.Context(), u.ID, req.Code)
+		return err
+	}) {
+		return
+	}
+	h.auditSvc.Record(r.Context(), h.auditEvent(r, audit.ActionRecoveryCodesRenewed, &u.ID, u.Email, ""))
+
+	writeJSON(w, http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *Handler) decodeCode(w http.ResponseWriter, r *http.Request, req *twoFactorCodeRequest) bool {
+	if err := h.decodeJSON(w, r, req); err != nil {
+		errorResponse(w, r, err)
+		return false
+	}
+	v := validate.New()
+	v.String("code", req.Code, validate.Required)
+	if err := v.Err(); err != nil {
+		errorResponse(w, r, err)
+		return false
+	}
+	return true
+}
+
+// guardTwoFactorCode runs check, which verifies a two-factor code of u,
+// under the login guard of their email, so that guessing codes counts as
+// failed logins. It writes the error response and returns false if check
+// is blocked or fails.
+func (h *Handler) guardTwoFactorCode(w http.ResponseWriter, r *http.Request, u *user.User, check func() error) bool {
+	ip := h.limiter.ClientIP(r)
+	if block, blocked := h.loginGuard.Check(r.Context(), u.Email, ip); blocked {
+		loginBlockedResponse(w, r, block)
+		return false
+	}
+	err := check()
+	if errors.Is(err, user.ErrInvalidCode) {
+		h.recordFailure(r, audit.ActionTwoFactorFailed, &u.ID, u.Email, ip)
+	}
+	if err != nil {
+		errorResponse(w, r, err)
+		return false
+	}
+	return true
+}
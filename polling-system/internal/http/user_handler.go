@@ -1,9 +1,9 @@
 package api
 
 import (
-	"encoding/json"
 	"net/http"
 
+	"polling-system/internal/domain/audit"
 	"polling-system/internal/platform/apperr"
 )
 
@@ -16,12 +16,12 @@ type updateRoleRequest struct {
 // @Security    BearerAuth
 // @Produce     json
 // @Success     200  {array}   user.User
-// @Failure     500  {object}  map[string]string  "server error"
+// @Failure     500  {object}  problem            "server error"
 // @Router      /api/v1/users [get]
 func (h *Handler) handleListUsers(w http.ResponseWriter, r *http.Request) {
 	users, err := h.userSvc.List(r.Context())
 	if err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, users)
@@ -34,29 +34,25 @@ func (h *Handler) handleListUsers(w http.ResponseWriter, r *http.Request) {
 // @Param       id       path     int64              true  "User ID"
 // @Param       request  body     updateRoleRequest  true  "New role"
 // @Success     204
-// @Failure     400      {object}  map[string]string  "invalid id or body"
-// @Failure     404      {object}  map[string]string  "not found"
-// @Failure     500      {object}  map[string]string  "server error"
+// @Failure     400      {object}  problem            "invalid id or body"
+// @Failure     404      {object}  problem            "not found"
+// @Failure     500      {object}  problem            "server error"
 // @Router      /api/v1/users/{id}/role [patch]
 func (h *Handler) handleUpdateUserRole(w http.ResponseWriter, r *http.Request) {
 	id, err := parseIDParam(r, "id")
 	if err != nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid id", err))
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid id", err))
 		return
 	}
 
 	var req updateRoleRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid body", err))
-		return
-	}
-	if req.Role != "admin" && req.Role != "user" {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid role", nil))
+	if err := h.decodeJSON(w, r, &req); err != nil {
+		errorResponse(w, r, err)
 		return
 	}
 
 	if err := h.userSvc.UpdateRole(r.Context(), id, req.Role); err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
 		return
 	}
 
@@ -68,23 +64,96 @@ func (h *Handler) handleUpdateUserRole(w http.ResponseWriter, r *http.Request) {
 // @Security    BearerAuth
 // @Param       id   path  int64  true  "User ID"
 // @Success     204
-// @Failure     400  {object}  map[string]string  "invalid id"
-// @Failure     401  {object}  map[string]string  "unauthorized"
-// @Failure     403  {object}  map[string]string  "forbidden"
-// @Failure     404  {object}  map[string]string  "not found"
-// @Failure     500  {object}  map[string]string  "server error"
+// @Failure     400  {object}  problem            "invalid id"
+// @Failure     401  {object}  problem            "unauthorized"
+// @Failure     403  {object}  problem            "forbidden"
+// @Failure     404  {object}  problem            "not found"
+// @Failure     500  {object}  problem            "server error"
 // @Router      /api/v1/users/{id}/deactivate [patch]
 func (h *Handler) handleDeactivateUser(w http.ResponseWriter, r *http.Request) {
 	id, err := parseIDParam(r, "id")
 	if err != nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid id", err))
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid id", err))
 		return
 	}
 
 	if err := h.userSvc.Deactivate(r.Context(), id); err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary     Unlock user login
+// @Description Forgets the failed logins of the user's email, lifting its delay or lockout. Failures counted against IP addresses are kept.
+// @Tags        users
+// @Security    BearerAuth
+// @Param       id   path  int64  true  "User ID"
+// @Success     204
+// @Failure     400  {object}  problem            "invalid id"
+// @Failure     401  {object}  problem            "unauthorized"
+// @Failure     403  {object}  problem            "forbidden"
+// @Failure     404  {object}  problem            "not found"
+// @Failure     500  {object}  problem            "server error"
+// @Router      /api/v1/users/{id}/unlock [patch]
+func (h *Handler) handleUnlockUser(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(r, "id")
+	if err != nil {
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid id", err))
+		return
+	}
+
+	u, err := h.userSvc.GetByID(r.Context(), id)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	if err := h.loginGuard.Unlock(r.Context(), u.Email); err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	e := h.auditEvent(r, audit.ActionAccountUnlocked, &u.ID, u.Email, "")
+	actorID := userIDFromCtx(r)
+	e.ActorID = &actorID
+	h.auditSvc.Record(r.Context(), e)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary     Reset user two-factor authentication
+// @Description For users who lost both their authenticator and their recovery codes: removes their two-factor setup, so they log in with their password alone and can enroll again. Users whose role requires two-factor authentication must then enroll before using the API.
+// @Tags        users
+// @Security    BearerAuth
+// @Param       id   path  int64  true  "User ID"
+// @Success     204
+// @Failure     400  {object}  problem            "invalid id"
+// @Failure     401  {object}  problem            "unauthorized"
+// @Failure     403  {object}  problem            "forbidden"
+// @Failure     404  {object}  problem            "not found"
+// @Failure     409  {object}  problem            "two-factor authentication not enabled"
+// @Failure     500  {object}  problem            "server error"
+// @Router      /api/v1/users/{id}/2fa [delete]
+func (h *Handler) handleResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(r, "id")
+	if err != nil {
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid id", err))
+		return
+	}
+
+	u, err := h.userSvc.GetByID(r.Context(), id)
+	if err != nil {
+		errorResponse(w, r, err)
+		return
+	}
+	if err := h.twoFactorSvc.Reset(r.Context(), u.ID); err != nil {
+		errorResponse(w, r, err)
 		return
 	}
+	e := h.auditEvent(r, audit.ActionTwoFactorReset, &u.ID, u.Email, "")
+	actorID := userIDFromCtx(r)
+	e.ActorID = &actorID
+	h.auditSvc.Record(r.Context(), e)
 
 	w.WriteHeader(http.StatusNoContent)
 }
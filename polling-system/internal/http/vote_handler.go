@@ -1,11 +1,18 @@
 package api
 
 import (
-	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 
 	"polling-system/internal/domain/vote"
+	"polling-system/internal/metrics"
 	"polling-system/internal/platform/apperr"
+	"polling-system/internal/platform/validate"
 	"polling-system/internal/worker"
 )
 
@@ -14,9 +21,13 @@ type voteRequest struct {
 }
 
 type pollResultsResponse struct {
-	PollID     int64         `json:"poll_id"`
-	TotalVotes int64         `json:"total_votes"`
-	Options    []vote.Result `json:"options"`
+	PollID         int64            `json:"poll_id"`
+	Status         string           `json:"status"`
+	TotalVotes     int64            `json:"total_votes"`
+	Options        []vote.Result    `json:"options"`
+	Tie            bool             `json:"tie"`
+	WinnerOptionID *int64           `json:"winner_option_id,omitempty"`
+	Confidence     *vote.Confidence `json:"confidence,omitempty"`
 }
 
 // @Summary     Vote for an option
@@ -26,71 +37,165 @@ type pollResultsResponse struct {
 // @Param       id       path      int64        true  "Poll ID"
 // @Param       request  body      voteRequest  true  "Vote payload"
 // @Success     204
-// @Failure     400      {object}  map[string]string  "invalid body or already voted"
-// @Failure     401      {object}  map[string]string  "unauthorized"
-// @Failure     404      {object}  map[string]string  "not found"
-// @Failure     409      {object}  map[string]string  "already voted"
-// @Failure     500      {object}  map[string]string  "server error"
+// @Failure     400      {object}  problem            "invalid body or already voted"
+// @Failure     401      {object}  problem            "unauthorized"
+// @Failure     404      {object}  problem            "not found"
+// @Failure     409      {object}  problem            "already voted"
+// @Failure     429      {object}  problem            "rate limited"
+// @Failure     500      {object}  problem            "server error"
 // @Router      /api/v1/polls/{id}/vote [post]
 func (h *Handler) handleVote(w http.ResponseWriter, r *http.Request) {
 	pollID, err := parseIDParam(r, "id")
 	if err != nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid poll id", err))
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid poll id", err))
 		return
 	}
 
 	var req voteRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid body", err))
+	if err := h.decodeJSON(w, r, &req); err != nil {
+		metrics.ObserveVote("invalid_input")
+		errorResponse(w, r, err)
 		return
 	}
-	if req.OptionID == 0 {
-		errorResponse(w, apperr.BadRequest("invalid_input", "option_id is required", nil))
+	v := validate.New()
+	v.Check(req.OptionID > 0, "option_id", "required", "option_id is required")
+	if err := v.Err(); err != nil {
+		metrics.ObserveVote("invalid_input")
+		errorResponse(w, r, err)
 		return
 	}
 
 	userID := userIDFromCtx(r)
 
 	if err := h.voteSvc.Vote(r.Context(), pollID, req.OptionID, userID); err != nil {
-		errorResponse(w, err)
+		metrics.ObserveVote(voteRejectReason(err))
+		errorResponse(w, r, err)
 		return
 	}
+	metrics.ObserveVote("")
 
 	select {
-	case h.voteCh <- worker.VoteEvent{PollID: pollID, OptionID: req.OptionID, UserID: userID}:
+	case h.voteCh <- worker.VoteEvent{PollID: pollID, OptionID: req.OptionID, UserID: userID, QueuedAt: time.Now(), SpanContext: trace.SpanContextFromContext(r.Context())}:
 	default:
+		metrics.IncVoteEventDropped()
+		slog.WarnContext(r.Context(), "vote queue full, aggregation event dropped", "poll_id", pollID, "option_id", req.OptionID)
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// voteRejectReason is the votes_rejected_total label for a failed vote.
+func voteRejectReason(err error) string {
+	switch {
+	case errors.Is(err, vote.ErrAlreadyVoted):
+		return "already_voted"
+	case errors.Is(err, vote.ErrPollNotActive):
+		return "poll_not_active"
+	case errors.Is(err, vote.ErrOptionNotInPoll):
+		return "option_not_in_poll"
+	case errors.Is(err, vote.ErrPollNotFound):
+		return "poll_not_found"
+	default:
+		return "error"
+	}
+}
+
 // @Summary     Poll results
+// @Description Options are ordered by votes, then position, then id; zero-vote options are included. Closed polls report a winner or a tie.
+// @Description Pass confidence (e.g. 0.95) to include Wilson score intervals, the margin of error and whether the leader is statistically ahead.
 // @Tags        polls
 // @Security    BearerAuth
 // @Produce     json
-// @Param       id   path     int64  true  "Poll ID"
+// @Param       id          path      int64   true   "Poll ID"
+// @Param       confidence  query     number  false  "Confidence level in (0, 1)"
 // @Success     200  {object} pollResultsResponse
-// @Failure     400  {object}  map[string]string  "invalid poll id"
-// @Failure     401  {object}  map[string]string  "unauthorized"
-// @Failure     404  {object}  map[string]string  "not found"
-// @Failure     500  {object}  map[string]string  "server error"
+// @Failure     400  {object}  problem            "invalid poll id or confidence"
+// @Failure     401  {object}  problem            "unauthorized"
+// @Failure     404  {object}  problem            "not found"
+// @Failure     500  {object}  problem            "server error"
 // @Router      /api/v1/polls/{id}/results [get]
 func (h *Handler) handlePollResults(w http.ResponseWriter, r *http.Request) {
 	pollID, err := parseIDParam(r, "id")
 	if err != nil {
-		errorResponse(w, apperr.BadRequest("invalid_input", "invalid poll id", err))
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid poll id", err))
+		return
+	}
+
+	res, err := h.voteSvc.Results(r.Context(), pollID)
+	if err != nil {
+		errorResponse(w, r, err)
 		return
 	}
 
-	res, total, err := h.voteSvc.Results(r.Context(), pollID)
+	resp := pollResultsResponse{
+		PollID:         res.PollID,
+		Status:         res.Status,
+		TotalVotes:     res.TotalVotes,
+		Options:        res.Options,
+		Tie:            res.Tie,
+		WinnerOptionID: res.WinnerOptionID,
+	}
+
+	if raw := r.URL.Query().Get("confidence"); raw != "" {
+		level, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid confidence", err))
+			return
+		}
+		resp.Options, resp.Confidence, err = vote.WithConfidence(res.Options, res.TotalVotes, level)
+		if err != nil {
+			errorResponse(w, r, err)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// @Summary     Poll vote analytics
+// @Description Admins and the poll's creator. Votes per option bucketed over time with cumulative curves, peak hour and participation rate.
+// @Tags        polls
+// @Security    BearerAuth
+// @Produce     json
+// @Param       id      path      int64   true   "Poll ID"
+// @Param       bucket  query     string  false  "Bucket size"  Enums(minute,hour,day)  default(hour)
+// @Success     200     {object}  vote.Analytics
+// @Failure     400     {object}  problem            "invalid poll id or bucket"
+// @Failure     401     {object}  problem            "unauthorized"
+// @Failure     403     {object}  problem            "forbidden"
+// @Failure     404     {object}  problem            "not found"
+// @Failure     500     {object}  problem            "server error"
+// @Router      /api/v1/polls/{id}/analytics [get]
+func (h *Handler) handlePollAnalytics(w http.ResponseWriter, r *http.Request) {
+	pollID, err := parseIDParam(r, "id")
+	if err != nil {
+		errorResponse(w, r, apperr.BadRequest("invalid_input", "invalid poll id", err))
+		return
+	}
+
+	// Admins see the analytics of every poll, other users only of their own.
+	if role, _ := r.Context().Value(ctxKeyRole).(string); role != "admin" {
+		p, _, err := h.pollSvc.Get(r.Context(), pollID)
+		if err != nil {
+			errorResponse(w, r, err)
+			return
+		}
+		if p.CreatorID != userIDFromCtx(r) {
+			errorResponse(w, r, apperr.Forbidden("forbidden", "insufficient permissions", nil))
+			return
+		}
+	}
+
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = vote.BucketHour
+	}
+
+	res, err := h.voteSvc.Analytics(r.Context(), pollID, bucket)
 	if err != nil {
-		errorResponse(w, err)
+		errorResponse(w, r, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{
-		"poll_id":     pollID,
-		"total_votes": total,
-		"options":     res,
-	})
+	writeJSON(w, http.StatusOK, res)
 }
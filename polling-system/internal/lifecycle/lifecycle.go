@@ -0,0 +1,101 @@
+// Package lifecycle coordinates the shutdown of the server's components:
+// the HTTP server, the background workers and anything added later.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Manager runs background components and stops every registered component
+// on Shutdown, in the order they were registered.
+type Manager struct {
+	logger   *slog.Logger
+	stopping atomic.Bool
+
+	mu    sync.Mutex
+	steps []step
+}
+
+type step struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+func New(logger *slog.Logger) *Manager {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Manager{logger: logger}
+}
+
+// ShuttingDown reports whether Shutdown has been called.
+func (m *Manager) ShuttingDown() bool {
+	return m.stopping.Load()
+}
+
+// OnShutdown registers stop to be called during Shutdown.
+func (m *Manager) OnShutdown(name string, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.steps = append(m.steps, step{name: name, stop: stop})
+}
+
+// Go starts run in a goroutine. On shutdown drain is called to ask run to
+// finish its work, for example by closing its input channel, and run is
+// given until the shutdown deadline to return; after that its context is
+// cancelled. A nil drain cancels the context right away.
+func (m *Manager) Go(name string, run func(ctx context.Context), drain func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run(ctx)
+	}()
+
+	m.OnShutdown(name, func(stopCtx context.Context) error {
+		defer cancel()
+		if drain == nil {
+			cancel()
+			<-done
+			return nil
+		}
+		drain()
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			cancel()
+			<-done
+			return fmt.Errorf("drain did not finish in time: %w", stopCtx.Err())
+		}
+	})
+}
+
+// Shutdown marks the manager as shutting down and runs every registered
+// step in order. Steps run even if an earlier one failed or ctx expired, so
+// that each gets a chance to release its resources; the errors are joined.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.stopping.Store(true)
+
+	m.mu.Lock()
+	steps := append([]step(nil), m.steps...)
+	m.mu.Unlock()
+
+	var errs []error
+	for _, s := range steps {
+		start := time.Now()
+		if err := s.stop(ctx); err != nil {
+			m.logger.Error("shutdown step failed", "component", s.name, "duration", time.Since(start), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+		m.logger.Info("component stopped", "component", s.name, "duration", time.Since(start))
+	}
+	return errors.Join(errs...)
+}
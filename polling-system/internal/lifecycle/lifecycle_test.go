@@ -0,0 +1,111 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestShutdownRunsStepsInOrder(t *testing.T) {
+	m := New(testLogger())
+	var order []string
+	failure := errors.New("boom")
+	m.OnShutdown("http", func(ctx context.Context) error {
+		if !m.ShuttingDown() {
+			t.Errorf("ShuttingDown must be true while steps run")
+		}
+		order = append(order, "http")
+		return nil
+	})
+	m.OnShutdown("cache", func(ctx context.Context) error {
+		order = append(order, "cache")
+		return failure
+	})
+	m.OnShutdown("db", func(ctx context.Context) error {
+		order = append(order, "db")
+		return nil
+	})
+
+	if m.ShuttingDown() {
+		t.Fatalf("not shutting down before Shutdown")
+	}
+	err := m.Shutdown(context.Background())
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the failing step's error, got %v", err)
+	}
+	if want := []string{"http", "cache", "db"}; !slices.Equal(order, want) {
+		t.Fatalf("expected steps %v, got %v", want, order)
+	}
+}
+
+func TestGoDrainsBeforeCancelling(t *testing.T) {
+	m := New(testLogger())
+	ch := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		ch <- i
+	}
+	var processed int
+	m.Go("consumer", func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-ch:
+				if !ok {
+					return
+				}
+				time.Sleep(time.Millisecond)
+				processed++
+			}
+		}
+	}, func() { close(ch) })
+
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if processed != 10 {
+		t.Fatalf("expected the queue to be drained, processed %d", processed)
+	}
+}
+
+func TestGoCancelsAfterDeadline(t *testing.T) {
+	m := New(testLogger())
+	cancelled := make(chan struct{})
+	m.Go("stuck", func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	}, func() {})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := m.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+	select {
+	case <-cancelled:
+	default:
+		t.Fatalf("run must have returned after its context was cancelled")
+	}
+}
+
+func TestGoWithoutDrainCancelsImmediately(t *testing.T) {
+	m := New(testLogger())
+	m.Go("ticker", func(ctx context.Context) { <-ctx.Done() }, nil)
+
+	start := time.Now()
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("shutdown waited for a component without a drain")
+	}
+}
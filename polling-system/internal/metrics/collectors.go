@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// collectTimeout bounds the queries run while collecting a scrape.
+const collectTimeout = 2 * time.Second
+
+// RegisterDBStats exports the connection pool statistics of db as the
+// go_sql_* metrics.
+func RegisterDBStats(db *sql.DB, name string) {
+	registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterVoteQueue exports the depth and capacity of the stats worker
+// queue; depth is called on every scrape.
+func RegisterVoteQueue(depth func() int, capacity int) {
+	registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "polling",
+			Name:      "vote_queue_depth",
+			Help:      "Vote events waiting for a stats worker.",
+		}, func() float64 { return float64(depth()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "polling",
+			Name:      "vote_queue_capacity",
+			Help:      "Capacity of the stats worker queue.",
+		}, func() float64 { return float64(capacity) }),
+	)
+}
+
+// RegisterPollsByStatus exports the number of polls per status, counted by
+// count on every scrape.
+func RegisterPollsByStatus(count func(ctx context.Context) (map[string]int64, error)) {
+	registry.MustRegister(&pollsCollector{
+		count: count,
+		desc:  prometheus.NewDesc("polling_polls", "Polls by status.", []string{"status"}, nil),
+	})
+}
+
+type pollsCollector struct {
+	count func(ctx context.Context) (map[string]int64, error)
+	desc  *prometheus.Desc
+}
+
+func (c *pollsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *pollsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+	counts, err := c.count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	for status, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), status)
+	}
+}
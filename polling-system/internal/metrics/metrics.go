@@ -1,33 +1,281 @@
+// Package metrics defines the server's Prometheus metrics. They live on a
+// private registry served by Handler, so that libraries registering on the
+// global default registry cannot leak metrics into the scrape.
 package metrics
 
 import (
+	"net/http"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+var registry = prometheus.NewRegistry()
+
 var (
-	httpRequestsTotal *prometheus.CounterVec
-	registerOnce      sync.Once
+	httpRequestsTotal     *prometheus.CounterVec
+	httpRequestDuration   *prometheus.HistogramVec
+	httpRequestsInFlight  prometheus.Gauge
+	votesCastTotal        prometheus.Counter
+	votesRejectedTotal    *prometheus.CounterVec
+	voteEventsDropped     prometheus.Counter
+	cacheRequestsTotal    *prometheus.CounterVec
+	configReloadsTotal    *prometheus.CounterVec
+	configRestartRequired prometheus.Gauge
+	retriesTotal          *prometheus.CounterVec
+	circuitBreakerState   *prometheus.GaugeVec
+	deadLetterQueueSize   prometheus.Gauge
+	voteEventsTotal       *prometheus.CounterVec
+	voteAggregationDelay  prometheus.Histogram
+	statsFlushDuration    *prometheus.HistogramVec
+	statsFlushBatchSize   prometheus.Histogram
+	statsUpsertRowsTotal  prometheus.Counter
+	registerOnce          sync.Once
 )
 
-// Register initializes Prometheus metrics on the default registry.
+// Register initializes the metrics on the private registry, together with
+// the Go runtime and process collectors.
 func Register() {
 	registerOnce.Do(func() {
-		httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+		factory := promauto.With(registry)
+
+		httpRequestsTotal = factory.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "polling",
 			Name:      "http_requests_total",
 			Help:      "Total HTTP requests processed by the polling API.",
 		}, []string{"method", "path", "status"})
+		httpRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "polling",
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "path"})
+		httpRequestsInFlight = factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: "polling",
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests currently being served.",
+		})
+		votesCastTotal = factory.NewCounter(prometheus.CounterOpts{
+			Namespace: "polling",
+			Name:      "votes_cast_total",
+			Help:      "Votes accepted.",
+		})
+		votesRejectedTotal = factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "polling",
+			Name:      "votes_rejected_total",
+			Help:      "Votes rejected by reason.",
+		}, []string{"reason"})
+		voteEventsDropped = factory.NewCounter(prometheus.CounterOpts{
+			Namespace: "polling",
+			Name:      "vote_events_dropped_total",
+			Help:      "Vote events dropped because the stats worker queue was full.",
+		})
+		cacheRequestsTotal = factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "polling",
+			Name:      "results_cache_requests_total",
+			Help:      "Poll results cache lookups by backend and result (hit, miss or error).",
+		}, []string{"backend", "result"})
+		configReloadsTotal = factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "polling",
+			Name:      "config_reloads_total",
+			Help:      "Configuration reload attempts by result.",
+		}, []string{"result"})
+		configRestartRequired = factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: "polling",
+			Name:      "config_restart_required_settings",
+			Help:      "Number of changed settings that only take effect after a restart.",
+		})
+		retriesTotal = factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "polling",
+			Name:      "retries_total",
+			Help:      "Retried attempts and abandoned operations by operation and outcome (retry or gave_up).",
+		}, []string{"operation", "outcome"})
+		circuitBreakerState = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "polling",
+			Name:      "circuit_breaker_state",
+			Help:      "Circuit breaker state: 0 closed, 1 open, 2 half-open.",
+		}, []string{"breaker"})
+		deadLetterQueueSize = factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: "polling",
+			Name:      "dead_letter_queue_size",
+			Help:      "Vote events waiting in the dead-letter queue.",
+		})
+		voteEventsTotal = factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "polling",
+			Name:      "vote_events_total",
+			Help:      "Vote events processed by the stats workers by result (aggregated or failed).",
+		}, []string{"result"})
+		voteAggregationDelay = factory.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "polling",
+			Name:      "vote_aggregation_latency_seconds",
+			Help:      "Time from queueing a vote event to its aggregated count being written.",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		})
+		statsFlushDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "polling",
+			Name:      "stats_flush_duration_seconds",
+			Help:      "Duration of stats worker batch flushes, retries included, by result.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"result"})
+		statsFlushBatchSize = factory.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "polling",
+			Name:      "stats_flush_batch_size",
+			Help:      "Vote events per stats worker flush.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 11),
+		})
+		statsUpsertRowsTotal = factory.NewCounter(prometheus.CounterOpts{
+			Namespace: "polling",
+			Name:      "stats_upsert_rows_total",
+			Help:      "Aggregated result rows written by stats worker flushes; compare with vote_events_total for the coalescing ratio.",
+		})
 	})
 }
 
-// IncRequest increments the http_requests_total counter with the given labels.
-func IncRequest(method, path string, status int) {
+// Handler serves the private registry. Collection errors, such as a failed
+// poll count, drop the affected metric instead of failing the scrape.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.ContinueOnError,
+		Registry:      registry,
+	})
+}
+
+// ObserveRequest counts a served HTTP request and records its latency. path
+// is the route pattern, not the raw URL, to bound the label cardinality.
+func ObserveRequest(method, path string, status int, took time.Duration) {
 	if httpRequestsTotal == nil {
 		return
 	}
 	httpRequestsTotal.WithLabelValues(method, path, strconv.Itoa(status)).Inc()
+	httpRequestDuration.WithLabelValues(method, path).Observe(took.Seconds())
+}
+
+// TrackInFlight counts a request as in flight until the returned func is
+// called.
+func TrackInFlight() func() {
+	if httpRequestsInFlight == nil {
+		return func() {}
+	}
+	httpRequestsInFlight.Inc()
+	return httpRequestsInFlight.Dec
+}
+
+// ObserveVote counts an accepted vote, or a rejected one when reason is not
+// empty.
+func ObserveVote(reason string) {
+	if votesCastTotal == nil {
+		return
+	}
+	if reason == "" {
+		votesCastTotal.Inc()
+		return
+	}
+	votesRejectedTotal.WithLabelValues(reason).Inc()
+}
+
+// IncVoteEventDropped counts a vote event that did not fit in the queue.
+func IncVoteEventDropped() {
+	if voteEventsDropped == nil {
+		return
+	}
+	voteEventsDropped.Inc()
+}
+
+// ObserveCacheLookup counts a results cache lookup; result is hit, miss or
+// error.
+func ObserveCacheLookup(backend, result string) {
+	if cacheRequestsTotal == nil {
+		return
+	}
+	cacheRequestsTotal.WithLabelValues(backend, result).Inc()
+}
+
+// ObserveConfigReload counts a configuration reload attempt.
+func ObserveConfigReload(success bool) {
+	if configReloadsTotal == nil {
+		return
+	}
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	configReloadsTotal.WithLabelValues(result).Inc()
+}
+
+// SetConfigRestartRequired records how many changed settings await a restart.
+func SetConfigRestartRequired(n int) {
+	if configRestartRequired == nil {
+		return
+	}
+	configRestartRequired.Set(float64(n))
+}
+
+// ObserveRetry counts a retry of operation, or an operation given up on.
+func ObserveRetry(operation string, gaveUp bool) {
+	if retriesTotal == nil {
+		return
+	}
+	outcome := "retry"
+	if gaveUp {
+		outcome = "gave_up"
+	}
+	retriesTotal.WithLabelValues(operation, outcome).Inc()
+}
+
+// SetCircuitBreakerState records the state of the named breaker.
+func SetCircuitBreakerState(breaker string, state int) {
+	if circuitBreakerState == nil {
+		return
+	}
+	circuitBreakerState.WithLabelValues(breaker).Set(float64(state))
+}
+
+// SetDeadLetterQueueSize records the number of dead-lettered vote events.
+func SetDeadLetterQueueSize(n int64) {
+	if deadLetterQueueSize == nil {
+		return
+	}
+	deadLetterQueueSize.Set(float64(n))
+}
+
+// ObserveVoteEvent counts a vote event the stats workers aggregated or gave
+// up on. latency is recorded for aggregated events when positive.
+func ObserveVoteEvent(aggregated bool, latency time.Duration) {
+	if voteEventsTotal == nil {
+		return
+	}
+	if !aggregated {
+		voteEventsTotal.WithLabelValues("failed").Inc()
+		return
+	}
+	voteEventsTotal.WithLabelValues("aggregated").Inc()
+	if latency > 0 {
+		voteAggregationDelay.Observe(latency.Seconds())
+	}
+}
+
+// ObserveStatsFlush records a stats worker flush of events coalesced into
+// rows upserted rows.
+func ObserveStatsFlush(events, rows int, took time.Duration, success bool) {
+	if statsFlushDuration == nil {
+		return
+	}
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	statsFlushDuration.WithLabelValues(result).Observe(took.Seconds())
+	statsFlushBatchSize.Observe(float64(events))
+	if success {
+		statsUpsertRowsTotal.Add(float64(rows))
+	}
 }
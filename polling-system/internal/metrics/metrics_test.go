@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerServesPrivateRegistry(t *testing.T) {
+	Register()
+	ObserveRequest(http.MethodGet, "/api/v1/polls/{id}", http.StatusOK, 20*time.Millisecond)
+	ObserveVote("")
+	ObserveVote("already_voted")
+	RegisterVoteQueue(func() int { return 3 }, 100)
+	RegisterPollsByStatus(func(ctx context.Context) (map[string]int64, error) {
+		return nil, errors.New("database is down")
+	})
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	out := string(body)
+
+	for _, want := range []string{
+		`polling_http_request_duration_seconds_count{method="GET",path="/api/v1/polls/{id}"} 1`,
+		`polling_votes_cast_total 1`,
+		`polling_votes_rejected_total{reason="already_voted"} 1`,
+		`polling_vote_queue_depth 3`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("scrape is missing %q", want)
+		}
+	}
+	// A failing collector drops its own metric but not the scrape.
+	if strings.Contains(out, "polling_polls{") {
+		t.Errorf("failed poll count must not be exported")
+	}
+}
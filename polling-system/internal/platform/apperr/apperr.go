@@ -6,10 +6,18 @@ import (
 )
 
 type AppError struct {
-	Code    string `json:"error"`
+	Code       string      `json:"error"`
+	Message    string      `json:"message"`
+	Violations []Violation `json:"errors,omitempty"`
+	Err        error       `json:"-"`
+	status     int
+}
+
+// Violation describes one invalid field of a request.
+type Violation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
 	Message string `json:"message"`
-	Err     error  `json:"-"`
-	status  int
 }
 
 func (e *AppError) Error() string {
@@ -59,6 +67,21 @@ func Forbidden(code, msg string, err error) *AppError {
 	return newAppError(code, msg, err, http.StatusForbidden)
 }
 
+func TooManyRequests(code, msg string, err error) *AppError {
+	return newAppError(code, msg, err, http.StatusTooManyRequests)
+}
+
+// Validation reports a request with one or more invalid fields.
+func Validation(violations ...Violation) *AppError {
+	e := newAppError("validation_failed", "the request has invalid fields", nil, http.StatusBadRequest)
+	e.Violations = violations
+	return e
+}
+
+func RequestTooLarge(code, msg string, err error) *AppError {
+	return newAppError(code, msg, err, http.StatusRequestEntityTooLarge)
+}
+
 func Internal(code, msg string, err error) *AppError {
 	return newAppError(code, msg, err, http.StatusInternalServerError)
 }
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+
+	"polling-system/internal/config"
+)
+
+// Supported database dialects.
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// Dialect reports which backend dsn selects. DSNs with a sqlite: scheme open
+// SQLite; anything else is treated as Postgres.
+func Dialect(dsn string) string {
+	if strings.HasPrefix(dsn, "sqlite:") {
+		return SQLite
+	}
+	return Postgres
+}
+
+// Open connects to the backend selected by cfg.DSN and returns its dialect.
+func Open(cfg config.DBConfig) (*sql.DB, string, error) {
+	dialect := Dialect(cfg.DSN)
+	var (
+		db  *sql.DB
+		err error
+	)
+	switch dialect {
+	case SQLite:
+		db, err = NewSQLite(cfg)
+	default:
+		db, err = NewPostgres(cfg)
+	}
+	return db, dialect, err
+}
@@ -6,17 +6,19 @@ import (
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"polling-system/internal/config"
 )
 
-func NewPostgres(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("pgx", dsn)
+func NewPostgres(cfg config.DBConfig) (*sql.DB, error) {
+	db, err := sql.Open("pgx", cfg.DSN)
 	if err != nil {
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(time.Hour)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
 	deadline := time.Now().Add(15 * time.Second)
 	for {
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"strings"
+
+	_ "github.com/glebarez/go-sqlite"
+
+	"polling-system/internal/config"
+)
+
+// NewSQLite opens the SQLite database named by a DSN such as
+// sqlite://polling.db, sqlite:///var/lib/polling.db or sqlite::memory:.
+// Foreign keys are enforced and transactions take the write lock up front so
+// concurrent writers wait on the busy timeout instead of failing.
+func NewSQLite(cfg config.DBConfig) (*sql.DB, error) {
+	path, query, _ := strings.Cut(sqlitePath(cfg.DSN), "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	memory := path == "" || path == ":memory:"
+	if memory {
+		path = ":memory:"
+	}
+
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	if !memory {
+		params.Add("_pragma", "journal_mode(WAL)")
+	}
+	if params.Get("_txlock") == "" {
+		params.Set("_txlock", "immediate")
+	}
+
+	db, err := sql.Open("sqlite", path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	// Every connection to :memory: is a separate database, so keep exactly
+	// one open for the lifetime of the pool.
+	if memory {
+		db.SetMaxOpenConns(1)
+		db.SetConnMaxLifetime(0)
+	} else {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+
+	if err := db.PingContext(context.Background()); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func sqlitePath(dsn string) string {
+	if p, ok := strings.CutPrefix(dsn, "sqlite://"); ok {
+		return p
+	}
+	return strings.TrimPrefix(dsn, "sqlite:")
+}
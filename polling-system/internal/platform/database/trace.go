@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"runtime"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"polling-system/internal/platform/tracing"
+)
+
+// tracedExecutor records a client span for every statement. Conn only uses
+// it when ctx carries a recording span, so untraced work pays nothing.
+type tracedExecutor struct {
+	Executor
+}
+
+func (e tracedExecutor) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	res, err := e.Executor.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return res, err
+}
+
+func (e tracedExecutor) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	rows, err := e.Executor.QueryContext(ctx, query, args...)
+	tracing.End(span, err)
+	return rows, err
+}
+
+func (e tracedExecutor) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	row := e.Executor.QueryRowContext(ctx, query, args...)
+	tracing.End(span, row.Err())
+	return row
+}
+
+// startQuery names the span after the SQL operation and records the
+// statement, without its arguments, and the repository method that ran it.
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	statement := strings.Join(strings.Fields(query), " ")
+	operation, _, _ := strings.Cut(statement, " ")
+	attrs := []attribute.KeyValue{
+		attribute.String("db.operation.name", strings.ToUpper(operation)),
+		attribute.String("db.query.text", statement),
+	}
+	// Skip startQuery and the tracedExecutor method.
+	if pc, _, _, ok := runtime.Caller(2); ok {
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			name := fn.Name()
+			if i := strings.LastIndex(name, "/"); i >= 0 {
+				name = name[i+1:]
+			}
+			attrs = append(attrs, attribute.String("code.function.name", name))
+		}
+	}
+	ctx, span := tracing.Start(ctx, strings.ToUpper(operation), attrs...)
+	return ctx, span
+}
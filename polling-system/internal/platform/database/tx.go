@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"polling-system/internal/platform/tracing"
+	"polling-system/internal/retry"
+)
+
+// txPolicy retries units of work that lost a race with a concurrent
+// transaction. Jitter keeps the competitors from colliding again.
+var txPolicy = retry.Policy{
+	MaxAttempts: 5,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    200 * time.Millisecond,
+	Jitter:      retry.FullJitter,
+	Retryable:   retry.IsSerializationFailure,
+}
+
+// Executor is the part of *sql.DB and *sql.Tx that repositories use.
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// Conn returns the transaction carried by ctx, or db when there is none.
+// Repositories run every statement through it so that they take part in a
+// unit of work started by TxManager.
+func Conn(ctx context.Context, db *sql.DB) Executor {
+	var exec Executor = db
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		exec = tx
+	}
+	if trace.SpanFromContext(ctx).IsRecording() {
+		return tracedExecutor{exec}
+	}
+	return exec
+}
+
+// InTx runs fn in a transaction. If ctx already carries one, fn joins it and
+// the outermost caller decides whether to commit; otherwise a new transaction
+// is opened and committed when fn returns nil.
+func InTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	ctx, span := tracing.Start(ctx, "db.transaction")
+	err := inNewTx(ctx, db, fn)
+	tracing.End(span, err)
+	return err
+}
+
+func inNewTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// TxManager runs units of work: several repository calls that commit or roll
+// back together. Transactions that fail with a serialization failure or a
+// deadlock are retried from the start, so fn must not have side effects
+// outside the database.
+type TxManager struct {
+	db *sql.DB
+}
+
+func NewTxManager(db *sql.DB) *TxManager {
+	return &TxManager{db: db}
+}
+
+// WithinTx runs fn in one transaction. Repository calls must use the ctx
+// passed to fn. Nested calls join the outer transaction and are not retried
+// on their own.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+	return retry.Do(ctx, txPolicy, func(ctx context.Context) error {
+		return InTx(ctx, m.db, fn)
+	})
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"polling-system/internal/config"
+)
+
+func openTxTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := NewSQLite(config.DBConfig{DSN: "sqlite::memory:"})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`CREATE TABLE items (name TEXT NOT NULL)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	return db
+}
+
+func countItems(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM items`).Scan(&n); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	return n
+}
+
+func insertItem(ctx context.Context, db *sql.DB, name string) error {
+	_, err := Conn(ctx, db).ExecContext(ctx, `INSERT INTO items (name) VALUES ($1)`, name)
+	return err
+}
+
+func TestWithinTxCommitsAndRollsBack(t *testing.T) {
+	db := openTxTestDB(t)
+	m := NewTxManager(db)
+	ctx := context.Background()
+
+	err := m.WithinTx(ctx, func(ctx context.Context) error {
+		if err := insertItem(ctx, db, "a"); err != nil {
+			return err
+		}
+		return m.WithinTx(ctx, func(ctx context.Context) error {
+			return insertItem(ctx, db, "b")
+		})
+	})
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if n := countItems(t, db); n != 2 {
+		t.Fatalf("expected 2 committed items, got %d", n)
+	}
+
+	boom := errors.New("boom")
+	err = m.WithinTx(ctx, func(ctx context.Context) error {
+		if err := insertItem(ctx, db, "c"); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected fn error, got %v", err)
+	}
+	if n := countItems(t, db); n != 2 {
+		t.Fatalf("failed unit of work must roll back, got %d items", n)
+	}
+}
+
+func TestWithinTxRetriesSerializationFailures(t *testing.T) {
+	db := openTxTestDB(t)
+	m := NewTxManager(db)
+	ctx := context.Background()
+
+	calls := 0
+	err := m.WithinTx(ctx, func(ctx context.Context) error {
+		calls++
+		if err := insertItem(ctx, db, "x"); err != nil {
+			return err
+		}
+		if calls < 3 {
+			return &pgconn.PgError{Code: "40001"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls)
+	}
+	if n := countItems(t, db); n != 1 {
+		t.Fatalf("only the successful attempt may commit, got %d items", n)
+	}
+
+	calls = 0
+	err = m.WithinTx(ctx, func(ctx context.Context) error {
+		calls++
+		return &pgconn.PgError{Code: "23505"}
+	})
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" || calls != 1 {
+		t.Fatalf("other errors must not be retried: calls=%d err=%v", calls, err)
+	}
+}
+
+func TestConnTracesStatementsInTracedContexts(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	db := openTxTestDB(t)
+	if err := insertItem(context.Background(), db, "untraced"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if n := len(recorder.Ended()); n != 0 {
+		t.Fatalf("statements without a recording span must not be traced, got %d spans", n)
+	}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	err := NewTxManager(db).WithinTx(ctx, func(ctx context.Context) error {
+		return insertItem(ctx, db, "traced")
+	})
+	parent.End()
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected statement, transaction and parent spans, got %d", len(spans))
+	}
+	stmt, tx := spans[0], spans[1]
+	if stmt.Name() != "INSERT" || tx.Name() != "db.transaction" {
+		t.Fatalf("unexpected span names %q, %q", stmt.Name(), tx.Name())
+	}
+	if stmt.Parent().SpanID() != tx.SpanContext().SpanID() {
+		t.Fatalf("statement span must be a child of the transaction span")
+	}
+	attrs := make(map[string]string)
+	for _, kv := range stmt.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["db.query.text"] != `INSERT INTO items (name) VALUES ($1)` {
+		t.Fatalf("unexpected query attribute %q", attrs["db.query.text"])
+	}
+	if attrs["code.function.name"] != "database.insertItem" {
+		t.Fatalf("unexpected caller attribute %q", attrs["code.function.name"])
+	}
+}
@@ -2,17 +2,38 @@ package jwt
 
 import (
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Authentication methods (RFC 8176) recorded in access tokens.
+const (
+	MethodPassword = "pwd"
+	MethodOTP      = "otp"
+)
+
+// PurposeTwoFactor marks the intermediate token issued after the password
+// step of a two-factor login. It only grants the second step.
+const PurposeTwoFactor = "two_factor"
+
 type Claims struct {
 	UserID int64  `json:"user_id"`
-	Role   string `json:"role"`
+	Role   string `json:"role,omitempty"`
+	// AMR lists the authentication methods of an access token.
+	AMR []string `json:"amr,omitempty"`
+	// Purpose is set on tokens that are not access tokens.
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// HasMethod reports whether the token was issued after authenticating with
+// method.
+func (c *Claims) HasMethod(method string) bool {
+	return slices.Contains(c.AMR, method)
+}
+
 type Manager struct {
 	secret []byte
 	issuer string
@@ -25,22 +46,52 @@ func NewManager(secret, issuer string) *Manager {
 	return &Manager{secret: []byte(secret), issuer: issuer}
 }
 
-func (m *Manager) Generate(userID int64, role string, ttl time.Duration) (string, error) {
-	claims := Claims{
-		UserID: userID,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    m.issuer,
-		},
-	}
+// Generate issues an access token. methods lists how the user
+// authenticated, e.g. MethodPassword and MethodOTP.
+func (m *Manager) Generate(userID int64, role string, ttl time.Duration, methods ...string) (string, error) {
+	return m.sign(Claims{UserID: userID, Role: role, AMR: methods}, ttl)
+}
+
+// GenerateChallenge issues the intermediate token of a two-factor login.
+func (m *Manager) GenerateChallenge(userID int64, ttl time.Duration) (string, error) {
+	return m.sign(Claims{UserID: userID, Purpose: PurposeTwoFactor}, ttl)
+}
 
+func (m *Manager) sign(claims Claims, ttl time.Duration) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		Issuer:    m.issuer,
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(m.secret)
 }
 
+// Parse validates an access token. Intermediate tokens are rejected.
 func (m *Manager) Parse(tokenStr string) (*Claims, error) {
+	claims, err := m.parse(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
+// ParseChallenge validates the intermediate token of a two-factor login.
+func (m *Manager) ParseChallenge(tokenStr string) (*Claims, error) {
+	claims, err := m.parse(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != PurposeTwoFactor {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
+func (m *Manager) parse(tokenStr string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %s", token.Method.Alg())
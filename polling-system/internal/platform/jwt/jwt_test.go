@@ -0,0 +1,39 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChallengeTokensAreNotAccessTokens(t *testing.T) {
+	m := NewManager("secret", "test")
+
+	challenge, err := m.GenerateChallenge(7, time.Minute)
+	if err != nil {
+		t.Fatalf("generate challenge: %v", err)
+	}
+	if _, err := m.Parse(challenge); err == nil {
+		t.Fatal("a challenge token must not be accepted as an access token")
+	}
+	claims, err := m.ParseChallenge(challenge)
+	if err != nil || claims.UserID != 7 || claims.Role != "" {
+		t.Fatalf("unexpected challenge claims %+v (%v)", claims, err)
+	}
+
+	access, err := m.Generate(7, "admin", time.Minute, MethodPassword, MethodOTP)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if _, err := m.ParseChallenge(access); err == nil {
+		t.Fatal("an access token must not be accepted as a challenge")
+	}
+	claims, err = m.Parse(access)
+	if err != nil || !claims.HasMethod(MethodOTP) || claims.Role != "admin" {
+		t.Fatalf("unexpected access claims %+v (%v)", claims, err)
+	}
+
+	expired, _ := m.GenerateChallenge(7, -time.Second)
+	if _, err := m.ParseChallenge(expired); err == nil {
+		t.Fatal("an expired challenge must be rejected")
+	}
+}
@@ -0,0 +1,102 @@
+// Package logging carries request-scoped log fields in a context and
+// redacts sensitive values before they are written.
+//
+// Fields added with With are attached to every record logged with that
+// context, so any layer can log with the *Context methods of a logger and
+// get the request ID, user and route of the request it is serving.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// Redacted replaces the value of sensitive attributes.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are matched case-insensitively against the last part of an
+// attribute key, so "new_password" and "access_token" are redacted too.
+var sensitiveKeys = []string{"password", "token", "secret", "authorization", "cookie", "recovery_code", "totp_code"}
+
+type fieldsKey struct{}
+
+// With returns a copy of ctx whose log records carry args, given as
+// alternating keys and values or slog.Attrs as with slog.Logger.With.
+func With(ctx context.Context, args ...any) context.Context {
+	if len(args) == 0 {
+		return ctx
+	}
+	attrs := slog.Group("", args...).Value.Group()
+	prev := Fields(ctx)
+	fields := make([]slog.Attr, 0, len(prev)+len(attrs))
+	fields = append(append(fields, prev...), attrs...)
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// Fields returns the fields added to ctx with With.
+func Fields(ctx context.Context) []slog.Attr {
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	return fields
+}
+
+// Handler adds the fields of the record's context to every record and
+// redacts sensitive attributes.
+type Handler struct {
+	slog.Handler
+}
+
+func NewHandler(h slog.Handler) *Handler {
+	return &Handler{Handler: h}
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	for _, a := range Fields(ctx) {
+		out.AddAttrs(redact(a))
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redact(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redact(a)
+	}
+	return &Handler{Handler: h.Handler.WithAttrs(redacted)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name)}
+}
+
+// redact replaces the value of a sensitive attribute, looking into groups.
+func redact(a slog.Attr) slog.Attr {
+	if isSensitive(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	v := a.Value.Resolve()
+	if v.Kind() != slog.KindGroup {
+		return slog.Attr{Key: a.Key, Value: v}
+	}
+	group := v.Group()
+	attrs := make([]slog.Attr, len(group))
+	for i, g := range group {
+		attrs[i] = redact(g)
+	}
+	return slog.Attr{Key: a.Key, Value: slog.GroupValue(attrs...)}
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func decodeRecord(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("decode %q: %v", buf.String(), err)
+	}
+	buf.Reset()
+	return rec
+}
+
+func TestHandlerAddsContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil)))
+
+	ctx := With(context.Background(), "request_id", "r1")
+	ctx = With(ctx, slog.Int64("user_id", 7))
+	logger.InfoContext(ctx, "hello", "poll_id", 3)
+
+	rec := decodeRecord(t, &buf)
+	if rec["request_id"] != "r1" || rec["user_id"] != float64(7) || rec["poll_id"] != float64(3) {
+		t.Fatalf("expected context and record fields, got %v", rec)
+	}
+
+	logger.Info("no context")
+	if rec := decodeRecord(t, &buf); rec["request_id"] != nil {
+		t.Fatalf("records without the context must not carry its fields, got %v", rec)
+	}
+}
+
+func TestHandlerRedactsSensitiveFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil))).With("jwt_secret", "s3cr3t")
+
+	logger.Info("login",
+		"email", "a@example.com",
+		"password", "hunter2",
+		slog.Group("headers", "Authorization", "Bearer abc"),
+		"access_token", "xyz",
+	)
+
+	rec := decodeRecord(t, &buf)
+	headers, _ := rec["headers"].(map[string]any)
+	if rec["password"] != Redacted || rec["access_token"] != Redacted || rec["jwt_secret"] != Redacted || headers["Authorization"] != Redacted {
+		t.Fatalf("expected sensitive fields to be redacted, got %v", rec)
+	}
+	if rec["email"] != "a@example.com" {
+		t.Fatalf("non-sensitive fields must be kept, got %v", rec)
+	}
+}
@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileOutboxWritesMessages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	o, err := NewFileOutbox(dir, "Polls <no-reply@example.com>")
+	if err != nil {
+		t.Fatalf("new outbox: %v", err)
+	}
+	msg := Message{To: "john@example.com", Subject: "Vérifiez", Body: "line one\nline two"}
+	if err := o.Send(context.Background(), msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("expected one message file, got %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	for _, want := range []string{"From: Polls <no-reply@example.com>\r\n", "To: john@example.com\r\n", "Subject: =?utf-8?q?", "\r\n\r\nline one\r\nline two"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("expected %q in message:\n%s", want, data)
+		}
+	}
+}
+
+func TestOutboxRejectsHeaderInjection(t *testing.T) {
+	o := NewOutbox()
+	if err := o.Send(context.Background(), Message{To: "john@example.com", Subject: "hi\r\nBcc: eve@example.com"}); err == nil {
+		t.Fatalf("expected a subject with a line break to be rejected")
+	}
+	if err := o.Send(context.Background(), Message{To: "not an address"}); err == nil {
+		t.Fatalf("expected an invalid recipient to be rejected")
+	}
+	if err := o.Send(context.Background(), Message{To: "john@example.com", Subject: "hi"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if msgs := o.Messages(); len(msgs) != 1 || msgs[0].Subject != "hi" {
+		t.Fatalf("unexpected outbox: %+v", msgs)
+	}
+}
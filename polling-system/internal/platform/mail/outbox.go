@@ -0,0 +1,64 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Outbox keeps sent messages in memory.
+type Outbox struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func NewOutbox() *Outbox {
+	return &Outbox{}
+}
+
+func (o *Outbox) Send(ctx context.Context, msg Message) error {
+	if _, err := format("", msg, time.Now()); err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Message(nil), o.sent...)
+}
+
+// FileOutbox writes every message to its own .eml file in a directory,
+// where it can be opened with a mail client.
+type FileOutbox struct {
+	dir  string
+	from string
+	seq  atomic.Int64
+}
+
+// NewFileOutbox creates dir if needed and returns an outbox writing to it.
+func NewFileOutbox(dir, from string) (*FileOutbox, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &FileOutbox{dir: dir, from: from}, nil
+}
+
+func (o *FileOutbox) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := format(o.from, msg, now)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%04d.eml", now.UTC().Format("20060102T150405.000000"), o.seq.Add(1))
+	return os.WriteFile(filepath.Join(o.dir, name), data, 0o640)
+}
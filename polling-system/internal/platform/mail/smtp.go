@@ -0,0 +1,90 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTP sends messages through an SMTP server, upgrading to TLS when the
+// server offers STARTTLS. Credentials are only sent over TLS.
+type SMTP struct {
+	addr     string
+	from     string
+	username string
+	password string
+}
+
+// NewSMTP returns a Mailer for the server at addr (host:port). from is the
+// sender, e.g. "Polls <no-reply@example.com>"; an empty username disables
+// authentication.
+func NewSMTP(addr, from, username, password string) *SMTP {
+	return &SMTP{addr: addr, from: from, username: username, password: password}
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	data, err := format(s.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	sender, err := mail.ParseAddress(s.from)
+	if err != nil {
+		return err
+	}
+	rcpt, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(s.addr)
+	if err != nil {
+		return err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.username != "" {
+		// PlainAuth refuses to send credentials without TLS, except to
+		// localhost.
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(sender.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(rcpt.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
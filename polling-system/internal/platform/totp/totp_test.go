@@ -0,0 +1,71 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// TestRFC6238Vectors checks the SHA-1 test vectors of RFC 6238, appendix B.
+func TestRFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	secret := base32.StdEncoding.EncodeToString(key)
+	vectors := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, v := range vectors {
+		step := Step(time.Unix(v.unix, 0))
+		if got := hotp(key, uint64(step), 8); got != v.want {
+			t.Fatalf("T=%d: got %s, want %s", v.unix, got, v.want)
+		}
+		code, err := Code(secret, step)
+		if err != nil || code != v.want[2:] {
+			t.Fatalf("T=%d: got 6-digit code %s (%v), want %s", v.unix, code, err, v.want[2:])
+		}
+	}
+}
+
+func TestMatchAcceptsClockSkew(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatalf("new secret: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	prev, _ := Code(secret, Step(now)-1)
+	if step, ok := Match(secret, prev, now, 1); !ok || step != Step(now)-1 {
+		t.Fatalf("expected the previous step to match, got %d %v", step, ok)
+	}
+	old, _ := Code(secret, Step(now)-2)
+	if _, ok := Match(secret, old, now, 1); ok {
+		t.Fatal("expected a code two steps old to be rejected")
+	}
+	if _, ok := Match(secret, "12345", now, 1); ok {
+		t.Fatal("expected a short code to be rejected")
+	}
+	if _, ok := Match("not base32!", "123456", now, 1); ok {
+		t.Fatal("expected an invalid secret to match nothing")
+	}
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI("Polling System", "john@example.com", "JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Polling System:john@example.com" {
+		t.Fatalf("unexpected URI %s", u)
+	}
+	q := u.Query()
+	if q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "Polling System" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Fatalf("unexpected parameters %v", q)
+	}
+}
@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLogHandlerAddsTraceIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&buf, nil))).With("component", "test")
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	logger.InfoContext(trace.ContextWithSpanContext(context.Background(), sc), "traced")
+	logger.InfoContext(context.Background(), "untraced")
+
+	dec := json.NewDecoder(&buf)
+	var traced, untraced map[string]any
+	if err := dec.Decode(&traced); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if err := dec.Decode(&untraced); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if traced["trace_id"] != sc.TraceID().String() || traced["span_id"] != sc.SpanID().String() {
+		t.Fatalf("expected trace and span IDs, got %v", traced)
+	}
+	if traced["component"] != "test" {
+		t.Fatalf("attributes added with With must be kept, got %v", traced)
+	}
+	if _, ok := untraced["trace_id"]; ok {
+		t.Fatalf("untraced record must not have a trace_id, got %v", untraced)
+	}
+}
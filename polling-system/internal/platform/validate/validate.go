@@ -0,0 +1,223 @@
+// Package validate checks input against declarative rules and collects
+// every violation instead of stopping at the first one.
+//
+// Rules are declared once, next to the type they constrain, usually in the
+// domain package that owns it:
+//
+//	v := validate.New()
+//	v.String("title", p.Title, validate.Required, validate.MaxLen(200))
+//	v.List("options", texts, validate.MinItems(2), validate.Unique)
+//	v.Each("options", texts, validate.Required, validate.MaxLen(200))
+//	return v.Err()
+//
+// The HTTP layer maps Errors to a validation problem listing every field.
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError is a violation of one field.
+type FieldError struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+// Errors holds the violations of a validation run.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Rule checks a string. It returns the code of the violation and a message
+// completing "<field> ...", or ok.
+type Rule func(value string) (code, msg string, ok bool)
+
+// ListRule checks a list of strings. A rule that rejects one item reports
+// its index, otherwise index is -1.
+type ListRule func(values []string) (index int, code, msg string, ok bool)
+
+// Validator collects violations.
+type Validator struct {
+	errs Errors
+}
+
+func New() *Validator {
+	return &Validator{}
+}
+
+// Add records a violation of field.
+func (v *Validator) Add(field, code, msg string) {
+	v.errs = append(v.errs, FieldError{Field: field, Code: code, Message: msg})
+}
+
+// Check records a violation of field unless ok.
+func (v *Validator) Check(ok bool, field, code, msg string) {
+	if !ok {
+		v.Add(field, code, msg)
+	}
+}
+
+// String applies rules to value, recording only the first violation so a
+// missing field is not also reported as too short.
+func (v *Validator) String(field, value string, rules ...Rule) {
+	for _, rule := range rules {
+		if code, msg, ok := rule(value); !ok {
+			v.Add(field, code, field+" "+msg)
+			return
+		}
+	}
+}
+
+// OptionalString applies rules to value if it is set.
+func (v *Validator) OptionalString(field string, value *string, rules ...Rule) {
+	if value != nil {
+		v.String(field, *value, rules...)
+	}
+}
+
+// List applies rules to values as a whole.
+func (v *Validator) List(field string, values []string, rules ...ListRule) {
+	for _, rule := range rules {
+		if index, code, msg, ok := rule(values); !ok {
+			if index >= 0 {
+				field = fmt.Sprintf("%s[%d]", field, index)
+			}
+			v.Add(field, code, field+" "+msg)
+			return
+		}
+	}
+}
+
+// Each applies rules to every item of values as field[i].
+func (v *Validator) Each(field string, values []string, rules ...Rule) {
+	for i, value := range values {
+		v.String(fmt.Sprintf("%s[%d]", field, i), value, rules...)
+	}
+}
+
+// Has reports whether field has a violation, so checks that need a valid
+// field can skip it.
+func (v *Validator) Has(field string) bool {
+	for _, fe := range v.errs {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge records the violations of err if it holds Errors and returns
+// whether it did. Other errors are left to the caller.
+func (v *Validator) Merge(err error) bool {
+	var errs Errors
+	if !errors.As(err, &errs) {
+		return false
+	}
+	v.errs = append(v.errs, errs...)
+	return true
+}
+
+// Err returns the violations as Errors, or nil if there are none.
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// Required rejects empty and blank strings.
+func Required(value string) (string, string, bool) {
+	return "required", "is required", strings.TrimSpace(value) != ""
+}
+
+// MinLen rejects strings shorter than n characters.
+func MinLen(n int) Rule {
+	return func(value string) (string, string, bool) {
+		return "too_short", fmt.Sprintf("must be at least %d characters", n), utf8.RuneCountInString(value) >= n
+	}
+}
+
+// MaxLen rejects strings longer than n characters.
+func MaxLen(n int) Rule {
+	return func(value string) (string, string, bool) {
+		return "too_long", fmt.Sprintf("must be at most %d characters", n), utf8.RuneCountInString(value) <= n
+	}
+}
+
+// MaxBytes rejects strings longer than n bytes.
+func MaxBytes(n int) Rule {
+	return func(value string) (string, string, bool) {
+		return "too_long", fmt.Sprintf("must be at most %d bytes", n), len(value) <= n
+	}
+}
+
+// OneOf rejects strings other than allowed.
+func OneOf(allowed ...string) Rule {
+	return func(value string) (string, string, bool) {
+		for _, a := range allowed {
+			if value == a {
+				return "", "", true
+			}
+		}
+		return "not_allowed", "must be one of " + strings.Join(allowed, ", "), false
+	}
+}
+
+// Email rejects anything but a bare address such as user@example.com.
+func Email(value string) (string, string, bool) {
+	addr, err := mail.ParseAddress(value)
+	ok := err == nil && addr.Address == value && strings.Contains(value[strings.LastIndexByte(value, '@')+1:], ".")
+	return "invalid_format", "must be an email address", ok
+}
+
+// RFC3339 rejects strings that are not RFC 3339 times.
+func RFC3339(value string) (string, string, bool) {
+	_, err := time.Parse(time.RFC3339, value)
+	return "invalid_format", "must be an RFC 3339 time", err == nil
+}
+
+// Contains rejects strings without a rune matching fn, described by what
+// as in "must contain a digit".
+func Contains(what string, fn func(rune) bool) Rule {
+	return func(value string) (string, string, bool) {
+		return "too_weak", "must contain " + what, strings.IndexFunc(value, fn) >= 0
+	}
+}
+
+// MinItems rejects lists with fewer than n items.
+func MinItems(n int) ListRule {
+	return func(values []string) (int, string, string, bool) {
+		return -1, "too_few", fmt.Sprintf("must have at least %d items", n), len(values) >= n
+	}
+}
+
+// MaxItems rejects lists with more than n items.
+func MaxItems(n int) ListRule {
+	return func(values []string) (int, string, string, bool) {
+		return -1, "too_many", fmt.Sprintf("must have at most %d items", n), len(values) <= n
+	}
+}
+
+// Unique rejects the first item equal to an earlier one.
+func Unique(values []string) (int, string, string, bool) {
+	seen := make(map[string]bool, len(values))
+	for i, value := range values {
+		if seen[value] {
+			return i, "duplicate", "must be unique", false
+		}
+		seen[value] = true
+	}
+	return -1, "", "", true
+}
@@ -0,0 +1,79 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"unicode"
+)
+
+func TestValidatorCollectsFirstViolationPerField(t *testing.T) {
+	v := New()
+	v.String("title", "", Required, MaxLen(3))
+	v.String("name", "abcd", Required, MaxLen(3))
+	v.OptionalString("skipped", nil, Required)
+	v.List("options", []string{"a", "b", "a"}, MinItems(2), Unique)
+	v.Each("options", []string{"a", " "}, Required)
+
+	var errs Errors
+	if !errors.As(v.Err(), &errs) {
+		t.Fatalf("expected Errors, got %v", v.Err())
+	}
+	want := []string{"title:required", "name:too_long", "options[2]:duplicate", "options[1]:required"}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %v, got %+v", want, errs)
+	}
+	for i, fe := range errs {
+		if got := fe.Field + ":" + fe.Code; got != want[i] {
+			t.Fatalf("violation %d: expected %s, got %s", i, want[i], got)
+		}
+	}
+	if errs[0].Message != "title is required" {
+		t.Fatalf("unexpected message %q", errs[0].Message)
+	}
+	if !v.Has("name") || v.Has("skipped") {
+		t.Fatalf("Has reports the wrong fields")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	inner := New()
+	inner.Add("a", "required", "a is required")
+
+	v := New()
+	if !v.Merge(fmt.Errorf("wrapped: %w", inner.Err())) || !v.Has("a") {
+		t.Fatalf("expected wrapped violations to be merged")
+	}
+	if v.Merge(errors.New("other")) || v.Merge(nil) {
+		t.Fatalf("expected other errors to be left alone")
+	}
+	if New().Err() != nil {
+		t.Fatalf("expected nil error without violations")
+	}
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		rule  Rule
+		value string
+		ok    bool
+	}{
+		{Email, "user@example.com", true},
+		{Email, "User <user@example.com>", false},
+		{Email, "user@localhost", false},
+		{Email, "user", false},
+		{RFC3339, "2030-01-01T00:00:00Z", true},
+		{RFC3339, "2030-01-01", false},
+		{OneOf("admin", "user"), "user", true},
+		{OneOf("admin", "user"), "owner", false},
+		{MinLen(3), "héé", true},
+		{MaxBytes(3), "héé", false},
+		{Contains("a digit", unicode.IsDigit), "abc1", true},
+		{Contains("a digit", unicode.IsDigit), "abcd", false},
+	}
+	for _, tt := range tests {
+		if _, _, ok := tt.rule(tt.value); ok != tt.ok {
+			t.Errorf("rule on %q: expected ok=%v", tt.value, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// IPResolver determines the client address of a request. X-Forwarded-For is
+// only honoured when the direct peer is a trusted proxy, and it is read from
+// the right so that entries prepended by the client cannot be used to spoof
+// the address.
+type IPResolver struct {
+	trusted []netip.Prefix
+}
+
+// NewIPResolver parses trusted proxy CIDRs (or single addresses).
+func NewIPResolver(cidrs []string) (*IPResolver, error) {
+	r := &IPResolver{}
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		if !strings.Contains(c, "/") {
+			addr, err := netip.ParseAddr(c)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", c, err)
+			}
+			r.trusted = append(r.trusted, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(c)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", c, err)
+		}
+		r.trusted = append(r.trusted, prefix.Masked())
+	}
+	return r, nil
+}
+
+func (r *IPResolver) ClientIP(req *http.Request) string {
+	peer := remoteHost(req.RemoteAddr)
+	if !r.isTrusted(peer) {
+		return peer
+	}
+
+	hops := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !r.isTrusted(hop) {
+			return hop
+		}
+		peer = hop
+	}
+	return peer
+}
+
+func (r *IPResolver) isTrusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range r.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
@@ -0,0 +1,190 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// LockoutPolicy slows down, then stops, a subject that keeps failing, such
+// as logins to one account or from one IP address.
+type LockoutPolicy struct {
+	// FreeAttempts failures are allowed without delay.
+	FreeAttempts int
+	// Every further failure doubles the wait before the next attempt,
+	// starting at BaseDelay and capped at MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockAfter failures lock the subject for LockDuration.
+	LockAfter    int
+	LockDuration time.Duration
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration
+}
+
+func (p LockoutPolicy) Validate() error {
+	switch {
+	case p.FreeAttempts < 0:
+		return fmt.Errorf("free attempts must not be negative")
+	case p.BaseDelay <= 0 || p.MaxDelay < p.BaseDelay:
+		return fmt.Errorf("delays must be positive with max delay not below base delay")
+	case p.LockAfter <= p.FreeAttempts:
+		return fmt.Errorf("lock after must be above free attempts")
+	case p.LockDuration <= 0:
+		return fmt.Errorf("lock duration must be positive")
+	case p.Window < p.LockDuration:
+		return fmt.Errorf("window must not be below lock duration")
+	}
+	return nil
+}
+
+// wait returns how long a subject must wait after its last failure, and
+// whether that wait is a lockout.
+func (p LockoutPolicy) wait(failures int) (time.Duration, bool) {
+	if failures >= p.LockAfter {
+		return p.LockDuration, true
+	}
+	if failures <= p.FreeAttempts {
+		return 0, false
+	}
+	d := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxDelay), false
+}
+
+// Failures are the recent failures of a subject.
+type Failures struct {
+	Count int
+	Last  time.Time
+}
+
+// FailureStore counts failures per key. Counts are forgotten window after
+// the last failure.
+type FailureStore interface {
+	Failures(ctx context.Context, key string) (Failures, error)
+	AddFailure(ctx context.Context, key string, window time.Duration) (Failures, error)
+	ResetFailures(ctx context.Context, key string) error
+}
+
+// Login block scopes.
+const (
+	ScopeAccount = "account"
+	ScopeIP      = "ip"
+)
+
+// LoginBlock refuses login attempts until RetryAfter has passed.
+type LoginBlock struct {
+	Scope      string
+	RetryAfter time.Duration
+	// Locked is set when the subject reached LockAfter failures, rather
+	// than being delayed.
+	Locked   bool
+	Failures int
+}
+
+// LoginGuard tracks failed logins per account and per client IP. Accounts
+// are tracked by email whether or not they exist, so blocks do not reveal
+// which emails are registered.
+type LoginGuard struct {
+	store   FailureStore
+	account LockoutPolicy
+	ip      LockoutPolicy
+	logger  *slog.Logger
+	now     func() time.Time
+}
+
+func NewLoginGuard(store FailureStore, account, ip LockoutPolicy, logger *slog.Logger) *LoginGuard {
+	return NewLoginGuardWithClock(store, account, ip, logger, time.Now)
+}
+
+// NewLoginGuardWithClock builds a LoginGuard that reads the time from now.
+// It should be the clock of store, since delays are measured from the
+// failure times the store records.
+func NewLoginGuardWithClock(store FailureStore, account, ip LockoutPolicy, logger *slog.Logger, now func() time.Time) *LoginGuard {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &LoginGuard{store: store, account: account, ip: ip, logger: logger, now: now}
+}
+
+// Check returns the block on a login to email from ip, if any. If the store
+// fails the attempt is let through, as with Limiter.Allow.
+func (g *LoginGuard) Check(ctx context.Context, email, ip string) (LoginBlock, bool) {
+	var block LoginBlock
+	for _, s := range g.subjects(email, ip) {
+		f, err := g.store.Failures(ctx, s.key)
+		if err != nil {
+			g.logger.Warn("login guard store unavailable", "error", err)
+			return LoginBlock{}, false
+		}
+		if b, ok := g.block(s, f); ok && b.RetryAfter > block.RetryAfter {
+			block = b
+		}
+	}
+	return block, block.RetryAfter > 0
+}
+
+// Fail records a failed login to email from ip and returns the block it
+// causes, if any.
+func (g *LoginGuard) Fail(ctx context.Context, email, ip string) (LoginBlock, bool) {
+	var block LoginBlock
+	for _, s := range g.subjects(email, ip) {
+		f, err := g.store.AddFailure(ctx, s.key, s.policy.Window)
+		if err != nil {
+			g.logger.Warn("login guard store unavailable", "error", err)
+			continue
+		}
+		if b, ok := g.block(s, f); ok && b.RetryAfter > block.RetryAfter {
+			block = b
+		}
+	}
+	return block, block.RetryAfter > 0
+}
+
+// Succeed forgets the failures of the account. Failures of the IP are kept,
+// so an attacker cannot clear them by logging in to an account of their own.
+func (g *LoginGuard) Succeed(ctx context.Context, email string) {
+	if err := g.Unlock(ctx, email); err != nil {
+		g.logger.Warn("login guard store unavailable", "error", err)
+	}
+}
+
+// Unlock forgets the failures of the account with email.
+func (g *LoginGuard) Unlock(ctx context.Context, email string) error {
+	return g.store.ResetFailures(ctx, accountKey(email))
+}
+
+type loginSubject struct {
+	scope  string
+	key    string
+	policy LockoutPolicy
+}
+
+func (g *LoginGuard) subjects(email, ip string) []loginSubject {
+	return []loginSubject{
+		{ScopeAccount, accountKey(email), g.account},
+		{ScopeIP, "login:ip:" + ip, g.ip},
+	}
+}
+
+func (g *LoginGuard) block(s loginSubject, f Failures) (LoginBlock, bool) {
+	wait, locked := s.policy.wait(f.Count)
+	retryAfter := f.Last.Add(wait).Sub(g.now())
+	if retryAfter <= 0 {
+		return LoginBlock{}, false
+	}
+	return LoginBlock{Scope: s.scope, RetryAfter: retryAfter, Locked: locked, Failures: f.Count}, true
+}
+
+// accountKey identifies an account by a hash of its normalized email, so
+// the store holds no addresses.
+func accountKey(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return "login:account:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestLockoutPolicyWait(t *testing.T) {
+	p := LockoutPolicy{FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: 5 * time.Second, LockAfter: 7, LockDuration: time.Minute, Window: time.Hour}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	want := []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, time.Minute, time.Minute}
+	for failures, w := range want {
+		got, locked := p.wait(failures)
+		if got != w || locked != (failures >= 7) {
+			t.Fatalf("%d failures: got %s locked=%v, want %s", failures, got, locked, w)
+		}
+	}
+
+	bad := p
+	bad.Window = 30 * time.Second
+	if bad.Validate() == nil {
+		t.Fatal("expected a window shorter than the lockout to be rejected")
+	}
+}
+
+func TestLoginGuard(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	store := NewMemoryStoreWithClock(clock)
+	account := LockoutPolicy{FreeAttempts: 1, BaseDelay: time.Second, MaxDelay: 4 * time.Second, LockAfter: 4, LockDuration: time.Minute, Window: time.Hour}
+	ip := LockoutPolicy{FreeAttempts: 4, BaseDelay: time.Second, MaxDelay: time.Second, LockAfter: 6, LockDuration: time.Minute, Window: time.Hour}
+	g := NewLoginGuardWithClock(store, account, ip, nil, clock)
+	ctx := context.Background()
+
+	if _, blocked := g.Fail(ctx, "John@Example.com", "203.0.113.7"); blocked {
+		t.Fatal("the first failure must be free")
+	}
+	block, blocked := g.Fail(ctx, "john@example.com ", "203.0.113.7")
+	if !blocked || block.Scope != ScopeAccount || block.Locked || block.RetryAfter != time.Second {
+		t.Fatalf("expected a 1s account delay for the normalized email, got %+v", block)
+	}
+	if block, blocked := g.Check(ctx, "john@example.com", "198.51.100.2"); !blocked || block.RetryAfter != time.Second {
+		t.Fatalf("the account delay must apply from any IP, got %+v", block)
+	}
+	if _, blocked := g.Check(ctx, "jane@example.com", "203.0.113.7"); blocked {
+		t.Fatal("other accounts must not be delayed yet")
+	}
+
+	now = now.Add(time.Second)
+	if _, blocked := g.Check(ctx, "john@example.com", "203.0.113.7"); blocked {
+		t.Fatal("expected the delay to be over")
+	}
+	g.Fail(ctx, "john@example.com", "203.0.113.7")
+	block, blocked = g.Fail(ctx, "john@example.com", "203.0.113.7")
+	if !blocked || !block.Locked || block.Failures != 4 || block.RetryAfter != time.Minute {
+		t.Fatalf("expected the account to lock after 4 failures, got %+v", block)
+	}
+
+	// Failures from one IP against other accounts add up.
+	g.Fail(ctx, "jane@example.com", "203.0.113.7")
+	block, blocked = g.Check(ctx, "nobody@example.com", "203.0.113.7")
+	if !blocked || block.Scope != ScopeIP || block.Locked {
+		t.Fatalf("expected the IP to be delayed, got %+v", block)
+	}
+
+	if err := g.Unlock(ctx, "JOHN@example.com"); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if block, blocked := g.Check(ctx, "john@example.com", "198.51.100.2"); blocked {
+		t.Fatalf("expected the account to be unlocked, got %+v", block)
+	}
+	g.Succeed(ctx, "jane@example.com")
+	if _, blocked := g.Check(ctx, "jane@example.com", "203.0.113.7"); !blocked {
+		t.Fatal("a successful login must not clear the IP's failures")
+	}
+
+	now = now.Add(time.Hour)
+	if _, blocked := g.Check(ctx, "john@example.com", "203.0.113.7"); blocked {
+		t.Fatal("failures must be forgotten after the window")
+	}
+}
+
+func TestRedisStoreFailures(t *testing.T) {
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	store := NewRedisStore(client)
+	ctx := context.Background()
+
+	if f, err := store.Failures(ctx, "k"); err != nil || f.Count != 0 {
+		t.Fatalf("expected no failures, got %+v (%v)", f, err)
+	}
+	for i := 1; i <= 3; i++ {
+		f, err := store.AddFailure(ctx, "k", time.Minute)
+		if err != nil || f.Count != i || f.Last.IsZero() {
+			t.Fatalf("failure %d: got %+v (%v)", i, f, err)
+		}
+	}
+	f, err := store.Failures(ctx, "k")
+	if err != nil || f.Count != 3 {
+		t.Fatalf("expected 3 failures, got %+v (%v)", f, err)
+	}
+
+	srv.FastForward(time.Minute)
+	if f, err := store.Failures(ctx, "k"); err != nil || f.Count != 0 {
+		t.Fatalf("expected failures to expire, got %+v (%v)", f, err)
+	}
+	store.AddFailure(ctx, "k", time.Minute)
+	if err := store.ResetFailures(ctx, "k"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if f, _ := store.Failures(ctx, "k"); f.Count != 0 {
+		t.Fatalf("expected failures to be reset, got %+v", f)
+	}
+}
@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+const (
+	memoryShards  = 32
+	sweepInterval = time.Minute
+)
+
+// MemoryStore keeps limiter state in process. It is sharded so that requests
+// for different keys rarely contend, and each shard drops expired keys at most
+// once per sweep interval instead of on every request.
+type MemoryStore struct {
+	shards [memoryShards]memoryShard
+	now    func() time.Time
+}
+
+type memoryShard struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	failures  map[string]memoryFailures
+	lastSweep time.Time
+}
+
+type memoryFailures struct {
+	Failures
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithClock(time.Now)
+}
+
+// NewMemoryStoreWithClock builds a MemoryStore that reads the time from now,
+// so that tests can move it instead of sleeping.
+func NewMemoryStoreWithClock(now func() time.Time) *MemoryStore {
+	s := &MemoryStore{now: now}
+	for i := range s.shards {
+		s.shards[i].tats = make(map[string]time.Time)
+		s.shards[i].failures = make(map[string]memoryFailures)
+	}
+	return s
+}
+
+func (s *MemoryStore) Allow(ctx context.Context, key string, p Policy) (Decision, error) {
+	sh := s.shard(key)
+	now := s.now()
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.sweep(now)
+
+	d, tat := gcra(now, sh.tats[key], p)
+	sh.tats[key] = tat
+	return d, nil
+}
+
+func (s *MemoryStore) Failures(ctx context.Context, key string) (Failures, error) {
+	sh := s.shard(key)
+	now := s.now()
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	f, ok := sh.failures[key]
+	if !ok || !f.expires.After(now) {
+		return Failures{}, nil
+	}
+	return f.Failures, nil
+}
+
+func (s *MemoryStore) AddFailure(ctx context.Context, key string, window time.Duration) (Failures, error) {
+	sh := s.shard(key)
+	now := s.now()
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.sweep(now)
+
+	f := sh.failures[key]
+	if !f.expires.After(now) {
+		f = memoryFailures{}
+	}
+	f.Count++
+	f.Last = now
+	f.expires = now.Add(window)
+	sh.failures[key] = f
+	return f.Failures, nil
+}
+
+func (s *MemoryStore) ResetFailures(ctx context.Context, key string) error {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	delete(sh.failures, key)
+	return nil
+}
+
+// sweep drops expired keys at most once per sweep interval. The caller
+// holds sh.mu.
+func (sh *memoryShard) sweep(now time.Time) {
+	if now.Sub(sh.lastSweep) <= sweepInterval {
+		return
+	}
+	for k, tat := range sh.tats {
+		if tat.Before(now) {
+			delete(sh.tats, k)
+		}
+	}
+	for k, f := range sh.failures {
+		if !f.expires.After(now) {
+			delete(sh.failures, k)
+		}
+	}
+	sh.lastSweep = now
+}
+
+func (s *MemoryStore) shard(key string) *memoryShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return &s.shards[h.Sum32()%memoryShards]
+}
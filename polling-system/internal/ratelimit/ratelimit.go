@@ -0,0 +1,217 @@
+// Package ratelimit implements per-route request limits keyed by user or
+// client IP. Limits are enforced with GCRA (a token bucket expressed as a
+// single "theoretical arrival time" per key), which lets a shared Store hold
+// them for every replica with one value per key.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	KeyUser = "user"
+	KeyIP   = "ip"
+)
+
+// Policy allows Requests per Period on average with bursts of up to Burst.
+type Policy struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+	Key      string
+}
+
+func (p Policy) interval() time.Duration {
+	return p.Period / time.Duration(p.Requests)
+}
+
+func (p Policy) validate() error {
+	if p.Requests <= 0 || p.Period <= 0 {
+		return fmt.Errorf("rate must be positive")
+	}
+	if p.Burst <= 0 {
+		return fmt.Errorf("burst must be positive")
+	}
+	if p.Key != KeyUser && p.Key != KeyIP {
+		return fmt.Errorf("key must be %q or %q", KeyUser, KeyIP)
+	}
+	return nil
+}
+
+// Decision is the outcome of a single request against a policy.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration
+	RetryAfter time.Duration
+}
+
+// Store keeps the limiter state. Implementations must be safe for concurrent
+// use; shared stores make limits hold across replicas.
+type Store interface {
+	Allow(ctx context.Context, key string, p Policy) (Decision, error)
+}
+
+// DefaultPolicies limits voting to 10 requests per minute per user with a
+// burst of 3, and resending verification emails to 5 per hour per user. The
+// unauthenticated account routes are guarded per client IP: logins, password
+// reset requests, and the routes that take a mailed token.
+func DefaultPolicies() map[string]Policy {
+	return map[string]Policy{
+		"vote":           {Requests: 10, Period: time.Minute, Burst: 3, Key: KeyUser},
+		"login":          {Requests: 10, Period: time.Minute, Burst: 5, Key: KeyIP},
+		"password_reset": {Requests: 5, Period: time.Hour, Burst: 3, Key: KeyIP},
+		"verify_email":   {Requests: 5, Period: time.Hour, Burst: 3, Key: KeyUser},
+		"account_token":  {Requests: 10, Period: time.Minute, Burst: 5, Key: KeyIP},
+	}
+}
+
+// ParsePolicies reads route policies in the form
+//
+//	vote=10/1m,burst=3,key=user;login=5/1m,key=ip
+//
+// Burst defaults to 1 and key defaults to user.
+func ParsePolicies(spec string) (map[string]Policy, error) {
+	policies := make(map[string]Policy)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fields := strings.Split(entry, ",")
+		name, rateSpec, ok := strings.Cut(strings.TrimSpace(fields[0]), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("rate limit policy %q: expected name=requests/period", entry)
+		}
+		reqs, period, ok := strings.Cut(rateSpec, "/")
+		if !ok {
+			return nil, fmt.Errorf("rate limit policy %q: expected requests/period", name)
+		}
+
+		p := Policy{Burst: 1, Key: KeyUser}
+		var err error
+		if p.Requests, err = strconv.Atoi(reqs); err != nil {
+			return nil, fmt.Errorf("rate limit policy %q: invalid requests: %w", name, err)
+		}
+		if p.Period, err = time.ParseDuration(period); err != nil {
+			return nil, fmt.Errorf("rate limit policy %q: invalid period: %w", name, err)
+		}
+
+		for _, opt := range fields[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			switch k {
+			case "burst":
+				if p.Burst, err = strconv.Atoi(v); err != nil {
+					return nil, fmt.Errorf("rate limit policy %q: invalid burst: %w", name, err)
+				}
+			case "key":
+				p.Key = v
+			default:
+				return nil, fmt.Errorf("rate limit policy %q: unknown option %q", name, k)
+			}
+		}
+
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("rate limit policy %q: %w", name, err)
+		}
+		policies[name] = p
+	}
+	return policies, nil
+}
+
+// Limiter applies named route policies against a Store. Policies and trusted
+// proxies can be replaced at runtime with Update.
+type Limiter struct {
+	store  Store
+	state  atomic.Pointer[limiterState]
+	logger *slog.Logger
+}
+
+type limiterState struct {
+	policies map[string]Policy
+	ips      *IPResolver
+}
+
+func NewLimiter(store Store, policies map[string]Policy, ips *IPResolver, logger *slog.Logger) *Limiter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	l := &Limiter{store: store, logger: logger}
+	l.Update(policies, ips)
+	return l
+}
+
+// Update atomically swaps the route policies and trusted proxies. Limiter
+// state already held by the store is kept, so lowering a limit applies to
+// clients mid-window.
+func (l *Limiter) Update(policies map[string]Policy, ips *IPResolver) {
+	if ips == nil {
+		ips = &IPResolver{}
+	}
+	l.state.Store(&limiterState{policies: policies, ips: ips})
+}
+
+// ClientIP resolves the client address of r honouring trusted proxies.
+func (l *Limiter) ClientIP(r *http.Request) string {
+	return l.state.Load().ips.ClientIP(r)
+}
+
+// Policy returns the policy configured for route, if any.
+func (l *Limiter) Policy(route string) (Policy, bool) {
+	p, ok := l.state.Load().policies[route]
+	return p, ok
+}
+
+// Allow checks subject against the route policy. Routes without a policy are
+// always allowed. If the store fails the request is let through so that an
+// outage of the shared backend does not take the API down with it.
+func (l *Limiter) Allow(ctx context.Context, route, subject string) (Decision, bool) {
+	p, ok := l.Policy(route)
+	if !ok {
+		return Decision{Allowed: true}, false
+	}
+
+	d, err := l.store.Allow(ctx, route+":"+subject, p)
+	if err != nil {
+		l.logger.Warn("rate limit store unavailable", "route", route, "error", err)
+		return Decision{Allowed: true, Limit: p.Burst, Remaining: p.Burst}, true
+	}
+	return d, true
+}
+
+// gcra evaluates one request given the stored theoretical arrival time and
+// returns the decision together with the new arrival time to store.
+func gcra(now, tat time.Time, p Policy) (Decision, time.Time) {
+	interval := p.interval()
+	if tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(interval)
+	allowAt := newTat.Add(-interval * time.Duration(p.Burst))
+
+	if now.Before(allowAt) {
+		return Decision{
+			Allowed:    false,
+			Limit:      p.Burst,
+			Remaining:  0,
+			ResetAfter: tat.Sub(now),
+			RetryAfter: allowAt.Sub(now),
+		}, tat
+	}
+
+	return Decision{
+		Allowed:    true,
+		Limit:      p.Burst,
+		Remaining:  int(now.Sub(allowAt) / interval),
+		ResetAfter: newTat.Sub(now),
+	}, newTat
+}
@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestParsePolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string]Policy
+		wantErr bool
+	}{
+		{name: "empty", spec: "", want: map[string]Policy{}},
+		{
+			name: "defaults",
+			spec: "vote=10/1m",
+			want: map[string]Policy{"vote": {Requests: 10, Period: time.Minute, Burst: 1, Key: KeyUser}},
+		},
+		{
+			name: "several routes",
+			spec: "vote=10/1m,burst=3,key=user; login=5/30s,burst=5,key=ip",
+			want: map[string]Policy{
+				"vote":  {Requests: 10, Period: time.Minute, Burst: 3, Key: KeyUser},
+				"login": {Requests: 5, Period: 30 * time.Second, Burst: 5, Key: KeyIP},
+			},
+		},
+		{name: "missing rate", spec: "vote", wantErr: true},
+		{name: "bad period", spec: "vote=10/minute", wantErr: true},
+		{name: "zero requests", spec: "vote=0/1m", wantErr: true},
+		{name: "unknown key", spec: "vote=10/1m,key=session", wantErr: true},
+		{name: "unknown option", spec: "vote=10/1m,window=1m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePolicies(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for %q", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d policies, want %d", len(got), len(tt.want))
+			}
+			for name, p := range tt.want {
+				if got[name] != p {
+					t.Fatalf("policy %q = %+v, want %+v", name, got[name], p)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreBurstAndRefill(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	p := Policy{Requests: 10, Period: time.Minute, Burst: 3, Key: KeyUser}
+	ctx := context.Background()
+
+	for i, wantRemaining := range []int{2, 1, 0} {
+		d, _ := store.Allow(ctx, "u1", p)
+		if !d.Allowed || d.Remaining != wantRemaining {
+			t.Fatalf("request %d: got %+v, want allowed with %d remaining", i+1, d, wantRemaining)
+		}
+	}
+
+	d, _ := store.Allow(ctx, "u1", p)
+	if d.Allowed || d.RetryAfter != 6*time.Second {
+		t.Fatalf("expected denial with 6s retry, got %+v", d)
+	}
+	if d, _ := store.Allow(ctx, "u2", p); !d.Allowed {
+		t.Fatalf("other keys must not be limited")
+	}
+
+	now = now.Add(6 * time.Second)
+	if d, _ := store.Allow(ctx, "u1", p); !d.Allowed {
+		t.Fatalf("expected a token after refill, got %+v", d)
+	}
+}
+
+func TestRedisStoreSharedAcrossReplicas(t *testing.T) {
+	srv := miniredis.RunT(t)
+	p := Policy{Requests: 10, Period: time.Minute, Burst: 2, Key: KeyIP}
+	ctx := context.Background()
+
+	replicas := make([]*RedisStore, 2)
+	for i := range replicas {
+		client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+		t.Cleanup(func() { _ = client.Close() })
+		replicas[i] = NewRedisStore(client)
+	}
+
+	for i, store := range replicas {
+		d, err := store.Allow(ctx, "1.2.3.4", p)
+		if err != nil || !d.Allowed {
+			t.Fatalf("request %d: expected allowed, got %+v (%v)", i+1, d, err)
+		}
+	}
+
+	d, err := replicas[0].Allow(ctx, "1.2.3.4", p)
+	if err != nil {
+		t.Fatalf("allow: %v", err)
+	}
+	if d.Allowed || d.RetryAfter <= 0 || d.RetryAfter > 6*time.Second {
+		t.Fatalf("expected the shared burst to be exhausted, got %+v", d)
+	}
+}
+
+func TestIPResolver(t *testing.T) {
+	resolver, err := NewIPResolver([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("new resolver: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		want       string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "untrusted peer ignores header", remoteAddr: "203.0.113.7:5000", xff: "1.1.1.1", want: "203.0.113.7"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.5:5000", xff: "198.51.100.2", want: "198.51.100.2"},
+		{name: "spoofed leftmost entry", remoteAddr: "10.0.0.5:5000", xff: "1.1.1.1, 198.51.100.2", want: "198.51.100.2"},
+		{name: "chain of proxies", remoteAddr: "192.168.1.1:5000", xff: "198.51.100.2, 10.1.2.3", want: "198.51.100.2"},
+		{name: "only proxies", remoteAddr: "10.0.0.5:5000", xff: "10.1.2.3", want: "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if got := resolver.ClientIP(req); got != tt.want {
+				t.Fatalf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewIPResolver([]string{"not-a-cidr"}); err == nil {
+		t.Fatalf("expected invalid proxy error")
+	}
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript runs GCRA atomically on the server, using the server clock so
+// that replicas with skewed clocks agree on the limit.
+var gcraScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local new_tat = tat + interval
+local allow_at = new_tat - interval * burst
+
+if now < allow_at then
+	return {0, 0, tat - now, allow_at - now}
+end
+
+redis.call("SET", KEYS[1], string.format("%.0f", new_tat), "PX", math.ceil((new_tat - now) / 1000))
+return {1, math.floor((now - allow_at) / interval), new_tat - now, 0}
+`)
+
+// failureScript counts a failure and stamps it with the server clock.
+var failureScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local count = redis.call("HINCRBY", KEYS[1], "count", 1)
+redis.call("HSET", KEYS[1], "last", string.format("%.0f", now))
+redis.call("PEXPIRE", KEYS[1], ARGV[1])
+return {count, now}
+`)
+
+// RedisStore keeps limiter state in a server speaking the Redis protocol so
+// that limits hold across replicas.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client, prefix: "polling:ratelimit:"}
+}
+
+func (s *RedisStore) Allow(ctx context.Context, key string, p Policy) (Decision, error) {
+	res, err := gcraScript.Run(ctx, s.client, []string{s.prefix + key},
+		p.interval().Microseconds(), p.Burst).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+
+	return Decision{
+		Allowed:    res[0] == 1,
+		Limit:      p.Burst,
+		Remaining:  int(res[1]),
+		ResetAfter: time.Duration(res[2]) * time.Microsecond,
+		RetryAfter: time.Duration(res[3]) * time.Microsecond,
+	}, nil
+}
+
+func (s *RedisStore) Failures(ctx context.Context, key string) (Failures, error) {
+	vals, err := s.client.HMGet(ctx, s.prefix+key, "count", "last").Result()
+	if err != nil {
+		return Failures{}, err
+	}
+	count, _ := vals[0].(string)
+	last, _ := vals[1].(string)
+	if count == "" || last == "" {
+		return Failures{}, nil
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return Failures{}, err
+	}
+	us, err := strconv.ParseInt(last, 10, 64)
+	if err != nil {
+		return Failures{}, err
+	}
+	return Failures{Count: n, Last: time.UnixMicro(us)}, nil
+}
+
+func (s *RedisStore) AddFailure(ctx context.Context, key string, window time.Duration) (Failures, error) {
+	res, err := failureScript.Run(ctx, s.client, []string{s.prefix + key}, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Failures{}, err
+	}
+	return Failures{Count: int(res[0]), Last: time.UnixMicro(res[1])}, nil
+}
+
+func (s *RedisStore) ResetFailures(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
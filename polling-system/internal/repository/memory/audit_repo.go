@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"polling-system/internal/domain/audit"
+)
+
+type AuditRepo struct {
+	s *Store
+}
+
+func NewAuditRepo(s *Store) *AuditRepo {
+	return &AuditRepo{s: s}
+}
+
+func (r *AuditRepo) Create(ctx context.Context, e *audit.Event) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.nextAuditID++
+	e.ID = r.s.nextAuditID
+	e.CreatedAt = now()
+	r.s.auditLog[e.ID] = *e
+	return nil
+}
+
+func (r *AuditRepo) List(ctx context.Context, f audit.Filter, limit, offset int) ([]audit.Event, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var res []audit.Event
+	for _, e := range r.s.auditLog {
+		if f.Action != "" && e.Action != f.Action {
+			continue
+		}
+		if f.UserID != 0 && (e.UserID == nil || *e.UserID != f.UserID) {
+			continue
+		}
+		res = append(res, e)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID > res[j].ID })
+	if offset >= len(res) {
+		return nil, nil
+	}
+	res = res[offset:]
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
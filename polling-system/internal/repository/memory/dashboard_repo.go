@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"polling-system/internal/domain/dashboard"
+)
+
+type DashboardRepo struct {
+	s *Store
+}
+
+func NewDashboardRepo(s *Store) *DashboardRepo {
+	return &DashboardRepo{s: s}
+}
+
+func (r *DashboardRepo) Totals(ctx context.Context, today, weekStart time.Time) (dashboard.Totals, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var t dashboard.Totals
+	for _, u := range r.s.users {
+		t.Users.Total++
+		if u.IsActive {
+			t.Users.Active++
+		}
+	}
+	for _, v := range r.s.votes {
+		if !v.CreatedAt.Before(today) {
+			t.Votes.Today++
+		}
+		if !v.CreatedAt.Before(weekStart) {
+			t.Votes.ThisWeek++
+		}
+	}
+	return t, nil
+}
+
+func (r *DashboardRepo) TopPolls(ctx context.Context, limit int) ([]dashboard.PollParticipation, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	votes := make(map[int64]int64)
+	for k, n := range r.s.aggregated {
+		votes[k.pollID] += n
+	}
+	var res []dashboard.PollParticipation
+	for id, n := range votes {
+		if n == 0 {
+			continue
+		}
+		p := r.s.polls[id]
+		res = append(res, dashboard.PollParticipation{PollID: id, Title: p.Title, Status: p.Status, Votes: n})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Votes != res[j].Votes {
+			return res[i].Votes > res[j].Votes
+		}
+		return res[i].PollID < res[j].PollID
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (r *DashboardRepo) RecentRegistrations(ctx context.Context, limit int) ([]dashboard.Registration, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var res []dashboard.Registration
+	for _, u := range r.s.users {
+		res = append(res, dashboard.Registration{UserID: u.ID, Email: u.Email, Role: u.Role, CreatedAt: u.CreatedAt})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].UserID > res[j].UserID })
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
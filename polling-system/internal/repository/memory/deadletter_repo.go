@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"polling-system/internal/domain/deadletter"
+)
+
+type DeadLetterRepo struct {
+	s *Store
+}
+
+func NewDeadLetterRepo(s *Store) *DeadLetterRepo {
+	return &DeadLetterRepo{s: s}
+}
+
+func (r *DeadLetterRepo) Create(ctx context.Context, e *deadletter.Entry) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.nextDeadLetterID++
+	e.ID = r.s.nextDeadLetterID
+	e.CreatedAt = now()
+	e.LastFailedAt = e.CreatedAt
+	r.s.deadLetters[e.ID] = *e
+	return nil
+}
+
+func (r *DeadLetterRepo) GetByID(ctx context.Context, id int64) (*deadletter.Entry, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	e, ok := r.s.deadLetters[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &e, nil
+}
+
+func (r *DeadLetterRepo) List(ctx context.Context, limit, offset int) ([]deadletter.Entry, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var res []deadletter.Entry
+	for _, e := range r.s.deadLetters {
+		res = append(res, e)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	if offset >= len(res) {
+		return nil, nil
+	}
+	res = res[offset:]
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (r *DeadLetterRepo) Count(ctx context.Context) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return int64(len(r.s.deadLetters)), nil
+}
+
+func (r *DeadLetterRepo) RecordFailure(ctx context.Context, id int64, attempts int, errMsg string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	e, ok := r.s.deadLetters[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	e.Attempts += attempts
+	e.Error = errMsg
+	e.LastFailedAt = now()
+	r.s.deadLetters[id] = e
+	return nil
+}
+
+func (r *DeadLetterRepo) Delete(ctx context.Context, id int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.deadLetters[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.s.deadLetters, id)
+	return nil
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"polling-system/internal/repository/repotest"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repos {
+		s := NewStore()
+		return repotest.Repos{Tx: NewTxManager(s), Users: NewUserRepo(s), Tokens: NewTokenRepo(s), TwoFactor: NewTwoFactorRepo(s), Polls: NewPollRepo(s), Votes: NewVoteRepo(s), DeadLetters: NewDeadLetterRepo(s), Dashboard: NewDashboardRepo(s), Audit: NewAuditRepo(s)}
+	})
+}
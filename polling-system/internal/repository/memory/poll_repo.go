@@ -0,0 +1,164 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"polling-system/internal/domain/poll"
+)
+
+type PollRepo struct {
+	s *Store
+}
+
+func NewPollRepo(s *Store) *PollRepo {
+	return &PollRepo{s: s}
+}
+
+func validStatus(status string) bool {
+	return status == "draft" || status == "active" || status == "closed"
+}
+
+func (r *PollRepo) Create(ctx context.Context, p *poll.Poll, options []poll.Option) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if !validStatus(p.Status) {
+		return 0, constraintError("polls_status_check")
+	}
+	if _, ok := r.s.users[p.CreatorID]; !ok {
+		return 0, constraintError("polls_creator_id_fkey")
+	}
+	seen := make(map[string]bool, len(options))
+	for _, o := range options {
+		if seen[o.Text] {
+			return 0, poll.ErrDuplicateOption
+		}
+		seen[o.Text] = true
+	}
+
+	ts := now()
+	r.s.nextPollID++
+	p.ID = r.s.nextPollID
+	p.CreatedAt = ts
+	p.UpdatedAt = ts
+	r.s.polls[p.ID] = *p
+
+	for i := range options {
+		r.s.nextOptionID++
+		options[i].ID = r.s.nextOptionID
+		options[i].PollID = p.ID
+		options[i].Position = i
+		options[i].CreatedAt = ts
+		r.s.options[options[i].ID] = options[i]
+	}
+	return p.ID, nil
+}
+
+func (r *PollRepo) GetByID(ctx context.Context, id int64) (*poll.Poll, []poll.Option, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p, ok := r.s.polls[id]
+	if !ok {
+		return nil, nil, sql.ErrNoRows
+	}
+	var opts []poll.Option
+	for _, o := range r.s.options {
+		if o.PollID == id {
+			opts = append(opts, o)
+		}
+	}
+	sort.Slice(opts, func(i, j int) bool {
+		if opts[i].Position != opts[j].Position {
+			return opts[i].Position < opts[j].Position
+		}
+		return opts[i].ID < opts[j].ID
+	})
+	return &p, opts, nil
+}
+
+func (r *PollRepo) List(ctx context.Context, status *string) ([]poll.Poll, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var res []poll.Poll
+	for _, p := range r.s.polls {
+		if status == nil || p.Status == *status {
+			res = append(res, p)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].CreatedAt.Equal(res[j].CreatedAt) {
+			return res[i].CreatedAt.After(res[j].CreatedAt)
+		}
+		return res[i].ID > res[j].ID
+	})
+	return res, nil
+}
+
+func (r *PollRepo) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	res := make(map[string]int64)
+	for _, p := range r.s.polls {
+		res[p.Status]++
+	}
+	return res, nil
+}
+
+func (r *PollRepo) UpdateStatus(ctx context.Context, id int64, status string) error {
+	if !validStatus(status) {
+		return constraintError("polls_status_check")
+	}
+	return r.update(id, func(p *poll.Poll) { p.Status = status })
+}
+
+func (r *PollRepo) Update(ctx context.Context, id int64, input poll.UpdateInput) error {
+	if input.Title == nil && input.Description == nil && input.StartsAt == nil && input.EndsAt == nil {
+		return fmt.Errorf("no fields to update")
+	}
+	return r.update(id, func(p *poll.Poll) {
+		if input.Title != nil {
+			p.Title = *input.Title
+		}
+		if input.Description != nil {
+			d := *input.Description
+			p.Description = &d
+		}
+		if input.StartsAt != nil {
+			t := *input.StartsAt
+			p.StartsAt = &t
+		}
+		if input.EndsAt != nil {
+			t := *input.EndsAt
+			p.EndsAt = &t
+		}
+	})
+}
+
+func (r *PollRepo) update(id int64, fn func(p *poll.Poll)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	p, ok := r.s.polls[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	fn(&p)
+	p.UpdatedAt = now()
+	r.s.polls[id] = p
+	return nil
+}
+
+func (r *PollRepo) Delete(ctx context.Context, id int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.polls[id]; !ok {
+		return sql.ErrNoRows
+	}
+	r.s.deletePoll(id)
+	return nil
+}
@@ -0,0 +1,162 @@
+// Package memory is an in-memory implementation of the domain repositories.
+// It is the reference for the repository conformance suite: it enforces the
+// same unique keys, foreign keys, cascades and orderings as the SQL schema,
+// so tests built on it behave like the real backends.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"polling-system/internal/domain/audit"
+	"polling-system/internal/domain/deadletter"
+	"polling-system/internal/domain/poll"
+	"polling-system/internal/domain/user"
+)
+
+// Store holds the tables shared by the repositories of one database.
+type Store struct {
+	mu sync.Mutex
+	// txMu serializes units of work run by TxManager.
+	txMu sync.Mutex
+
+	users      map[int64]user.User
+	tokens     map[int64]user.Token
+	totp       map[int64]user.TwoFactor
+	recovery   map[int64]storedRecoveryCode
+	polls      map[int64]poll.Poll
+	options    map[int64]poll.Option
+	votes      map[int64]storedVote
+	aggregated map[optionKey]int64
+	rollups    map[rollupKey]int64
+	// deadLetters has no foreign keys and survives poll deletion.
+	deadLetters map[int64]deadletter.Entry
+	// auditLog has no foreign keys and survives user deletion.
+	auditLog map[int64]audit.Event
+
+	nextUserID   int64
+	nextTokenID  int64
+	nextCodeID   int64
+	nextPollID   int64
+	nextOptionID int64
+	nextVoteID   int64
+
+	nextDeadLetterID int64
+	nextAuditID      int64
+}
+
+type storedVote struct {
+	ID        int64
+	PollID    int64
+	OptionID  int64
+	UserID    int64
+	CreatedAt time.Time
+}
+
+type storedRecoveryCode struct {
+	UserID int64
+	Hash   string
+	UsedAt *time.Time
+}
+
+type optionKey struct {
+	pollID   int64
+	optionID int64
+}
+
+type rollupKey struct {
+	optionKey
+	bucket time.Time
+}
+
+func NewStore() *Store {
+	return &Store{
+		users:      make(map[int64]user.User),
+		tokens:     make(map[int64]user.Token),
+		totp:       make(map[int64]user.TwoFactor),
+		recovery:   make(map[int64]storedRecoveryCode),
+		polls:      make(map[int64]poll.Poll),
+		options:    make(map[int64]poll.Option),
+		votes:      make(map[int64]storedVote),
+		aggregated: make(map[optionKey]int64),
+		rollups:    make(map[rollupKey]int64),
+
+		deadLetters: make(map[int64]deadletter.Entry),
+		auditLog:    make(map[int64]audit.Event),
+	}
+}
+
+// now mirrors the database clock: UTC with microsecond precision.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// deletePoll removes a poll and cascades to its options, votes, aggregated
+// results and rollups. The caller holds s.mu.
+func (s *Store) deletePoll(id int64) {
+	delete(s.polls, id)
+	for optID, o := range s.options {
+		if o.PollID == id {
+			delete(s.options, optID)
+		}
+	}
+	for voteID, v := range s.votes {
+		if v.PollID == id {
+			delete(s.votes, voteID)
+		}
+	}
+	for k := range s.aggregated {
+		if k.pollID == id {
+			delete(s.aggregated, k)
+		}
+	}
+	for k := range s.rollups {
+		if k.pollID == id {
+			delete(s.rollups, k)
+		}
+	}
+}
+
+// deleteRecoveryCodes removes the recovery codes of a user. The caller
+// holds s.mu.
+func (s *Store) deleteRecoveryCodes(userID int64) {
+	for id, c := range s.recovery {
+		if c.UserID == userID {
+			delete(s.recovery, id)
+		}
+	}
+}
+
+func (s *Store) optionInPoll(pollID, optionID int64) bool {
+	o, ok := s.options[optionID]
+	return ok && o.PollID == pollID
+}
+
+// constraintError reports a violated constraint that has no domain error,
+// as the database drivers do.
+func constraintError(name string) error {
+	return fmt.Errorf("memory: violates constraint %q", name)
+}
+
+// TxManager runs units of work against a Store one at a time. It holds no
+// snapshot, so changes made before fn fails are kept.
+type TxManager struct {
+	s *Store
+}
+
+func NewTxManager(s *Store) *TxManager {
+	return &TxManager{s: s}
+}
+
+type txKey struct{}
+
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) == m.s {
+		return fn(ctx)
+	}
+	m.s.txMu.Lock()
+	defer m.s.txMu.Unlock()
+	return fn(context.WithValue(ctx, txKey{}, m.s))
+}
@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"polling-system/internal/domain/user"
+)
+
+type TokenRepo struct {
+	s *Store
+}
+
+func NewTokenRepo(s *Store) *TokenRepo {
+	return &TokenRepo{s: s}
+}
+
+func (r *TokenRepo) Create(ctx context.Context, t *user.Token) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.users[t.UserID]; !ok {
+		return constraintError("user_tokens_user_id_fkey")
+	}
+	if t.Purpose != user.PurposeVerifyEmail && t.Purpose != user.PurposeResetPassword {
+		return constraintError("user_tokens_purpose_check")
+	}
+	for _, existing := range r.s.tokens {
+		if existing.Hash == t.Hash {
+			return constraintError("user_tokens_token_hash_key")
+		}
+	}
+
+	r.s.nextTokenID++
+	t.ID = r.s.nextTokenID
+	t.ExpiresAt = t.ExpiresAt.UTC().Truncate(time.Microsecond)
+	t.UsedAt = nil
+	t.CreatedAt = now()
+	r.s.tokens[t.ID] = *t
+	return nil
+}
+
+func (r *TokenRepo) Consume(ctx context.Context, purpose, hash string, at time.Time) (*user.Token, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	at = at.UTC().Truncate(time.Microsecond)
+	for id, t := range r.s.tokens {
+		if t.Hash != hash || t.Purpose != purpose || t.UsedAt != nil || !t.ExpiresAt.After(at) {
+			continue
+		}
+		t.UsedAt = &at
+		r.s.tokens[id] = t
+		return &t, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *TokenRepo) DeleteUnused(ctx context.Context, userID int64, purpose string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, t := range r.s.tokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			delete(r.s.tokens, id)
+		}
+	}
+	return nil
+}
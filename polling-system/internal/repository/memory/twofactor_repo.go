@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"polling-system/internal/domain/user"
+)
+
+type TwoFactorRepo struct {
+	s *Store
+}
+
+func NewTwoFactorRepo(s *Store) *TwoFactorRepo {
+	return &TwoFactorRepo{s: s}
+}
+
+func (r *TwoFactorRepo) Get(ctx context.Context, userID int64) (*user.TwoFactor, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t, ok := r.s.totp[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &t, nil
+}
+
+func (r *TwoFactorRepo) SavePending(ctx context.Context, t *user.TwoFactor) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.users[t.UserID]; !ok {
+		return constraintError("user_totp_user_id_fkey")
+	}
+	if existing, ok := r.s.totp[t.UserID]; ok && existing.EnabledAt != nil {
+		return user.ErrTwoFactorEnabled
+	}
+	t.EnabledAt, t.LastStep, t.CreatedAt = nil, 0, now()
+	r.s.totp[t.UserID] = *t
+	return nil
+}
+
+func (r *TwoFactorRepo) Enable(ctx context.Context, userID, step int64, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t, ok := r.s.totp[userID]
+	if !ok || t.EnabledAt != nil {
+		return sql.ErrNoRows
+	}
+	at = at.UTC().Truncate(time.Microsecond)
+	t.EnabledAt, t.LastStep = &at, step
+	r.s.totp[userID] = t
+	return nil
+}
+
+func (r *TwoFactorRepo) UseStep(ctx context.Context, userID, step int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	t, ok := r.s.totp[userID]
+	if !ok || t.EnabledAt == nil || t.LastStep >= step {
+		return sql.ErrNoRows
+	}
+	t.LastStep = step
+	r.s.totp[userID] = t
+	return nil
+}
+
+func (r *TwoFactorRepo) Delete(ctx context.Context, userID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.deleteRecoveryCodes(userID)
+	if _, ok := r.s.totp[userID]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.s.totp, userID)
+	return nil
+}
+
+func (r *TwoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.users[userID]; !ok {
+		return constraintError("user_recovery_codes_user_id_fkey")
+	}
+	r.s.deleteRecoveryCodes(userID)
+	seen := make(map[string]bool, len(hashes))
+	for _, h := range hashes {
+		if seen[h] {
+			return constraintError("user_recovery_codes_user_id_code_hash_key")
+		}
+		seen[h] = true
+		r.s.nextCodeID++
+		r.s.recovery[r.s.nextCodeID] = storedRecoveryCode{UserID: userID, Hash: h}
+	}
+	return nil
+}
+
+func (r *TwoFactorRepo) UseRecoveryCode(ctx context.Context, userID int64, hash string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	at = at.UTC().Truncate(time.Microsecond)
+	for id, c := range r.s.recovery {
+		if c.UserID == userID && c.Hash == hash && c.UsedAt == nil {
+			c.UsedAt = &at
+			r.s.recovery[id] = c
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *TwoFactorRepo) CountRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	n := 0
+	for _, c := range r.s.recovery {
+		if c.UserID == userID && c.UsedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
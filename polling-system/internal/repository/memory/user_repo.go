@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"polling-system/internal/domain/user"
+)
+
+type UserRepo struct {
+	s *Store
+}
+
+func NewUserRepo(s *Store) *UserRepo {
+	return &UserRepo{s: s}
+}
+
+func (r *UserRepo) Create(ctx context.Context, u *user.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if u.Role != "admin" && u.Role != "user" {
+		return constraintError("users_role_check")
+	}
+	for _, existing := range r.s.users {
+		if existing.Email == u.Email {
+			return user.ErrEmailTaken
+		}
+	}
+
+	r.s.nextUserID++
+	u.ID = r.s.nextUserID
+	u.CreatedAt = now()
+	u.IsActive = true
+	r.s.users[u.ID] = *u
+	return nil
+}
+
+func (r *UserRepo) GetByEmail(ctx context.Context, email string) (*user.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, u := range r.s.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *UserRepo) GetByID(ctx context.Context, id int64) (*user.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	u, ok := r.s.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &u, nil
+}
+
+func (r *UserRepo) List(ctx context.Context) ([]user.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var res []user.User
+	for _, u := range r.s.users {
+		res = append(res, u)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
+
+func (r *UserRepo) UpdateRole(ctx context.Context, id int64, role string) error {
+	if role != "admin" && role != "user" {
+		return constraintError("users_role_check")
+	}
+	return r.update(id, func(u *user.User) { u.Role = role })
+}
+
+func (r *UserRepo) Deactivate(ctx context.Context, id int64) error {
+	return r.update(id, func(u *user.User) { u.IsActive = false })
+}
+
+func (r *UserRepo) SetPassword(ctx context.Context, id int64, passwordHash string) error {
+	return r.update(id, func(u *user.User) { u.PasswordHash = passwordHash })
+}
+
+func (r *UserRepo) MarkEmailVerified(ctx context.Context, id int64, at time.Time) error {
+	at = at.UTC().Truncate(time.Microsecond)
+	return r.update(id, func(u *user.User) {
+		if u.EmailVerifiedAt == nil {
+			u.EmailVerifiedAt = &at
+		}
+	})
+}
+
+func (r *UserRepo) update(id int64, fn func(u *user.User)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	u, ok := r.s.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	fn(&u)
+	r.s.users[id] = u
+	return nil
+}
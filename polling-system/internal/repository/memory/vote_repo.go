@@ -0,0 +1,232 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"polling-system/internal/domain/vote"
+)
+
+type VoteRepo struct {
+	s *Store
+}
+
+func NewVoteRepo(s *Store) *VoteRepo {
+	return &VoteRepo{s: s}
+}
+
+func (r *VoteRepo) Create(ctx context.Context, v *vote.Vote) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.polls[v.PollID]; !ok {
+		return vote.ErrPollNotFound
+	}
+	if !r.s.optionInPoll(v.PollID, v.OptionID) {
+		return vote.ErrOptionNotInPoll
+	}
+	if _, ok := r.s.users[v.UserID]; !ok {
+		return constraintError("votes_user_id_fkey")
+	}
+	for _, existing := range r.s.votes {
+		if existing.PollID == v.PollID && existing.UserID == v.UserID {
+			return vote.ErrAlreadyVoted
+		}
+	}
+
+	r.s.nextVoteID++
+	v.ID = r.s.nextVoteID
+	v.CreatedAt = now()
+	r.s.votes[v.ID] = storedVote{
+		ID:        v.ID,
+		PollID:    v.PollID,
+		OptionID:  v.OptionID,
+		UserID:    v.UserID,
+		CreatedAt: v.CreatedAt,
+	}
+	return nil
+}
+
+func (r *VoteRepo) CountByPoll(ctx context.Context, pollID int64) (map[int64]int64, int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	res := make(map[int64]int64)
+	var total int64
+	for _, v := range r.s.votes {
+		if v.PollID == pollID {
+			res[v.OptionID]++
+			total++
+		}
+	}
+	return res, total, nil
+}
+
+func (r *VoteRepo) AggregatedByPoll(ctx context.Context, pollID int64) (map[int64]int64, int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	res := make(map[int64]int64)
+	var total int64
+	for k, c := range r.s.aggregated {
+		if k.pollID == pollID {
+			res[k.optionID] = c
+			total += c
+		}
+	}
+	return res, total, nil
+}
+
+func (r *VoteRepo) IncrementAggregated(ctx context.Context, pollID, optionID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if !r.s.optionInPoll(pollID, optionID) {
+		return constraintError("aggregated_results_option_poll_fkey")
+	}
+	r.s.aggregated[optionKey{pollID: pollID, optionID: optionID}]++
+	return nil
+}
+
+func (r *VoteRepo) AddAggregated(ctx context.Context, deltas []vote.AggregateDelta) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	seen := make(map[optionKey]bool, len(deltas))
+	for _, d := range deltas {
+		k := optionKey{pollID: d.PollID, optionID: d.OptionID}
+		if seen[k] {
+			return errors.New("duplicate aggregate delta for the same option")
+		}
+		seen[k] = true
+		if !r.s.optionInPoll(d.PollID, d.OptionID) {
+			return constraintError("aggregated_results_option_poll_fkey")
+		}
+	}
+	for _, d := range deltas {
+		r.s.aggregated[optionKey{pollID: d.PollID, optionID: d.OptionID}] += d.Votes
+	}
+	return nil
+}
+
+func (r *VoteRepo) GetPollStatus(ctx context.Context, pollID int64) (string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p, ok := r.s.polls[pollID]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return p.Status, nil
+}
+
+// LockPollStatus relies on TxManager running units of work one at a time.
+func (r *VoteRepo) LockPollStatus(ctx context.Context, pollID int64) (string, error) {
+	return r.GetPollStatus(ctx, pollID)
+}
+
+func (r *VoteRepo) ListOptions(ctx context.Context, pollID int64) ([]vote.OptionInfo, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var res []vote.OptionInfo
+	for _, o := range r.s.options {
+		if o.PollID == pollID {
+			res = append(res, vote.OptionInfo{ID: o.ID, Text: o.Text, Position: o.Position})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Position != res[j].Position {
+			return res[i].Position < res[j].Position
+		}
+		return res[i].ID < res[j].ID
+	})
+	return res, nil
+}
+
+func (r *VoteRepo) VotesTimeSeries(ctx context.Context, pollID int64, bucket string) ([]vote.BucketCount, error) {
+	if !vote.ValidBucket(bucket) {
+		return nil, vote.ErrInvalidBucket
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	counts := make(map[rollupKey]int64)
+	for _, v := range r.s.votes {
+		if v.PollID == pollID {
+			counts[rollupKey{optionKey{pollID, v.OptionID}, truncate(v.CreatedAt, bucket)}]++
+		}
+	}
+	return sortedSeries(counts), nil
+}
+
+func (r *VoteRepo) RollupTimeSeries(ctx context.Context, pollID int64, bucket string) ([]vote.BucketCount, error) {
+	if !vote.ValidBucket(bucket) {
+		return nil, vote.ErrInvalidBucket
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	counts := make(map[rollupKey]int64)
+	for k, c := range r.s.rollups {
+		if k.pollID == pollID {
+			counts[rollupKey{k.optionKey, truncate(k.bucket, bucket)}] += c
+		}
+	}
+	return sortedSeries(counts), nil
+}
+
+func (r *VoteRepo) CountEligibleVoters(ctx context.Context) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var n int64
+	for _, u := range r.s.users {
+		if u.IsActive {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// RefreshRollups recomputes every per-minute rollup from the votes.
+func (r *VoteRepo) RefreshRollups(ctx context.Context) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	rollups := make(map[rollupKey]int64)
+	for _, v := range r.s.votes {
+		rollups[rollupKey{optionKey{v.PollID, v.OptionID}, truncate(v.CreatedAt, vote.BucketMinute)}]++
+	}
+	r.s.rollups = rollups
+	return nil
+}
+
+func truncate(t time.Time, bucket string) time.Time {
+	t = t.UTC()
+	switch bucket {
+	case vote.BucketMinute:
+		return t.Truncate(time.Minute)
+	case vote.BucketHour:
+		return t.Truncate(time.Hour)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func sortedSeries(counts map[rollupKey]int64) []vote.BucketCount {
+	res := make([]vote.BucketCount, 0, len(counts))
+	for k, c := range counts {
+		res = append(res, vote.BucketCount{Bucket: k.bucket, OptionID: k.optionID, Votes: c})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].Bucket.Equal(res[j].Bucket) {
+			return res[i].Bucket.Before(res[j].Bucket)
+		}
+		return res[i].OptionID < res[j].OptionID
+	})
+	return res
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"polling-system/internal/domain/audit"
+	"polling-system/internal/platform/database"
+)
+
+type AuditRepo struct {
+	db *sql.DB
+}
+
+func NewAuditRepo(db *sql.DB) *AuditRepo {
+	return &AuditRepo{db: db}
+}
+
+func (r *AuditRepo) Create(ctx context.Context, e *audit.Event) error {
+	query := `
+        INSERT INTO audit_log (action, user_id, actor_id, email, ip, request_id, detail)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, created_at
+    `
+	return database.Conn(ctx, r.db).QueryRowContext(ctx, query, e.Action, e.UserID, e.ActorID, e.Email, e.IP, e.RequestID, e.Detail).
+		Scan(&e.ID, &e.CreatedAt)
+}
+
+func (r *AuditRepo) List(ctx context.Context, f audit.Filter, limit, offset int) ([]audit.Event, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, `
+        SELECT id, action, user_id, actor_id, email, ip, request_id, detail, created_at
+        FROM audit_log
+        WHERE ($1 = '' OR action = $1) AND ($2 = 0 OR user_id = $2)
+        ORDER BY id DESC
+        LIMIT $3 OFFSET $4
+    `, f.Action, f.UserID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []audit.Event
+	for rows.Next() {
+		var e audit.Event
+		if err := rows.Scan(&e.ID, &e.Action, &e.UserID, &e.ActorID, &e.Email, &e.IP, &e.RequestID, &e.Detail, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
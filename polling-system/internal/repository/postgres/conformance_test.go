@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"polling-system/internal/config"
+	"polling-system/internal/db/migrations"
+	"polling-system/internal/platform/database"
+	"polling-system/internal/repository/repotest"
+)
+
+// TestConformance runs against the database in POLLING_TEST_POSTGRES_DSN.
+// Every table is truncated between subtests, so never point it at real data.
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("POLLING_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POLLING_TEST_POSTGRES_DSN not set")
+	}
+	db, err := database.NewPostgres(config.DBConfig{DSN: dsn, MaxOpenConns: 16})
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrations.New(db, database.Postgres, nil)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	repotest.Run(t, func(t *testing.T) repotest.Repos {
+		_, err := db.Exec(`TRUNCATE users, polls, options, votes, aggregated_results, vote_rollups, dead_letters, audit_log, user_totp, user_recovery_codes RESTART IDENTITY CASCADE`)
+		if err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+		return repotest.Repos{Tx: database.NewTxManager(db), Users: NewUserRepo(db), Tokens: NewTokenRepo(db), TwoFactor: NewTwoFactorRepo(db), Polls: NewPollRepo(db), Votes: NewVoteRepo(db), DeadLetters: NewDeadLetterRepo(db), Dashboard: NewDashboardRepo(db), Audit: NewAuditRepo(db)}
+	})
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"polling-system/internal/domain/dashboard"
+	"polling-system/internal/platform/database"
+)
+
+type DashboardRepo struct {
+	db *sql.DB
+}
+
+func NewDashboardRepo(db *sql.DB) *DashboardRepo {
+	return &DashboardRepo{db: db}
+}
+
+func (r *DashboardRepo) Totals(ctx context.Context, today, weekStart time.Time) (dashboard.Totals, error) {
+	var t dashboard.Totals
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `
+        SELECT
+            (SELECT COUNT(*) FROM users),
+            (SELECT COUNT(*) FROM users WHERE is_active),
+            (SELECT COUNT(*) FROM votes WHERE created_at >= $1),
+            (SELECT COUNT(*) FROM votes WHERE created_at >= $2)
+    `, today, weekStart).Scan(&t.Users.Total, &t.Users.Active, &t.Votes.Today, &t.Votes.ThisWeek)
+	return t, err
+}
+
+func (r *DashboardRepo) TopPolls(ctx context.Context, limit int) ([]dashboard.PollParticipation, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, `
+        SELECT p.id, p.title, p.status, SUM(a.votes_count) AS votes
+        FROM aggregated_results a
+        JOIN polls p ON p.id = a.poll_id
+        GROUP BY p.id, p.title, p.status
+        HAVING SUM(a.votes_count) > 0
+        ORDER BY votes DESC, p.id
+        LIMIT $1
+    `, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []dashboard.PollParticipation
+	for rows.Next() {
+		var p dashboard.PollParticipation
+		if err := rows.Scan(&p.PollID, &p.Title, &p.Status, &p.Votes); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
+func (r *DashboardRepo) RecentRegistrations(ctx context.Context, limit int) ([]dashboard.Registration, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, `
+        SELECT id, email, role, created_at
+        FROM users
+        ORDER BY id DESC
+        LIMIT $1
+    `, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []dashboard.Registration
+	for rows.Next() {
+		var u dashboard.Registration
+		if err := rows.Scan(&u.UserID, &u.Email, &u.Role, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, u)
+	}
+	return res, rows.Err()
+}
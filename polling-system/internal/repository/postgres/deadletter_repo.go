@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"polling-system/internal/domain/deadletter"
+	"polling-system/internal/platform/database"
+)
+
+type DeadLetterRepo struct {
+	db *sql.DB
+}
+
+func NewDeadLetterRepo(db *sql.DB) *DeadLetterRepo {
+	return &DeadLetterRepo{db: db}
+}
+
+const deadLetterColumns = `id, poll_id, option_id, user_id, error, attempts, created_at, last_failed_at`
+
+func scanDeadLetter(row interface{ Scan(...any) error }, e *deadletter.Entry) error {
+	return row.Scan(&e.ID, &e.PollID, &e.OptionID, &e.UserID, &e.Error, &e.Attempts, &e.CreatedAt, &e.LastFailedAt)
+}
+
+func (r *DeadLetterRepo) Create(ctx context.Context, e *deadletter.Entry) error {
+	query := `
+        INSERT INTO dead_letters (poll_id, option_id, user_id, error, attempts)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at, last_failed_at
+    `
+	return database.Conn(ctx, r.db).QueryRowContext(ctx, query, e.PollID, e.OptionID, e.UserID, e.Error, e.Attempts).
+		Scan(&e.ID, &e.CreatedAt, &e.LastFailedAt)
+}
+
+func (r *DeadLetterRepo) GetByID(ctx context.Context, id int64) (*deadletter.Entry, error) {
+	e := &deadletter.Entry{}
+	row := database.Conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+deadLetterColumns+` FROM dead_letters WHERE id = $1`, id)
+	if err := scanDeadLetter(row, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (r *DeadLetterRepo) List(ctx context.Context, limit, offset int) ([]deadletter.Entry, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, `
+        SELECT `+deadLetterColumns+`
+        FROM dead_letters
+        ORDER BY id
+        LIMIT $1 OFFSET $2
+    `, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []deadletter.Entry
+	for rows.Next() {
+		var e deadletter.Entry
+		if err := scanDeadLetter(rows, &e); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
+
+func (r *DeadLetterRepo) Count(ctx context.Context) (int64, error) {
+	var n int64
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM dead_letters`).Scan(&n)
+	return n, err
+}
+
+func (r *DeadLetterRepo) RecordFailure(ctx context.Context, id int64, attempts int, errMsg string) error {
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `
+        UPDATE dead_letters
+        SET attempts = attempts + $1, error = $2, last_failed_at = now()
+        WHERE id = $3
+    `, attempts, errMsg, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *DeadLetterRepo) Delete(ctx context.Context, id int64) error {
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `DELETE FROM dead_letters WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
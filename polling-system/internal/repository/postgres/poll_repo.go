@@ -3,10 +3,14 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/jackc/pgx/v5/pgconn"
+
 	"polling-system/internal/domain/poll"
+	"polling-system/internal/platform/database"
 )
 
 type PollRepo struct {
@@ -18,45 +22,48 @@ func NewPollRepo(db *sql.DB) *PollRepo {
 }
 
 func (r *PollRepo) Create(ctx context.Context, p *poll.Poll, options []poll.Option) (int64, error) {
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		return 0, err
-	}
-	defer tx.Rollback()
-
-	queryPoll := `
-        INSERT INTO polls (title, description, status, starts_at, ends_at, creator_id)
-        VALUES ($1, $2, $3, $4, $5, $6)
-        RETURNING id, created_at, updated_at
-    `
-
-	err = tx.QueryRowContext(ctx, queryPoll,
-		p.Title,
-		p.Description,
-		p.Status,
-		p.StartsAt,
-		p.EndsAt,
-		p.CreatorID,
-	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
-	if err != nil {
-		return 0, err
-	}
-
-	queryOpt := `
-        INSERT INTO options (poll_id, text)
-        VALUES ($1, $2)
-        RETURNING id, created_at
-    `
-
-	for i := range options {
-		options[i].PollID = p.ID
-		if err := tx.QueryRowContext(ctx, queryOpt, options[i].PollID, options[i].Text).
-			Scan(&options[i].ID, &options[i].CreatedAt); err != nil {
-			return 0, err
+	err := database.InTx(ctx, r.db, func(ctx context.Context) error {
+		tx := database.Conn(ctx, r.db)
+
+		queryPoll := `
+            INSERT INTO polls (title, description, status, starts_at, ends_at, creator_id)
+            VALUES ($1, $2, $3, $4, $5, $6)
+            RETURNING id, created_at, updated_at
+        `
+
+		err := tx.QueryRowContext(ctx, queryPoll,
+			p.Title,
+			p.Description,
+			p.Status,
+			p.StartsAt,
+			p.EndsAt,
+			p.CreatorID,
+		).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			return err
 		}
-	}
 
-	if err := tx.Commit(); err != nil {
+		queryOpt := `
+            INSERT INTO options (poll_id, text, position)
+            VALUES ($1, $2, $3)
+            RETURNING id, created_at
+        `
+
+		for i := range options {
+			options[i].PollID = p.ID
+			options[i].Position = i
+			if err := tx.QueryRowContext(ctx, queryOpt, options[i].PollID, options[i].Text, options[i].Position).
+				Scan(&options[i].ID, &options[i].CreatedAt); err != nil {
+				var pgErr *pgconn.PgError
+				if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_options_poll_text" {
+					return poll.ErrDuplicateOption
+				}
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return 0, err
 	}
 
@@ -65,7 +72,7 @@ func (r *PollRepo) Create(ctx context.Context, p *poll.Poll, options []poll.Opti
 
 func (r *PollRepo) GetByID(ctx context.Context, id int64) (*poll.Poll, []poll.Option, error) {
 	p := &poll.Poll{}
-	err := r.db.QueryRowContext(ctx, `
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `
         SELECT id, title, description, status, starts_at, ends_at, creator_id, created_at, updated_at
         FROM polls WHERE id = $1
     `, id).Scan(
@@ -76,9 +83,10 @@ func (r *PollRepo) GetByID(ctx context.Context, id int64) (*poll.Poll, []poll.Op
 		return nil, nil, err
 	}
 
-	rows, err := r.db.QueryContext(ctx, `
-        SELECT id, poll_id, text, created_at
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, `
+        SELECT id, poll_id, text, position, created_at
         FROM options WHERE poll_id = $1
+        ORDER BY position, id
     `, id)
 	if err != nil {
 		return nil, nil, err
@@ -88,7 +96,7 @@ func (r *PollRepo) GetByID(ctx context.Context, id int64) (*poll.Poll, []poll.Op
 	var opts []poll.Option
 	for rows.Next() {
 		var o poll.Option
-		if err := rows.Scan(&o.ID, &o.PollID, &o.Text, &o.CreatedAt); err != nil {
+		if err := rows.Scan(&o.ID, &o.PollID, &o.Text, &o.Position, &o.CreatedAt); err != nil {
 			return nil, nil, err
 		}
 		opts = append(opts, o)
@@ -106,11 +114,11 @@ func (r *PollRepo) List(ctx context.Context, status *string) ([]poll.Poll, error
 	var err error
 
 	if status != nil {
-		query += " WHERE status = $1 ORDER BY created_at DESC"
-		rows, err = r.db.QueryContext(ctx, query, *status)
+		query += " WHERE status = $1 ORDER BY created_at DESC, id DESC"
+		rows, err = database.Conn(ctx, r.db).QueryContext(ctx, query, *status)
 	} else {
-		query += " ORDER BY created_at DESC"
-		rows, err = r.db.QueryContext(ctx, query)
+		query += " ORDER BY created_at DESC, id DESC"
+		rows, err = database.Conn(ctx, r.db).QueryContext(ctx, query)
 	}
 	if err != nil {
 		return nil, err
@@ -129,8 +137,27 @@ func (r *PollRepo) List(ctx context.Context, status *string) ([]poll.Poll, error
 	return res, nil
 }
 
+func (r *PollRepo) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, `SELECT status, COUNT(*) FROM polls GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[string]int64)
+	for rows.Next() {
+		var status string
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		res[status] = n
+	}
+	return res, rows.Err()
+}
+
 func (r *PollRepo) UpdateStatus(ctx context.Context, id int64, status string) error {
-	res, err := r.db.ExecContext(ctx, `UPDATE polls SET status = $1, updated_at = now() WHERE id = $2`, status, id)
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `UPDATE polls SET status = $1, updated_at = now() WHERE id = $2`, status, id)
 	if err != nil {
 		return err
 	}
@@ -174,7 +201,7 @@ func (r *PollRepo) Update(ctx context.Context, id int64, input poll.UpdateInput)
 	query := fmt.Sprintf("UPDATE polls SET %s WHERE id = $%d", strings.Join(setParts, ", "), idx)
 	args = append(args, id)
 
-	res, err := r.db.ExecContext(ctx, query, args...)
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, query, args...)
 	if err != nil {
 		return err
 	}
@@ -185,7 +212,7 @@ func (r *PollRepo) Update(ctx context.Context, id int64, input poll.UpdateInput)
 }
 
 func (r *PollRepo) Delete(ctx context.Context, id int64) error {
-	res, err := r.db.ExecContext(ctx, `DELETE FROM polls WHERE id = $1`, id)
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `DELETE FROM polls WHERE id = $1`, id)
 	if err != nil {
 		return err
 	}
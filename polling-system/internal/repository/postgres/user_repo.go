@@ -3,8 +3,13 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
 
 	"polling-system/internal/domain/user"
+	"polling-system/internal/platform/database"
 )
 
 type UserRepo struct {
@@ -21,18 +26,23 @@ func (r *UserRepo) Create(ctx context.Context, u *user.User) error {
         VALUES ($1, $2, $3)
         RETURNING id, created_at, is_active
     `
-	return r.db.QueryRowContext(ctx, query, u.Email, u.PasswordHash, u.Role).
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, query, u.Email, u.PasswordHash, u.Role).
 		Scan(&u.ID, &u.CreatedAt, &u.IsActive)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "users_email_key" {
+		return user.ErrEmailTaken
+	}
+	return err
 }
 
 func (r *UserRepo) GetByEmail(ctx context.Context, email string) (*user.User, error) {
 	query := `
-        SELECT id, email, password_hash, role, created_at, is_active
+        SELECT id, email, password_hash, role, created_at, is_active, email_verified_at
         FROM users WHERE email = $1
     `
 	u := &user.User{}
-	err := r.db.QueryRowContext(ctx, query, email).
-		Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.IsActive)
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, query, email).
+		Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.IsActive, &u.EmailVerifiedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -41,12 +51,12 @@ func (r *UserRepo) GetByEmail(ctx context.Context, email string) (*user.User, er
 
 func (r *UserRepo) GetByID(ctx context.Context, id int64) (*user.User, error) {
 	query := `
-        SELECT id, email, password_hash, role, created_at, is_active
+        SELECT id, email, password_hash, role, created_at, is_active, email_verified_at
         FROM users WHERE id = $1
     `
 	u := &user.User{}
-	err := r.db.QueryRowContext(ctx, query, id).
-		Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.IsActive)
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, query, id).
+		Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.IsActive, &u.EmailVerifiedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -54,8 +64,8 @@ func (r *UserRepo) GetByID(ctx context.Context, id int64) (*user.User, error) {
 }
 
 func (r *UserRepo) List(ctx context.Context) ([]user.User, error) {
-	rows, err := r.db.QueryContext(ctx, `
-        SELECT id, email, password_hash, role, created_at, is_active
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, `
+        SELECT id, email, password_hash, role, created_at, is_active, email_verified_at
         FROM users ORDER BY id
     `)
 	if err != nil {
@@ -66,7 +76,7 @@ func (r *UserRepo) List(ctx context.Context) ([]user.User, error) {
 	var usersList []user.User
 	for rows.Next() {
 		var u user.User
-		if err := rows.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.IsActive); err != nil {
+		if err := rows.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.IsActive, &u.EmailVerifiedAt); err != nil {
 			return nil, err
 		}
 		usersList = append(usersList, u)
@@ -75,7 +85,7 @@ func (r *UserRepo) List(ctx context.Context) ([]user.User, error) {
 }
 
 func (r *UserRepo) UpdateRole(ctx context.Context, id int64, role string) error {
-	res, err := r.db.ExecContext(ctx, `UPDATE users SET role = $1 WHERE id = $2`, role, id)
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `UPDATE users SET role = $1 WHERE id = $2`, role, id)
 	if err != nil {
 		return err
 	}
@@ -86,7 +96,31 @@ func (r *UserRepo) UpdateRole(ctx context.Context, id int64, role string) error
 }
 
 func (r *UserRepo) Deactivate(ctx context.Context, id int64) error {
-	res, err := r.db.ExecContext(ctx, `UPDATE users SET is_active = FALSE WHERE id = $1`, id)
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `UPDATE users SET is_active = FALSE WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *UserRepo) SetPassword(ctx context.Context, id int64, passwordHash string) error {
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `UPDATE users SET password_hash = $1 WHERE id = $2`, passwordHash, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *UserRepo) MarkEmailVerified(ctx context.Context, id int64, at time.Time) error {
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `
+        UPDATE users SET email_verified_at = COALESCE(email_verified_at, $1) WHERE id = $2
+    `, at.UTC(), id)
 	if err != nil {
 		return err
 	}
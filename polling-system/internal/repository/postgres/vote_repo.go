@@ -1,13 +1,18 @@
 package postgres
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/jackc/pgx/v5/pgconn"
 
 	"polling-system/internal/domain/vote"
+	"polling-system/internal/platform/database"
 )
 
 type VoteRepo struct {
@@ -24,7 +29,7 @@ func (r *VoteRepo) Create(ctx context.Context, v *vote.Vote) error {
         VALUES ($1, $2, $3)
         RETURNING id, created_at
     `
-	err := r.db.QueryRowContext(ctx, query, v.PollID, v.OptionID, v.UserID).
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, query, v.PollID, v.OptionID, v.UserID).
 		Scan(&v.ID, &v.CreatedAt)
 	if err != nil {
 		return mapVoteError(err)
@@ -33,7 +38,7 @@ func (r *VoteRepo) Create(ctx context.Context, v *vote.Vote) error {
 }
 
 func (r *VoteRepo) CountByPoll(ctx context.Context, pollID int64) (map[int64]int64, int64, error) {
-	rows, err := r.db.QueryContext(ctx, `
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, `
         SELECT option_id, COUNT(*)
         FROM votes
         WHERE poll_id = $1
@@ -60,7 +65,7 @@ func (r *VoteRepo) CountByPoll(ctx context.Context, pollID int64) (map[int64]int
 }
 
 func (r *VoteRepo) AggregatedByPoll(ctx context.Context, pollID int64) (map[int64]int64, int64, error) {
-	rows, err := r.db.QueryContext(ctx, `
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, `
         SELECT option_id, votes_count
         FROM aggregated_results
         WHERE poll_id = $1
@@ -85,7 +90,7 @@ func (r *VoteRepo) AggregatedByPoll(ctx context.Context, pollID int64) (map[int6
 }
 
 func (r *VoteRepo) IncrementAggregated(ctx context.Context, pollID, optionID int64) error {
-	_, err := r.db.ExecContext(ctx, `
+	_, err := database.Conn(ctx, r.db).ExecContext(ctx, `
         INSERT INTO aggregated_results (poll_id, option_id, votes_count)
         VALUES ($1, $2, 1)
         ON CONFLICT (poll_id, option_id) DO UPDATE
@@ -95,12 +100,125 @@ func (r *VoteRepo) IncrementAggregated(ctx context.Context, pollID, optionID int
 	return err
 }
 
+// AddAggregated upserts all deltas with one multi-row statement. Rows are
+// written in key order so that concurrent batches lock them in the same
+// order and cannot deadlock.
+func (r *VoteRepo) AddAggregated(ctx context.Context, deltas []vote.AggregateDelta) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	values, args := aggregateValues(deltas)
+	_, err := database.Conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO aggregated_results (poll_id, option_id, votes_count)
+        VALUES `+values+`
+        ON CONFLICT (poll_id, option_id) DO UPDATE
+        SET votes_count = aggregated_results.votes_count + excluded.votes_count,
+            updated_at = now()
+    `, args...)
+	return err
+}
+
 func (r *VoteRepo) GetPollStatus(ctx context.Context, pollID int64) (string, error) {
 	var status string
-	err := r.db.QueryRowContext(ctx, `SELECT status FROM polls WHERE id = $1`, pollID).Scan(&status)
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `SELECT status FROM polls WHERE id = $1`, pollID).Scan(&status)
+	return status, err
+}
+
+func (r *VoteRepo) LockPollStatus(ctx context.Context, pollID int64) (string, error) {
+	var status string
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `SELECT status FROM polls WHERE id = $1 FOR UPDATE`, pollID).Scan(&status)
 	return status, err
 }
 
+func (r *VoteRepo) ListOptions(ctx context.Context, pollID int64) ([]vote.OptionInfo, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, `
+        SELECT id, text, position
+        FROM options
+        WHERE poll_id = $1
+        ORDER BY position, id
+    `, pollID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []vote.OptionInfo
+	for rows.Next() {
+		var o vote.OptionInfo
+		if err := rows.Scan(&o.ID, &o.Text, &o.Position); err != nil {
+			return nil, err
+		}
+		res = append(res, o)
+	}
+	return res, rows.Err()
+}
+
+func (r *VoteRepo) VotesTimeSeries(ctx context.Context, pollID int64, bucket string) ([]vote.BucketCount, error) {
+	return r.queryTimeSeries(ctx, `
+        SELECT date_trunc($2::text, created_at) AS bucket, option_id, COUNT(*)
+        FROM votes
+        WHERE poll_id = $1
+        GROUP BY 1, 2
+        ORDER BY 1, 2
+    `, pollID, bucket)
+}
+
+func (r *VoteRepo) RollupTimeSeries(ctx context.Context, pollID int64, bucket string) ([]vote.BucketCount, error) {
+	return r.queryTimeSeries(ctx, `
+        SELECT date_trunc($2::text, bucket_start) AS bucket, option_id, SUM(votes_count)
+        FROM vote_rollups
+        WHERE poll_id = $1
+        GROUP BY 1, 2
+        ORDER BY 1, 2
+    `, pollID, bucket)
+}
+
+func (r *VoteRepo) queryTimeSeries(ctx context.Context, query string, pollID int64, bucket string) ([]vote.BucketCount, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, query, pollID, bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []vote.BucketCount
+	for rows.Next() {
+		var c vote.BucketCount
+		if err := rows.Scan(&c.Bucket, &c.OptionID, &c.Votes); err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
+
+func (r *VoteRepo) CountEligibleVoters(ctx context.Context) (int64, error) {
+	var n int64
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE is_active`).Scan(&n)
+	return n, err
+}
+
+// RefreshRollups recounts the per-minute vote buckets that may have changed
+// since the last refresh. Everything from a minute before the newest
+// rolled-up bucket is re-scanned, and always at least the last 15 minutes, so
+// that votes committed late are picked up whichever poll took the newest
+// vote.
+func (r *VoteRepo) RefreshRollups(ctx context.Context) error {
+	_, err := database.Conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO vote_rollups (poll_id, option_id, bucket_start, votes_count)
+        SELECT poll_id, option_id, date_trunc('minute', created_at), COUNT(*)
+        FROM votes
+        WHERE created_at >= LEAST(
+            COALESCE((SELECT MAX(bucket_start) FROM vote_rollups) - interval '1 minute', '-infinity'::timestamp),
+            LOCALTIMESTAMP - interval '15 minutes'
+        )
+        GROUP BY 1, 2, 3
+        ON CONFLICT (poll_id, option_id, bucket_start) DO UPDATE
+        SET votes_count = EXCLUDED.votes_count,
+            updated_at = now()
+    `)
+	return err
+}
+
 func mapVoteError(err error) error {
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) {
@@ -121,3 +239,19 @@ func mapVoteError(err error) error {
 	}
 	return err
 }
+
+// aggregateValues renders deltas, sorted by poll and option, as the
+// placeholders and arguments of a multi-row VALUES list.
+func aggregateValues(deltas []vote.AggregateDelta) (string, []any) {
+	sorted := slices.Clone(deltas)
+	slices.SortFunc(sorted, func(a, b vote.AggregateDelta) int {
+		return cmp.Or(cmp.Compare(a.PollID, b.PollID), cmp.Compare(a.OptionID, b.OptionID))
+	})
+	rows := make([]string, len(sorted))
+	args := make([]any, 0, 3*len(sorted))
+	for i, d := range sorted {
+		rows[i] = fmt.Sprintf("($%d, $%d, $%d)", 3*i+1, 3*i+2, 3*i+3)
+		args = append(args, d.PollID, d.OptionID, d.Votes)
+	}
+	return strings.Join(rows, ", "), args
+}
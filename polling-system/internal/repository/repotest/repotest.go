@@ -0,0 +1,1098 @@
+// Package repotest is a conformance suite for implementations of
+// poll.Repository, user.Repository, user.TokenRepository,
+// user.TwoFactorRepository, vote.Repository, deadletter.Repository,
+// dashboard.Repository and audit.Repository. Every backend runs it, so the in-memory reference implementation and the SQL repositories are
+// held to the same error mapping, ordering, cascade and concurrency rules.
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"polling-system/internal/domain/audit"
+	"polling-system/internal/domain/dashboard"
+	"polling-system/internal/domain/deadletter"
+	"polling-system/internal/domain/poll"
+	"polling-system/internal/domain/user"
+	"polling-system/internal/domain/vote"
+)
+
+// Repos are the repositories under test. They must share one empty
+// database, and Tx must run units of work against it.
+type Repos struct {
+	Tx     vote.TxManager
+	Users  user.Repository
+	Tokens user.TokenRepository
+	// TwoFactor is the TOTP enrollment and recovery code repository.
+	TwoFactor user.TwoFactorRepository
+	Polls     poll.Repository
+	Votes     interface {
+		vote.Repository
+		RefreshRollups(ctx context.Context) error
+	}
+	DeadLetters deadletter.Repository
+	Dashboard   dashboard.Repository
+	Audit       audit.Repository
+}
+
+// Run runs the suite. open is called once per subtest and must return
+// repositories backed by a fresh, empty database.
+func Run(t *testing.T, open func(t *testing.T) Repos) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, r Repos)
+	}{
+		{"UserCreateAndGet", testUserCreateAndGet},
+		{"UserDuplicateEmail", testUserDuplicateEmail},
+		{"UserNotFound", testUserNotFound},
+		{"UserListOrder", testUserListOrder},
+		{"UserUpdates", testUserUpdates},
+		{"UserTokens", testUserTokens},
+		{"TwoFactor", testTwoFactor},
+		{"PollCreateAndGet", testPollCreateAndGet},
+		{"PollDuplicateOption", testPollDuplicateOption},
+		{"PollUnknownCreator", testPollUnknownCreator},
+		{"PollNotFound", testPollNotFound},
+		{"PollListOrderAndFilter", testPollListOrderAndFilter},
+		{"PollUpdate", testPollUpdate},
+		{"VoteErrors", testVoteErrors},
+		{"VoteCounts", testVoteCounts},
+		{"AddAggregated", testAddAggregated},
+		{"VoteOptionsAndStatus", testVoteOptionsAndStatus},
+		{"UnitOfWork", testUnitOfWork},
+		{"VoteTimeSeries", testVoteTimeSeries},
+		{"EligibleVoters", testEligibleVoters},
+		{"DeletePollCascades", testDeletePollCascades},
+		{"DeadLetters", testDeadLetters},
+		{"Dashboard", testDashboard},
+		{"AuditLog", testAuditLog},
+		{"ConcurrentVotes", testConcurrentVotes},
+		{"ConcurrentAggregation", testConcurrentAggregation},
+		{"ConcurrentRegistration", testConcurrentRegistration},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, open(t))
+		})
+	}
+}
+
+func createUser(t *testing.T, r Repos, email string) *user.User {
+	t.Helper()
+	u := &user.User{Email: email, PasswordHash: "hash", Role: "user"}
+	if err := r.Users.Create(context.Background(), u); err != nil {
+		t.Fatalf("create user %s: %v", email, err)
+	}
+	return u
+}
+
+func createPoll(t *testing.T, r Repos, creatorID int64, status string, options ...string) (*poll.Poll, []poll.Option) {
+	t.Helper()
+	p := &poll.Poll{Title: "Poll", Status: status, CreatorID: creatorID}
+	opts := make([]poll.Option, len(options))
+	for i, text := range options {
+		opts[i].Text = text
+	}
+	if _, err := r.Polls.Create(context.Background(), p, opts); err != nil {
+		t.Fatalf("create poll: %v", err)
+	}
+	return p, opts
+}
+
+func castVote(r Repos, pollID, optionID, userID int64) error {
+	return r.Votes.Create(context.Background(), &vote.Vote{PollID: pollID, OptionID: optionID, UserID: userID})
+}
+
+func testUserCreateAndGet(t *testing.T, r Repos) {
+	ctx := context.Background()
+	u := createUser(t, r, "alice@test.com")
+	if u.ID == 0 || u.CreatedAt.IsZero() || !u.IsActive {
+		t.Fatalf("create should fill id, created_at and is_active: %+v", u)
+	}
+
+	byEmail, err := r.Users.GetByEmail(ctx, "alice@test.com")
+	if err != nil {
+		t.Fatalf("get by email: %v", err)
+	}
+	byID, err := r.Users.GetByID(ctx, u.ID)
+	if err != nil {
+		t.Fatalf("get by id: %v", err)
+	}
+	for _, got := range []*user.User{byEmail, byID} {
+		if got.ID != u.ID || got.Email != u.Email || got.PasswordHash != "hash" || got.Role != "user" || !got.IsActive {
+			t.Fatalf("unexpected user %+v", got)
+		}
+	}
+}
+
+func testUserDuplicateEmail(t *testing.T, r Repos) {
+	createUser(t, r, "dup@test.com")
+	err := r.Users.Create(context.Background(), &user.User{Email: "dup@test.com", PasswordHash: "hash", Role: "user"})
+	if !errors.Is(err, user.ErrEmailTaken) {
+		t.Fatalf("expected ErrEmailTaken, got %v", err)
+	}
+}
+
+func testUserNotFound(t *testing.T, r Repos) {
+	ctx := context.Background()
+	if _, err := r.Users.GetByEmail(ctx, "nobody@test.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("get by email: expected sql.ErrNoRows, got %v", err)
+	}
+	if _, err := r.Users.GetByID(ctx, 999); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("get by id: expected sql.ErrNoRows, got %v", err)
+	}
+	if err := r.Users.UpdateRole(ctx, 999, "admin"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("update role: expected sql.ErrNoRows, got %v", err)
+	}
+	if err := r.Users.Deactivate(ctx, 999); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("deactivate: expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func testUserListOrder(t *testing.T, r Repos) {
+	for i := 0; i < 5; i++ {
+		createUser(t, r, fmt.Sprintf("user%d@test.com", i))
+	}
+	users, err := r.Users.List(context.Background())
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(users) != 5 {
+		t.Fatalf("expected 5 users, got %d", len(users))
+	}
+	for i := 1; i < len(users); i++ {
+		if users[i-1].ID >= users[i].ID {
+			t.Fatalf("users must be ordered by id: %d before %d", users[i-1].ID, users[i].ID)
+		}
+	}
+}
+
+func testUserUpdates(t *testing.T, r Repos) {
+	ctx := context.Background()
+	u := createUser(t, r, "bob@test.com")
+
+	if err := r.Users.UpdateRole(ctx, u.ID, "admin"); err != nil {
+		t.Fatalf("update role: %v", err)
+	}
+	if err := r.Users.UpdateRole(ctx, u.ID, "owner"); err == nil {
+		t.Fatalf("expected unknown role to be rejected")
+	}
+	if err := r.Users.Deactivate(ctx, u.ID); err != nil {
+		t.Fatalf("deactivate: %v", err)
+	}
+	if err := r.Users.SetPassword(ctx, u.ID, "new-hash"); err != nil {
+		t.Fatalf("set password: %v", err)
+	}
+	if u.EmailVerifiedAt != nil {
+		t.Fatalf("new users must not be verified")
+	}
+	verifiedAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := r.Users.MarkEmailVerified(ctx, u.ID, verifiedAt); err != nil {
+		t.Fatalf("mark email verified: %v", err)
+	}
+	if err := r.Users.MarkEmailVerified(ctx, u.ID, verifiedAt.Add(time.Hour)); err != nil {
+		t.Fatalf("mark email verified again: %v", err)
+	}
+
+	got, err := r.Users.GetByID(ctx, u.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Role != "admin" || got.IsActive || got.PasswordHash != "new-hash" {
+		t.Fatalf("updates not persisted: %+v", got)
+	}
+	if got.EmailVerifiedAt == nil || !got.EmailVerifiedAt.Equal(verifiedAt) {
+		t.Fatalf("expected the first verification time to be kept, got %v", got.EmailVerifiedAt)
+	}
+	if err := r.Users.SetPassword(ctx, 999, "hash"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("set password: expected sql.ErrNoRows, got %v", err)
+	}
+	if err := r.Users.MarkEmailVerified(ctx, 999, verifiedAt); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("mark email verified: expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func testUserTokens(t *testing.T, r Repos) {
+	ctx := context.Background()
+	u := createUser(t, r, "carol@test.com")
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	newToken := func(hash, purpose string, expiresAt time.Time) *user.Token {
+		t.Helper()
+		tok := &user.Token{UserID: u.ID, Purpose: purpose, Hash: hash, ExpiresAt: expiresAt}
+		if err := r.Tokens.Create(ctx, tok); err != nil {
+			t.Fatalf("create token %s: %v", hash, err)
+		}
+		if tok.ID == 0 || tok.CreatedAt.IsZero() {
+			t.Fatalf("create should fill id and created_at: %+v", tok)
+		}
+		return tok
+	}
+	newToken("valid", user.PurposeResetPassword, now.Add(time.Hour))
+	newToken("expired", user.PurposeResetPassword, now.Add(-time.Second))
+	newToken("verify", user.PurposeVerifyEmail, now.Add(time.Hour))
+
+	if err := r.Tokens.Create(ctx, &user.Token{UserID: u.ID, Purpose: user.PurposeVerifyEmail, Hash: "valid", ExpiresAt: now}); err == nil {
+		t.Fatalf("expected a duplicate hash to be rejected")
+	}
+	if err := r.Tokens.Create(ctx, &user.Token{UserID: 999, Purpose: user.PurposeVerifyEmail, Hash: "orphan", ExpiresAt: now}); err == nil {
+		t.Fatalf("expected a token of an unknown user to be rejected")
+	}
+
+	if _, err := r.Tokens.Consume(ctx, user.PurposeVerifyEmail, "valid", now); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("consume with the wrong purpose: expected sql.ErrNoRows, got %v", err)
+	}
+	if _, err := r.Tokens.Consume(ctx, user.PurposeResetPassword, "expired", now); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("consume expired: expected sql.ErrNoRows, got %v", err)
+	}
+	got, err := r.Tokens.Consume(ctx, user.PurposeResetPassword, "valid", now)
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	if got.UserID != u.ID || got.Purpose != user.PurposeResetPassword || got.UsedAt == nil || !got.UsedAt.Equal(now) || !got.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("unexpected consumed token %+v", got)
+	}
+	if _, err := r.Tokens.Consume(ctx, user.PurposeResetPassword, "valid", now); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("consume twice: expected sql.ErrNoRows, got %v", err)
+	}
+
+	if err := r.Tokens.DeleteUnused(ctx, u.ID, user.PurposeVerifyEmail); err != nil {
+		t.Fatalf("delete unused: %v", err)
+	}
+	if _, err := r.Tokens.Consume(ctx, user.PurposeVerifyEmail, "verify", now); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("consume deleted: expected sql.ErrNoRows, got %v", err)
+	}
+
+	// Concurrent consumers of one token: exactly one wins.
+	newToken("race", user.PurposeVerifyEmail, now.Add(time.Hour))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	wins := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.Tokens.Consume(ctx, user.PurposeVerifyEmail, "race", now); err == nil {
+				mu.Lock()
+				wins++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if wins != 1 {
+		t.Fatalf("expected exactly one consumer to win, got %d", wins)
+	}
+}
+
+func testPollCreateAndGet(t *testing.T, r Repos) {
+	ctx := context.Background()
+	creator := createUser(t, r, "creator@test.com")
+	desc := "where to eat"
+	starts := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	p := &poll.Poll{Title: "Lunch", Description: &desc, Status: "draft", StartsAt: &starts, CreatorID: creator.ID}
+	opts := []poll.Option{{Text: "pizza"}, {Text: "sushi"}, {Text: "tacos"}}
+
+	id, err := r.Polls.Create(ctx, p, opts)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if id == 0 || id != p.ID || p.CreatedAt.IsZero() || p.UpdatedAt.IsZero() {
+		t.Fatalf("create should fill id and timestamps: %+v", p)
+	}
+	for i, o := range opts {
+		if o.ID == 0 || o.PollID != id || o.Position != i {
+			t.Fatalf("create should fill option %d: %+v", i, o)
+		}
+	}
+
+	got, gotOpts, err := r.Polls.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Title != "Lunch" || got.Description == nil || *got.Description != desc || got.Status != "draft" || got.CreatorID != creator.ID {
+		t.Fatalf("unexpected poll %+v", got)
+	}
+	if got.StartsAt == nil || !got.StartsAt.Equal(starts) || got.EndsAt != nil {
+		t.Fatalf("unexpected schedule starts=%v ends=%v", got.StartsAt, got.EndsAt)
+	}
+	if len(gotOpts) != 3 {
+		t.Fatalf("expected 3 options, got %d", len(gotOpts))
+	}
+	for i, o := range gotOpts {
+		if o.ID != opts[i].ID || o.Text != opts[i].Text || o.Position != i {
+			t.Fatalf("options must be returned in position order: %+v", gotOpts)
+		}
+	}
+}
+
+func testPollDuplicateOption(t *testing.T, r Repos) {
+	ctx := context.Background()
+	creator := createUser(t, r, "creator@test.com")
+	p := &poll.Poll{Title: "Dup", Status: "draft", CreatorID: creator.ID}
+	_, err := r.Polls.Create(ctx, p, []poll.Option{{Text: "yes"}, {Text: "yes"}})
+	if !errors.Is(err, poll.ErrDuplicateOption) {
+		t.Fatalf("expected ErrDuplicateOption, got %v", err)
+	}
+
+	polls, err := r.Polls.List(ctx, nil)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(polls) != 0 {
+		t.Fatalf("failed create must not leave a poll behind, got %d", len(polls))
+	}
+}
+
+func testPollUnknownCreator(t *testing.T, r Repos) {
+	p := &poll.Poll{Title: "Orphan", Status: "draft", CreatorID: 999}
+	if _, err := r.Polls.Create(context.Background(), p, []poll.Option{{Text: "a"}, {Text: "b"}}); err == nil {
+		t.Fatalf("expected unknown creator to be rejected")
+	}
+}
+
+func testPollNotFound(t *testing.T, r Repos) {
+	ctx := context.Background()
+	title := "x"
+	if _, _, err := r.Polls.GetByID(ctx, 999); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("get: expected sql.ErrNoRows, got %v", err)
+	}
+	if err := r.Polls.UpdateStatus(ctx, 999, "active"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("update status: expected sql.ErrNoRows, got %v", err)
+	}
+	if err := r.Polls.Update(ctx, 999, poll.UpdateInput{Title: &title}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("update: expected sql.ErrNoRows, got %v", err)
+	}
+	if err := r.Polls.Delete(ctx, 999); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("delete: expected sql.ErrNoRows, got %v", err)
+	}
+	if _, err := r.Votes.GetPollStatus(ctx, 999); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("poll status: expected sql.ErrNoRows, got %v", err)
+	}
+	err := r.Tx.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := r.Votes.LockPollStatus(ctx, 999)
+		return err
+	})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("lock poll status: expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func testPollListOrderAndFilter(t *testing.T, r Repos) {
+	ctx := context.Background()
+	creator := createUser(t, r, "creator@test.com")
+	var ids []int64
+	for i, status := range []string{"draft", "active", "active", "closed"} {
+		p, _ := createPoll(t, r, creator.ID, status, fmt.Sprintf("a%d", i), fmt.Sprintf("b%d", i))
+		ids = append(ids, p.ID)
+	}
+
+	all, err := r.Polls.List(ctx, nil)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(all) != 4 {
+		t.Fatalf("expected 4 polls, got %d", len(all))
+	}
+	for i, p := range all {
+		if p.ID != ids[len(ids)-1-i] {
+			t.Fatalf("polls must be listed newest first, got %v", pollIDs(all))
+		}
+	}
+
+	active := "active"
+	filtered, err := r.Polls.List(ctx, &active)
+	if err != nil {
+		t.Fatalf("list active: %v", err)
+	}
+	if len(filtered) != 2 || filtered[0].ID != ids[2] || filtered[1].ID != ids[1] {
+		t.Fatalf("unexpected active polls %v", pollIDs(filtered))
+	}
+
+	counts, err := r.Polls.CountByStatus(ctx)
+	if err != nil {
+		t.Fatalf("count by status: %v", err)
+	}
+	if counts["draft"] != 1 || counts["active"] != 2 || counts["closed"] != 1 {
+		t.Fatalf("unexpected counts by status %v", counts)
+	}
+}
+
+func pollIDs(polls []poll.Poll) []int64 {
+	ids := make([]int64, len(polls))
+	for i, p := range polls {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+func testPollUpdate(t *testing.T, r Repos) {
+	ctx := context.Background()
+	creator := createUser(t, r, "creator@test.com")
+	p, _ := createPoll(t, r, creator.ID, "draft", "a", "b")
+
+	title := "Renamed"
+	ends := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	if err := r.Polls.Update(ctx, p.ID, poll.UpdateInput{Title: &title, EndsAt: &ends}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := r.Polls.Update(ctx, p.ID, poll.UpdateInput{}); err == nil {
+		t.Fatalf("expected empty update to be rejected")
+	}
+	if err := r.Polls.UpdateStatus(ctx, p.ID, "active"); err != nil {
+		t.Fatalf("update status: %v", err)
+	}
+	if err := r.Polls.UpdateStatus(ctx, p.ID, "archived"); err == nil {
+		t.Fatalf("expected unknown status to be rejected")
+	}
+
+	got, _, err := r.Polls.GetByID(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Title != title || got.Status != "active" || got.EndsAt == nil || !got.EndsAt.Equal(ends) {
+		t.Fatalf("updates not persisted: %+v", got)
+	}
+	if got.UpdatedAt.Before(p.UpdatedAt) {
+		t.Fatalf("updated_at must not go backwards: %v < %v", got.UpdatedAt, p.UpdatedAt)
+	}
+}
+
+func testVoteErrors(t *testing.T, r Repos) {
+	voter := createUser(t, r, "voter@test.com")
+	p, opts := createPoll(t, r, voter.ID, "active", "a", "b")
+	other, otherOpts := createPoll(t, r, voter.ID, "active", "c", "d")
+
+	if err := castVote(r, p.ID, opts[0].ID, voter.ID); err != nil {
+		t.Fatalf("first vote: %v", err)
+	}
+
+	cases := []struct {
+		name             string
+		pollID, optionID int64
+		want             error
+	}{
+		{"already voted", p.ID, opts[1].ID, vote.ErrAlreadyVoted},
+		{"option of another poll", other.ID, opts[0].ID, vote.ErrOptionNotInPoll},
+		{"unknown option", other.ID, otherOpts[1].ID + 1000, vote.ErrOptionNotInPoll},
+		{"unknown poll", other.ID + 1000, otherOpts[0].ID, vote.ErrPollNotFound},
+	}
+	for _, tc := range cases {
+		if err := castVote(r, tc.pollID, tc.optionID, voter.ID); !errors.Is(err, tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+	}
+
+	if err := castVote(r, other.ID, otherOpts[0].ID, 999); err == nil {
+		t.Fatalf("expected unknown user to be rejected")
+	}
+}
+
+func testVoteCounts(t *testing.T, r Repos) {
+	ctx := context.Background()
+	creator := createUser(t, r, "creator@test.com")
+	p, opts := createPoll(t, r, creator.ID, "active", "a", "b", "c")
+
+	for i := 0; i < 3; i++ {
+		u := createUser(t, r, fmt.Sprintf("voter%d@test.com", i))
+		optionID := opts[0].ID
+		if i == 2 {
+			optionID = opts[1].ID
+		}
+		v := &vote.Vote{PollID: p.ID, OptionID: optionID, UserID: u.ID}
+		if err := r.Votes.Create(ctx, v); err != nil {
+			t.Fatalf("vote: %v", err)
+		}
+		if v.ID == 0 || v.CreatedAt.IsZero() {
+			t.Fatalf("create should fill id and created_at: %+v", v)
+		}
+		if err := r.Votes.IncrementAggregated(ctx, p.ID, optionID); err != nil {
+			t.Fatalf("increment: %v", err)
+		}
+	}
+
+	counts, total, err := r.Votes.CountByPoll(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if total != 3 || counts[opts[0].ID] != 2 || counts[opts[1].ID] != 1 || len(counts) != 2 {
+		t.Fatalf("unexpected counts %v total %d", counts, total)
+	}
+
+	agg, aggTotal, err := r.Votes.AggregatedByPoll(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("aggregated: %v", err)
+	}
+	if aggTotal != 3 || agg[opts[0].ID] != 2 || agg[opts[1].ID] != 1 || len(agg) != 2 {
+		t.Fatalf("unexpected aggregated counts %v total %d", agg, aggTotal)
+	}
+
+	if err := r.Votes.IncrementAggregated(ctx, p.ID, opts[2].ID+1000); err == nil {
+		t.Fatalf("expected aggregation of unknown option to be rejected")
+	}
+
+	empty, emptyTotal, err := r.Votes.CountByPoll(ctx, p.ID+1000)
+	if err != nil || emptyTotal != 0 || len(empty) != 0 {
+		t.Fatalf("unknown poll should have no votes: %v %d %v", empty, emptyTotal, err)
+	}
+}
+
+func testAddAggregated(t *testing.T, r Repos) {
+	ctx := context.Background()
+	creator := createUser(t, r, "creator@test.com")
+	p1, opts1 := createPoll(t, r, creator.ID, "active", "a", "b")
+	p2, opts2 := createPoll(t, r, creator.ID, "active", "c", "d")
+
+	if err := r.Votes.AddAggregated(ctx, nil); err != nil {
+		t.Fatalf("empty batch: %v", err)
+	}
+	if err := r.Votes.IncrementAggregated(ctx, p1.ID, opts1[0].ID); err != nil {
+		t.Fatalf("increment: %v", err)
+	}
+	err := r.Votes.AddAggregated(ctx, []vote.AggregateDelta{
+		{PollID: p2.ID, OptionID: opts2[1].ID, Votes: 2},
+		{PollID: p1.ID, OptionID: opts1[0].ID, Votes: 5},
+		{PollID: p1.ID, OptionID: opts1[1].ID, Votes: 1},
+	})
+	if err != nil {
+		t.Fatalf("add aggregated: %v", err)
+	}
+
+	counts, total, err := r.Votes.AggregatedByPoll(ctx, p1.ID)
+	if err != nil {
+		t.Fatalf("aggregated: %v", err)
+	}
+	if total != 7 || counts[opts1[0].ID] != 6 || counts[opts1[1].ID] != 1 {
+		t.Fatalf("deltas must add to existing counts: %v total %d", counts, total)
+	}
+
+	// A delta for an option of another poll fails the whole batch.
+	err = r.Votes.AddAggregated(ctx, []vote.AggregateDelta{
+		{PollID: p2.ID, OptionID: opts2[1].ID, Votes: 1},
+		{PollID: p2.ID, OptionID: opts1[0].ID, Votes: 1},
+	})
+	if err == nil {
+		t.Fatalf("expected a batch with an unknown option to be rejected")
+	}
+	if counts, total, _ := r.Votes.AggregatedByPoll(ctx, p2.ID); total != 2 || counts[opts2[1].ID] != 2 {
+		t.Fatalf("rejected batch must not be partially applied: %v total %d", counts, total)
+	}
+}
+
+func testVoteOptionsAndStatus(t *testing.T, r Repos) {
+	ctx := context.Background()
+	creator := createUser(t, r, "creator@test.com")
+	p, opts := createPoll(t, r, creator.ID, "closed", "first", "second", "third")
+
+	status, err := r.Votes.GetPollStatus(ctx, p.ID)
+	if err != nil || status != "closed" {
+		t.Fatalf("unexpected status %q, err %v", status, err)
+	}
+
+	infos, err := r.Votes.ListOptions(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("list options: %v", err)
+	}
+	if len(infos) != len(opts) {
+		t.Fatalf("expected %d options, got %d", len(opts), len(infos))
+	}
+	for i, o := range infos {
+		if o.ID != opts[i].ID || o.Text != opts[i].Text || o.Position != i {
+			t.Fatalf("options must be in position order: %+v", infos)
+		}
+	}
+}
+
+func testUnitOfWork(t *testing.T, r Repos) {
+	ctx := context.Background()
+	voter := createUser(t, r, "voter@test.com")
+	p, opts := createPoll(t, r, voter.ID, "active", "a", "b")
+
+	err := r.Tx.WithinTx(ctx, func(ctx context.Context) error {
+		status, err := r.Votes.LockPollStatus(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		if status != "active" {
+			return fmt.Errorf("unexpected status %q", status)
+		}
+		// A nested unit of work joins the outer one.
+		return r.Tx.WithinTx(ctx, func(ctx context.Context) error {
+			if err := r.Votes.Create(ctx, &vote.Vote{PollID: p.ID, OptionID: opts[0].ID, UserID: voter.ID}); err != nil {
+				return err
+			}
+			return r.Votes.IncrementAggregated(ctx, p.ID, opts[0].ID)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unit of work: %v", err)
+	}
+
+	if _, total, err := r.Votes.CountByPoll(ctx, p.ID); err != nil || total != 1 {
+		t.Fatalf("vote not committed: %d %v", total, err)
+	}
+	if _, total, err := r.Votes.AggregatedByPoll(ctx, p.ID); err != nil || total != 1 {
+		t.Fatalf("aggregate not committed: %d %v", total, err)
+	}
+
+	err = r.Tx.WithinTx(ctx, func(ctx context.Context) error {
+		return r.Votes.Create(ctx, &vote.Vote{PollID: p.ID, OptionID: opts[1].ID, UserID: voter.ID})
+	})
+	if !errors.Is(err, vote.ErrAlreadyVoted) {
+		t.Fatalf("errors must surface from the unit of work unchanged, got %v", err)
+	}
+}
+
+func testVoteTimeSeries(t *testing.T, r Repos) {
+	ctx := context.Background()
+	voter := createUser(t, r, "voter@test.com")
+	p, opts := createPoll(t, r, voter.ID, "active", "a", "b")
+
+	before := time.Now().UTC().Truncate(time.Hour)
+	if err := castVote(r, p.ID, opts[1].ID, voter.ID); err != nil {
+		t.Fatalf("vote: %v", err)
+	}
+	after := time.Now().UTC().Truncate(time.Hour)
+
+	checkSeries := func(name string, series []vote.BucketCount, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(series) != 1 || series[0].OptionID != opts[1].ID || series[0].Votes != 1 {
+			t.Fatalf("%s: unexpected series %+v", name, series)
+		}
+		b := series[0].Bucket.UTC()
+		if b.Before(before) || b.After(after) || !b.Equal(b.Truncate(time.Hour)) {
+			t.Fatalf("%s: bucket %v is not the hour of the vote", name, b)
+		}
+	}
+
+	series, err := r.Votes.VotesTimeSeries(ctx, p.ID, vote.BucketHour)
+	checkSeries("votes", series, err)
+
+	if err := r.Votes.RefreshRollups(ctx); err != nil {
+		t.Fatalf("refresh rollups: %v", err)
+	}
+	if err := r.Votes.RefreshRollups(ctx); err != nil {
+		t.Fatalf("refresh rollups twice: %v", err)
+	}
+	rolled, err := r.Votes.RollupTimeSeries(ctx, p.ID, vote.BucketHour)
+	checkSeries("rollups", rolled, err)
+}
+
+func testEligibleVoters(t *testing.T, r Repos) {
+	ctx := context.Background()
+	createUser(t, r, "a@test.com")
+	b := createUser(t, r, "b@test.com")
+	createUser(t, r, "c@test.com")
+	if err := r.Users.Deactivate(ctx, b.ID); err != nil {
+		t.Fatalf("deactivate: %v", err)
+	}
+
+	n, err := r.Votes.CountEligibleVoters(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 eligible voters, got %d (%v)", n, err)
+	}
+}
+
+func testDeletePollCascades(t *testing.T, r Repos) {
+	ctx := context.Background()
+	voter := createUser(t, r, "voter@test.com")
+	p, opts := createPoll(t, r, voter.ID, "active", "a", "b")
+	kept, keptOpts := createPoll(t, r, voter.ID, "active", "c", "d")
+
+	for _, v := range []struct{ pollID, optionID int64 }{{p.ID, opts[0].ID}, {kept.ID, keptOpts[0].ID}} {
+		if err := castVote(r, v.pollID, v.optionID, voter.ID); err != nil {
+			t.Fatalf("vote: %v", err)
+		}
+		if err := r.Votes.IncrementAggregated(ctx, v.pollID, v.optionID); err != nil {
+			t.Fatalf("increment: %v", err)
+		}
+	}
+	if err := r.Votes.RefreshRollups(ctx); err != nil {
+		t.Fatalf("refresh rollups: %v", err)
+	}
+
+	if err := r.Polls.Delete(ctx, p.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if _, _, err := r.Polls.GetByID(ctx, p.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("deleted poll still found: %v", err)
+	}
+	if opts, err := r.Votes.ListOptions(ctx, p.ID); err != nil || len(opts) != 0 {
+		t.Fatalf("options must be deleted with the poll: %v %v", opts, err)
+	}
+	if _, total, err := r.Votes.CountByPoll(ctx, p.ID); err != nil || total != 0 {
+		t.Fatalf("votes must be deleted with the poll: %d %v", total, err)
+	}
+	if _, total, err := r.Votes.AggregatedByPoll(ctx, p.ID); err != nil || total != 0 {
+		t.Fatalf("aggregated results must be deleted with the poll: %d %v", total, err)
+	}
+	if series, err := r.Votes.RollupTimeSeries(ctx, p.ID, vote.BucketDay); err != nil || len(series) != 0 {
+		t.Fatalf("rollups must be deleted with the poll: %v %v", series, err)
+	}
+
+	if _, total, err := r.Votes.CountByPoll(ctx, kept.ID); err != nil || total != 1 {
+		t.Fatalf("other polls must be untouched: %d %v", total, err)
+	}
+	if err := castVote(r, p.ID, opts[1].ID, voter.ID); !errors.Is(err, vote.ErrPollNotFound) {
+		t.Fatalf("voting on a deleted poll: expected ErrPollNotFound, got %v", err)
+	}
+}
+
+func testDeadLetters(t *testing.T, r Repos) {
+	ctx := context.Background()
+	var ids []int64
+	for i := int64(1); i <= 3; i++ {
+		e := &deadletter.Entry{PollID: i, OptionID: 10 * i, UserID: 100 * i, Error: "boom", Attempts: 4}
+		if err := r.DeadLetters.Create(ctx, e); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if e.ID == 0 || e.CreatedAt.IsZero() || e.LastFailedAt.IsZero() {
+			t.Fatalf("create should fill id and timestamps: %+v", e)
+		}
+		ids = append(ids, e.ID)
+	}
+
+	if n, err := r.DeadLetters.Count(ctx); err != nil || n != 3 {
+		t.Fatalf("expected 3 entries, got %d (%v)", n, err)
+	}
+	page, err := r.DeadLetters.List(ctx, 2, 1)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(page) != 2 || page[0].ID != ids[1] || page[1].ID != ids[2] {
+		t.Fatalf("entries must be listed oldest first with limit and offset: %+v", page)
+	}
+	if page, err := r.DeadLetters.List(ctx, 10, 3); err != nil || len(page) != 0 {
+		t.Fatalf("offset past the end must return nothing: %+v %v", page, err)
+	}
+
+	if err := r.DeadLetters.RecordFailure(ctx, ids[0], 1, "still failing"); err != nil {
+		t.Fatalf("record failure: %v", err)
+	}
+	got, err := r.DeadLetters.GetByID(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.PollID != 1 || got.OptionID != 10 || got.UserID != 100 || got.Attempts != 5 || got.Error != "still failing" {
+		t.Fatalf("unexpected entry %+v", got)
+	}
+	if got.LastFailedAt.Before(got.CreatedAt) {
+		t.Fatalf("last_failed_at must not precede created_at: %+v", got)
+	}
+
+	if err := r.DeadLetters.Delete(ctx, ids[0]); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := r.DeadLetters.GetByID(ctx, ids[0]); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("get deleted: expected sql.ErrNoRows, got %v", err)
+	}
+	if err := r.DeadLetters.Delete(ctx, ids[0]); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("delete twice: expected sql.ErrNoRows, got %v", err)
+	}
+	if err := r.DeadLetters.RecordFailure(ctx, ids[0], 1, "x"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("record failure on deleted entry: expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func testTwoFactor(t *testing.T, r Repos) {
+	ctx := context.Background()
+	u := createUser(t, r, "totp@test.com")
+	if _, err := r.TwoFactor.Get(ctx, u.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows before enrolling, got %v", err)
+	}
+	if err := r.TwoFactor.SavePending(ctx, &user.TwoFactor{UserID: u.ID + 100, Secret: "GONE"}); err == nil {
+		t.Fatalf("expected enrollment of unknown user to fail")
+	}
+
+	tf := &user.TwoFactor{UserID: u.ID, Secret: "FIRST"}
+	if err := r.TwoFactor.SavePending(ctx, tf); err != nil || tf.CreatedAt.IsZero() {
+		t.Fatalf("save pending: %+v %v", tf, err)
+	}
+	if err := r.TwoFactor.SavePending(ctx, &user.TwoFactor{UserID: u.ID, Secret: "SECOND"}); err != nil {
+		t.Fatalf("replace pending: %v", err)
+	}
+	if err := r.TwoFactor.UseStep(ctx, u.ID, 5); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("pending enrollment must not accept steps, got %v", err)
+	}
+
+	at := time.Now().UTC().Truncate(time.Millisecond)
+	if err := r.TwoFactor.Enable(ctx, u.ID, 10, at); err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+	if err := r.TwoFactor.Enable(ctx, u.ID, 11, at); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected enabling twice to fail, got %v", err)
+	}
+	if err := r.TwoFactor.SavePending(ctx, &user.TwoFactor{UserID: u.ID, Secret: "THIRD"}); !errors.Is(err, user.ErrTwoFactorEnabled) {
+		t.Fatalf("expected ErrTwoFactorEnabled, got %v", err)
+	}
+	got, err := r.TwoFactor.Get(ctx, u.ID)
+	if err != nil || got.Secret != "SECOND" || got.LastStep != 10 || got.EnabledAt == nil || !got.EnabledAt.Equal(at) {
+		t.Fatalf("unexpected enrollment %+v (%v)", got, err)
+	}
+
+	for _, tc := range []struct {
+		step int64
+		want error
+	}{{10, sql.ErrNoRows}, {9, sql.ErrNoRows}, {11, nil}, {11, sql.ErrNoRows}, {13, nil}} {
+		if err := r.TwoFactor.UseStep(ctx, u.ID, tc.step); !errors.Is(err, tc.want) {
+			t.Fatalf("use step %d: expected %v, got %v", tc.step, tc.want, err)
+		}
+	}
+
+	if err := r.TwoFactor.ReplaceRecoveryCodes(ctx, u.ID, []string{"h1", "h2", "h3"}); err != nil {
+		t.Fatalf("replace codes: %v", err)
+	}
+	if err := r.TwoFactor.UseRecoveryCode(ctx, u.ID, "h2", at); err != nil {
+		t.Fatalf("use code: %v", err)
+	}
+	if err := r.TwoFactor.UseRecoveryCode(ctx, u.ID, "h2", at); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected used code to be refused, got %v", err)
+	}
+	other := createUser(t, r, "other@test.com")
+	if err := r.TwoFactor.UseRecoveryCode(ctx, other.ID, "h1", at); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected another user's code to be refused, got %v", err)
+	}
+	if n, err := r.TwoFactor.CountRecoveryCodes(ctx, u.ID); err != nil || n != 2 {
+		t.Fatalf("expected 2 unused codes, got %d (%v)", n, err)
+	}
+	if err := r.TwoFactor.ReplaceRecoveryCodes(ctx, u.ID, []string{"h4"}); err != nil {
+		t.Fatalf("replace codes again: %v", err)
+	}
+	if err := r.TwoFactor.UseRecoveryCode(ctx, u.ID, "h1", at); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected replaced code to be refused, got %v", err)
+	}
+
+	if err := r.TwoFactor.Delete(ctx, u.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := r.TwoFactor.Delete(ctx, u.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected second delete to return sql.ErrNoRows, got %v", err)
+	}
+	if n, err := r.TwoFactor.CountRecoveryCodes(ctx, u.ID); err != nil || n != 0 {
+		t.Fatalf("delete must remove recovery codes, got %d (%v)", n, err)
+	}
+}
+
+func testAuditLog(t *testing.T, r Repos) {
+	ctx := context.Background()
+	u := createUser(t, r, "audited@test.com")
+	events := []audit.Event{
+		{Action: audit.ActionLoginFailed, Email: "nobody@test.com", IP: "203.0.113.7"},
+		{Action: audit.ActionLoginFailed, UserID: &u.ID, Email: u.Email, IP: "203.0.113.7", RequestID: "req-1"},
+		{Action: audit.ActionLoginSucceeded, UserID: &u.ID, Email: u.Email},
+		{Action: audit.ActionAccountUnlocked, UserID: &u.ID, ActorID: &u.ID, Detail: "by admin"},
+	}
+	var ids []int64
+	for i := range events {
+		if err := r.Audit.Create(ctx, &events[i]); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if events[i].ID == 0 || events[i].CreatedAt.IsZero() {
+			t.Fatalf("create should fill id and created_at: %+v", events[i])
+		}
+		ids = append(ids, events[i].ID)
+	}
+
+	all, err := r.Audit.List(ctx, audit.Filter{}, 10, 0)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(all) != 4 || all[0].ID != ids[3] || all[3].ID != ids[0] {
+		t.Fatalf("events must be listed newest first: %+v", all)
+	}
+	if all[3].UserID != nil || all[3].ActorID != nil || all[3].IP != "203.0.113.7" {
+		t.Fatalf("unexpected event without user: %+v", all[3])
+	}
+	if got := all[0]; got.UserID == nil || *got.UserID != u.ID || got.ActorID == nil || *got.ActorID != u.ID || got.Detail != "by admin" {
+		t.Fatalf("unexpected unlock event: %+v", got)
+	}
+
+	failed, err := r.Audit.List(ctx, audit.Filter{Action: audit.ActionLoginFailed}, 10, 0)
+	if err != nil || len(failed) != 2 {
+		t.Fatalf("expected 2 failed logins, got %+v (%v)", failed, err)
+	}
+	mine, err := r.Audit.List(ctx, audit.Filter{Action: audit.ActionLoginFailed, UserID: u.ID}, 10, 0)
+	if err != nil || len(mine) != 1 || mine[0].RequestID != "req-1" {
+		t.Fatalf("expected the user's failed login, got %+v (%v)", mine, err)
+	}
+	page, err := r.Audit.List(ctx, audit.Filter{UserID: u.ID}, 2, 1)
+	if err != nil || len(page) != 2 || page[0].ID != ids[2] || page[1].ID != ids[1] {
+		t.Fatalf("unexpected page: %+v (%v)", page, err)
+	}
+	if page, err := r.Audit.List(ctx, audit.Filter{}, 10, 4); err != nil || len(page) != 0 {
+		t.Fatalf("offset past the end must return nothing: %+v %v", page, err)
+	}
+}
+
+func testDashboard(t *testing.T, r Repos) {
+	ctx := context.Background()
+	creator := createUser(t, r, "creator@test.com")
+	voter := createUser(t, r, "voter@test.com")
+	inactive := createUser(t, r, "inactive@test.com")
+	if err := r.Users.Deactivate(ctx, inactive.ID); err != nil {
+		t.Fatalf("deactivate: %v", err)
+	}
+	p1, opts1 := createPoll(t, r, creator.ID, "active", "a", "b")
+	p2, opts2 := createPoll(t, r, creator.ID, "closed", "c", "d")
+	createPoll(t, r, creator.ID, "active", "e", "f")
+	for _, u := range []int64{creator.ID, voter.ID} {
+		if err := castVote(r, p1.ID, opts1[0].ID, u); err != nil {
+			t.Fatalf("vote: %v", err)
+		}
+	}
+	err := r.Votes.AddAggregated(ctx, []vote.AggregateDelta{
+		{PollID: p1.ID, OptionID: opts1[0].ID, Votes: 1},
+		{PollID: p1.ID, OptionID: opts1[1].ID, Votes: 1},
+		{PollID: p2.ID, OptionID: opts2[0].ID, Votes: 3},
+	})
+	if err != nil {
+		t.Fatalf("add aggregated: %v", err)
+	}
+
+	now := time.Now()
+	totals, err := r.Dashboard.Totals(ctx, now.Add(-time.Hour), now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("totals: %v", err)
+	}
+	want := dashboard.Totals{
+		Users: dashboard.UserTotals{Total: 3, Active: 2},
+		Votes: dashboard.VoteTotals{Today: 2, ThisWeek: 2},
+	}
+	if totals != want {
+		t.Fatalf("expected %+v, got %+v", want, totals)
+	}
+	if totals, err := r.Dashboard.Totals(ctx, now.Add(time.Hour), now.Add(-time.Hour)); err != nil || totals.Votes.Today != 0 || totals.Votes.ThisWeek != 2 {
+		t.Fatalf("votes before a period start must not count: %+v %v", totals, err)
+	}
+
+	top, err := r.Dashboard.TopPolls(ctx, 5)
+	if err != nil {
+		t.Fatalf("top polls: %v", err)
+	}
+	if len(top) != 2 || top[0].PollID != p2.ID || top[0].Votes != 3 || top[0].Status != "closed" || top[1].PollID != p1.ID || top[1].Votes != 2 {
+		t.Fatalf("polls without votes must be left out and the rest ranked by votes: %+v", top)
+	}
+	if top, err := r.Dashboard.TopPolls(ctx, 1); err != nil || len(top) != 1 {
+		t.Fatalf("limit must apply: %+v %v", top, err)
+	}
+
+	recent, err := r.Dashboard.RecentRegistrations(ctx, 2)
+	if err != nil {
+		t.Fatalf("recent registrations: %v", err)
+	}
+	if len(recent) != 2 || recent[0].UserID != inactive.ID || recent[1].UserID != voter.ID || recent[0].Email != "inactive@test.com" || recent[0].CreatedAt.IsZero() {
+		t.Fatalf("expected the newest users first: %+v", recent)
+	}
+}
+
+const concurrency = 16
+
+func testConcurrentVotes(t *testing.T, r Repos) {
+	voter := createUser(t, r, "voter@test.com")
+	p, opts := createPoll(t, r, voter.ID, "active", "a", "b")
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		accepted int
+		failures []error
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := castVote(r, p.ID, opts[i%2].ID, voter.ID)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				accepted++
+			case !errors.Is(err, vote.ErrAlreadyVoted):
+				failures = append(failures, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(failures) > 0 {
+		t.Fatalf("duplicate votes must fail with ErrAlreadyVoted, got %v", failures)
+	}
+	if accepted != 1 {
+		t.Fatalf("exactly one concurrent vote must be accepted, got %d", accepted)
+	}
+	if _, total, err := r.Votes.CountByPoll(context.Background(), p.ID); err != nil || total != 1 {
+		t.Fatalf("expected 1 stored vote, got %d (%v)", total, err)
+	}
+}
+
+func testConcurrentAggregation(t *testing.T, r Repos) {
+	ctx := context.Background()
+	creator := createUser(t, r, "creator@test.com")
+	p, opts := createPoll(t, r, creator.ID, "active", "a", "b")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- r.Votes.IncrementAggregated(ctx, p.ID, opts[0].ID)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("increment: %v", err)
+		}
+	}
+
+	counts, total, err := r.Votes.AggregatedByPoll(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("aggregated: %v", err)
+	}
+	if total != concurrency || counts[opts[0].ID] != concurrency {
+		t.Fatalf("lost increments: %v total %d, want %d", counts, total, concurrency)
+	}
+}
+
+func testConcurrentRegistration(t *testing.T, r Repos) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		created  int
+		failures []error
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := r.Users.Create(context.Background(), &user.User{Email: "race@test.com", PasswordHash: "hash", Role: "user"})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				created++
+			case !errors.Is(err, user.ErrEmailTaken):
+				failures = append(failures, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(failures) > 0 {
+		t.Fatalf("duplicate registrations must fail with ErrEmailTaken, got %v", failures)
+	}
+	if created != 1 {
+		t.Fatalf("exactly one registration must succeed, got %d", created)
+	}
+}
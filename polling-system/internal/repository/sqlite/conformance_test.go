@@ -0,0 +1,38 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"polling-system/internal/config"
+	"polling-system/internal/db/migrations"
+	"polling-system/internal/platform/database"
+	"polling-system/internal/repository/repotest"
+)
+
+// TestConformance uses a database file rather than :memory: so concurrent
+// writers go through separate connections and the busy timeout.
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repos {
+		dsn := "sqlite://" + filepath.Join(t.TempDir(), "polls.db")
+		db, err := database.NewSQLite(config.DBConfig{DSN: dsn, MaxOpenConns: 8})
+		if err != nil {
+			t.Fatalf("open sqlite: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		m, err := migrations.New(db, database.SQLite, nil)
+		if err != nil {
+			t.Fatalf("load migrations: %v", err)
+		}
+		if _, err := m.Up(context.Background()); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		// The suite expects an empty database; drop the seeded admin.
+		if _, err := db.Exec(`DELETE FROM users`); err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+		return repotest.Repos{Tx: database.NewTxManager(db), Users: NewUserRepo(db), Tokens: NewTokenRepo(db), TwoFactor: NewTwoFactorRepo(db), Polls: NewPollRepo(db), Votes: NewVoteRepo(db), DeadLetters: NewDeadLetterRepo(db), Dashboard: NewDashboardRepo(db), Audit: NewAuditRepo(db)}
+	})
+}
@@ -0,0 +1,219 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"polling-system/internal/domain/poll"
+	"polling-system/internal/platform/database"
+)
+
+type PollRepo struct {
+	db *sql.DB
+}
+
+func NewPollRepo(db *sql.DB) *PollRepo {
+	return &PollRepo{db: db}
+}
+
+func (r *PollRepo) Create(ctx context.Context, p *poll.Poll, options []poll.Option) (int64, error) {
+	err := database.InTx(ctx, r.db, func(ctx context.Context) error {
+		tx := database.Conn(ctx, r.db)
+
+		queryPoll := `
+            INSERT INTO polls (title, description, status, starts_at, ends_at, creator_id)
+            VALUES ($1, $2, $3, $4, $5, $6)
+            RETURNING id, created_at, updated_at
+        `
+
+		err := tx.QueryRowContext(ctx, queryPoll,
+			p.Title,
+			p.Description,
+			p.Status,
+			timeArg(p.StartsAt),
+			timeArg(p.EndsAt),
+			p.CreatorID,
+		).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		queryOpt := `
+            INSERT INTO options (poll_id, text, position)
+            VALUES ($1, $2, $3)
+            RETURNING id, created_at
+        `
+
+		for i := range options {
+			options[i].PollID = p.ID
+			options[i].Position = i
+			if err := tx.QueryRowContext(ctx, queryOpt, options[i].PollID, options[i].Text, options[i].Position).
+				Scan(&options[i].ID, &options[i].CreatedAt); err != nil {
+				if isConstraint(err, constraintUnique) {
+					return poll.ErrDuplicateOption
+				}
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return p.ID, nil
+}
+
+func (r *PollRepo) GetByID(ctx context.Context, id int64) (*poll.Poll, []poll.Option, error) {
+	p := &poll.Poll{}
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `
+        SELECT id, title, description, status, starts_at, ends_at, creator_id, created_at, updated_at
+        FROM polls WHERE id = $1
+    `, id).Scan(
+		&p.ID, &p.Title, &p.Description, &p.Status,
+		&p.StartsAt, &p.EndsAt, &p.CreatorID, &p.CreatedAt, &p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, `
+        SELECT id, poll_id, text, position, created_at
+        FROM options WHERE poll_id = $1
+        ORDER BY position, id
+    `, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var opts []poll.Option
+	for rows.Next() {
+		var o poll.Option
+		if err := rows.Scan(&o.ID, &o.PollID, &o.Text, &o.Position, &o.CreatedAt); err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, o)
+	}
+
+	return p, opts, nil
+}
+
+func (r *PollRepo) List(ctx context.Context, status *string) ([]poll.Poll, error) {
+	query := `
+        SELECT id, title, description, status, starts_at, ends_at, creator_id, created_at, updated_at
+        FROM polls
+    `
+	var rows *sql.Rows
+	var err error
+
+	if status != nil {
+		query += " WHERE status = $1 ORDER BY created_at DESC, id DESC"
+		rows, err = database.Conn(ctx, r.db).QueryContext(ctx, query, *status)
+	} else {
+		query += " ORDER BY created_at DESC, id DESC"
+		rows, err = database.Conn(ctx, r.db).QueryContext(ctx, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []poll.Poll
+	for rows.Next() {
+		var p poll.Poll
+		if err := rows.Scan(&p.ID, &p.Title, &p.Description, &p.Status,
+			&p.StartsAt, &p.EndsAt, &p.CreatorID, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+func (r *PollRepo) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, `SELECT status, COUNT(*) FROM polls GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[string]int64)
+	for rows.Next() {
+		var status string
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		res[status] = n
+	}
+	return res, rows.Err()
+}
+
+func (r *PollRepo) UpdateStatus(ctx context.Context, id int64, status string) error {
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `UPDATE polls SET status = $1, updated_at = `+now+` WHERE id = $2`, status, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *PollRepo) Update(ctx context.Context, id int64, input poll.UpdateInput) error {
+	setParts := make([]string, 0, 4)
+	args := make([]any, 0, 5)
+	idx := 1
+
+	if input.Title != nil {
+		setParts = append(setParts, fmt.Sprintf("title = $%d", idx))
+		args = append(args, *input.Title)
+		idx++
+	}
+	if input.Description != nil {
+		setParts = append(setParts, fmt.Sprintf("description = $%d", idx))
+		args = append(args, *input.Description)
+		idx++
+	}
+	if input.StartsAt != nil {
+		setParts = append(setParts, fmt.Sprintf("starts_at = $%d", idx))
+		args = append(args, timeArg(input.StartsAt))
+		idx++
+	}
+	if input.EndsAt != nil {
+		setParts = append(setParts, fmt.Sprintf("ends_at = $%d", idx))
+		args = append(args, timeArg(input.EndsAt))
+		idx++
+	}
+
+	if len(setParts) == 0 {
+		return fmt.Errorf("no fields to update")
+	}
+
+	setParts = append(setParts, "updated_at = "+now)
+	query := fmt.Sprintf("UPDATE polls SET %s WHERE id = $%d", strings.Join(setParts, ", "), idx)
+	args = append(args, id)
+
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *PollRepo) Delete(ctx context.Context, id int64) error {
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `DELETE FROM polls WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+// Package sqlite implements the domain repositories on SQLite. It mirrors
+// the postgres package query for query; timestamps are stored as UTC text so
+// that they compare and sort lexically.
+package sqlite
+
+import (
+	"errors"
+	"time"
+
+	sqlite "github.com/glebarez/go-sqlite"
+)
+
+// Extended SQLite result codes for constraint violations.
+const (
+	constraintForeignKey = 787
+	constraintUnique     = 2067
+)
+
+// now is the SQL expression for the current time in the stored format.
+const now = "strftime('%Y-%m-%d %H:%M:%f', 'now')"
+
+const timeLayout = "2006-01-02 15:04:05.000"
+
+// timeArg converts an optional time into its stored representation.
+func timeArg(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(timeLayout)
+}
+
+// isConstraint reports whether err is a SQLite error with the given extended
+// result code.
+func isConstraint(err error, code int) bool {
+	var sqlErr *sqlite.Error
+	return errors.As(err, &sqlErr) && sqlErr.Code() == code
+}
@@ -0,0 +1,51 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"polling-system/internal/domain/user"
+	"polling-system/internal/platform/database"
+)
+
+type TokenRepo struct {
+	db *sql.DB
+}
+
+func NewTokenRepo(db *sql.DB) *TokenRepo {
+	return &TokenRepo{db: db}
+}
+
+func (r *TokenRepo) Create(ctx context.Context, t *user.Token) error {
+	query := `
+        INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, expires_at, created_at
+    `
+	t.UsedAt = nil
+	return database.Conn(ctx, r.db).QueryRowContext(ctx, query, t.UserID, t.Purpose, t.Hash, timeArg(&t.ExpiresAt)).
+		Scan(&t.ID, &t.ExpiresAt, &t.CreatedAt)
+}
+
+func (r *TokenRepo) Consume(ctx context.Context, purpose, hash string, at time.Time) (*user.Token, error) {
+	query := `
+        UPDATE user_tokens SET used_at = $1
+        WHERE token_hash = $2 AND purpose = $3 AND used_at IS NULL AND expires_at > $1
+        RETURNING id, user_id, purpose, token_hash, expires_at, used_at, created_at
+    `
+	t := &user.Token{}
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, query, timeArg(&at), hash, purpose).
+		Scan(&t.ID, &t.UserID, &t.Purpose, &t.Hash, &t.ExpiresAt, &t.UsedAt, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *TokenRepo) DeleteUnused(ctx context.Context, userID int64, purpose string) error {
+	_, err := database.Conn(ctx, r.db).ExecContext(ctx, `
+        DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+    `, userID, purpose)
+	return err
+}
@@ -0,0 +1,124 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"polling-system/internal/domain/user"
+	"polling-system/internal/platform/database"
+)
+
+type TwoFactorRepo struct {
+	db *sql.DB
+}
+
+func NewTwoFactorRepo(db *sql.DB) *TwoFactorRepo {
+	return &TwoFactorRepo{db: db}
+}
+
+func (r *TwoFactorRepo) Get(ctx context.Context, userID int64) (*user.TwoFactor, error) {
+	t := &user.TwoFactor{}
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `
+        SELECT user_id, secret, enabled_at, last_step, created_at FROM user_totp WHERE user_id = $1
+    `, userID).Scan(&t.UserID, &t.Secret, &t.EnabledAt, &t.LastStep, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *TwoFactorRepo) SavePending(ctx context.Context, t *user.TwoFactor) error {
+	// The conflict update is skipped for an enabled row, which then returns
+	// no row.
+	query := `
+        INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+        ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_step = 0, created_at = ` + now + `
+        WHERE user_totp.enabled_at IS NULL
+        RETURNING created_at
+    `
+	t.EnabledAt, t.LastStep = nil, 0
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, query, t.UserID, t.Secret).Scan(&t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user.ErrTwoFactorEnabled
+	}
+	return err
+}
+
+func (r *TwoFactorRepo) Enable(ctx context.Context, userID, step int64, at time.Time) error {
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `
+        UPDATE user_totp SET enabled_at = $1, last_step = $2 WHERE user_id = $3 AND enabled_at IS NULL
+    `, timeArg(&at), step, userID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *TwoFactorRepo) UseStep(ctx context.Context, userID, step int64) error {
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `
+        UPDATE user_totp SET last_step = $1 WHERE user_id = $2 AND enabled_at IS NOT NULL AND last_step < $1
+    `, step, userID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *TwoFactorRepo) Delete(ctx context.Context, userID int64) error {
+	conn := database.Conn(ctx, r.db)
+	if _, err := conn.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	res, err := conn.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *TwoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error {
+	conn := database.Conn(ctx, r.db)
+	if _, err := conn.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		if _, err := conn.ExecContext(ctx, `
+            INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)
+        `, userID, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *TwoFactorRepo) UseRecoveryCode(ctx context.Context, userID int64, hash string, at time.Time) error {
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `
+        UPDATE user_recovery_codes SET used_at = $1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+    `, timeArg(&at), userID, hash)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *TwoFactorRepo) CountRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	var n int
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL
+    `, userID).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,127 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"polling-system/internal/domain/user"
+	"polling-system/internal/platform/database"
+)
+
+type UserRepo struct {
+	db *sql.DB
+}
+
+func NewUserRepo(db *sql.DB) *UserRepo {
+	return &UserRepo{db: db}
+}
+
+func (r *UserRepo) Create(ctx context.Context, u *user.User) error {
+	query := `
+        INSERT INTO users (email, password_hash, role)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at, is_active
+    `
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, query, u.Email, u.PasswordHash, u.Role).
+		Scan(&u.ID, &u.CreatedAt, &u.IsActive)
+	if isConstraint(err, constraintUnique) {
+		return user.ErrEmailTaken
+	}
+	return err
+}
+
+func (r *UserRepo) GetByEmail(ctx context.Context, email string) (*user.User, error) {
+	query := `
+        SELECT id, email, password_hash, role, created_at, is_active, email_verified_at
+        FROM users WHERE email = $1
+    `
+	u := &user.User{}
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, query, email).
+		Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.IsActive, &u.EmailVerifiedAt)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (r *UserRepo) GetByID(ctx context.Context, id int64) (*user.User, error) {
+	query := `
+        SELECT id, email, password_hash, role, created_at, is_active, email_verified_at
+        FROM users WHERE id = $1
+    `
+	u := &user.User{}
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, query, id).
+		Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.IsActive, &u.EmailVerifiedAt)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (r *UserRepo) List(ctx context.Context) ([]user.User, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, `
+        SELECT id, email, password_hash, role, created_at, is_active, email_verified_at
+        FROM users ORDER BY id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usersList []user.User
+	for rows.Next() {
+		var u user.User
+		if err := rows.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.IsActive, &u.EmailVerifiedAt); err != nil {
+			return nil, err
+		}
+		usersList = append(usersList, u)
+	}
+	return usersList, nil
+}
+
+func (r *UserRepo) UpdateRole(ctx context.Context, id int64, role string) error {
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `UPDATE users SET role = $1 WHERE id = $2`, role, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *UserRepo) Deactivate(ctx context.Context, id int64) error {
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `UPDATE users SET is_active = FALSE WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *UserRepo) SetPassword(ctx context.Context, id int64, passwordHash string) error {
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `UPDATE users SET password_hash = $1 WHERE id = $2`, passwordHash, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *UserRepo) MarkEmailVerified(ctx context.Context, id int64, at time.Time) error {
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx, `
+        UPDATE users SET email_verified_at = COALESCE(email_verified_at, $1) WHERE id = $2
+    `, timeArg(&at), id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
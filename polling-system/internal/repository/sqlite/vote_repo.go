@@ -0,0 +1,254 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"polling-system/internal/domain/vote"
+	"polling-system/internal/platform/database"
+)
+
+// bucketFormats truncate a stored timestamp to the start of a bucket with
+// strftime, the SQLite counterpart of Postgres date_trunc.
+var bucketFormats = map[string]string{
+	vote.BucketMinute: "%Y-%m-%d %H:%M:00",
+	vote.BucketHour:   "%Y-%m-%d %H:00:00",
+	vote.BucketDay:    "%Y-%m-%d 00:00:00",
+}
+
+type VoteRepo struct {
+	db *sql.DB
+}
+
+func NewVoteRepo(db *sql.DB) *VoteRepo {
+	return &VoteRepo{db: db}
+}
+
+func (r *VoteRepo) Create(ctx context.Context, v *vote.Vote) error {
+	query := `
+        INSERT INTO votes (poll_id, option_id, user_id)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at
+    `
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, query, v.PollID, v.OptionID, v.UserID).
+		Scan(&v.ID, &v.CreatedAt)
+	if err != nil {
+		return r.mapVoteError(ctx, err, v)
+	}
+	return nil
+}
+
+func (r *VoteRepo) CountByPoll(ctx context.Context, pollID int64) (map[int64]int64, int64, error) {
+	return r.countByOption(ctx, `
+        SELECT option_id, COUNT(*)
+        FROM votes
+        WHERE poll_id = $1
+        GROUP BY option_id
+    `, pollID)
+}
+
+func (r *VoteRepo) AggregatedByPoll(ctx context.Context, pollID int64) (map[int64]int64, int64, error) {
+	return r.countByOption(ctx, `
+        SELECT option_id, votes_count
+        FROM aggregated_results
+        WHERE poll_id = $1
+    `, pollID)
+}
+
+func (r *VoteRepo) countByOption(ctx context.Context, query string, pollID int64) (map[int64]int64, int64, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, query, pollID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	res := make(map[int64]int64)
+	var total int64
+	for rows.Next() {
+		var optID int64
+		var c int64
+		if err := rows.Scan(&optID, &c); err != nil {
+			return nil, 0, err
+		}
+		res[optID] = c
+		total += c
+	}
+	return res, total, rows.Err()
+}
+
+func (r *VoteRepo) IncrementAggregated(ctx context.Context, pollID, optionID int64) error {
+	_, err := database.Conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO aggregated_results (poll_id, option_id, votes_count)
+        VALUES ($1, $2, 1)
+        ON CONFLICT (poll_id, option_id) DO UPDATE
+        SET votes_count = aggregated_results.votes_count + 1,
+            updated_at = `+now, pollID, optionID)
+	return err
+}
+
+func (r *VoteRepo) AddAggregated(ctx context.Context, deltas []vote.AggregateDelta) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	values, args := aggregateValues(deltas)
+	_, err := database.Conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO aggregated_results (poll_id, option_id, votes_count)
+        VALUES `+values+`
+        ON CONFLICT (poll_id, option_id) DO UPDATE
+        SET votes_count = aggregated_results.votes_count + excluded.votes_count,
+            updated_at = `+now, args...)
+	return err
+}
+
+func (r *VoteRepo) GetPollStatus(ctx context.Context, pollID int64) (string, error) {
+	var status string
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `SELECT status FROM polls WHERE id = $1`, pollID).Scan(&status)
+	return status, err
+}
+
+// LockPollStatus needs no row lock: transactions begin IMMEDIATE, so the
+// database write lock is already held.
+func (r *VoteRepo) LockPollStatus(ctx context.Context, pollID int64) (string, error) {
+	return r.GetPollStatus(ctx, pollID)
+}
+
+func (r *VoteRepo) ListOptions(ctx context.Context, pollID int64) ([]vote.OptionInfo, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, `
+        SELECT id, text, position
+        FROM options
+        WHERE poll_id = $1
+        ORDER BY position, id
+    `, pollID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []vote.OptionInfo
+	for rows.Next() {
+		var o vote.OptionInfo
+		if err := rows.Scan(&o.ID, &o.Text, &o.Position); err != nil {
+			return nil, err
+		}
+		res = append(res, o)
+	}
+	return res, rows.Err()
+}
+
+func (r *VoteRepo) VotesTimeSeries(ctx context.Context, pollID int64, bucket string) ([]vote.BucketCount, error) {
+	return r.queryTimeSeries(ctx, `
+        SELECT strftime($2, created_at) AS bucket, option_id, COUNT(*)
+        FROM votes
+        WHERE poll_id = $1
+        GROUP BY 1, 2
+        ORDER BY 1, 2
+    `, pollID, bucket)
+}
+
+func (r *VoteRepo) RollupTimeSeries(ctx context.Context, pollID int64, bucket string) ([]vote.BucketCount, error) {
+	return r.queryTimeSeries(ctx, `
+        SELECT strftime($2, bucket_start) AS bucket, option_id, SUM(votes_count)
+        FROM vote_rollups
+        WHERE poll_id = $1
+        GROUP BY 1, 2
+        ORDER BY 1, 2
+    `, pollID, bucket)
+}
+
+func (r *VoteRepo) queryTimeSeries(ctx context.Context, query string, pollID int64, bucket string) ([]vote.BucketCount, error) {
+	format, ok := bucketFormats[bucket]
+	if !ok {
+		return nil, vote.ErrInvalidBucket
+	}
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, query, pollID, format)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []vote.BucketCount
+	for rows.Next() {
+		var (
+			c      vote.BucketCount
+			bucket string
+		)
+		if err := rows.Scan(&bucket, &c.OptionID, &c.Votes); err != nil {
+			return nil, err
+		}
+		if c.Bucket, err = time.ParseInLocation(time.DateTime, bucket, time.UTC); err != nil {
+			return nil, fmt.Errorf("parse bucket %q: %w", bucket, err)
+		}
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
+
+func (r *VoteRepo) CountEligibleVoters(ctx context.Context) (int64, error) {
+	var n int64
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE is_active`).Scan(&n)
+	return n, err
+}
+
+// RefreshRollups recounts the per-minute vote buckets that may have changed
+// since the last refresh. Everything from a minute before the newest
+// rolled-up bucket is re-scanned, and always at least the last 15 minutes, so
+// that votes committed late are picked up whichever poll took the newest
+// vote.
+func (r *VoteRepo) RefreshRollups(ctx context.Context) error {
+	_, err := database.Conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO vote_rollups (poll_id, option_id, bucket_start, votes_count)
+        SELECT poll_id, option_id, strftime('%Y-%m-%d %H:%M:00', created_at), COUNT(*)
+        FROM votes
+        WHERE created_at >= COALESCE(
+            MIN(
+                (SELECT strftime('%Y-%m-%d %H:%M:00', MAX(bucket_start), '-1 minute') FROM vote_rollups),
+                strftime('%Y-%m-%d %H:%M:%f', 'now', '-15 minutes')
+            ),
+            ''
+        )
+        GROUP BY 1, 2, 3
+        ON CONFLICT (poll_id, option_id, bucket_start) DO UPDATE
+        SET votes_count = excluded.votes_count,
+            updated_at = `+now)
+	return err
+}
+
+// mapVoteError translates constraint violations into domain errors. SQLite
+// does not name the foreign key that failed, so the poll and option are
+// looked up to tell which one is missing.
+func (r *VoteRepo) mapVoteError(ctx context.Context, err error, v *vote.Vote) error {
+	switch {
+	case isConstraint(err, constraintUnique):
+		return vote.ErrAlreadyVoted
+	case isConstraint(err, constraintForeignKey):
+		var pollExists, optionInPoll bool
+		if qErr := database.Conn(ctx, r.db).QueryRowContext(ctx, `
+            SELECT EXISTS (SELECT 1 FROM polls WHERE id = $1),
+                   EXISTS (SELECT 1 FROM options WHERE id = $2 AND poll_id = $1)
+        `, v.PollID, v.OptionID).Scan(&pollExists, &optionInPoll); qErr != nil {
+			return err
+		}
+		switch {
+		case !pollExists:
+			return vote.ErrPollNotFound
+		case !optionInPoll:
+			return vote.ErrOptionNotInPoll
+		}
+	}
+	return err
+}
+
+// aggregateValues renders deltas as the placeholders and arguments of a
+// multi-row VALUES list.
+func aggregateValues(deltas []vote.AggregateDelta) (string, []any) {
+	rows := make([]string, len(deltas))
+	args := make([]any, 0, 3*len(deltas))
+	for i, d := range deltas {
+		rows[i] = fmt.Sprintf("($%d, $%d, $%d)", 3*i+1, 3*i+2, 3*i+3)
+		args = append(args, d.PollID, d.OptionID, d.Votes)
+	}
+	return strings.Join(rows, ", "), args
+}
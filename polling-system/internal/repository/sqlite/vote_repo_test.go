@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"polling-system/internal/config"
+	"polling-system/internal/db/migrations"
+	"polling-system/internal/domain/poll"
+	"polling-system/internal/domain/user"
+	"polling-system/internal/domain/vote"
+	"polling-system/internal/platform/database"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := database.NewSQLite(config.DBConfig{DSN: "sqlite::memory:"})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrations.New(db, database.SQLite, nil)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func seedPoll(t *testing.T, db *sql.DB) (userID int64, p *poll.Poll, opts []poll.Option) {
+	t.Helper()
+	ctx := context.Background()
+	u := &user.User{Email: "voter@test.com", PasswordHash: "x", Role: "user"}
+	if err := NewUserRepo(db).Create(ctx, u); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	p = &poll.Poll{Title: "Lunch", Status: "active", CreatorID: u.ID}
+	opts = []poll.Option{{Text: "pizza"}, {Text: "sushi"}}
+	if _, err := NewPollRepo(db).Create(ctx, p, opts); err != nil {
+		t.Fatalf("create poll: %v", err)
+	}
+	return u.ID, p, opts
+}
+
+func TestVoteErrorMapping(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	userID, p, opts := seedPoll(t, db)
+	repo := NewVoteRepo(db)
+
+	if err := repo.Create(ctx, &vote.Vote{PollID: p.ID, OptionID: opts[0].ID, UserID: userID}); err != nil {
+		t.Fatalf("first vote: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		v    vote.Vote
+		want error
+	}{
+		{"duplicate", vote.Vote{PollID: p.ID, OptionID: opts[1].ID, UserID: userID}, vote.ErrAlreadyVoted},
+		{"missing poll", vote.Vote{PollID: p.ID + 100, OptionID: opts[0].ID, UserID: userID}, vote.ErrPollNotFound},
+		{"foreign option", vote.Vote{PollID: p.ID, OptionID: opts[1].ID + 100, UserID: userID + 1}, vote.ErrOptionNotInPoll},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := tc.v
+			if err := repo.Create(ctx, &v); !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestTimeSeriesAndRollups(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	userID, p, opts := seedPoll(t, db)
+	repo := NewVoteRepo(db)
+
+	if _, err := db.Exec(`
+        INSERT INTO votes (poll_id, option_id, user_id, created_at)
+        VALUES ($1, $2, $3, '2026-03-01 10:15:30.000')
+    `, p.ID, opts[0].ID, userID); err != nil {
+		t.Fatalf("insert vote: %v", err)
+	}
+
+	series, err := repo.VotesTimeSeries(ctx, p.ID, vote.BucketHour)
+	if err != nil {
+		t.Fatalf("time series: %v", err)
+	}
+	if len(series) != 1 || series[0].Votes != 1 || series[0].Bucket.Format("2006-01-02 15:04") != "2026-03-01 10:00" {
+		t.Fatalf("unexpected series %+v", series)
+	}
+
+	if err := repo.RefreshRollups(ctx); err != nil {
+		t.Fatalf("refresh rollups: %v", err)
+	}
+	rolled, err := repo.RollupTimeSeries(ctx, p.ID, vote.BucketDay)
+	if err != nil {
+		t.Fatalf("rollup series: %v", err)
+	}
+	if len(rolled) != 1 || rolled[0].Votes != 1 || rolled[0].Bucket.Format("2006-01-02 15:04") != "2026-03-01 00:00" {
+		t.Fatalf("unexpected rollup series %+v", rolled)
+	}
+
+	if _, err := repo.VotesTimeSeries(ctx, p.ID, "week"); !errors.Is(err, vote.ErrInvalidBucket) {
+		t.Fatalf("expected invalid bucket error, got %v", err)
+	}
+}
+
+func TestRollupsPickUpLateVotesOfOtherPolls(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	userID, busy, busyOpts := seedPoll(t, db)
+	quiet := &poll.Poll{Title: "Dinner", Status: "active", CreatorID: userID}
+	quietOpts := []poll.Option{{Text: "soup"}, {Text: "salad"}}
+	if _, err := NewPollRepo(db).Create(ctx, quiet, quietOpts); err != nil {
+		t.Fatalf("create poll: %v", err)
+	}
+	repo := NewVoteRepo(db)
+
+	if err := repo.Create(ctx, &vote.Vote{PollID: busy.ID, OptionID: busyOpts[0].ID, UserID: userID}); err != nil {
+		t.Fatalf("vote: %v", err)
+	}
+	if err := repo.RefreshRollups(ctx); err != nil {
+		t.Fatalf("refresh rollups: %v", err)
+	}
+
+	// A vote for the other poll commits a few minutes after it was cast,
+	// behind the newest rolled-up bucket.
+	if _, err := db.Exec(`
+        INSERT INTO votes (poll_id, option_id, user_id, created_at)
+        VALUES ($1, $2, $3, strftime('%Y-%m-%d %H:%M:%f', 'now', '-5 minutes'))
+    `, quiet.ID, quietOpts[0].ID, userID); err != nil {
+		t.Fatalf("insert vote: %v", err)
+	}
+	if err := repo.RefreshRollups(ctx); err != nil {
+		t.Fatalf("refresh rollups: %v", err)
+	}
+
+	rolled, err := repo.RollupTimeSeries(ctx, quiet.ID, vote.BucketDay)
+	if err != nil {
+		t.Fatalf("rollup series: %v", err)
+	}
+	var total int64
+	for _, b := range rolled {
+		total += b.Votes
+	}
+	if total != 1 {
+		t.Fatalf("expected the late vote to be rolled up, got %+v", rolled)
+	}
+}
@@ -0,0 +1,150 @@
+package retry
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned while a Breaker refuses calls.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// State is the position of a Breaker.
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+	// Open refuses calls until the cooldown has passed.
+	Open
+	// HalfOpen lets a single trial call through to probe for recovery.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker stops calls to a dependency after threshold consecutive failures.
+// Once cooldown has passed it lets one trial call through: success closes it
+// again, failure reopens it for another cooldown. It is safe for concurrent
+// use.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	onChange  func(from, to State)
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewBreaker builds a closed Breaker. onChange, if not nil, is called after
+// every state transition; it must not call back into the Breaker.
+func NewBreaker(threshold int, cooldown time.Duration, onChange func(from, to State)) *Breaker {
+	return &Breaker{
+		threshold: max(threshold, 1),
+		cooldown:  cooldown,
+		onChange:  onChange,
+		now:       time.Now,
+	}
+}
+
+// State returns the current state, moving an expired Open breaker to
+// HalfOpen.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	from := b.state
+	b.expire()
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+	return to
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be
+// followed by Record or Release.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	from := b.state
+	b.expire()
+	to := b.state
+	var err error
+	switch {
+	case to == Open, to == HalfOpen && b.trial:
+		err = ErrCircuitOpen
+	case to == HalfOpen:
+		b.trial = true
+	}
+	b.mu.Unlock()
+	b.notify(from, to)
+	return err
+}
+
+// Record reports the outcome of an allowed call.
+func (b *Breaker) Record(ok bool) {
+	b.mu.Lock()
+	from := b.state
+	switch b.state {
+	case Closed:
+		if ok {
+			b.failures = 0
+		} else if b.failures++; b.failures >= b.threshold {
+			b.open()
+		}
+	case HalfOpen:
+		b.trial = false
+		if ok {
+			b.state = Closed
+			b.failures = 0
+		} else {
+			b.open()
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+// Release ends an allowed call whose outcome says nothing about the
+// dependency, such as one cancelled by its caller. The failure count is left
+// alone, and a HalfOpen breaker lets another trial call through.
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == HalfOpen {
+		b.trial = false
+	}
+}
+
+func (b *Breaker) open() {
+	b.state = Open
+	b.openedAt = b.now()
+	b.trial = false
+}
+
+// expire moves an Open breaker whose cooldown has passed to HalfOpen. The
+// caller holds b.mu.
+func (b *Breaker) expire() {
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.cooldown {
+		b.state = HalfOpen
+	}
+}
+
+func (b *Breaker) notify(from, to State) {
+	if from != to && b.onChange != nil {
+		b.onChange(from, to)
+	}
+}
@@ -0,0 +1,120 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	now := time.Unix(0, 0)
+	var transitions []string
+	b := NewBreaker(2, time.Minute, func(from, to State) {
+		transitions = append(transitions, from.String()+">"+to.String())
+	})
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("closed breaker must allow calls: %v", err)
+		}
+		b.Record(false)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected open breaker, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expected a trial call after the cooldown: %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("only one trial call may run while half-open, got %v", err)
+	}
+	b.Record(false)
+	if b.State() != Open {
+		t.Fatalf("failed trial must reopen the breaker, got %v", b.State())
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expected a second trial: %v", err)
+	}
+	b.Record(true)
+	if b.State() != Closed {
+		t.Fatalf("successful trial must close the breaker, got %v", b.State())
+	}
+
+	want := []string{"closed>open", "open>half-open", "half-open>open", "open>half-open", "half-open>closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("expected transitions %v, got %v", want, transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("expected transitions %v, got %v", want, transitions)
+		}
+	}
+}
+
+func TestDoRespectsBreaker(t *testing.T) {
+	b := NewBreaker(2, time.Hour, nil)
+	p := Policy{MaxAttempts: 5, Breaker: b}
+
+	calls := 0
+	err := Do(context.Background(), p, func(context.Context) error {
+		calls++
+		return errTransient
+	})
+	if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, errTransient) || calls != 2 {
+		t.Fatalf("expected the breaker to stop retries after 2 calls, calls=%d err=%v", calls, err)
+	}
+
+	err = Do(context.Background(), p, func(context.Context) error {
+		t.Fatalf("open breaker must not let calls through")
+		return nil
+	})
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+}
+
+func TestBreakerIgnoresNonRetryableErrors(t *testing.T) {
+	b := NewBreaker(1, time.Hour, nil)
+	p := Policy{MaxAttempts: 1, Breaker: b, Retryable: func(err error) bool { return errors.Is(err, errTransient) }}
+	for i := 0; i < 3; i++ {
+		_ = Do(context.Background(), p, func(context.Context) error { return errors.New("bad request") })
+	}
+	if b.State() != Closed {
+		t.Fatalf("errors that are not transient must not open the breaker")
+	}
+}
+
+func TestBreakerIgnoresContextErrors(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewBreaker(2, time.Minute, nil)
+	b.now = func() time.Time { return now }
+	p := Policy{MaxAttempts: 1, Breaker: b, Retryable: func(err error) bool { return errors.Is(err, errTransient) }}
+	run := func(err error) {
+		_ = Do(context.Background(), p, func(context.Context) error { return err })
+	}
+
+	// A cancelled call between two failures does not reset the count.
+	run(errTransient)
+	run(context.Canceled)
+	run(errTransient)
+	if b.State() != Open {
+		t.Fatalf("expected the breaker to open, got %v", b.State())
+	}
+
+	// A trial call that times out leaves room for another trial.
+	now = now.Add(time.Minute)
+	run(fmt.Errorf("query: %w", context.DeadlineExceeded))
+	if b.State() != HalfOpen {
+		t.Fatalf("expected the breaker to stay half-open, got %v", b.State())
+	}
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expected another trial call, got %v", err)
+	}
+}
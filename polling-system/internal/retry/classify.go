@@ -0,0 +1,84 @@
+package retry
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	sqlite "github.com/glebarez/go-sqlite"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SQLite primary result codes for a locked database.
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+// IsTransient reports whether err is likely to go away on its own: a
+// serialization failure, deadlock or lock timeout, a dropped or refused
+// connection, a server that is restarting or out of connections, a network
+// timeout, or a busy SQLite database. Cancellation by the caller is never
+// transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case strings.HasPrefix(pgErr.Code, "08"): // connection_exception
+			return true
+		case strings.HasPrefix(pgErr.Code, "40"): // transaction_rollback
+			return true
+		}
+		switch pgErr.Code {
+		case "53300", // too_many_connections
+			"55P03", // lock_not_available
+			"57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		return false
+	}
+
+	if IsSerializationFailure(err) {
+		return true
+	}
+
+	if pgconn.SafeToRetry(err) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsSerializationFailure reports whether err aborted a transaction only
+// because it conflicted with a concurrent one, so running it again may
+// succeed. Unlike IsTransient it leaves out connection errors, after which a
+// commit may or may not have happened.
+func IsSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+	var sqlErr *sqlite.Error
+	if errors.As(err, &sqlErr) {
+		code := sqlErr.Code() & 0xff
+		return code == sqliteBusy || code == sqliteLocked
+	}
+	return false
+}
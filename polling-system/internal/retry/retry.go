@@ -1,37 +1,224 @@
+// Package retry runs operations that may fail transiently. A Policy decides
+// how often and how long to wait between attempts, which errors are worth
+// retrying, and whether a circuit Breaker may short-circuit the call.
 package retry
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
 	"time"
 )
 
-// DoWithRetry executes fn up to attempts times with exponential backoff.
-// It stops early if the context is canceled.
-func DoWithRetry(ctx context.Context, attempts int, baseDelay time.Duration, fn func() error) error {
-	var err error
-	delay := baseDelay
+// Jitter selects how backoff delays are randomized.
+type Jitter int
 
-	for i := 0; i < attempts; i++ {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+const (
+	// NoJitter waits exactly BaseDelay * Multiplier^(attempt-1).
+	NoJitter Jitter = iota
+	// FullJitter waits a random duration between zero and the exponential
+	// delay.
+	FullJitter
+	// DecorrelatedJitter waits a random duration between BaseDelay and three
+	// times the previous delay.
+	DecorrelatedJitter
+)
+
+// ParseJitter parses "none", "full" or "decorrelated".
+func ParseJitter(s string) (Jitter, error) {
+	switch s {
+	case "none":
+		return NoJitter, nil
+	case "full":
+		return FullJitter, nil
+	case "decorrelated":
+		return DecorrelatedJitter, nil
+	}
+	return 0, fmt.Errorf("unknown jitter %q", s)
+}
+
+// Hooks observe a retry loop. Either field may be nil.
+type Hooks struct {
+	// OnRetry is called before waiting delay to make attempt+1.
+	OnRetry func(attempt int, err error, delay time.Duration)
+	// OnGiveUp is called when a retryable error is returned because attempts,
+	// the budget or the breaker ran out.
+	OnGiveUp func(attempts int, err error)
+}
+
+// Policy configures Do. The zero value makes a single attempt.
+type Policy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	// MaxDelay caps a single wait; zero means no cap.
+	MaxDelay time.Duration
+	// Multiplier grows the delay between attempts; values <= 1 mean 2.
+	Multiplier float64
+	Jitter     Jitter
+	// Budget bounds the whole call, waits included. Do never starts a wait
+	// that would end after the budget or the context deadline.
+	Budget time.Duration
+	// Retryable reports whether an error may succeed on a later attempt.
+	// Nil retries every error.
+	Retryable func(err error) bool
+	// Breaker, if set, is consulted before every attempt and told about
+	// every outcome.
+	Breaker *Breaker
+	Hooks   Hooks
+}
+
+// permanentError marks an error that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that Do returns it immediately instead of trying
+// again, whatever the policy's classifier says. Do returns the original,
+// unwrapped error.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Do calls fn until it succeeds, returns an error that is not retryable, or
+// the policy runs out. It returns the last error from fn, joined with
+// ErrCircuitOpen if the breaker stopped it, or ctx.Err() if the caller's
+// context ends while waiting.
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context) error) error {
+	attempts := max(p.MaxAttempts, 1)
+	if p.Budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Budget)
+		defer cancel()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var (
+		err   error
+		delay time.Duration
+	)
+	for attempt := 1; ; attempt++ {
+		if p.Breaker != nil {
+			if berr := p.Breaker.Allow(); berr != nil {
+				if err == nil {
+					return berr
+				}
+				p.giveUp(attempt-1, err)
+				return errors.Join(berr, err)
+			}
 		}
 
-		if err = fn(); err == nil {
+		err = fn(ctx)
+		if err == nil {
+			p.record(true, nil)
 			return nil
 		}
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			p.record(true, err)
+			return perm.err
+		}
+		if p.Retryable != nil && !p.Retryable(err) {
+			// The dependency answered; the request itself was bad.
+			p.record(true, err)
+			return err
+		}
+		p.record(false, err)
 
-		if i == attempts-1 {
-			break
+		if attempt >= attempts {
+			p.giveUp(attempt, err)
+			return err
+		}
+		delay = p.backoff(attempt, delay)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			p.giveUp(attempt, err)
+			return err
+		}
+		if p.Hooks.OnRetry != nil {
+			p.Hooks.OnRetry(attempt, err, delay)
 		}
 
+		timer := time.NewTimer(delay)
 		select {
 		case <-ctx.Done():
+			timer.Stop()
 			return ctx.Err()
-		case <-time.After(delay):
+		case <-timer.C:
+		}
+	}
+}
+
+// DoWithRetry executes fn up to attempts times with exponential backoff and
+// no jitter, retrying every error that is not Permanent.
+func DoWithRetry(ctx context.Context, attempts int, baseDelay time.Duration, fn func() error) error {
+	p := Policy{MaxAttempts: attempts, BaseDelay: baseDelay}
+	return Do(ctx, p, func(context.Context) error { return fn() })
+}
+
+// backoff returns the wait before attempt+1, given the previous wait.
+func (p Policy) backoff(attempt int, prev time.Duration) time.Duration {
+	var d time.Duration
+	if p.Jitter == DecorrelatedJitter {
+		lo := p.BaseDelay
+		hi := max(prev, lo)
+		if hi > math.MaxInt64/3 {
+			hi = math.MaxInt64
+		} else {
+			hi *= 3
 		}
-		delay *= 2
+		d = lo
+		if hi > lo {
+			d += rand.N(hi - lo)
+		}
+	} else {
+		mult := p.Multiplier
+		if mult <= 1 {
+			mult = 2
+		}
+		f := float64(p.BaseDelay) * math.Pow(mult, float64(attempt-1))
+		if f >= math.MaxInt64 {
+			d = math.MaxInt64
+		} else {
+			d = time.Duration(f)
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter == FullJitter && d > 0 {
+		// Without MaxDelay, d saturates at math.MaxInt64 and d+1 would
+		// overflow.
+		d = rand.N(min(d, math.MaxInt64-1) + 1)
+	}
+	return d
+}
+
+// record tells the breaker the outcome of an attempt that returned err. A
+// cancelled or timed out attempt says nothing about the dependency, so it is
+// released instead of counted either way.
+func (p Policy) record(ok bool, err error) {
+	switch {
+	case p.Breaker == nil:
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		p.Breaker.Release()
+	default:
+		p.Breaker.Record(ok)
+	}
+}
+
+func (p Policy) giveUp(attempts int, err error) {
+	if p.Hooks.OnGiveUp != nil {
+		p.Hooks.OnGiveUp(attempts, err)
 	}
-	return err
 }
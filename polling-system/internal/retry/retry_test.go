@@ -0,0 +1,190 @@
+package retry
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var errTransient = errors.New("transient")
+
+func TestDoStopsOnNonRetryableErrors(t *testing.T) {
+	calls := 0
+	bad := errors.New("bad request")
+	p := Policy{MaxAttempts: 5, Retryable: func(err error) bool { return errors.Is(err, errTransient) }}
+	err := Do(context.Background(), p, func(context.Context) error {
+		calls++
+		if calls < 2 {
+			return errTransient
+		}
+		return bad
+	})
+	if !errors.Is(err, bad) || calls != 2 {
+		t.Fatalf("expected to stop at the first non-retryable error, calls=%d err=%v", calls, err)
+	}
+
+	calls = 0
+	err = Do(context.Background(), Policy{MaxAttempts: 5}, func(context.Context) error {
+		calls++
+		return Permanent(bad)
+	})
+	if err != bad || calls != 1 {
+		t.Fatalf("permanent errors must be returned unwrapped at once, calls=%d err=%v", calls, err)
+	}
+}
+
+func TestDoHooksAndExhaustion(t *testing.T) {
+	var delays []time.Duration
+	gaveUp := 0
+	p := Policy{
+		MaxAttempts: 4,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    3 * time.Millisecond,
+		Hooks: Hooks{
+			OnRetry:  func(attempt int, err error, delay time.Duration) { delays = append(delays, delay) },
+			OnGiveUp: func(attempts int, err error) { gaveUp = attempts },
+		},
+	}
+	err := Do(context.Background(), p, func(context.Context) error { return errTransient })
+	if !errors.Is(err, errTransient) {
+		t.Fatalf("expected last error, got %v", err)
+	}
+	want := []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}
+	if fmt.Sprint(delays) != fmt.Sprint(want) {
+		t.Fatalf("expected capped exponential delays %v, got %v", want, delays)
+	}
+	if gaveUp != 4 {
+		t.Fatalf("expected OnGiveUp after 4 attempts, got %d", gaveUp)
+	}
+}
+
+func TestDoRespectsBudget(t *testing.T) {
+	calls := 0
+	start := time.Now()
+	p := Policy{MaxAttempts: 100, BaseDelay: 20 * time.Millisecond, Multiplier: 1.5, Budget: 50 * time.Millisecond}
+	err := Do(context.Background(), p, func(ctx context.Context) error {
+		calls++
+		if _, ok := ctx.Deadline(); !ok {
+			t.Errorf("attempts must run under the budget deadline")
+		}
+		return errTransient
+	})
+	if !errors.Is(err, errTransient) {
+		t.Fatalf("expected the last error when the budget runs out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("budget exceeded: %v", elapsed)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 attempts within the budget, got %d", calls)
+	}
+}
+
+func TestDoCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	err := Do(ctx, Policy{MaxAttempts: 3}, func(context.Context) error {
+		calls++
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || calls != 0 {
+		t.Fatalf("expected no attempt on a canceled context, calls=%d err=%v", calls, err)
+	}
+}
+
+func TestBackoffJitterBounds(t *testing.T) {
+	full := Policy{BaseDelay: 10 * time.Millisecond, MaxDelay: 100 * time.Millisecond, Jitter: FullJitter}
+	dec := Policy{BaseDelay: 10 * time.Millisecond, MaxDelay: 100 * time.Millisecond, Jitter: DecorrelatedJitter}
+	var prev time.Duration
+	for i := 0; i < 1000; i++ {
+		attempt := i%8 + 1
+		if d := full.backoff(attempt, 0); d < 0 || d > 100*time.Millisecond {
+			t.Fatalf("full jitter out of range: %v", d)
+		}
+		d := dec.backoff(attempt, prev)
+		if d < 10*time.Millisecond || d > 100*time.Millisecond || d > max(prev, 10*time.Millisecond)*3 {
+			t.Fatalf("decorrelated jitter out of range: %v after %v", d, prev)
+		}
+		prev = d
+	}
+}
+
+func TestBackoffWithoutMaxDelaySaturates(t *testing.T) {
+	full := Policy{BaseDelay: time.Second, Jitter: FullJitter}
+	dec := Policy{BaseDelay: time.Second, Jitter: DecorrelatedJitter}
+	for _, attempt := range []int{64, 1000, math.MaxInt} {
+		if d := full.backoff(attempt, 0); d < 0 {
+			t.Fatalf("full jitter at attempt %d: got %v", attempt, d)
+		}
+		if d := dec.backoff(attempt, math.MaxInt64); d <= time.Second {
+			t.Fatalf("decorrelated jitter at attempt %d: got %v", attempt, d)
+		}
+	}
+	if d := (Policy{BaseDelay: time.Second}).backoff(1000, 0); d != math.MaxInt64 {
+		t.Fatalf("expected the delay to saturate, got %v", d)
+	}
+}
+
+func TestParseJitter(t *testing.T) {
+	for s, want := range map[string]Jitter{"none": NoJitter, "full": FullJitter, "decorrelated": DecorrelatedJitter} {
+		if got, err := ParseJitter(s); err != nil || got != want {
+			t.Fatalf("ParseJitter(%q) = %v, %v", s, got, err)
+		}
+	}
+	if _, err := ParseJitter("random"); err == nil {
+		t.Fatalf("expected unknown jitter to be rejected")
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&pgconn.PgError{Code: "40001"}, true},
+		{&pgconn.PgError{Code: "40P01"}, true},
+		{&pgconn.PgError{Code: "08006"}, true},
+		{&pgconn.PgError{Code: "57P01"}, true},
+		{fmt.Errorf("insert: %w", &pgconn.PgError{Code: "40001"}), true},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{&pgconn.PgError{Code: "23503"}, false},
+		{driver.ErrBadConn, true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{syscall.ECONNREFUSED, true},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, false},
+		{errors.New("boom"), false},
+		{nil, false},
+	}
+	for _, tc := range cases {
+		if got := IsTransient(tc.err); got != tc.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestIsSerializationFailure(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&pgconn.PgError{Code: "40001"}, true},
+		{fmt.Errorf("commit: %w", &pgconn.PgError{Code: "40P01"}), true},
+		{&pgconn.PgError{Code: "08006"}, false},
+		{driver.ErrBadConn, false},
+		{errors.New("boom"), false},
+	}
+	for _, tc := range cases {
+		if got := IsSerializationFailure(tc.err); got != tc.want {
+			t.Errorf("IsSerializationFailure(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
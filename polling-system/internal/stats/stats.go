@@ -0,0 +1,75 @@
+// Package stats implements the small set of sampling statistics used to
+// qualify poll results: Wilson score intervals, margin of error and a
+// significance test for the gap between two options of the same poll.
+package stats
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrInvalidConfidence = errors.New("confidence level must be between 0 and 1")
+
+// Interval is a confidence interval for a proportion, expressed in [0, 1].
+type Interval struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// ZScore returns the two-sided critical value of the standard normal
+// distribution for the given confidence level (e.g. 0.95 -> 1.96).
+func ZScore(confidence float64) (float64, error) {
+	if math.IsNaN(confidence) || confidence <= 0 || confidence >= 1 {
+		return 0, ErrInvalidConfidence
+	}
+	return math.Sqrt2 * math.Erfinv(confidence), nil
+}
+
+// Wilson returns the Wilson score interval for successes out of n trials.
+// An empty sample yields the uninformative interval [0, 1].
+func Wilson(successes, n int64, z float64) Interval {
+	if n <= 0 {
+		return Interval{Lower: 0, Upper: 1}
+	}
+	nf := float64(n)
+	p := float64(successes) / nf
+	z2 := z * z
+
+	denom := 1 + z2/nf
+	center := (p + z2/(2*nf)) / denom
+	half := z * math.Sqrt(p*(1-p)/nf+z2/(4*nf*nf)) / denom
+
+	return Interval{
+		Lower: math.Max(0, center-half),
+		Upper: math.Min(1, center+half),
+	}
+}
+
+// MarginOfError returns the conservative (p = 0.5) margin of error of a
+// proportion estimated from a sample of size n.
+func MarginOfError(n int64, z float64) float64 {
+	if n <= 0 {
+		return 1
+	}
+	return z * math.Sqrt(0.25/float64(n))
+}
+
+// LeadIsSignificant reports whether the leader's share is ahead of the
+// runner-up's at the given critical value. Both counts come from the same
+// sample of size n, so the variance of the difference accounts for their
+// negative covariance.
+func LeadIsSignificant(leader, runnerUp, n int64, z float64) bool {
+	if n <= 0 || leader <= runnerUp {
+		return false
+	}
+	nf := float64(n)
+	p1 := float64(leader) / nf
+	p2 := float64(runnerUp) / nf
+	diff := p1 - p2
+
+	variance := (p1 + p2 - diff*diff) / nf
+	if variance <= 0 {
+		return diff > 0
+	}
+	return diff > z*math.Sqrt(variance)
+}
@@ -0,0 +1,117 @@
+package stats
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-3
+}
+
+func TestZScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		confidence float64
+		want       float64
+		wantErr    bool
+	}{
+		{name: "90%", confidence: 0.90, want: 1.645},
+		{name: "95%", confidence: 0.95, want: 1.960},
+		{name: "99%", confidence: 0.99, want: 2.576},
+		{name: "zero", confidence: 0, wantErr: true},
+		{name: "one", confidence: 1, wantErr: true},
+		{name: "percent instead of fraction", confidence: 95, wantErr: true},
+		{name: "NaN", confidence: math.NaN(), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ZScore(tt.confidence)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidConfidence) {
+					t.Fatalf("expected ErrInvalidConfidence, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !almostEqual(got, tt.want) {
+				t.Fatalf("ZScore(%v) = %.4f, want %.4f", tt.confidence, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWilson(t *testing.T) {
+	tests := []struct {
+		name      string
+		successes int64
+		n         int64
+		want      Interval
+	}{
+		{name: "empty sample", successes: 0, n: 0, want: Interval{Lower: 0, Upper: 1}},
+		{name: "half of 100", successes: 50, n: 100, want: Interval{Lower: 0.4038, Upper: 0.5962}},
+		{name: "none of 10", successes: 0, n: 10, want: Interval{Lower: 0, Upper: 0.2775}},
+		{name: "all of 10", successes: 10, n: 10, want: Interval{Lower: 0.7225, Upper: 1}},
+		{name: "81 of 263", successes: 81, n: 263, want: Interval{Lower: 0.2553, Upper: 0.3662}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Wilson(tt.successes, tt.n, 1.96)
+			if !almostEqual(got.Lower, tt.want.Lower) || !almostEqual(got.Upper, tt.want.Upper) {
+				t.Fatalf("Wilson(%d, %d) = %+v, want %+v", tt.successes, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarginOfError(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int64
+		z    float64
+		want float64
+	}{
+		{name: "empty sample", n: 0, z: 1.96, want: 1},
+		{name: "100 at 95%", n: 100, z: 1.96, want: 0.098},
+		{name: "1000 at 95%", n: 1000, z: 1.96, want: 0.031},
+		{name: "1000 at 99%", n: 1000, z: 2.576, want: 0.0407},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MarginOfError(tt.n, tt.z); !almostEqual(got, tt.want) {
+				t.Fatalf("MarginOfError(%d) = %.4f, want %.4f", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeadIsSignificant(t *testing.T) {
+	tests := []struct {
+		name     string
+		leader   int64
+		runnerUp int64
+		n        int64
+		want     bool
+	}{
+		{name: "empty sample", leader: 0, runnerUp: 0, n: 0, want: false},
+		{name: "tie", leader: 40, runnerUp: 40, n: 100, want: false},
+		{name: "small gap small sample", leader: 52, runnerUp: 48, n: 100, want: false},
+		{name: "small gap large sample", leader: 5200, runnerUp: 4800, n: 10000, want: true},
+		{name: "landslide", leader: 80, runnerUp: 20, n: 100, want: true},
+		{name: "unanimous", leader: 10, runnerUp: 0, n: 10, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LeadIsSignificant(tt.leader, tt.runnerUp, tt.n, 1.96); got != tt.want {
+				t.Fatalf("LeadIsSignificant(%d, %d, %d) = %v, want %v", tt.leader, tt.runnerUp, tt.n, got, tt.want)
+			}
+		})
+	}
+}
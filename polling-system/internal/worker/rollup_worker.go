@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type Rollup interface {
+	RefreshRollups(ctx context.Context) error
+}
+
+// RollupWorker periodically refreshes the per-minute vote rollups that back
+// analytics for heavy polls.
+type RollupWorker struct {
+	rollup   Rollup
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+func NewRollupWorker(rollup Rollup, interval time.Duration, logger *slog.Logger) *RollupWorker {
+	return &RollupWorker{
+		rollup:   rollup,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+func (w *RollupWorker) Run(ctx context.Context) {
+	if w.logger == nil {
+		w.logger = slog.Default()
+	}
+	w.logger.Info("rollup worker started", "interval", w.interval.String())
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.refresh(ctx)
+		select {
+		case <-ctx.Done():
+			w.logger.Info("rollup worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *RollupWorker) refresh(ctx context.Context) {
+	start := time.Now()
+	if err := w.rollup.RefreshRollups(ctx); err != nil {
+		if ctx.Err() == nil {
+			w.logger.Error("failed to refresh vote rollups", "error", err)
+		}
+		return
+	}
+	w.logger.Debug("refreshed vote rollups", "duration_ms", time.Since(start).Milliseconds())
+}
@@ -2,10 +2,18 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"polling-system/internal/domain/vote"
+	"polling-system/internal/metrics"
+	"polling-system/internal/platform/tracing"
 	"polling-system/internal/retry"
 )
 
@@ -13,33 +21,85 @@ type VoteEvent struct {
 	PollID   int64
 	OptionID int64
 	UserID   int64
+	// QueuedAt is when the event was sent to the workers; it is used to
+	// measure aggregation latency and may be zero.
+	QueuedAt time.Time
+	// SpanContext identifies the span that cast the vote. The flush that
+	// aggregates the event links to it, so the two traces can be followed
+	// from one another. It is invalid when the request was not traced.
+	SpanContext trace.SpanContext
 }
 
 type Aggregator interface {
-	IncrementAggregated(ctx context.Context, pollID, optionID int64) error
+	AddAggregated(ctx context.Context, deltas []vote.AggregateDelta) error
+}
+
+// DeadLetters stores events that could not be aggregated.
+type DeadLetters interface {
+	Record(ctx context.Context, pollID, optionID, userID int64, attempts int, cause error) error
 }
 
+// deadLetterTimeout bounds storing a failed event, which must also work
+// while the worker is shutting down.
+const deadLetterTimeout = 5 * time.Second
+
+// heartbeatInterval is how often an idle worker reports that it is alive.
+const heartbeatInterval = 5 * time.Second
+
+// finalFlushTimeout bounds the flush of the last batch once the worker has
+// been cancelled.
+const finalFlushTimeout = 10 * time.Second
+
 type StatsWorker struct {
-	Ch      <-chan VoteEvent
-	agg     Aggregator
-	workers int
-	logger  *slog.Logger
+	Ch       <-chan VoteEvent
+	agg      Aggregator
+	workers  int
+	window   time.Duration
+	maxBatch int
+	policy   retry.Policy
+	dlq      DeadLetters
+	logger   *slog.Logger
+
+	// lastAggregation is the Unix time in nanoseconds of the last
+	// successful flush, or zero.
+	lastAggregation atomic.Int64
+	// beats holds the Unix time in nanoseconds at which each worker last
+	// made progress.
+	beats []atomic.Int64
 }
 
-func NewStatsWorker(ch <-chan VoteEvent, agg Aggregator, logger *slog.Logger) *StatsWorker {
+// NewStatsWorker builds a pool of workers that aggregate votes. Each worker
+// coalesces events per option for up to window, or until maxBatch events are
+// pending, and adds them with one upsert, retrying failed flushes according
+// to policy. Events that still fail are handed to dlq; a nil dlq drops them.
+// A zero window flushes every event on its own.
+func NewStatsWorker(ch <-chan VoteEvent, agg Aggregator, workers int, window time.Duration, maxBatch int, policy retry.Policy, dlq DeadLetters, logger *slog.Logger) *StatsWorker {
+	if workers <= 0 {
+		workers = 1
+	}
+	if maxBatch <= 0 || window <= 0 {
+		maxBatch = 1
+	}
 	return &StatsWorker{
-		Ch:      ch,
-		agg:     agg,
-		workers: 4,
-		logger:  logger,
+		Ch:       ch,
+		agg:      agg,
+		workers:  workers,
+		window:   window,
+		maxBatch: maxBatch,
+		policy:   policy,
+		dlq:      dlq,
+		logger:   logger,
+		beats:    make([]atomic.Int64, workers),
 	}
 }
 
+// Run aggregates events until ctx is cancelled or the channel is closed. In
+// both cases each worker flushes its pending batch before Run returns.
 func (w *StatsWorker) Run(ctx context.Context) {
 	if w.logger == nil {
 		w.logger = slog.Default()
 	}
-	w.logger.Info("stats worker pool started", "workers", w.workers)
+	w.logger.Info("stats worker pool started", "workers", w.workers, "batch_window", w.window.String(), "batch_size", w.maxBatch)
 	var wg sync.WaitGroup
 	for i := 0; i < w.workers; i++ {
 		wg.Add(1)
@@ -49,32 +109,235 @@ func (w *StatsWorker) Run(ctx context.Context) {
 		}(i)
 	}
 
-	<-ctx.Done()
 	wg.Wait()
 	w.logger.Info("stats worker pool stopped")
 }
 
+// QueueDepth returns the number of events waiting for a worker.
+func (w *StatsWorker) QueueDepth() int {
+	return len(w.Ch)
+}
+
+func (w *StatsWorker) QueueCapacity() int {
+	return cap(w.Ch)
+}
+
+// LastAggregation returns when a vote was last aggregated, or the zero time
+// if none was since the worker was built.
+func (w *StatsWorker) LastAggregation() time.Time {
+	if ns := w.lastAggregation.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
+// Heartbeat returns the oldest of the times at which each worker last made
+// progress, or the zero time if a worker has not started. Idle workers beat
+// every few seconds, so a stale heartbeat means a worker is stuck or gone.
+func (w *StatsWorker) Heartbeat() time.Time {
+	var oldest int64
+	for i := range w.beats {
+		beat := w.beats[i].Load()
+		if beat == 0 {
+			return time.Time{}
+		}
+		if oldest == 0 || beat < oldest {
+			oldest = beat
+		}
+	}
+	return time.Unix(0, oldest)
+}
+
+func (w *StatsWorker) beat(workerID int) {
+	w.beats[workerID].Store(time.Now().UnixNano())
+}
+
+// batch holds the events received since the last flush.
+type batch struct {
+	events []VoteEvent
+	deltas map[optionKey]int64
+}
+
+type optionKey struct {
+	pollID   int64
+	optionID int64
+}
+
+func (b *batch) add(ev VoteEvent) {
+	b.events = append(b.events, ev)
+	b.deltas[optionKey{pollID: ev.PollID, optionID: ev.OptionID}]++
+}
+
+func (b *batch) reset() {
+	b.events = b.events[:0]
+	clear(b.deltas)
+}
+
+func (b *batch) aggregateDeltas() []vote.AggregateDelta {
+	deltas := make([]vote.AggregateDelta, 0, len(b.deltas))
+	for k, n := range b.deltas {
+		deltas = append(deltas, vote.AggregateDelta{PollID: k.pollID, OptionID: k.optionID, Votes: n})
+	}
+	return deltas
+}
+
 func (w *StatsWorker) loop(ctx context.Context, workerID int) {
+	b := &batch{deltas: make(map[optionKey]int64)}
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	w.beat(workerID)
+
+	flush := func(ctx context.Context) {
+		timer.Stop()
+		w.flush(ctx, workerID, b)
+		b.reset()
+	}
+	// finalFlush runs on shutdown: the pending votes were already accepted
+	// and must be counted even though ctx may be cancelled by now.
+	finalFlush := func() {
+		flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finalFlushTimeout)
+		defer cancel()
+		flush(flushCtx)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
+			finalFlush()
 			return
 		case ev, ok := <-w.Ch:
 			if !ok {
+				finalFlush()
 				return
 			}
-			w.process(ctx, workerID, ev)
+			b.add(ev)
+			switch {
+			case len(b.events) >= w.maxBatch:
+				flush(ctx)
+			case len(b.events) == 1:
+				timer.Reset(w.window)
+			}
+		case <-timer.C:
+			flush(ctx)
+		case <-heartbeat.C:
 		}
+		w.beat(workerID)
 	}
 }
 
-func (w *StatsWorker) process(ctx context.Context, workerID int, ev VoteEvent) {
-	err := retry.DoWithRetry(ctx, 4, 150*time.Millisecond, func() error {
-		return w.agg.IncrementAggregated(ctx, ev.PollID, ev.OptionID)
+func (w *StatsWorker) flush(ctx context.Context, workerID int, b *batch) {
+	if len(b.events) == 0 {
+		return
+	}
+	deltas := b.aggregateDeltas()
+	ctx, span := tracing.Tracer().Start(ctx, "worker.StatsWorker.flush",
+		trace.WithNewRoot(),
+		trace.WithLinks(eventLinks(b.events)...),
+		trace.WithAttributes(
+			attribute.Int("worker.id", workerID),
+			attribute.Int("batch.events", len(b.events)),
+			attribute.Int("batch.rows", len(deltas)),
+		))
+	start := time.Now()
+	attempts, err := w.add(ctx, deltas)
+	defer func() { tracing.End(span, err) }()
+	metrics.ObserveStatsFlush(len(b.events), len(deltas), time.Since(start), err == nil)
+	if err == nil {
+		w.aggregated(b.events)
+		w.logger.DebugContext(ctx, "aggregated votes", "worker", workerID, "events", len(b.events), "rows", len(deltas), "attempts", attempts)
+		return
+	}
+
+	// A permanent failure of a multi-row batch, such as a poll deleted
+	// while its votes were queued, must not take the other polls' votes
+	// down with it, so each option is retried on its own.
+	if len(deltas) > 1 && !retry.IsTransient(err) && !errors.Is(err, retry.ErrCircuitOpen) && ctx.Err() == nil {
+		w.logger.WarnContext(ctx, "batched aggregation failed, retrying per option", "worker", workerID, "rows", len(deltas), "error", err)
+		failed := make(map[optionKey]error)
+		failedAttempts := make(map[optionKey]int)
+		for _, d := range deltas {
+			n, err := w.add(ctx, []vote.AggregateDelta{d})
+			if err != nil {
+				k := optionKey{pollID: d.PollID, optionID: d.OptionID}
+				failed[k], failedAttempts[k] = err, attempts+n
+			}
+		}
+		var ok []VoteEvent
+		for _, ev := range b.events {
+			k := optionKey{pollID: ev.PollID, optionID: ev.OptionID}
+			if err, bad := failed[k]; bad {
+				w.fail(ctx, workerID, ev, failedAttempts[k], err)
+			} else {
+				ok = append(ok, ev)
+			}
+		}
+		w.aggregated(ok)
+		return
+	}
+
+	for _, ev := range b.events {
+		w.fail(ctx, workerID, ev, attempts, err)
+	}
+}
+
+// eventLinks links a flush span to the spans that cast its votes.
+func eventLinks(events []VoteEvent) []trace.Link {
+	var links []trace.Link
+	for _, ev := range events {
+		if ev.SpanContext.IsValid() {
+			links = append(links, trace.Link{SpanContext: ev.SpanContext})
+		}
+	}
+	return links
+}
+
+// add applies deltas under the retry policy and reports how many attempts
+// were made.
+func (w *StatsWorker) add(ctx context.Context, deltas []vote.AggregateDelta) (int, error) {
+	attempts := 0
+	err := retry.Do(ctx, w.policy, func(ctx context.Context) error {
+		attempts++
+		return w.agg.AddAggregated(ctx, deltas)
 	})
-	if err != nil {
-		w.logger.Error("failed to aggregate vote", "worker", workerID, "poll_id", ev.PollID, "option_id", ev.OptionID, "error", err)
+	return attempts, err
+}
+
+func (w *StatsWorker) aggregated(events []VoteEvent) {
+	if len(events) == 0 {
+		return
+	}
+	now := time.Now()
+	w.lastAggregation.Store(now.UnixNano())
+	for _, ev := range events {
+		var latency time.Duration
+		if !ev.QueuedAt.IsZero() {
+			latency = now.Sub(ev.QueuedAt)
+		}
+		metrics.ObserveVoteEvent(true, latency)
+	}
+}
+
+func (w *StatsWorker) fail(ctx context.Context, workerID int, ev VoteEvent, attempts int, cause error) {
+	// Dead-lettering a large batch while the database is down is slow but
+	// is progress.
+	w.beat(workerID)
+	metrics.ObserveVoteEvent(false, 0)
+	w.logger.ErrorContext(ctx, "failed to aggregate vote", "worker", workerID, "poll_id", ev.PollID, "option_id", ev.OptionID, "attempts", attempts, "error", cause)
+	w.deadLetter(ctx, workerID, ev, attempts, cause)
+}
+
+func (w *StatsWorker) deadLetter(ctx context.Context, workerID int, ev VoteEvent, attempts int, cause error) {
+	if w.dlq == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), deadLetterTimeout)
+	defer cancel()
+	if err := w.dlq.Record(ctx, ev.PollID, ev.OptionID, ev.UserID, attempts, cause); err != nil {
+		w.logger.ErrorContext(ctx, "failed to store dead letter; vote event lost", "worker", workerID, "poll_id", ev.PollID, "option_id", ev.OptionID, "user_id", ev.UserID, "error", err)
 		return
 	}
-	w.logger.Info("aggregated vote", "worker", workerID, "poll_id", ev.PollID, "option_id", ev.OptionID, "user_id", ev.UserID)
+	w.logger.WarnContext(ctx, "vote event moved to dead-letter queue", "worker", workerID, "poll_id", ev.PollID, "option_id", ev.OptionID, "user_id", ev.UserID)
 }
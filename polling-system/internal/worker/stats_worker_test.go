@@ -0,0 +1,216 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"polling-system/internal/domain/vote"
+	"polling-system/internal/retry"
+)
+
+var errUnknownOption = errors.New("unknown option")
+
+// fakeAggregator records every AddAggregated call and rejects batches that
+// contain an option listed in bad.
+type fakeAggregator struct {
+	mu      sync.Mutex
+	calls   [][]vote.AggregateDelta
+	counts  map[int64]int64
+	bad     map[int64]bool
+	flushed chan int
+}
+
+func newFakeAggregator() *fakeAggregator {
+	return &fakeAggregator{
+		counts:  make(map[int64]int64),
+		bad:     make(map[int64]bool),
+		flushed: make(chan int, 100),
+	}
+}
+
+func (a *fakeAggregator) AddAggregated(ctx context.Context, deltas []vote.AggregateDelta) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.calls = append(a.calls, deltas)
+	for _, d := range deltas {
+		if a.bad[d.OptionID] {
+			return errUnknownOption
+		}
+	}
+	for _, d := range deltas {
+		a.counts[d.OptionID] += d.Votes
+	}
+	a.flushed <- len(deltas)
+	return nil
+}
+
+type fakeDeadLetters struct {
+	mu     sync.Mutex
+	events []VoteEvent
+}
+
+func (d *fakeDeadLetters) Record(ctx context.Context, pollID, optionID, userID int64, attempts int, cause error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.events = append(d.events, VoteEvent{PollID: pollID, OptionID: optionID, UserID: userID})
+	return nil
+}
+
+func newTestWorker(ch <-chan VoteEvent, agg Aggregator, window time.Duration, maxBatch int, dlq DeadLetters) *StatsWorker {
+	policy := retry.Policy{MaxAttempts: 1}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewStatsWorker(ch, agg, 1, window, maxBatch, policy, dlq, logger)
+}
+
+func TestStatsWorkerCoalescesAndFlushesOnClose(t *testing.T) {
+	ch := make(chan VoteEvent, 20)
+	agg := newFakeAggregator()
+	w := newTestWorker(ch, agg, time.Hour, 100, nil)
+
+	for i := 0; i < 10; i++ {
+		ch <- VoteEvent{PollID: 1, OptionID: 11, UserID: int64(i)}
+	}
+	ch <- VoteEvent{PollID: 1, OptionID: 12, UserID: 20}
+	ch <- VoteEvent{PollID: 2, OptionID: 21, UserID: 20}
+	close(ch)
+	if w.QueueDepth() != 12 || w.QueueCapacity() != 20 || !w.LastAggregation().IsZero() || !w.Heartbeat().IsZero() {
+		t.Fatalf("unexpected queue state %d/%d, last aggregation %v", w.QueueDepth(), w.QueueCapacity(), w.LastAggregation())
+	}
+
+	start := time.Now()
+	w.Run(context.Background())
+	if last := w.LastAggregation(); last.Before(start) {
+		t.Fatalf("expected the flush to set the last aggregation time, got %v", last)
+	}
+	if beat := w.Heartbeat(); beat.Before(start) {
+		t.Fatalf("expected the worker to beat while running, got %v", beat)
+	}
+
+	if len(agg.calls) != 1 || len(agg.calls[0]) != 3 {
+		t.Fatalf("expected one flush of 3 rows, got %v", agg.calls)
+	}
+	if agg.counts[11] != 10 || agg.counts[12] != 1 || agg.counts[21] != 1 {
+		t.Fatalf("unexpected counts %v", agg.counts)
+	}
+}
+
+func TestStatsWorkerFlushesOnSizeAndWindow(t *testing.T) {
+	ch := make(chan VoteEvent)
+	agg := newFakeAggregator()
+	w := newTestWorker(ch, agg, 20*time.Millisecond, 3, nil)
+	done := make(chan struct{})
+	go func() {
+		w.Run(context.Background())
+		close(done)
+	}()
+
+	for i := 0; i < 3; i++ {
+		ch <- VoteEvent{PollID: 1, OptionID: int64(i + 1)}
+	}
+	select {
+	case rows := <-agg.flushed:
+		if rows != 3 {
+			t.Fatalf("expected a full batch of 3 rows, got %d", rows)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("full batch was not flushed")
+	}
+
+	ch <- VoteEvent{PollID: 1, OptionID: 1}
+	select {
+	case rows := <-agg.flushed:
+		if rows != 1 {
+			t.Fatalf("expected the window to flush 1 row, got %d", rows)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("batch was not flushed when the window elapsed")
+	}
+
+	close(ch)
+	<-done
+}
+
+func TestStatsWorkerFlushesOnCancel(t *testing.T) {
+	ch := make(chan VoteEvent)
+	agg := newFakeAggregator()
+	w := newTestWorker(ch, agg, time.Hour, 100, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	ch <- VoteEvent{PollID: 1, OptionID: 1}
+	ch <- VoteEvent{PollID: 1, OptionID: 1}
+	cancel()
+	<-done
+
+	if agg.counts[1] != 2 {
+		t.Fatalf("pending batch must be flushed on shutdown, got %v", agg.counts)
+	}
+}
+
+func TestStatsWorkerIsolatesPermanentFailures(t *testing.T) {
+	ch := make(chan VoteEvent, 10)
+	agg := newFakeAggregator()
+	agg.bad[99] = true
+	dlq := &fakeDeadLetters{}
+	w := newTestWorker(ch, agg, time.Hour, 100, dlq)
+
+	ch <- VoteEvent{PollID: 1, OptionID: 1, UserID: 1}
+	ch <- VoteEvent{PollID: 1, OptionID: 99, UserID: 2}
+	ch <- VoteEvent{PollID: 1, OptionID: 99, UserID: 3}
+	ch <- VoteEvent{PollID: 2, OptionID: 2, UserID: 1}
+	close(ch)
+
+	w.Run(context.Background())
+
+	if agg.counts[1] != 1 || agg.counts[2] != 1 {
+		t.Fatalf("valid votes must still be aggregated, got %v", agg.counts)
+	}
+	if len(dlq.events) != 2 || dlq.events[0].OptionID != 99 || dlq.events[1].UserID != 3 {
+		t.Fatalf("expected both events of the bad option dead-lettered, got %+v", dlq.events)
+	}
+}
+
+func TestStatsWorkerLinksFlushToVoteSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	voteSpan := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ch := make(chan VoteEvent, 2)
+	ch <- VoteEvent{PollID: 1, OptionID: 11, UserID: 1, SpanContext: voteSpan}
+	ch <- VoteEvent{PollID: 1, OptionID: 11, UserID: 2}
+	close(ch)
+
+	newTestWorker(ch, newFakeAggregator(), time.Hour, 100, nil).Run(context.Background())
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one flush span, got %d", len(spans))
+	}
+	links := spans[0].Links()
+	if len(links) != 1 || links[0].SpanContext.SpanID() != voteSpan.SpanID() {
+		t.Fatalf("expected a link to the vote span only, got %v", links)
+	}
+	if spans[0].Parent().IsValid() {
+		t.Fatalf("flush span must start a new trace")
+	}
+}